@@ -0,0 +1,277 @@
+// Package builder builds a gost config in Go, with types in place of the
+// YAML of a config file:
+//
+//	cfg, err := builder.New().
+//		Service(builder.Service("proxy").
+//			Listen(":8080").
+//			Handler(builder.HTTP().Auth("user", "pass")).
+//			Chain(builder.Chain("chain-0").
+//				Hop(builder.Hop("hop-0").
+//					Node(builder.Node("node-0", "192.168.1.1:1080").
+//						Connector(builder.Connector("socks5")))))).
+//		Build()
+//
+// The result is the config.Config that parsing the equivalent config file
+// returns, ready for the server package. The resources referenced by a
+// builder (the chain of a service, its bypass, ...) are defined along with
+// it, the ones defined elsewhere are referenced by name with ChainRef,
+// HopRef, BypassRef, AdmissionRef and AutherRef. Build checks that every
+// reference resolves and that no name is defined twice.
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+)
+
+// Builder builds a config.
+type Builder struct {
+	services   []*ServiceBuilder
+	chains     []*ChainBuilder
+	hops       []*HopBuilder
+	bypasses   []*BypassBuilder
+	admissions []*AdmissionBuilder
+	authers    []*AutherBuilder
+
+	api     *config.APIConfig
+	metrics *config.MetricsConfig
+	log     *config.LogConfig
+}
+
+// New returns an empty config builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) Service(services ...*ServiceBuilder) *Builder {
+	b.services = append(b.services, services...)
+	return b
+}
+
+func (b *Builder) Chain(chains ...*ChainBuilder) *Builder {
+	b.chains = append(b.chains, chains...)
+	return b
+}
+
+func (b *Builder) Hop(hops ...*HopBuilder) *Builder {
+	b.hops = append(b.hops, hops...)
+	return b
+}
+
+func (b *Builder) Bypass(bypasses ...*BypassBuilder) *Builder {
+	b.bypasses = append(b.bypasses, bypasses...)
+	return b
+}
+
+func (b *Builder) Admission(admissions ...*AdmissionBuilder) *Builder {
+	b.admissions = append(b.admissions, admissions...)
+	return b
+}
+
+func (b *Builder) Auther(authers ...*AutherBuilder) *Builder {
+	b.authers = append(b.authers, authers...)
+	return b
+}
+
+// API enables the web API on addr.
+func (b *Builder) API(addr string) *Builder {
+	b.api = &config.APIConfig{Addr: addr}
+	return b
+}
+
+// Metrics enables the prometheus metrics on addr.
+func (b *Builder) Metrics(addr string) *Builder {
+	b.metrics = &config.MetricsConfig{Addr: addr}
+	return b
+}
+
+// Log sets the log level.
+func (b *Builder) Log(level string) *Builder {
+	b.log = &config.LogConfig{Level: level}
+	return b
+}
+
+// Build returns the config. The error lists all the resources without name
+// or defined twice, and all the references to undefined resources.
+func (b *Builder) Build() (*config.Config, error) {
+	c := &collector{
+		cfg:  &config.Config{},
+		seen: make(map[any]bool),
+	}
+
+	// The resources added to the builder come first, in order, then the
+	// ones they reference, as found.
+	for _, s := range b.services {
+		c.service(s)
+	}
+	for _, ch := range b.chains {
+		c.chain(ch)
+	}
+	for _, h := range b.hops {
+		c.hop(h)
+	}
+	for _, bp := range b.bypasses {
+		c.bypass(bp)
+	}
+	for _, adm := range b.admissions {
+		c.admission(adm)
+	}
+	for _, au := range b.authers {
+		c.auther(au)
+	}
+
+	cfg := c.cfg
+	cfg.API = b.api
+	cfg.Metrics = b.metrics
+	cfg.Log = b.log
+
+	if err := check(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// check checks the names of the resources of cfg and the references
+// between them.
+func check(cfg *config.Config) error {
+	var errs []error
+
+	defined := make(map[server.Resource]bool)
+	for _, kind := range server.Kinds() {
+		for _, c := range server.Configs(cfg, kind) {
+			r := server.Resource{Kind: kind, Name: server.ConfigName(c)}
+			if r.Name == "" {
+				errs = append(errs, fmt.Errorf("%s: resource without name", kind))
+				continue
+			}
+			if defined[r] {
+				errs = append(errs, fmt.Errorf("%s: defined twice", r))
+			}
+			defined[r] = true
+		}
+	}
+
+	for _, ref := range server.References(cfg) {
+		if !defined[ref.To] {
+			errs = append(errs, fmt.Errorf("%s: %s: reference to undefined %s", ref.From, ref.Path, ref.To))
+		}
+	}
+	for _, v := range []struct {
+		section string
+		auther  string
+	}{
+		{"api", autherOf(cfg.API)},
+		{"metrics", autherOf(cfg.Metrics)},
+	} {
+		to := server.Resource{Kind: "authers", Name: v.auther}
+		if v.auther != "" && !defined[to] {
+			errs = append(errs, fmt.Errorf("%s.auther: reference to undefined %s", v.section, to))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func autherOf(c any) string {
+	switch c := c.(type) {
+	case *config.APIConfig:
+		if c != nil {
+			return c.Auther
+		}
+	case *config.MetricsConfig:
+		if c != nil {
+			return c.Auther
+		}
+	}
+	return ""
+}
+
+// collector gathers the configs of the resources reachable from the
+// builder. A resource builder used several times is defined once.
+type collector struct {
+	cfg  *config.Config
+	seen map[any]bool
+}
+
+// define reports whether the resource of b is to be added to the config:
+// b is not a reference and was not seen yet.
+func (c *collector) define(b any, ref bool) bool {
+	if ref || c.seen[b] {
+		return false
+	}
+	c.seen[b] = true
+	return true
+}
+
+func (c *collector) service(b *ServiceBuilder) {
+	if b == nil || !c.define(b, false) {
+		return
+	}
+	c.cfg.Services = append(c.cfg.Services, b.build(c))
+}
+
+// chain returns the name of the chain of b, defining it if needed.
+func (c *collector) chain(b *ChainBuilder) string {
+	if b == nil {
+		return ""
+	}
+	if c.define(b, b.ref) {
+		c.cfg.Chains = append(c.cfg.Chains, b.build(c))
+	}
+	return b.name
+}
+
+func (c *collector) hop(b *HopBuilder) string {
+	if b == nil {
+		return ""
+	}
+	if c.define(b, b.ref) {
+		c.cfg.Hops = append(c.cfg.Hops, b.build(c))
+	}
+	return b.name
+}
+
+func (c *collector) bypass(b *BypassBuilder) string {
+	if b == nil {
+		return ""
+	}
+	if c.define(b, b.ref) {
+		c.cfg.Bypasses = append(c.cfg.Bypasses, b.build())
+	}
+	return b.name
+}
+
+func (c *collector) admission(b *AdmissionBuilder) string {
+	if b == nil {
+		return ""
+	}
+	if c.define(b, b.ref) {
+		c.cfg.Admissions = append(c.cfg.Admissions, b.build())
+	}
+	return b.name
+}
+
+func (c *collector) auther(b *AutherBuilder) string {
+	if b == nil {
+		return ""
+	}
+	if c.define(b, b.ref) {
+		c.cfg.Authers = append(c.cfg.Authers, b.build())
+	}
+	return b.name
+}
+
+// metadata returns a copy of md, nil if empty.
+func metadata(md map[string]any) map[string]any {
+	if len(md) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(md))
+	for k, v := range md {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,234 @@
+package builder
+
+import (
+	"time"
+
+	"github.com/go-gost/x/config"
+)
+
+// ChainBuilder builds a chain, or references one by name.
+type ChainBuilder struct {
+	name     string
+	ref      bool
+	hops     []*HopBuilder
+	metadata map[string]any
+}
+
+// Chain returns the builder of the chain name.
+func Chain(name string) *ChainBuilder {
+	return &ChainBuilder{name: name}
+}
+
+// ChainRef references the chain name, defined by another builder.
+func ChainRef(name string) *ChainBuilder {
+	return &ChainBuilder{name: name, ref: true}
+}
+
+// Hop appends hops to the chain. A hop built with Hop is defined in the
+// chain, one built with HopRef refers to a hop defined with Builder.Hop.
+func (b *ChainBuilder) Hop(hops ...*HopBuilder) *ChainBuilder {
+	b.hops = append(b.hops, hops...)
+	return b
+}
+
+func (b *ChainBuilder) Metadata(key string, value any) *ChainBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+func (b *ChainBuilder) build(c *collector) *config.ChainConfig {
+	chain := &config.ChainConfig{
+		Name:     b.name,
+		Metadata: metadata(b.metadata),
+	}
+	for _, h := range b.hops {
+		if h == nil {
+			continue
+		}
+		if h.ref {
+			chain.Hops = append(chain.Hops, &config.HopConfig{Name: h.name})
+			continue
+		}
+		chain.Hops = append(chain.Hops, h.build(c))
+	}
+	return chain
+}
+
+// HopBuilder builds a hop, or references one by name.
+type HopBuilder struct {
+	name     string
+	ref      bool
+	nodes    []*NodeBuilder
+	selector *config.SelectorConfig
+	bypasses []*BypassBuilder
+	metadata map[string]any
+}
+
+// Hop returns the builder of the hop name.
+func Hop(name string) *HopBuilder {
+	return &HopBuilder{name: name}
+}
+
+// HopRef references the hop name, defined by another builder.
+func HopRef(name string) *HopBuilder {
+	return &HopBuilder{name: name, ref: true}
+}
+
+func (b *HopBuilder) Node(nodes ...*NodeBuilder) *HopBuilder {
+	b.nodes = append(b.nodes, nodes...)
+	return b
+}
+
+// Selector sets how the nodes of the hop are selected, see the selector
+// section of a hop.
+func (b *HopBuilder) Selector(strategy string, maxFails int, failTimeout time.Duration) *HopBuilder {
+	b.selector = &config.SelectorConfig{
+		Strategy:    strategy,
+		MaxFails:    maxFails,
+		FailTimeout: failTimeout,
+	}
+	return b
+}
+
+func (b *HopBuilder) Bypass(bypasses ...*BypassBuilder) *HopBuilder {
+	b.bypasses = append(b.bypasses, bypasses...)
+	return b
+}
+
+func (b *HopBuilder) Metadata(key string, value any) *HopBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+func (b *HopBuilder) build(c *collector) *config.HopConfig {
+	hop := &config.HopConfig{
+		Name:     b.name,
+		Selector: b.selector,
+		Metadata: metadata(b.metadata),
+	}
+	hop.Bypass, hop.Bypasses = bypasses(c, b.bypasses)
+	for _, n := range b.nodes {
+		if n != nil {
+			hop.Nodes = append(hop.Nodes, n.build(c))
+		}
+	}
+	return hop
+}
+
+// NodeBuilder builds a node of a hop.
+type NodeBuilder struct {
+	name      string
+	addr      string
+	connector *ConnectorBuilder
+	dialer    *DialerBuilder
+	bypasses  []*BypassBuilder
+	metadata  map[string]any
+}
+
+// Node returns the builder of the node name at addr.
+func Node(name, addr string) *NodeBuilder {
+	return &NodeBuilder{name: name, addr: addr}
+}
+
+func (b *NodeBuilder) Connector(c *ConnectorBuilder) *NodeBuilder {
+	b.connector = c
+	return b
+}
+
+func (b *NodeBuilder) Dialer(d *DialerBuilder) *NodeBuilder {
+	b.dialer = d
+	return b
+}
+
+func (b *NodeBuilder) Bypass(bypasses ...*BypassBuilder) *NodeBuilder {
+	b.bypasses = append(b.bypasses, bypasses...)
+	return b
+}
+
+func (b *NodeBuilder) Metadata(key string, value any) *NodeBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+func (b *NodeBuilder) build(c *collector) *config.NodeConfig {
+	node := &config.NodeConfig{
+		Name:     b.name,
+		Addr:     b.addr,
+		Metadata: metadata(b.metadata),
+	}
+	node.Bypass, node.Bypasses = bypasses(c, b.bypasses)
+	if b.connector != nil {
+		node.Connector = &config.ConnectorConfig{
+			Type:     b.connector.typ,
+			Auth:     b.connector.auth,
+			TLS:      b.connector.tls,
+			Metadata: metadata(b.connector.metadata),
+		}
+	}
+	if b.dialer != nil {
+		node.Dialer = &config.DialerConfig{
+			Type:     b.dialer.typ,
+			Auth:     b.dialer.auth,
+			TLS:      b.dialer.tls,
+			Metadata: metadata(b.dialer.metadata),
+		}
+	}
+	return node
+}
+
+// ConnectorBuilder builds the connector of a node.
+type ConnectorBuilder struct {
+	typ      string
+	auth     *config.AuthConfig
+	tls      *config.TLSConfig
+	metadata map[string]any
+}
+
+// Connector returns the builder of a connector of type typ.
+func Connector(typ string) *ConnectorBuilder {
+	return &ConnectorBuilder{typ: typ}
+}
+
+// Auth sets the credentials sent to the node.
+func (b *ConnectorBuilder) Auth(username, password string) *ConnectorBuilder {
+	b.auth = &config.AuthConfig{Username: username, Password: password}
+	return b
+}
+
+func (b *ConnectorBuilder) TLS(tls *config.TLSConfig) *ConnectorBuilder {
+	b.tls = tls
+	return b
+}
+
+func (b *ConnectorBuilder) Metadata(key string, value any) *ConnectorBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+// DialerBuilder builds the dialer of a node.
+type DialerBuilder struct {
+	typ      string
+	auth     *config.AuthConfig
+	tls      *config.TLSConfig
+	metadata map[string]any
+}
+
+// Dialer returns the builder of a dialer of type typ.
+func Dialer(typ string) *DialerBuilder {
+	return &DialerBuilder{typ: typ}
+}
+
+func (b *DialerBuilder) Auth(username, password string) *DialerBuilder {
+	b.auth = &config.AuthConfig{Username: username, Password: password}
+	return b
+}
+
+func (b *DialerBuilder) TLS(tls *config.TLSConfig) *DialerBuilder {
+	b.tls = tls
+	return b
+}
+
+func (b *DialerBuilder) Metadata(key string, value any) *DialerBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
@@ -0,0 +1,140 @@
+package builder
+
+import (
+	"github.com/go-gost/x/config"
+)
+
+// BypassBuilder builds a bypass, or references one by name.
+type BypassBuilder struct {
+	name      string
+	ref       bool
+	whitelist bool
+	matchers  []string
+}
+
+// Bypass returns the builder of the bypass name.
+func Bypass(name string) *BypassBuilder {
+	return &BypassBuilder{name: name}
+}
+
+// BypassRef references the bypass name, defined by another builder.
+func BypassRef(name string) *BypassBuilder {
+	return &BypassBuilder{name: name, ref: true}
+}
+
+// Matchers appends addresses, CIDRs or domains to the bypass.
+func (b *BypassBuilder) Matchers(matchers ...string) *BypassBuilder {
+	b.matchers = append(b.matchers, matchers...)
+	return b
+}
+
+// Whitelist makes the bypass let through the matched addresses only.
+func (b *BypassBuilder) Whitelist() *BypassBuilder {
+	b.whitelist = true
+	return b
+}
+
+func (b *BypassBuilder) build() *config.BypassConfig {
+	return &config.BypassConfig{
+		Name:      b.name,
+		Whitelist: b.whitelist,
+		Matchers:  b.matchers,
+	}
+}
+
+// AdmissionBuilder builds an admission, or references one by name.
+type AdmissionBuilder struct {
+	name      string
+	ref       bool
+	whitelist bool
+	matchers  []string
+}
+
+// Admission returns the builder of the admission name.
+func Admission(name string) *AdmissionBuilder {
+	return &AdmissionBuilder{name: name}
+}
+
+// AdmissionRef references the admission name, defined by another builder.
+func AdmissionRef(name string) *AdmissionBuilder {
+	return &AdmissionBuilder{name: name, ref: true}
+}
+
+// Matchers appends client addresses or CIDRs to the admission.
+func (b *AdmissionBuilder) Matchers(matchers ...string) *AdmissionBuilder {
+	b.matchers = append(b.matchers, matchers...)
+	return b
+}
+
+// Whitelist makes the admission accept the matched clients only.
+func (b *AdmissionBuilder) Whitelist() *AdmissionBuilder {
+	b.whitelist = true
+	return b
+}
+
+func (b *AdmissionBuilder) build() *config.AdmissionConfig {
+	return &config.AdmissionConfig{
+		Name:      b.name,
+		Whitelist: b.whitelist,
+		Matchers:  b.matchers,
+	}
+}
+
+// AutherBuilder builds an auther, or references one by name.
+type AutherBuilder struct {
+	name  string
+	ref   bool
+	auths []*config.AuthConfig
+}
+
+// Auther returns the builder of the auther name.
+func Auther(name string) *AutherBuilder {
+	return &AutherBuilder{name: name}
+}
+
+// AutherRef references the auther name, defined by another builder.
+func AutherRef(name string) *AutherBuilder {
+	return &AutherBuilder{name: name, ref: true}
+}
+
+// User adds a user to the auther.
+func (b *AutherBuilder) User(username, password string) *AutherBuilder {
+	b.auths = append(b.auths, &config.AuthConfig{Username: username, Password: password})
+	return b
+}
+
+func (b *AutherBuilder) build() *config.AutherConfig {
+	return &config.AutherConfig{
+		Name:  b.name,
+		Auths: b.auths,
+	}
+}
+
+// bypasses returns the names of the bypasses of bs, defining them if
+// needed: as a single bypass field for one, as a list for more.
+func bypasses(c *collector, bs []*BypassBuilder) (string, []string) {
+	var names []string
+	for _, b := range bs {
+		if name := c.bypass(b); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	return "", names
+}
+
+// admissions is bypasses for admissions.
+func admissions(c *collector, as []*AdmissionBuilder) (string, []string) {
+	var names []string
+	for _, a := range as {
+		if name := c.admission(a); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	return "", names
+}
@@ -0,0 +1,217 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/go-gost/x/config"
+)
+
+// ServiceBuilder builds a service.
+type ServiceBuilder struct {
+	name       string
+	addr       string
+	handler    *HandlerBuilder
+	listener   *ListenerBuilder
+	chain      *ChainBuilder
+	forward    []string
+	bypasses   []*BypassBuilder
+	admissions []*AdmissionBuilder
+	metadata   map[string]any
+}
+
+// Service returns the builder of the service name.
+func Service(name string) *ServiceBuilder {
+	return &ServiceBuilder{name: name}
+}
+
+// Listen sets the address of the service.
+func (b *ServiceBuilder) Listen(addr string) *ServiceBuilder {
+	b.addr = addr
+	return b
+}
+
+func (b *ServiceBuilder) Handler(h *HandlerBuilder) *ServiceBuilder {
+	b.handler = h
+	return b
+}
+
+func (b *ServiceBuilder) Listener(l *ListenerBuilder) *ServiceBuilder {
+	b.listener = l
+	return b
+}
+
+// Chain sets the chain the handler connects through.
+func (b *ServiceBuilder) Chain(c *ChainBuilder) *ServiceBuilder {
+	b.chain = c
+	return b
+}
+
+// Forward sets the target addresses of a port forwarding service, as the
+// forward part of a -L flag does.
+func (b *ServiceBuilder) Forward(addrs ...string) *ServiceBuilder {
+	b.forward = append(b.forward, addrs...)
+	return b
+}
+
+func (b *ServiceBuilder) Bypass(bypasses ...*BypassBuilder) *ServiceBuilder {
+	b.bypasses = append(b.bypasses, bypasses...)
+	return b
+}
+
+func (b *ServiceBuilder) Admission(admissions ...*AdmissionBuilder) *ServiceBuilder {
+	b.admissions = append(b.admissions, admissions...)
+	return b
+}
+
+func (b *ServiceBuilder) Metadata(key string, value any) *ServiceBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+func (b *ServiceBuilder) build(c *collector) *config.ServiceConfig {
+	svc := &config.ServiceConfig{
+		Name:     b.name,
+		Addr:     b.addr,
+		Metadata: metadata(b.metadata),
+	}
+
+	if b.handler != nil {
+		svc.Handler = b.handler.build(c)
+	}
+	if chain := c.chain(b.chain); chain != "" {
+		if svc.Handler == nil {
+			svc.Handler = &config.HandlerConfig{}
+		}
+		svc.Handler.Chain = chain
+	}
+	if b.listener != nil {
+		svc.Listener = b.listener.build(c)
+	}
+
+	if len(b.forward) > 0 {
+		svc.Forwarder = &config.ForwarderConfig{}
+		for i, addr := range b.forward {
+			svc.Forwarder.Nodes = append(svc.Forwarder.Nodes, &config.ForwardNodeConfig{
+				Name: fmt.Sprintf("target-%d", i),
+				Addr: addr,
+			})
+		}
+	}
+
+	svc.Bypass, svc.Bypasses = bypasses(c, b.bypasses)
+	svc.Admission, svc.Admissions = admissions(c, b.admissions)
+
+	return svc
+}
+
+// HandlerBuilder builds the handler of a service.
+type HandlerBuilder struct {
+	typ      string
+	auth     *config.AuthConfig
+	auther   *AutherBuilder
+	tls      *config.TLSConfig
+	metadata map[string]any
+}
+
+// Handler returns the builder of a handler of type typ.
+func Handler(typ string) *HandlerBuilder {
+	return &HandlerBuilder{typ: typ}
+}
+
+func Auto() *HandlerBuilder   { return Handler("auto") }
+func HTTP() *HandlerBuilder   { return Handler("http") }
+func SOCKS4() *HandlerBuilder { return Handler("socks4") }
+func SOCKS5() *HandlerBuilder { return Handler("socks5") }
+func Relay() *HandlerBuilder  { return Handler("relay") }
+func SS() *HandlerBuilder     { return Handler("ss") }
+
+// Auth sets the single user allowed by the handler.
+func (b *HandlerBuilder) Auth(username, password string) *HandlerBuilder {
+	b.auth = &config.AuthConfig{Username: username, Password: password}
+	return b
+}
+
+func (b *HandlerBuilder) Auther(a *AutherBuilder) *HandlerBuilder {
+	b.auther = a
+	return b
+}
+
+func (b *HandlerBuilder) TLS(tls *config.TLSConfig) *HandlerBuilder {
+	b.tls = tls
+	return b
+}
+
+func (b *HandlerBuilder) Metadata(key string, value any) *HandlerBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+func (b *HandlerBuilder) build(c *collector) *config.HandlerConfig {
+	return &config.HandlerConfig{
+		Type:     b.typ,
+		Auth:     b.auth,
+		Auther:   c.auther(b.auther),
+		TLS:      b.tls,
+		Metadata: metadata(b.metadata),
+	}
+}
+
+// ListenerBuilder builds the listener of a service.
+type ListenerBuilder struct {
+	typ      string
+	auth     *config.AuthConfig
+	auther   *AutherBuilder
+	chain    *ChainBuilder
+	tls      *config.TLSConfig
+	metadata map[string]any
+}
+
+// Listener returns the builder of a listener of type typ.
+func Listener(typ string) *ListenerBuilder {
+	return &ListenerBuilder{typ: typ}
+}
+
+func (b *ListenerBuilder) Auth(username, password string) *ListenerBuilder {
+	b.auth = &config.AuthConfig{Username: username, Password: password}
+	return b
+}
+
+func (b *ListenerBuilder) Auther(a *AutherBuilder) *ListenerBuilder {
+	b.auther = a
+	return b
+}
+
+// Chain sets the chain a remote port forwarding listener listens through.
+func (b *ListenerBuilder) Chain(c *ChainBuilder) *ListenerBuilder {
+	b.chain = c
+	return b
+}
+
+func (b *ListenerBuilder) TLS(tls *config.TLSConfig) *ListenerBuilder {
+	b.tls = tls
+	return b
+}
+
+func (b *ListenerBuilder) Metadata(key string, value any) *ListenerBuilder {
+	b.metadata = setMetadata(b.metadata, key, value)
+	return b
+}
+
+func (b *ListenerBuilder) build(c *collector) *config.ListenerConfig {
+	return &config.ListenerConfig{
+		Type:     b.typ,
+		Auth:     b.auth,
+		Auther:   c.auther(b.auther),
+		Chain:    c.chain(b.chain),
+		TLS:      b.tls,
+		Metadata: metadata(b.metadata),
+	}
+}
+
+func setMetadata(md map[string]any, key string, value any) map[string]any {
+	if md == nil {
+		md = make(map[string]any)
+	}
+	md[key] = value
+	return md
+}
@@ -0,0 +1,251 @@
+// Package client is a Go client of the API service of gost: the config API
+// and the runtime endpoints of the server. Its operations are generated
+// from the OpenAPI document of the API, which the API serves as
+// openapi.json.
+//
+//	c := client.New("http://127.0.0.1:18080/api", client.BasicAuthOption("admin", "secret"))
+//
+//	var etag string
+//	bypass, err := c.GetBypass(ctx, "bypass-0", client.ETagOption(&etag))
+//	...
+//	bypass.Matchers = append(bypass.Matchers, "example.com")
+//	err = c.UpdateBypass(ctx, "bypass-0", bypass, client.IfMatchOption(etag))
+package client
+
+//go:generate go run ./internal/clientgen -o client_gen.go
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is an error answered by the API.
+type Error struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code and Msg are the error of the body, if any.
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *Error) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("api: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("api: %d %s", e.StatusCode, e.Msg)
+}
+
+// Client calls the API service.
+type Client struct {
+	baseURL string
+	client  *http.Client
+	auth    func(r *http.Request)
+}
+
+type Option func(c *Client)
+
+// HTTPClientOption sets the HTTP client the calls are made with, e.g. with
+// the TLS config of the API. http.DefaultClient is used by default.
+func HTTPClientOption(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+func BasicAuthOption(username, password string) Option {
+	return func(c *Client) {
+		c.auth = func(r *http.Request) {
+			r.SetBasicAuth(username, password)
+		}
+	}
+}
+
+// TokenOption authenticates the calls with an API token.
+func TokenOption(token string) Option {
+	return func(c *Client) {
+		c.auth = func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// New returns a client of the API served at baseURL, with the path prefix
+// of the API, e.g. http://127.0.0.1:18080/api.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type requestOptions struct {
+	header http.Header
+	etag   *string
+}
+
+// RequestOption sets an option of a call.
+type RequestOption func(opts *requestOptions)
+
+// HeaderOption sets a header of the request.
+func HeaderOption(key, value string) RequestOption {
+	return func(opts *requestOptions) {
+		opts.header.Set(key, value)
+	}
+}
+
+// IfMatchOption makes a change only if the resource has the ETag, a
+// changed resource fails with the status 412.
+func IfMatchOption(etag string) RequestOption {
+	return HeaderOption("If-Match", etag)
+}
+
+// IfNoneMatchOption makes a change only if the resource does not have the
+// ETag, * for a resource created only if it does not exist.
+func IfNoneMatchOption(etag string) RequestOption {
+	return HeaderOption("If-None-Match", etag)
+}
+
+// ETagOption stores the ETag of the response in etag.
+func ETagOption(etag *string) RequestOption {
+	return func(opts *requestOptions) {
+		opts.etag = etag
+	}
+}
+
+// send makes a request to path, the query and the JSON of body, if not nil,
+// included, and returns the successful response.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, opts []RequestOption) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != nil {
+		c.auth(req)
+	}
+
+	ro := &requestOptions{header: req.Header}
+	for _, opt := range opts {
+		opt(ro)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		e := &Error{StatusCode: resp.StatusCode}
+		if b, err := io.ReadAll(resp.Body); err == nil {
+			json.Unmarshal(b, e)
+		}
+		return nil, e
+	}
+	if ro.etag != nil {
+		*ro.etag = resp.Header.Get("ETag")
+	}
+	return resp, nil
+}
+
+// do makes a call, decoding the data of the response into data if not nil.
+// The raw responses are the data itself, in JSON or YAML, the other ones
+// wrap it.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, data any, raw bool, opts []RequestOption) error {
+	resp, err := c.send(ctx, method, path, query, body, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || data == nil {
+		return err
+	}
+	if !raw {
+		return json.Unmarshal(b, &struct {
+			Data any `json:"data"`
+		}{Data: data})
+	}
+	if strings.Contains(resp.Header.Get("Content-Type"), "yaml") {
+		return yaml.Unmarshal(b, data)
+	}
+	return json.Unmarshal(b, data)
+}
+
+// EventStream is a stream of events of the server.
+type EventStream struct {
+	body io.ReadCloser
+	r    *bufio.Reader
+}
+
+// StreamEvents opens the stream of the events of the server, selected by
+// params.
+func (c *Client) StreamEvents(ctx context.Context, params *StreamEventsParams, opts ...RequestOption) (*EventStream, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/events", params.query(), nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{
+		body: resp.Body,
+		r:    bufio.NewReader(resp.Body),
+	}, nil
+}
+
+// Next returns the next event, waiting for it. The error is io.EOF when
+// the stream is closed by the server.
+func (s *EventStream) Next() (*Event, error) {
+	var data []byte
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			if data == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal(data, &e); err != nil {
+				return nil, err
+			}
+			return &e, nil
+		}
+		if v, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimPrefix(v, []byte(" "))...)
+		}
+	}
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
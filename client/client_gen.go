@@ -0,0 +1,1402 @@
+// Code generated by clientgen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-gost/x/config"
+)
+
+// GetAuditLogParams are the query parameters of GetAuditLog.
+type GetAuditLogParams struct {
+	// Page is the page, starting at 1.
+	Page int
+	// Size is the size of the pages, all in one if 0.
+	Size int
+}
+
+func (p *GetAuditLogParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Page != 0 {
+		q.Set("page", strconv.Itoa(p.Page))
+	}
+	if p.Size != 0 {
+		q.Set("size", strconv.Itoa(p.Size))
+	}
+	return q
+}
+
+// GetAuditLog lists the entries of the audit log, the newest first.
+func (c *Client) GetAuditLog(ctx context.Context, params *GetAuditLogParams, opts ...RequestOption) (*AuditList, error) {
+	var data AuditList
+	if err := c.do(ctx, http.MethodGet, "/audit", params.query(), nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetConfigParams are the query parameters of GetConfig.
+type GetConfigParams struct {
+	// Format is the format of the config, json by default.
+	Format string
+}
+
+func (p *GetConfigParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	return q
+}
+
+// GetConfig gets the running config, redacted.
+func (c *Client) GetConfig(ctx context.Context, params *GetConfigParams, opts ...RequestOption) (*config.Config, error) {
+	var data config.Config
+	if err := c.do(ctx, http.MethodGet, "/config", params.query(), nil, &data, true, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// SaveConfigParams are the query parameters of SaveConfig.
+type SaveConfigParams struct {
+	// Format is the format of the file, yaml by default.
+	Format string
+	// Path is the file, gost.yaml or gost.json by default.
+	Path string
+}
+
+func (p *SaveConfigParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	if p.Path != "" {
+		q.Set("path", p.Path)
+	}
+	return q
+}
+
+// SaveConfig saves the running config, redacted, to a file of the server.
+func (c *Client) SaveConfig(ctx context.Context, params *SaveConfigParams, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config", params.query(), nil, nil, false, opts)
+}
+
+// GetAdmissionList lists the admissions.
+func (c *Client) GetAdmissionList(ctx context.Context, opts ...RequestOption) (*AdmissionList, error) {
+	var data AdmissionList
+	if err := c.do(ctx, http.MethodGet, "/config/admissions", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateAdmission creates a resource of the admissions, its name must be unique.
+func (c *Client) CreateAdmission(ctx context.Context, body *config.AdmissionConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/admissions", nil, body, nil, false, opts)
+}
+
+// GetAdmission gets a resource of the admissions.
+func (c *Client) GetAdmission(ctx context.Context, admission string, opts ...RequestOption) (*config.AdmissionConfig, error) {
+	var data config.AdmissionConfig
+	if err := c.do(ctx, http.MethodGet, "/config/admissions/"+url.PathEscape(admission), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateAdmission replaces a resource of the admissions.
+func (c *Client) UpdateAdmission(ctx context.Context, admission string, body *config.AdmissionConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/admissions/"+url.PathEscape(admission), nil, body, nil, false, opts)
+}
+
+// DeleteAdmission deletes a resource of the admissions.
+func (c *Client) DeleteAdmission(ctx context.Context, admission string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/admissions/"+url.PathEscape(admission), nil, nil, nil, false, opts)
+}
+
+// GetAutherList lists the authers.
+func (c *Client) GetAutherList(ctx context.Context, opts ...RequestOption) (*AutherList, error) {
+	var data AutherList
+	if err := c.do(ctx, http.MethodGet, "/config/authers", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateAuther creates a resource of the authers, its name must be unique.
+func (c *Client) CreateAuther(ctx context.Context, body *config.AutherConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/authers", nil, body, nil, false, opts)
+}
+
+// GetAuther gets a resource of the authers.
+func (c *Client) GetAuther(ctx context.Context, auther string, opts ...RequestOption) (*config.AutherConfig, error) {
+	var data config.AutherConfig
+	if err := c.do(ctx, http.MethodGet, "/config/authers/"+url.PathEscape(auther), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateAuther replaces a resource of the authers.
+func (c *Client) UpdateAuther(ctx context.Context, auther string, body *config.AutherConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/authers/"+url.PathEscape(auther), nil, body, nil, false, opts)
+}
+
+// DeleteAuther deletes a resource of the authers.
+func (c *Client) DeleteAuther(ctx context.Context, auther string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/authers/"+url.PathEscape(auther), nil, nil, nil, false, opts)
+}
+
+// BatchConfig applies the changes of the operations as one reload, all of them or none.
+func (c *Client) BatchConfig(ctx context.Context, body *BatchRequest, opts ...RequestOption) (*BatchResult, error) {
+	var data BatchResult
+	if err := c.do(ctx, http.MethodPost, "/config/batch", nil, body, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetBypassList lists the bypasses.
+func (c *Client) GetBypassList(ctx context.Context, opts ...RequestOption) (*BypassList, error) {
+	var data BypassList
+	if err := c.do(ctx, http.MethodGet, "/config/bypasses", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateBypass creates a resource of the bypasses, its name must be unique.
+func (c *Client) CreateBypass(ctx context.Context, body *config.BypassConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/bypasses", nil, body, nil, false, opts)
+}
+
+// GetBypass gets a resource of the bypasses.
+func (c *Client) GetBypass(ctx context.Context, bypass string, opts ...RequestOption) (*config.BypassConfig, error) {
+	var data config.BypassConfig
+	if err := c.do(ctx, http.MethodGet, "/config/bypasses/"+url.PathEscape(bypass), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateBypass replaces a resource of the bypasses.
+func (c *Client) UpdateBypass(ctx context.Context, bypass string, body *config.BypassConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/bypasses/"+url.PathEscape(bypass), nil, body, nil, false, opts)
+}
+
+// DeleteBypass deletes a resource of the bypasses.
+func (c *Client) DeleteBypass(ctx context.Context, bypass string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/bypasses/"+url.PathEscape(bypass), nil, nil, nil, false, opts)
+}
+
+// GetCacheList lists the caches.
+func (c *Client) GetCacheList(ctx context.Context, opts ...RequestOption) (*CacheList, error) {
+	var data CacheList
+	if err := c.do(ctx, http.MethodGet, "/config/caches", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateCache creates a resource of the caches, its name must be unique.
+func (c *Client) CreateCache(ctx context.Context, body *config.CacheConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/caches", nil, body, nil, false, opts)
+}
+
+// GetCache gets a resource of the caches.
+func (c *Client) GetCache(ctx context.Context, cache string, opts ...RequestOption) (*config.CacheConfig, error) {
+	var data config.CacheConfig
+	if err := c.do(ctx, http.MethodGet, "/config/caches/"+url.PathEscape(cache), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateCache replaces a resource of the caches.
+func (c *Client) UpdateCache(ctx context.Context, cache string, body *config.CacheConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/caches/"+url.PathEscape(cache), nil, body, nil, false, opts)
+}
+
+// DeleteCache deletes a resource of the caches.
+func (c *Client) DeleteCache(ctx context.Context, cache string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/caches/"+url.PathEscape(cache), nil, nil, nil, false, opts)
+}
+
+// GetChainList lists the chains.
+func (c *Client) GetChainList(ctx context.Context, opts ...RequestOption) (*ChainList, error) {
+	var data ChainList
+	if err := c.do(ctx, http.MethodGet, "/config/chains", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateChain creates a resource of the chains, its name must be unique.
+func (c *Client) CreateChain(ctx context.Context, body *config.ChainConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/chains", nil, body, nil, false, opts)
+}
+
+// GetChain gets a resource of the chains.
+func (c *Client) GetChain(ctx context.Context, chain string, opts ...RequestOption) (*config.ChainConfig, error) {
+	var data config.ChainConfig
+	if err := c.do(ctx, http.MethodGet, "/config/chains/"+url.PathEscape(chain), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateChain replaces a resource of the chains.
+func (c *Client) UpdateChain(ctx context.Context, chain string, body *config.ChainConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/chains/"+url.PathEscape(chain), nil, body, nil, false, opts)
+}
+
+// DeleteChain deletes a resource of the chains.
+func (c *Client) DeleteChain(ctx context.Context, chain string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/chains/"+url.PathEscape(chain), nil, nil, nil, false, opts)
+}
+
+// GetConnLimiterList lists the climiters.
+func (c *Client) GetConnLimiterList(ctx context.Context, opts ...RequestOption) (*ConnLimiterList, error) {
+	var data ConnLimiterList
+	if err := c.do(ctx, http.MethodGet, "/config/climiters", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateConnLimiter creates a resource of the climiters, its name must be unique.
+func (c *Client) CreateConnLimiter(ctx context.Context, body *config.LimiterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/climiters", nil, body, nil, false, opts)
+}
+
+// GetConnLimiter gets a resource of the climiters.
+func (c *Client) GetConnLimiter(ctx context.Context, limiter string, opts ...RequestOption) (*config.LimiterConfig, error) {
+	var data config.LimiterConfig
+	if err := c.do(ctx, http.MethodGet, "/config/climiters/"+url.PathEscape(limiter), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateConnLimiter replaces a resource of the climiters.
+func (c *Client) UpdateConnLimiter(ctx context.Context, limiter string, body *config.LimiterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/climiters/"+url.PathEscape(limiter), nil, body, nil, false, opts)
+}
+
+// DeleteConnLimiter deletes a resource of the climiters.
+func (c *Client) DeleteConnLimiter(ctx context.Context, limiter string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/climiters/"+url.PathEscape(limiter), nil, nil, nil, false, opts)
+}
+
+// GetHopList lists the hops.
+func (c *Client) GetHopList(ctx context.Context, opts ...RequestOption) (*HopList, error) {
+	var data HopList
+	if err := c.do(ctx, http.MethodGet, "/config/hops", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateHop creates a resource of the hops, its name must be unique.
+func (c *Client) CreateHop(ctx context.Context, body *config.HopConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/hops", nil, body, nil, false, opts)
+}
+
+// GetHop gets a resource of the hops.
+func (c *Client) GetHop(ctx context.Context, hop string, opts ...RequestOption) (*config.HopConfig, error) {
+	var data config.HopConfig
+	if err := c.do(ctx, http.MethodGet, "/config/hops/"+url.PathEscape(hop), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateHop replaces a resource of the hops.
+func (c *Client) UpdateHop(ctx context.Context, hop string, body *config.HopConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/hops/"+url.PathEscape(hop), nil, body, nil, false, opts)
+}
+
+// DeleteHop deletes a resource of the hops.
+func (c *Client) DeleteHop(ctx context.Context, hop string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/hops/"+url.PathEscape(hop), nil, nil, nil, false, opts)
+}
+
+// GetHostsList lists the hosts.
+func (c *Client) GetHostsList(ctx context.Context, opts ...RequestOption) (*HostsList, error) {
+	var data HostsList
+	if err := c.do(ctx, http.MethodGet, "/config/hosts", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateHosts creates a resource of the hosts, its name must be unique.
+func (c *Client) CreateHosts(ctx context.Context, body *config.HostsConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/hosts", nil, body, nil, false, opts)
+}
+
+// GetHosts gets a resource of the hosts.
+func (c *Client) GetHosts(ctx context.Context, hosts string, opts ...RequestOption) (*config.HostsConfig, error) {
+	var data config.HostsConfig
+	if err := c.do(ctx, http.MethodGet, "/config/hosts/"+url.PathEscape(hosts), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateHosts replaces a resource of the hosts.
+func (c *Client) UpdateHosts(ctx context.Context, hosts string, body *config.HostsConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/hosts/"+url.PathEscape(hosts), nil, body, nil, false, opts)
+}
+
+// DeleteHosts deletes a resource of the hosts.
+func (c *Client) DeleteHosts(ctx context.Context, hosts string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/hosts/"+url.PathEscape(hosts), nil, nil, nil, false, opts)
+}
+
+// GetIngressList lists the ingresses.
+func (c *Client) GetIngressList(ctx context.Context, opts ...RequestOption) (*IngressList, error) {
+	var data IngressList
+	if err := c.do(ctx, http.MethodGet, "/config/ingresses", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateIngress creates a resource of the ingresses, its name must be unique.
+func (c *Client) CreateIngress(ctx context.Context, body *config.IngressConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/ingresses", nil, body, nil, false, opts)
+}
+
+// GetIngress gets a resource of the ingresses.
+func (c *Client) GetIngress(ctx context.Context, ingress string, opts ...RequestOption) (*config.IngressConfig, error) {
+	var data config.IngressConfig
+	if err := c.do(ctx, http.MethodGet, "/config/ingresses/"+url.PathEscape(ingress), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateIngress replaces a resource of the ingresses.
+func (c *Client) UpdateIngress(ctx context.Context, ingress string, body *config.IngressConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/ingresses/"+url.PathEscape(ingress), nil, body, nil, false, opts)
+}
+
+// DeleteIngress deletes a resource of the ingresses.
+func (c *Client) DeleteIngress(ctx context.Context, ingress string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/ingresses/"+url.PathEscape(ingress), nil, nil, nil, false, opts)
+}
+
+// GetLimiterList lists the limiters.
+func (c *Client) GetLimiterList(ctx context.Context, opts ...RequestOption) (*LimiterList, error) {
+	var data LimiterList
+	if err := c.do(ctx, http.MethodGet, "/config/limiters", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateLimiter creates a resource of the limiters, its name must be unique.
+func (c *Client) CreateLimiter(ctx context.Context, body *config.LimiterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/limiters", nil, body, nil, false, opts)
+}
+
+// GetLimiter gets a resource of the limiters.
+func (c *Client) GetLimiter(ctx context.Context, limiter string, opts ...RequestOption) (*config.LimiterConfig, error) {
+	var data config.LimiterConfig
+	if err := c.do(ctx, http.MethodGet, "/config/limiters/"+url.PathEscape(limiter), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateLimiter replaces a resource of the limiters.
+func (c *Client) UpdateLimiter(ctx context.Context, limiter string, body *config.LimiterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/limiters/"+url.PathEscape(limiter), nil, body, nil, false, opts)
+}
+
+// DeleteLimiter deletes a resource of the limiters.
+func (c *Client) DeleteLimiter(ctx context.Context, limiter string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/limiters/"+url.PathEscape(limiter), nil, nil, nil, false, opts)
+}
+
+// GetObserverList lists the observers.
+func (c *Client) GetObserverList(ctx context.Context, opts ...RequestOption) (*ObserverList, error) {
+	var data ObserverList
+	if err := c.do(ctx, http.MethodGet, "/config/observers", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateObserver creates a resource of the observers, its name must be unique.
+func (c *Client) CreateObserver(ctx context.Context, body *config.ObserverConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/observers", nil, body, nil, false, opts)
+}
+
+// GetObserver gets a resource of the observers.
+func (c *Client) GetObserver(ctx context.Context, observer string, opts ...RequestOption) (*config.ObserverConfig, error) {
+	var data config.ObserverConfig
+	if err := c.do(ctx, http.MethodGet, "/config/observers/"+url.PathEscape(observer), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateObserver replaces a resource of the observers.
+func (c *Client) UpdateObserver(ctx context.Context, observer string, body *config.ObserverConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/observers/"+url.PathEscape(observer), nil, body, nil, false, opts)
+}
+
+// DeleteObserver deletes a resource of the observers.
+func (c *Client) DeleteObserver(ctx context.Context, observer string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/observers/"+url.PathEscape(observer), nil, nil, nil, false, opts)
+}
+
+// PlanConfig plans the reload of the running config with the config of the body.
+func (c *Client) PlanConfig(ctx context.Context, body *config.Config, opts ...RequestOption) (*Plan, error) {
+	var data Plan
+	if err := c.do(ctx, http.MethodPost, "/config/plan", nil, body, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetQuotaList lists the quotas.
+func (c *Client) GetQuotaList(ctx context.Context, opts ...RequestOption) (*QuotaList, error) {
+	var data QuotaList
+	if err := c.do(ctx, http.MethodGet, "/config/quotas", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateQuota creates a resource of the quotas, its name must be unique.
+func (c *Client) CreateQuota(ctx context.Context, body *config.QuotaConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/quotas", nil, body, nil, false, opts)
+}
+
+// GetQuota gets a resource of the quotas.
+func (c *Client) GetQuota(ctx context.Context, quota string, opts ...RequestOption) (*config.QuotaConfig, error) {
+	var data config.QuotaConfig
+	if err := c.do(ctx, http.MethodGet, "/config/quotas/"+url.PathEscape(quota), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateQuota replaces a resource of the quotas.
+func (c *Client) UpdateQuota(ctx context.Context, quota string, body *config.QuotaConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/quotas/"+url.PathEscape(quota), nil, body, nil, false, opts)
+}
+
+// DeleteQuota deletes a resource of the quotas.
+func (c *Client) DeleteQuota(ctx context.Context, quota string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/quotas/"+url.PathEscape(quota), nil, nil, nil, false, opts)
+}
+
+// ResetQuota overwrites the counter of a quota, 0 by default.
+func (c *Client) ResetQuota(ctx context.Context, quota string, body *QuotaReset, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/quotas/"+url.PathEscape(quota)+"/reset", nil, body, nil, false, opts)
+}
+
+// GetRecorderList lists the recorders.
+func (c *Client) GetRecorderList(ctx context.Context, opts ...RequestOption) (*RecorderList, error) {
+	var data RecorderList
+	if err := c.do(ctx, http.MethodGet, "/config/recorders", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateRecorder creates a resource of the recorders, its name must be unique.
+func (c *Client) CreateRecorder(ctx context.Context, body *config.RecorderConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/recorders", nil, body, nil, false, opts)
+}
+
+// GetRecorder gets a resource of the recorders.
+func (c *Client) GetRecorder(ctx context.Context, recorder string, opts ...RequestOption) (*config.RecorderConfig, error) {
+	var data config.RecorderConfig
+	if err := c.do(ctx, http.MethodGet, "/config/recorders/"+url.PathEscape(recorder), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateRecorder replaces a resource of the recorders.
+func (c *Client) UpdateRecorder(ctx context.Context, recorder string, body *config.RecorderConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/recorders/"+url.PathEscape(recorder), nil, body, nil, false, opts)
+}
+
+// DeleteRecorder deletes a resource of the recorders.
+func (c *Client) DeleteRecorder(ctx context.Context, recorder string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/recorders/"+url.PathEscape(recorder), nil, nil, nil, false, opts)
+}
+
+// ReloadConfig reloads the config from its source, as one transaction.
+func (c *Client) ReloadConfig(ctx context.Context, opts ...RequestOption) (*ReloadStatus, error) {
+	var data ReloadStatus
+	if err := c.do(ctx, http.MethodPost, "/config/reload", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetResolverList lists the resolvers.
+func (c *Client) GetResolverList(ctx context.Context, opts ...RequestOption) (*ResolverList, error) {
+	var data ResolverList
+	if err := c.do(ctx, http.MethodGet, "/config/resolvers", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateResolver creates a resource of the resolvers, its name must be unique.
+func (c *Client) CreateResolver(ctx context.Context, body *config.ResolverConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/resolvers", nil, body, nil, false, opts)
+}
+
+// GetResolver gets a resource of the resolvers.
+func (c *Client) GetResolver(ctx context.Context, resolver string, opts ...RequestOption) (*config.ResolverConfig, error) {
+	var data config.ResolverConfig
+	if err := c.do(ctx, http.MethodGet, "/config/resolvers/"+url.PathEscape(resolver), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateResolver replaces a resource of the resolvers.
+func (c *Client) UpdateResolver(ctx context.Context, resolver string, body *config.ResolverConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/resolvers/"+url.PathEscape(resolver), nil, body, nil, false, opts)
+}
+
+// DeleteResolver deletes a resource of the resolvers.
+func (c *Client) DeleteResolver(ctx context.Context, resolver string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/resolvers/"+url.PathEscape(resolver), nil, nil, nil, false, opts)
+}
+
+// GetRevisions lists the revisions of the config file, the newest first.
+func (c *Client) GetRevisions(ctx context.Context, opts ...RequestOption) (*RevisionList, error) {
+	var data RevisionList
+	if err := c.do(ctx, http.MethodGet, "/config/revisions", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DiffRevisionsParams are the query parameters of DiffRevisions.
+type DiffRevisionsParams struct {
+	// From is the revision compared, the one before to by default.
+	From int
+	// To is the revision compared to, the last one by default.
+	To int
+}
+
+func (p *DiffRevisionsParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.From != 0 {
+		q.Set("from", strconv.Itoa(p.From))
+	}
+	if p.To != 0 {
+		q.Set("to", strconv.Itoa(p.To))
+	}
+	return q
+}
+
+// DiffRevisions compares two revisions of the config file by resource.
+func (c *Client) DiffRevisions(ctx context.Context, params *DiffRevisionsParams, opts ...RequestOption) (*RevisionDiff, error) {
+	var data RevisionDiff
+	if err := c.do(ctx, http.MethodGet, "/config/revisions/diff", params.query(), nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// RollbackRevision restores a revision of the config file, as a new revision.
+func (c *Client) RollbackRevision(ctx context.Context, revision int, opts ...RequestOption) (*Revision, error) {
+	var data Revision
+	if err := c.do(ctx, http.MethodPost, "/config/revisions/"+strconv.Itoa(revision)+"/rollback", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetRateLimiterList lists the rlimiters.
+func (c *Client) GetRateLimiterList(ctx context.Context, opts ...RequestOption) (*RateLimiterList, error) {
+	var data RateLimiterList
+	if err := c.do(ctx, http.MethodGet, "/config/rlimiters", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateRateLimiter creates a resource of the rlimiters, its name must be unique.
+func (c *Client) CreateRateLimiter(ctx context.Context, body *config.LimiterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/rlimiters", nil, body, nil, false, opts)
+}
+
+// GetRateLimiter gets a resource of the rlimiters.
+func (c *Client) GetRateLimiter(ctx context.Context, limiter string, opts ...RequestOption) (*config.LimiterConfig, error) {
+	var data config.LimiterConfig
+	if err := c.do(ctx, http.MethodGet, "/config/rlimiters/"+url.PathEscape(limiter), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateRateLimiter replaces a resource of the rlimiters.
+func (c *Client) UpdateRateLimiter(ctx context.Context, limiter string, body *config.LimiterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/rlimiters/"+url.PathEscape(limiter), nil, body, nil, false, opts)
+}
+
+// DeleteRateLimiter deletes a resource of the rlimiters.
+func (c *Client) DeleteRateLimiter(ctx context.Context, limiter string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/rlimiters/"+url.PathEscape(limiter), nil, nil, nil, false, opts)
+}
+
+// GetRouterList lists the routers.
+func (c *Client) GetRouterList(ctx context.Context, opts ...RequestOption) (*RouterList, error) {
+	var data RouterList
+	if err := c.do(ctx, http.MethodGet, "/config/routers", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateRouter creates a resource of the routers, its name must be unique.
+func (c *Client) CreateRouter(ctx context.Context, body *config.RouterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/routers", nil, body, nil, false, opts)
+}
+
+// GetRouter gets a resource of the routers.
+func (c *Client) GetRouter(ctx context.Context, router string, opts ...RequestOption) (*config.RouterConfig, error) {
+	var data config.RouterConfig
+	if err := c.do(ctx, http.MethodGet, "/config/routers/"+url.PathEscape(router), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateRouter replaces a resource of the routers.
+func (c *Client) UpdateRouter(ctx context.Context, router string, body *config.RouterConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/routers/"+url.PathEscape(router), nil, body, nil, false, opts)
+}
+
+// DeleteRouter deletes a resource of the routers.
+func (c *Client) DeleteRouter(ctx context.Context, router string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/routers/"+url.PathEscape(router), nil, nil, nil, false, opts)
+}
+
+// GetSDList lists the sds.
+func (c *Client) GetSDList(ctx context.Context, opts ...RequestOption) (*SDList, error) {
+	var data SDList
+	if err := c.do(ctx, http.MethodGet, "/config/sds", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateSD creates a resource of the sds, its name must be unique.
+func (c *Client) CreateSD(ctx context.Context, body *config.SDConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/sds", nil, body, nil, false, opts)
+}
+
+// GetSD gets a resource of the sds.
+func (c *Client) GetSD(ctx context.Context, sd string, opts ...RequestOption) (*config.SDConfig, error) {
+	var data config.SDConfig
+	if err := c.do(ctx, http.MethodGet, "/config/sds/"+url.PathEscape(sd), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateSD replaces a resource of the sds.
+func (c *Client) UpdateSD(ctx context.Context, sd string, body *config.SDConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/sds/"+url.PathEscape(sd), nil, body, nil, false, opts)
+}
+
+// DeleteSD deletes a resource of the sds.
+func (c *Client) DeleteSD(ctx context.Context, sd string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/sds/"+url.PathEscape(sd), nil, nil, nil, false, opts)
+}
+
+// GetServiceList lists the services.
+func (c *Client) GetServiceList(ctx context.Context, opts ...RequestOption) (*ServiceList, error) {
+	var data ServiceList
+	if err := c.do(ctx, http.MethodGet, "/config/services", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CreateService creates a resource of the services, its name must be unique.
+func (c *Client) CreateService(ctx context.Context, body *config.ServiceConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPost, "/config/services", nil, body, nil, false, opts)
+}
+
+// GetService gets a resource of the services.
+func (c *Client) GetService(ctx context.Context, service string, opts ...RequestOption) (*config.ServiceConfig, error) {
+	var data config.ServiceConfig
+	if err := c.do(ctx, http.MethodGet, "/config/services/"+url.PathEscape(service), nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateService replaces a resource of the services.
+func (c *Client) UpdateService(ctx context.Context, service string, body *config.ServiceConfig, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodPut, "/config/services/"+url.PathEscape(service), nil, body, nil, false, opts)
+}
+
+// DeleteService deletes a resource of the services.
+func (c *Client) DeleteService(ctx context.Context, service string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/config/services/"+url.PathEscape(service), nil, nil, nil, false, opts)
+}
+
+// GetConnectionsParams are the query parameters of GetConnections.
+type GetConnectionsParams struct {
+	// Service is the service of the connections.
+	Service string
+	// User is the user of the connections.
+	User string
+	// Client is the client address or IP of the connections.
+	Client string
+	// Host is the target host of the connections.
+	Host string
+	// Page is the page, starting at 1.
+	Page int
+	// Size is the size of the pages, all in one if 0.
+	Size int
+}
+
+func (p *GetConnectionsParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Service != "" {
+		q.Set("service", p.Service)
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	if p.Client != "" {
+		q.Set("client", p.Client)
+	}
+	if p.Host != "" {
+		q.Set("host", p.Host)
+	}
+	if p.Page != 0 {
+		q.Set("page", strconv.Itoa(p.Page))
+	}
+	if p.Size != 0 {
+		q.Set("size", strconv.Itoa(p.Size))
+	}
+	return q
+}
+
+// GetConnections lists the connections handled by the services, the oldest first.
+func (c *Client) GetConnections(ctx context.Context, params *GetConnectionsParams, opts ...RequestOption) (*ConnectionList, error) {
+	var data ConnectionList
+	if err := c.do(ctx, http.MethodGet, "/connections", params.query(), nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CloseConnectionsParams are the query parameters of CloseConnections.
+type CloseConnectionsParams struct {
+	// Service is the service of the connections.
+	Service string
+	// User is the user of the connections.
+	User string
+	// Client is the client address or IP of the connections.
+	Client string
+	// Host is the target host of the connections.
+	Host string
+}
+
+func (p *CloseConnectionsParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Service != "" {
+		q.Set("service", p.Service)
+	}
+	if p.User != "" {
+		q.Set("user", p.User)
+	}
+	if p.Client != "" {
+		q.Set("client", p.Client)
+	}
+	if p.Host != "" {
+		q.Set("host", p.Host)
+	}
+	return q
+}
+
+// CloseConnections closes the connections matched, one filter at least is required.
+func (c *Client) CloseConnections(ctx context.Context, params *CloseConnectionsParams, opts ...RequestOption) (*ConnectionList, error) {
+	var data ConnectionList
+	if err := c.do(ctx, http.MethodDelete, "/connections", params.query(), nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// CloseConnection closes a connection.
+func (c *Client) CloseConnection(ctx context.Context, id string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/connections/"+url.PathEscape(id), nil, nil, nil, false, opts)
+}
+
+// GetDrainStatus gets the connections of the services being drained.
+func (c *Client) GetDrainStatus(ctx context.Context, opts ...RequestOption) (*DrainStatus, error) {
+	var data DrainStatus
+	if err := c.do(ctx, http.MethodGet, "/drain", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// StreamEventsParams are the query parameters of StreamEvents.
+type StreamEventsParams struct {
+	// Type is the list of the event types, or of their prefixes up to a dot.
+	Type []string
+	// Since is the ID of the last event received, the events retained after it are sent first.
+	Since int
+}
+
+func (p *StreamEventsParams) query() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	for _, v := range p.Type {
+		q.Add("type", v)
+	}
+	if p.Since != 0 {
+		q.Set("since", strconv.Itoa(p.Since))
+	}
+	return q
+}
+
+// GetHealth gets the health of the services and of the chains, without authentication.
+func (c *Client) GetHealth(ctx context.Context, opts ...RequestOption) (*Health, error) {
+	var data Health
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, &data, true, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetNodes lists the state of the nodes of the hops, of the chains and of the forwarders.
+func (c *Client) GetNodes(ctx context.Context, opts ...RequestOption) (*NodeList, error) {
+	var data NodeList
+	if err := c.do(ctx, http.MethodGet, "/nodes", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetOpenAPI gets this document.
+func (c *Client) GetOpenAPI(ctx context.Context, opts ...RequestOption) (map[string]json.RawMessage, error) {
+	var data map[string]json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &data, true, opts)
+	return data, err
+}
+
+// GetReadiness gets the health of the services and of the chains, without authentication, unavailable until the server is ready.
+func (c *Client) GetReadiness(ctx context.Context, opts ...RequestOption) (*Health, error) {
+	var data Health
+	if err := c.do(ctx, http.MethodGet, "/readyz", nil, nil, &data, true, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetReloadStatus gets the outcome of the last reload.
+func (c *Client) GetReloadStatus(ctx context.Context, opts ...RequestOption) (*ReloadStatus, error) {
+	var data ReloadStatus
+	if err := c.do(ctx, http.MethodGet, "/reload", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetReloads lists the outcomes of the last reloads, the newest first.
+func (c *Client) GetReloads(ctx context.Context, opts ...RequestOption) (*ReloadList, error) {
+	var data ReloadList
+	if err := c.do(ctx, http.MethodGet, "/reloads", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetTokens lists the API tokens.
+func (c *Client) GetTokens(ctx context.Context, opts ...RequestOption) (*TokenList, error) {
+	var data TokenList
+	if err := c.do(ctx, http.MethodGet, "/tokens", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// IssueToken issues an API token, its secret is only returned now.
+func (c *Client) IssueToken(ctx context.Context, body *TokenRequest, opts ...RequestOption) (*IssuedToken, error) {
+	var data IssuedToken
+	if err := c.do(ctx, http.MethodPost, "/tokens", nil, body, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// RevokeToken revokes an API token.
+func (c *Client) RevokeToken(ctx context.Context, id string, opts ...RequestOption) error {
+	return c.do(ctx, http.MethodDelete, "/tokens/"+url.PathEscape(id), nil, nil, nil, false, opts)
+}
+
+// GetTunnels lists the connectors bound to the tunnels of the services.
+func (c *Client) GetTunnels(ctx context.Context, opts ...RequestOption) (*TunnelList, error) {
+	var data TunnelList
+	if err := c.do(ctx, http.MethodGet, "/tunnels", nil, nil, &data, false, opts); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// AdmissionList is the AdmissionList schema of the API.
+type AdmissionList struct {
+	Count    int                      `json:"count"`
+	List     []config.AdmissionConfig `json:"list"`
+	Versions map[string]string        `json:"versions,omitempty"`
+}
+
+// AuditEntry is the AuditEntry schema of the API.
+type AuditEntry struct {
+	Time    time.Time        `json:"time"`
+	User    string           `json:"user"`
+	Client  string           `json:"client"`
+	Method  string           `json:"method"`
+	Path    string           `json:"path"`
+	Status  int              `json:"status"`
+	Changes []ResourceChange `json:"changes,omitempty"`
+}
+
+// AuditList is the AuditList schema of the API.
+type AuditList struct {
+	Count int          `json:"count"`
+	List  []AuditEntry `json:"list"`
+}
+
+// AutherList is the AutherList schema of the API.
+type AutherList struct {
+	Count    int                   `json:"count"`
+	List     []config.AutherConfig `json:"list"`
+	Versions map[string]string     `json:"versions,omitempty"`
+}
+
+// BatchOp is the BatchOp schema of the API.
+type BatchOp struct {
+	Op      string          `json:"op"`
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`
+	IfMatch string          `json:"ifMatch,omitempty"`
+	Config  json.RawMessage `json:"config,omitempty"`
+}
+
+// BatchRequest is the BatchRequest schema of the API.
+type BatchRequest struct {
+	Operations []BatchOp `json:"operations"`
+}
+
+// BatchResult is the BatchResult schema of the API.
+type BatchResult struct {
+	Changes  string            `json:"changes"`
+	Versions map[string]string `json:"versions"`
+}
+
+// BypassList is the BypassList schema of the API.
+type BypassList struct {
+	Count    int                   `json:"count"`
+	List     []config.BypassConfig `json:"list"`
+	Versions map[string]string     `json:"versions,omitempty"`
+}
+
+// CacheList is the CacheList schema of the API.
+type CacheList struct {
+	Count    int                  `json:"count"`
+	List     []config.CacheConfig `json:"list"`
+	Versions map[string]string    `json:"versions,omitempty"`
+}
+
+// ChainHealth is the ChainHealth schema of the API.
+type ChainHealth struct {
+	Name     string      `json:"name"`
+	Required bool        `json:"required"`
+	Ready    bool        `json:"ready"`
+	Error    string      `json:"error,omitempty"`
+	Hops     []HopHealth `json:"hops,omitempty"`
+}
+
+// ChainList is the ChainList schema of the API.
+type ChainList struct {
+	Count    int                  `json:"count"`
+	List     []config.ChainConfig `json:"list"`
+	Versions map[string]string    `json:"versions,omitempty"`
+}
+
+// ConnLimiterList is the ConnLimiterList schema of the API.
+type ConnLimiterList struct {
+	Count    int                    `json:"count"`
+	List     []config.LimiterConfig `json:"list"`
+	Versions map[string]string      `json:"versions,omitempty"`
+}
+
+// ConnectionList is the ConnectionList schema of the API.
+type ConnectionList struct {
+	Count int       `json:"count"`
+	List  []Session `json:"list"`
+}
+
+// DrainServiceStatus is the DrainServiceStatus schema of the API.
+type DrainServiceStatus struct {
+	Service     string    `json:"service"`
+	Connections int       `json:"connections"`
+	Deadline    time.Time `json:"deadline,omitzero"`
+}
+
+// DrainStatus is the DrainStatus schema of the API.
+type DrainStatus struct {
+	Connections int                  `json:"connections"`
+	Services    []DrainServiceStatus `json:"services,omitempty"`
+}
+
+// Event is the Event schema of the API.
+type Event struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Service   string    `json:"service,omitempty"`
+	Resource  string    `json:"resource,omitempty"`
+	Chain     string    `json:"chain,omitempty"`
+	Hop       string    `json:"hop,omitempty"`
+	Node      string    `json:"node,omitempty"`
+	Addr      string    `json:"addr,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Tunnel    string    `json:"tunnel,omitempty"`
+	Connector string    `json:"connector,omitempty"`
+	Network   string    `json:"network,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Changes   string    `json:"changes,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Count     int       `json:"count,omitempty"`
+}
+
+// Grant is the Grant schema of the API.
+type Grant struct {
+	User      string   `json:"user,omitempty"`
+	Role      string   `json:"role"`
+	Resources []string `json:"resources,omitempty"`
+}
+
+// Health is the Health schema of the API.
+type Health struct {
+	Ready    bool            `json:"ready"`
+	Services []ServiceHealth `json:"services"`
+	Chains   []ChainHealth   `json:"chains"`
+}
+
+// HopHealth is the HopHealth schema of the API.
+type HopHealth struct {
+	Name    string `json:"name"`
+	Nodes   int    `json:"nodes"`
+	Passing int    `json:"passing"`
+}
+
+// HopList is the HopList schema of the API.
+type HopList struct {
+	Count    int                `json:"count"`
+	List     []config.HopConfig `json:"list"`
+	Versions map[string]string  `json:"versions,omitempty"`
+}
+
+// HostsList is the HostsList schema of the API.
+type HostsList struct {
+	Count    int                  `json:"count"`
+	List     []config.HostsConfig `json:"list"`
+	Versions map[string]string    `json:"versions,omitempty"`
+}
+
+// IngressList is the IngressList schema of the API.
+type IngressList struct {
+	Count    int                    `json:"count"`
+	List     []config.IngressConfig `json:"list"`
+	Versions map[string]string      `json:"versions,omitempty"`
+}
+
+// IssuedToken is the IssuedToken schema of the API.
+type IssuedToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	Grants    []Grant   `json:"grants"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires,omitzero"`
+	Secret    string    `json:"secret"`
+}
+
+// LimiterList is the LimiterList schema of the API.
+type LimiterList struct {
+	Count    int                    `json:"count"`
+	List     []config.LimiterConfig `json:"list"`
+	Versions map[string]string      `json:"versions,omitempty"`
+}
+
+// NodeList is the NodeList schema of the API.
+type NodeList struct {
+	Count int          `json:"count"`
+	List  []NodeStatus `json:"list"`
+}
+
+// NodeProbe is the NodeProbe schema of the API.
+type NodeProbe struct {
+	Success bool      `json:"success"`
+	Latency int       `json:"latency"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// NodeStatus is the NodeStatus schema of the API.
+type NodeStatus struct {
+	Service string     `json:"service,omitempty"`
+	Chain   string     `json:"chain,omitempty"`
+	Hop     string     `json:"hop,omitempty"`
+	Node    string     `json:"node"`
+	Addr    string     `json:"addr"`
+	Failed  bool       `json:"failed"`
+	Reason  string     `json:"reason,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Fails   int        `json:"fails"`
+	Probe   *NodeProbe `json:"probe,omitempty"`
+}
+
+// ObserverList is the ObserverList schema of the API.
+type ObserverList struct {
+	Count    int                     `json:"count"`
+	List     []config.ObserverConfig `json:"list"`
+	Versions map[string]string       `json:"versions,omitempty"`
+}
+
+// Plan is the Plan schema of the API.
+type Plan struct {
+	Changes     []PlanChange `json:"changes,omitempty"`
+	Add         int          `json:"add"`
+	Change      int          `json:"change"`
+	Remove      int          `json:"remove"`
+	Connections int          `json:"connections"`
+}
+
+// PlanChange is the PlanChange schema of the API.
+type PlanChange struct {
+	Resource    string   `json:"resource"`
+	Action      string   `json:"action"`
+	Cause       string   `json:"cause,omitempty"`
+	Rebind      bool     `json:"rebind,omitempty"`
+	Addr        string   `json:"addr,omitempty"`
+	Connections int      `json:"connections,omitempty"`
+	Details     []string `json:"details,omitempty"`
+}
+
+// QuotaList is the QuotaList schema of the API.
+type QuotaList struct {
+	Count    int                  `json:"count"`
+	List     []config.QuotaConfig `json:"list"`
+	Versions map[string]string    `json:"versions,omitempty"`
+}
+
+// QuotaReset is the QuotaReset schema of the API.
+type QuotaReset struct {
+	Used uint64 `json:"used,omitempty"`
+}
+
+// RateLimiterList is the RateLimiterList schema of the API.
+type RateLimiterList struct {
+	Count    int                    `json:"count"`
+	List     []config.LimiterConfig `json:"list"`
+	Versions map[string]string      `json:"versions,omitempty"`
+}
+
+// RecorderList is the RecorderList schema of the API.
+type RecorderList struct {
+	Count    int                     `json:"count"`
+	List     []config.RecorderConfig `json:"list"`
+	Versions map[string]string       `json:"versions,omitempty"`
+}
+
+// ReloadList is the ReloadList schema of the API.
+type ReloadList struct {
+	Count int            `json:"count"`
+	List  []ReloadStatus `json:"list"`
+}
+
+// ReloadStatus is the ReloadStatus schema of the API.
+type ReloadStatus struct {
+	Status  string    `json:"status"`
+	Time    time.Time `json:"time"`
+	Changes string    `json:"changes,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// ResolverList is the ResolverList schema of the API.
+type ResolverList struct {
+	Count    int                     `json:"count"`
+	List     []config.ResolverConfig `json:"list"`
+	Versions map[string]string       `json:"versions,omitempty"`
+}
+
+// ResourceChange is the ResourceChange schema of the API.
+type ResourceChange struct {
+	Resource string          `json:"resource"`
+	Change   string          `json:"change"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	After    json.RawMessage `json:"after,omitempty"`
+}
+
+// Revision is the Revision schema of the API.
+type Revision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	User     string    `json:"user,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Rollback int       `json:"rollback,omitempty"`
+}
+
+// RevisionDiff is the RevisionDiff schema of the API.
+type RevisionDiff struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []ResourceChange `json:"changes"`
+}
+
+// RevisionList is the RevisionList schema of the API.
+type RevisionList struct {
+	Count int        `json:"count"`
+	List  []Revision `json:"list"`
+}
+
+// RouterList is the RouterList schema of the API.
+type RouterList struct {
+	Count    int                   `json:"count"`
+	List     []config.RouterConfig `json:"list"`
+	Versions map[string]string     `json:"versions,omitempty"`
+}
+
+// SDList is the SDList schema of the API.
+type SDList struct {
+	Count    int               `json:"count"`
+	List     []config.SDConfig `json:"list"`
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+// ServiceHealth is the ServiceHealth schema of the API.
+type ServiceHealth struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Ready bool   `json:"ready"`
+}
+
+// ServiceList is the ServiceList schema of the API.
+type ServiceList struct {
+	Count    int                    `json:"count"`
+	List     []config.ServiceConfig `json:"list"`
+	Versions map[string]string      `json:"versions,omitempty"`
+}
+
+// Session is the Session schema of the API.
+type Session struct {
+	ID          string        `json:"id"`
+	Service     string        `json:"service"`
+	Client      string        `json:"client"`
+	User        string        `json:"user,omitempty"`
+	Network     string        `json:"network,omitempty"`
+	Host        string        `json:"host,omitempty"`
+	Chain       string        `json:"chain,omitempty"`
+	Path        []SessionNode `json:"path,omitempty"`
+	Proto       string        `json:"proto,omitempty"`
+	SNI         string        `json:"sni,omitempty"`
+	InputBytes  uint64        `json:"inputBytes"`
+	OutputBytes uint64        `json:"outputBytes"`
+	Start       time.Time     `json:"start"`
+}
+
+// SessionNode is the SessionNode schema of the API.
+type SessionNode struct {
+	Hop  string `json:"hop,omitempty"`
+	Node string `json:"node"`
+	Addr string `json:"addr"`
+}
+
+// Token is the Token schema of the API.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	Grants    []Grant   `json:"grants"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires,omitzero"`
+}
+
+// TokenList is the TokenList schema of the API.
+type TokenList struct {
+	Count int     `json:"count"`
+	List  []Token `json:"list"`
+}
+
+// TokenRequest is the TokenRequest schema of the API.
+type TokenRequest struct {
+	Name   string  `json:"name,omitempty"`
+	Grants []Grant `json:"grants"`
+	TTL    string  `json:"ttl,omitempty"`
+}
+
+// TunnelConnector is the TunnelConnector schema of the API.
+type TunnelConnector struct {
+	Service   string    `json:"service"`
+	Tunnel    string    `json:"tunnel"`
+	Connector string    `json:"connector"`
+	Node      string    `json:"node,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	Network   string    `json:"network,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// TunnelList is the TunnelList schema of the API.
+type TunnelList struct {
+	Count int               `json:"count"`
+	List  []TunnelConnector `json:"list"`
+}
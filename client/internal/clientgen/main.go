@@ -0,0 +1,414 @@
+// Command clientgen generates the operations of the API client, and the
+// types of their parameters and results, from the OpenAPI document of the
+// API returned by server.OpenAPI.
+//
+// An operation becomes a method named after its operation ID, taking the
+// path parameters, the request body and the query parameters, in this
+// order. The header parameters are set with the request options. The
+// schemas naming their Go type with x-go-type are that type, the other
+// ones are generated as structs, except the Response and Error envelopes
+// handled by the client. The operations streaming server-sent events are
+// written by hand.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-gost/gost/server"
+)
+
+// envelopes are the schemas of the responses, handled by the client.
+var envelopes = map[string]bool{
+	"Response": true,
+	"Error":    true,
+}
+
+// methods are the HTTP methods in the order of the operations of a path.
+var methods = []string{"get", "post", "put", "delete"}
+
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary"`
+	Parameters  []*parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]*mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]*struct {
+		Content map[string]*mediaType `json:"content"`
+	} `json:"responses"`
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	ContentEncoding      string             `json:"contentEncoding"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	AllOf                []*schema          `json:"allOf"`
+	GoName               string             `json:"x-go-name"`
+	GoType               string             `json:"x-go-type"`
+	Order                int                `json:"x-order"`
+}
+
+func main() {
+	output := flag.String("o", "client_gen.go", "output file")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("clientgen: ")
+
+	b, err := json.Marshal(server.OpenAPI(""))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(&doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type generator struct {
+	doc *document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func generate(doc *document) ([]byte, error) {
+	g := &generator{doc: doc}
+
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, method := range methods {
+			if op := doc.Paths[path][method]; op != nil {
+				if err := g.operation(method, path, op); err != nil {
+					return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+				}
+			}
+		}
+	}
+
+	var names []string
+	for name, s := range doc.Components.Schemas {
+		if s.GoType == "" && !envelopes[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.schema(name, doc.Components.Schemas[name])
+	}
+
+	body := g.buf.String()
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by clientgen; DO NOT EDIT.\n\n")
+	buf.WriteString("package client\n\n")
+	buf.WriteString("import (\n")
+	for _, imp := range []struct{ path, name string }{
+		{"context", "context."},
+		{"encoding/json", "json."},
+		{"net/http", "http."},
+		{"net/url", "url."},
+		{"strconv", "strconv."},
+		{"time", "time."},
+		{"", ""},
+		{"github.com/go-gost/x/config", "config."},
+	} {
+		if imp.path == "" {
+			buf.WriteString("\n")
+		} else if strings.Contains(body, imp.name) {
+			fmt.Fprintf(&buf, "%q\n", imp.path)
+		}
+	}
+	buf.WriteString(")\n")
+	buf.WriteString(body)
+
+	return format.Source(buf.Bytes())
+}
+
+// operation generates the method of op, and the type of its query
+// parameters if any.
+func (g *generator) operation(method, path string, op *operation) error {
+	name := exported(op.OperationID)
+
+	var pathParams, queryParams []*parameter
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			pathParams = append(pathParams, p)
+		case "query":
+			queryParams = append(queryParams, p)
+		}
+	}
+
+	params := name + "Params"
+	if len(queryParams) > 0 {
+		g.printf("\n// %s are the query parameters of %s.\n", params, name)
+		g.printf("type %s struct {\n", params)
+		for _, p := range queryParams {
+			if p.Description != "" {
+				g.printf("// %s is %s\n", exported(p.Name), lower(p.Description))
+			}
+			g.printf("%s %s\n", exported(p.Name), g.goType(p.Schema, false))
+		}
+		g.printf("}\n")
+
+		g.printf("\nfunc (p *%s) query() url.Values {\n", params)
+		g.printf("q := url.Values{}\nif p == nil {\nreturn q\n}\n")
+		for _, p := range queryParams {
+			field := "p." + exported(p.Name)
+			switch t := g.goType(p.Schema, false); t {
+			case "string":
+				g.printf("if %s != \"\" {\nq.Set(%q, %s)\n}\n", field, p.Name, field)
+			case "int":
+				g.printf("if %s != 0 {\nq.Set(%q, strconv.Itoa(%s))\n}\n", field, p.Name, field)
+			case "[]string":
+				g.printf("for _, v := range %s {\nq.Add(%q, v)\n}\n", field, p.Name)
+			default:
+				return fmt.Errorf("query parameter %s: unsupported type %s", p.Name, t)
+			}
+		}
+		g.printf("return q\n}\n")
+	}
+
+	ok := op.Responses["200"]
+	if ok == nil {
+		return fmt.Errorf("no response")
+	}
+	media := ok.Content["application/json"]
+	if media == nil {
+		// Server-sent events.
+		return nil
+	}
+
+	var data *schema
+	raw := true
+	switch s := media.Schema; {
+	case s.Ref == "#/components/schemas/Response":
+		raw = false
+	case len(s.AllOf) == 2:
+		data = s.AllOf[1].Properties["data"]
+		raw = false
+	default:
+		data = s
+	}
+
+	args := []string{"ctx context.Context"}
+	pathExpr := []string{}
+	rest := path
+	for _, p := range pathParams {
+		before, after, _ := strings.Cut(rest, "{"+p.Name+"}")
+		t := g.goType(p.Schema, false)
+		args = append(args, p.Name+" "+t)
+		pathExpr = append(pathExpr, fmt.Sprintf("%q", before))
+		switch t {
+		case "string":
+			pathExpr = append(pathExpr, "url.PathEscape("+p.Name+")")
+		case "int":
+			pathExpr = append(pathExpr, "strconv.Itoa("+p.Name+")")
+		default:
+			return fmt.Errorf("path parameter %s: unsupported type %s", p.Name, t)
+		}
+		rest = after
+	}
+	if rest != "" {
+		pathExpr = append(pathExpr, fmt.Sprintf("%q", rest))
+	}
+
+	body := "nil"
+	if op.RequestBody != nil {
+		args = append(args, "body "+g.goType(op.RequestBody.Content["application/json"].Schema, true))
+		body = "body"
+	}
+	query := "nil"
+	if len(queryParams) > 0 {
+		args = append(args, "params *"+params)
+		query = "params.query()"
+	}
+	args = append(args, "opts ...RequestOption")
+
+	call := fmt.Sprintf("c.do(ctx, http.Method%s, %s, %s, %s, %%s, %t, opts)",
+		exported(method), strings.Join(pathExpr, "+"), query, body, raw)
+
+	g.printf("\n// %s %s\n", name, sentence(op.Summary))
+	switch {
+	case data == nil:
+		g.printf("func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+		g.printf("return "+call+"\n}\n", "nil")
+	case data.Ref != "":
+		t := g.goType(data, false)
+		g.printf("func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), t)
+		g.printf("var data %s\n", t)
+		g.printf("if err := "+call+"; err != nil {\nreturn nil, err\n}\n", "&data")
+		g.printf("return &data, nil\n}\n")
+	default:
+		t := g.goType(data, false)
+		g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), t)
+		g.printf("var data %s\n", t)
+		g.printf("err := "+call+"\n", "&data")
+		g.printf("return data, err\n}\n")
+	}
+	return nil
+}
+
+// schema generates the struct type of the object schema s.
+func (g *generator) schema(name string, s *schema) {
+	type field struct {
+		name string
+		s    *schema
+	}
+	var fields []field
+	for name, p := range s.Properties {
+		fields = append(fields, field{name, p})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].s.Order != fields[j].s.Order {
+			return fields[i].s.Order < fields[j].s.Order
+		}
+		return fields[i].name < fields[j].name
+	})
+
+	g.printf("\n// %s is the %s schema of the API.\n", name, name)
+	g.printf("type %s struct {\n", name)
+	for _, f := range fields {
+		goName := f.s.GoName
+		if goName == "" {
+			goName = exported(f.name)
+		}
+		t := g.goType(f.s, true)
+		tag := f.name
+		switch {
+		case slices.Contains(s.Required, f.name):
+		case t == "time.Time":
+			tag += ",omitzero"
+		default:
+			tag += ",omitempty"
+		}
+		g.printf("%s %s `json:%q`\n", goName, t, tag)
+	}
+	g.printf("}\n")
+}
+
+// goType returns the Go type of the values of s, a pointer to the struct
+// type of a reference if ptr.
+func (g *generator) goType(s *schema, ptr bool) string {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		t := name
+		if ref := g.doc.Components.Schemas[name]; ref != nil && ref.GoType != "" {
+			t = ref.GoType
+		}
+		if ptr {
+			return "*" + t
+		}
+		return t
+	}
+
+	switch s.Type {
+	case "array":
+		return "[]" + g.goType(s.Items, false)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + g.goType(s.AdditionalProperties, false)
+		}
+		return "map[string]any"
+	case "string":
+		switch {
+		case s.Format == "date-time":
+			return "time.Time"
+		case s.ContentEncoding == "base64":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "uint64" {
+			return "uint64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	default:
+		// Any value.
+		return "json.RawMessage"
+	}
+}
+
+// exported returns the exported Go name of an operation ID, a parameter or
+// a method, e.g. GetBypass for getBypass.
+func exported(name string) string {
+	if name == "" {
+		return ""
+	}
+	if name == "id" {
+		return "ID"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// sentence returns the summary of an operation as the rest of a doc
+// comment starting with the name of its method: "Get the config." becomes
+// "gets the config.".
+func sentence(summary string) string {
+	verb, rest, _ := strings.Cut(summary, " ")
+	verb = lower(verb)
+	if v, ok := strings.CutSuffix(verb, "y"); ok {
+		return v + "ies " + rest
+	}
+	return verb + "s " + rest
+}
+
+// lower returns s with its first letter in lower case.
+func lower(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToLower(r[0])
+	}
+	return string(r)
+}
@@ -0,0 +1,32 @@
+// Command gost-controlplane runs the reference management server of the
+// controlplane package. The gost instances subscribe to it with
+// --subscribe, the resources are managed through its HTTP API, e.g.
+//
+//	curl -X PUT http://127.0.0.1:18000/v1/resources/services/proxy \
+//		-d '{"addr": ":8080", "handler": {"type": "http"}, "listener": {"type": "tcp"}}'
+//	curl http://127.0.0.1:18000/v1/nodes
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-gost/gost/controlplane"
+)
+
+func main() {
+	var (
+		addr string
+		wait time.Duration
+	)
+	flag.StringVar(&addr, "L", ":18000", "listen address")
+	flag.DurationVar(&wait, "wait", controlplane.DefaultWaitTimeout, "how long a discovery request waits for a change")
+	flag.Parse()
+
+	srv := controlplane.NewServer(controlplane.WaitTimeoutOption(wait))
+
+	log.Printf("listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, srv))
+}
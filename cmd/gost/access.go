@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-gost/gost/server"
+	"gopkg.in/yaml.v3"
+)
+
+// loadAccess reads the access control of the API given by --api-access, a
+// YAML file such as
+//
+//	grants:
+//	- user: admin
+//	  role: admin
+//	- user: alice
+//	  role: operator
+//	  resources: [bypasses/bypass-0]
+//	tokens: /var/lib/gost/tokens.json
+//	audit: /var/log/gost/audit.log
+func loadAccess(path string) (*server.Access, error) {
+	access := &server.Access{}
+	if err := decodeFile(path, access); err != nil {
+		return nil, err
+	}
+	if err := access.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return access, nil
+}
+
+// decodeFile decodes the YAML file path into v, rejecting unknown fields.
+func decodeFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := decodeStrict(b, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeStrict decodes the YAML b into v, rejecting unknown fields.
+func decodeStrict(b []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/config/cmd"
+	metrics "github.com/go-gost/x/metrics/service"
+	xs "github.com/go-gost/x/selector"
+	"gopkg.in/yaml.v3"
+)
+
+// explainConfig writes cfg in YAML with the defaults filled in, each value
+// annotated with its source:
+//
+//	file:line         the config file defining it
+//	flag -L           a command line flag
+//	env NAME          an environment variable, set by the parser
+//	                  (GOST_API...) or referenced by ${NAME}
+//	default           a default applied when the config is loaded
+//
+// A value expanded from references is annotated with the file defining it
+// and the sources of the references.
+func explainConfig(cfg *config.Config) ([]byte, error) {
+	defaults := fillDefaults(cfg)
+
+	var buf bytes.Buffer
+	if err := cfg.Write(&buf, "yaml"); err != nil {
+		return nil, err
+	}
+	b, err := redact(buf.Bytes(), "yaml")
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return b, nil
+	}
+
+	files, _, err := resolveIncludes(cfgFiles)
+	if err != nil {
+		return nil, err
+	}
+	src, err := indexSources(files)
+	if err != nil {
+		return nil, err
+	}
+	e := &explainer{
+		src:       src,
+		defaults:  defaults,
+		overrides: overrides(),
+		other:     otherSources(),
+	}
+	if e.flags, err = flagResources(); err != nil {
+		return nil, err
+	}
+	e.annotate(doc.Content[0])
+
+	buf.Reset()
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	return buf.Bytes(), nil
+}
+
+type explainer struct {
+	src *sourceIndex
+	// defaults are the paths of the values filled by fillDefaults.
+	defaults map[string]bool
+	// overrides are the sources of the values set over the config files,
+	// by path prefix.
+	overrides []override
+	// flags are the sources of the resources built from -L and -F.
+	flags map[server.Resource]string
+	// other is the source of the values not found in the config files.
+	other string
+}
+
+type override struct {
+	prefix string
+	source string
+}
+
+// origin is where the values under a config node are read from: a node of
+// a config file, reached exactly or only up to an enclosing node, or
+// another source.
+type origin struct {
+	node   *yaml.Node
+	file   string
+	exact  bool
+	source string
+}
+
+func (o origin) child(key string) origin {
+	if o.node == nil || !o.exact {
+		return o
+	}
+	if v := mappingValue(o.node, key); v != nil {
+		o.node = v
+		return o
+	}
+	o.exact = false
+	return o
+}
+
+func (o origin) index(i int) origin {
+	if o.node == nil || !o.exact {
+		return o
+	}
+	if o.node.Kind == yaml.SequenceNode && i < len(o.node.Content) {
+		o.node = o.node.Content[i]
+		return o
+	}
+	o.exact = false
+	return o
+}
+
+func (e *explainer) annotate(root *yaml.Node) {
+	kinds := make(map[string]bool)
+	for _, kind := range server.Kinds() {
+		kinds[kind] = true
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section, v := root.Content[i].Value, root.Content[i+1]
+		if !kinds[section] || v.Kind != yaml.SequenceNode {
+			e.walk(v, section, e.sectionOrigin(section))
+			continue
+		}
+
+		seen := make(map[string]int)
+		for j, item := range v.Content {
+			var name string
+			if n := mappingValue(item, "name"); n != nil {
+				name = n.Value
+			}
+			e.walk(item, fmt.Sprintf("%s[%d]", section, j), e.resourceOrigin(section, name, seen[name]))
+			seen[name]++
+		}
+	}
+}
+
+func (e *explainer) walk(node *yaml.Node, path string, o origin) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			e.walk(node.Content[i+1], path+"."+key, o.child(key))
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			e.walk(n, fmt.Sprintf("%s[%d]", path, i), o.index(i))
+		}
+	case yaml.ScalarNode:
+		node.LineComment = e.source(strings.ToLower(path), node.Value, o)
+	}
+}
+
+func (e *explainer) source(path, value string, o origin) string {
+	for _, ov := range e.overrides {
+		if path == ov.prefix || strings.HasPrefix(path, ov.prefix+".") {
+			return ov.source
+		}
+	}
+	if e.defaults[path] {
+		return "default"
+	}
+	if o.node == nil {
+		return o.source
+	}
+
+	s := fmt.Sprintf("%s:%d", o.file, o.node.Line)
+	if o.exact {
+		if refs := referenceSources(value); refs != "" {
+			s += ", " + refs
+		}
+	}
+	return s
+}
+
+// resourceOrigin returns the origin of the n-th resource of kind named name.
+func (e *explainer) resourceOrigin(kind, name string, n int) origin {
+	for _, f := range e.src.files {
+		list := mappingValue(f.root, kind)
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range list.Content {
+			if v := mappingValue(item, "name"); v == nil || v.Value != name {
+				continue
+			}
+			if n > 0 {
+				n--
+				continue
+			}
+			return origin{node: item, file: f.name, exact: true}
+		}
+	}
+	if source, ok := e.flags[server.Resource{Kind: kind, Name: name}]; ok {
+		return origin{source: source}
+	}
+	return origin{source: e.other}
+}
+
+// sectionOrigin returns the origin of a top-level section. The section of
+// the last file defining it replaces the ones of the previous files.
+func (e *explainer) sectionOrigin(section string) origin {
+	for i := len(e.src.files) - 1; i >= 0; i-- {
+		f := e.src.files[i]
+		if v := mappingValue(f.root, section); v != nil {
+			return origin{node: v, file: f.name, exact: true}
+		}
+	}
+	return origin{source: e.other}
+}
+
+// referenceSources describes the sources of the references in a value.
+func referenceSources(s string) string {
+	var sources []string
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			break
+		}
+		escaped := i > 0 && s[i-1] == '$'
+		ref := s[i+2 : i+j]
+		s = s[i+j+1:]
+		if escaped {
+			continue
+		}
+
+		if path, ok := strings.CutPrefix(ref, "file:"); ok {
+			sources = append(sources, "file "+path)
+			continue
+		}
+		name, _, hasDef := strings.Cut(ref, ":-")
+		if hasDef && os.Getenv(name) == "" {
+			sources = append(sources, "default of "+name)
+			continue
+		}
+		sources = append(sources, "env "+name)
+	}
+	return strings.Join(sources, ", ")
+}
+
+// overrides returns the sources of the values the parser sets over the
+// config files, from the flags and the environment, by path prefix.
+func overrides() []override {
+	var ovs []override
+	switch {
+	case trace:
+		ovs = append(ovs, override{"log.level", "flag -DD"})
+	case debug:
+		ovs = append(ovs, override{"log.level", "flag -D"})
+	case os.Getenv("GOST_LOGGER_LEVEL") != "":
+		ovs = append(ovs, override{"log.level", "env GOST_LOGGER_LEVEL"})
+	}
+	if apiAddr != "" {
+		ovs = append(ovs, override{"api", "flag -api"})
+	} else if os.Getenv("GOST_API") != "" {
+		ovs = append(ovs, override{"api.addr", "env GOST_API"})
+	}
+	if metricsAddr != "" {
+		ovs = append(ovs, override{"metrics", "flag -metrics"})
+	} else if os.Getenv("GOST_METRICS") != "" {
+		ovs = append(ovs, override{"metrics.addr", "env GOST_METRICS"})
+	}
+	if os.Getenv("GOST_PROFILING") != "" {
+		ovs = append(ovs, override{"profiling.addr", "env GOST_PROFILING"})
+	}
+	return ovs
+}
+
+// flagResources returns the sources of the resources built from the -L and
+// -F flags: the services and what they need for -L, the chains for -F.
+func flagResources() (map[server.Resource]string, error) {
+	expandAll := func(args []string) []string {
+		var list []string
+		for _, arg := range args {
+			s, _ := expand(arg, func(ref, value string) {})
+			list = append(list, s)
+		}
+		return list
+	}
+	svcs, fwds := expandAll(services), expandAll(nodes)
+
+	resources := make(map[server.Resource]string)
+	for _, v := range []struct {
+		source string
+		nodes  []string
+	}{
+		{"flag -L", nil},
+		{"flag -F", fwds},
+	} {
+		cfg, err := cmd.BuildConfigFromCmd(svcs, v.nodes)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range server.Kinds() {
+			for _, c := range server.Configs(cfg, kind) {
+				key := server.Resource{Kind: kind, Name: server.ConfigName(c)}
+				if _, ok := resources[key]; !ok {
+					resources[key] = v.source
+				}
+			}
+		}
+	}
+	return resources, nil
+}
+
+// otherSources describes the config sources which are not local files.
+func otherSources() string {
+	var sources []string
+	for _, source := range cfgFiles {
+		source = strings.TrimSpace(source)
+		switch {
+		case source == "" || isLocalFile(source):
+		case source == "-":
+			sources = append(sources, "stdin")
+		case isURL(source):
+			if u, err := url.Parse(source); err == nil {
+				u.User = nil
+				source = u.String()
+			}
+			sources = append(sources, source)
+		default:
+			sources = append(sources, "inline JSON")
+		}
+	}
+	if len(sources) == 0 {
+		return "config"
+	}
+	return "-C " + strings.Join(sources, ", ")
+}
+
+// fillDefaults sets the values left empty in cfg to the defaults applied
+// when the config is loaded, and returns their paths in lower case.
+func fillDefaults(cfg *config.Config) map[string]bool {
+	defaults := make(map[string]bool)
+	setString := func(v *string, def, path string) {
+		if strings.TrimSpace(*v) == "" {
+			*v = def
+			defaults[strings.ToLower(path)] = true
+		}
+	}
+	setSelector := func(sel **config.SelectorConfig, path string) {
+		if *sel == nil {
+			*sel = &config.SelectorConfig{}
+		}
+		s := *sel
+		setString(&s.Strategy, "round", path+".strategy")
+		if s.MaxFails <= 0 {
+			s.MaxFails = xs.DefaultMaxFails
+			defaults[strings.ToLower(path+".maxFails")] = true
+		}
+		if s.FailTimeout <= 0 {
+			s.FailTimeout = xs.DefaultFailTimeout
+			defaults[strings.ToLower(path+".failTimeout")] = true
+		}
+	}
+	setHop := func(hop *config.HopConfig, path string) {
+		setSelector(&hop.Selector, path+".selector")
+		for i, node := range hop.Nodes {
+			if node == nil {
+				continue
+			}
+			nodePath := fmt.Sprintf("%s.nodes[%d]", path, i)
+			if node.Connector == nil {
+				node.Connector = &config.ConnectorConfig{}
+			}
+			setString(&node.Connector.Type, "http", nodePath+".connector.type")
+			if node.Dialer == nil {
+				node.Dialer = &config.DialerConfig{}
+			}
+			setString(&node.Dialer.Type, "tcp", nodePath+".dialer.type")
+		}
+	}
+
+	for i, svc := range cfg.Services {
+		if svc == nil {
+			continue
+		}
+		path := fmt.Sprintf("services[%d]", i)
+		if svc.Listener == nil {
+			svc.Listener = &config.ListenerConfig{}
+		}
+		setString(&svc.Listener.Type, "tcp", path+".listener.type")
+		if svc.Handler == nil {
+			svc.Handler = &config.HandlerConfig{}
+		}
+		setString(&svc.Handler.Type, "auto", path+".handler.type")
+
+		if g := svc.Handler.ChainGroup; g != nil {
+			setSelector(&g.Selector, path+".handler.chainGroup.selector")
+		}
+		if g := svc.Listener.ChainGroup; g != nil {
+			setSelector(&g.Selector, path+".listener.chainGroup.selector")
+		}
+		if f := svc.Forwarder; f != nil && f.Hop == "" {
+			setSelector(&f.Selector, path+".forwarder.selector")
+		}
+	}
+	for i, c := range cfg.Chains {
+		if c == nil {
+			continue
+		}
+		for j, hop := range c.Hops {
+			// The hops without nodes refer to the hops defined apart.
+			if hop != nil && (hop.Nodes != nil || hop.Plugin != nil) {
+				setHop(hop, fmt.Sprintf("chains[%d].hops[%d]", i, j))
+			}
+		}
+	}
+	for i, hop := range cfg.Hops {
+		if hop != nil {
+			setHop(hop, fmt.Sprintf("hops[%d]", i))
+		}
+	}
+
+	if cfg.Log == nil {
+		cfg.Log = &config.LogConfig{}
+	}
+	setString(&cfg.Log.Level, "info", "log.level")
+	setString(&cfg.Log.Format, "json", "log.format")
+	setString(&cfg.Log.Output, "stderr", "log.output")
+
+	if cfg.Profiling != nil {
+		setString(&cfg.Profiling.Addr, server.DefaultProfilingAddr, "profiling.addr")
+	}
+	if cfg.Metrics != nil {
+		setString(&cfg.Metrics.Path, metrics.DefaultPath, "metrics.path")
+	}
+
+	return defaults
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-gost/gost/server"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveIncludes returns the config sources with the files included by
+// the local config files inserted after them, recursively, and the include
+// patterns as absolute paths.
+//
+// A config file includes other files with the include top-level key, a
+// path or a list of paths, which may be glob patterns (e.g. conf.d/*.yaml)
+// and are relative to the including file. A file included several times is
+// read once. A path without pattern must exist.
+func resolveIncludes(sources []string) (files []string, patterns []string, err error) {
+	seen := make(map[string]bool)
+
+	var visit func(file string, stack []string) error
+	visit = func(file string, stack []string) error {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if seen[abs] {
+			return nil
+		}
+		seen[abs] = true
+		files = append(files, file)
+
+		includes, err := readIncludes(file)
+		if err != nil {
+			return err
+		}
+		for _, inc := range includes {
+			pattern := inc
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(file), pattern)
+			}
+			if p, err := filepath.Abs(pattern); err == nil {
+				patterns = append(patterns, p)
+			}
+
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: include %q: %w", file, inc, err)
+			}
+			if len(matches) == 0 && !hasGlobMeta(inc) {
+				return fmt.Errorf("%s: include %q: %w", file, inc, os.ErrNotExist)
+			}
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+					continue
+				}
+				if a, _ := filepath.Abs(m); a == abs || slices.Contains(stack, a) {
+					return fmt.Errorf("%s: include %q: cycle through %s", file, inc, m)
+				}
+				if err := visit(m, append(stack, abs)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		if !isLocalFile(source) {
+			files = append(files, source)
+			continue
+		}
+		if err := visit(source, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, patterns, nil
+}
+
+// readIncludes returns the paths of the include key of a config file.
+// Syntax errors are left to the config parsing.
+func readIncludes(file string) ([]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil || len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	v := mappingValue(root.Content[0], "include")
+	if v == nil {
+		return nil, nil
+	}
+	switch v.Kind {
+	case yaml.ScalarNode:
+		if v.Tag == "!!null" {
+			return nil, nil
+		}
+		return []string{v.Value}, nil
+	case yaml.SequenceNode:
+		var paths []string
+		for _, n := range v.Content {
+			if n.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s:%d:%d: include: expected a path", file, n.Line, n.Column)
+			}
+			paths = append(paths, n.Value)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("%s:%d:%d: include: expected a path or a list of paths", file, v.Line, v.Column)
+	}
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// checkConflicts reports the resources defined in more than one config
+// file. The config files are merged, each resource must be defined once.
+func checkConflicts(src *sourceIndex) error {
+	defs := make(map[server.Resource]position)
+
+	var errs []error
+	for _, f := range src.files {
+		for _, kind := range server.Kinds() {
+			list := mappingValue(f.root, kind)
+			if list == nil || list.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, item := range list.Content {
+				n := mappingValue(item, "name")
+				if n == nil || n.Value == "" {
+					continue
+				}
+				key := server.Resource{Kind: kind, Name: n.Value}
+				pos := position{file: f.name, line: n.Line, col: n.Column}
+
+				prev, ok := defs[key]
+				if !ok {
+					defs[key] = pos
+					continue
+				}
+				if prev.file != pos.file {
+					errs = append(errs, &configError{
+						pos:  pos,
+						key:  &key,
+						path: "name",
+						msg:  fmt.Sprintf("already defined at %s", prev),
+					})
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
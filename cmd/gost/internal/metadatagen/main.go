@@ -0,0 +1,300 @@
+// Command metadatagen generates the table of the metadata keys read by the
+// handlers, listeners, dialers and connectors registered by gost, for the
+// config schema.
+//
+// The packages imported by the components package are parsed for their
+// registrations (registry.HandlerRegistry().Register("http", ...)) and for
+// the metadata they read with the x/metadata/util getters
+// (mdutil.GetDuration(md, "readTimeout")). Keys given by string constants
+// are resolved, other ones are skipped.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	xModule  = "github.com/go-gost/x/"
+	utilPath = "github.com/go-gost/x/metadata/util"
+)
+
+// registries maps the registry functions to the component kinds.
+var registries = map[string]string{
+	"HandlerRegistry":   "handler",
+	"ListenerRegistry":  "listener",
+	"DialerRegistry":    "dialer",
+	"ConnectorRegistry": "connector",
+}
+
+// getters maps the x/metadata/util getters to the kinds of values.
+var getters = map[string]string{
+	"GetBool":            "bool",
+	"GetInt":             "int",
+	"GetFloat":           "float",
+	"GetDuration":        "duration",
+	"GetString":          "string",
+	"GetStrings":         "strings",
+	"GetStringMap":       "map",
+	"GetStringMapString": "map",
+}
+
+type key struct {
+	name, kind string
+}
+
+func main() {
+	var (
+		register = flag.String("register", "../../components/components.go", "file importing the registered components")
+		output   = flag.String("o", "metadata_gen.go", "output file")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("metadatagen: ")
+
+	pkgs, err := importedPackages(*register)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dirs, err := packageDirs(pkgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// kind -> type -> keys
+	table := make(map[string]map[string][]key)
+	for _, pkg := range pkgs {
+		types, keys, err := scanPackage(dirs[pkg])
+		if err != nil {
+			log.Fatalf("%s: %v", pkg, err)
+		}
+		for kind, names := range types {
+			if table[kind] == nil {
+				table[kind] = make(map[string][]key)
+			}
+			for _, name := range names {
+				table[kind][name] = append(table[kind][name], keys...)
+			}
+		}
+	}
+
+	b, err := generate(table)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, b, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// importedPackages returns the component packages of x imported by file.
+func importedPackages(file string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []string
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		rest, ok := strings.CutPrefix(path, xModule)
+		if !ok {
+			continue
+		}
+		switch strings.SplitN(rest, "/", 2)[0] {
+		case "handler", "listener", "dialer", "connector":
+			pkgs = append(pkgs, path)
+		}
+	}
+	return pkgs, nil
+}
+
+// packageDirs returns the source directories of pkgs, as resolved by the
+// go command for the current module.
+func packageDirs(pkgs []string) (map[string]string, error) {
+	cmd := exec.Command("go", append([]string{"list", "-f", "{{.ImportPath}} {{.Dir}}"}, pkgs...)...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+
+	dirs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if pkg, dir, ok := strings.Cut(line, " "); ok {
+			dirs[pkg] = dir
+		}
+	}
+	return dirs, nil
+}
+
+// scanPackage returns the component types registered by the package in
+// dir, by kind, and the metadata keys it reads.
+func scanPackage(dir string) (map[string][]string, []key, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	consts := make(map[string]string)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed = append(parsed, f)
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			decl, ok := n.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				return true
+			}
+			for _, spec := range decl.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if i < len(vs.Values) {
+						if s, ok := stringLit(vs.Values[i]); ok {
+							consts[name.Name] = s
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	types := make(map[string][]string)
+	var keys []key
+	seen := make(map[string]bool)
+	for _, f := range parsed {
+		util := importName(f, utilPath)
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			// registry.HandlerRegistry().Register("http", NewHandler)
+			if sel.Sel.Name == "Register" && len(call.Args) == 2 {
+				if inner, ok := sel.X.(*ast.CallExpr); ok {
+					if fn, ok := inner.Fun.(*ast.SelectorExpr); ok {
+						if kind, ok := registries[fn.Sel.Name]; ok {
+							if name, ok := stringLit(call.Args[0]); ok {
+								types[kind] = append(types[kind], name)
+							}
+						}
+					}
+				}
+				return true
+			}
+
+			// mdutil.GetDuration(md, "readTimeout")
+			x, ok := sel.X.(*ast.Ident)
+			if !ok || util == "" || x.Name != util {
+				return true
+			}
+			kind, ok := getters[sel.Sel.Name]
+			if !ok || len(call.Args) < 2 {
+				return true
+			}
+			for _, arg := range call.Args[1:] {
+				name, ok := stringLit(arg)
+				if !ok {
+					if id, isIdent := arg.(*ast.Ident); isIdent {
+						name, ok = consts[id.Name]
+					}
+				}
+				if !ok || name == "" || seen[name] {
+					continue
+				}
+				seen[name] = true
+				keys = append(keys, key{name: name, kind: kind})
+			}
+			return true
+		})
+	}
+	return types, keys, nil
+}
+
+// importName returns the name path is imported as in f, if it is.
+func importName(f *ast.File, path string) string {
+	for _, spec := range f.Imports {
+		if p, _ := strconv.Unquote(spec.Path.Value); p != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return filepath.Base(path)
+	}
+	return ""
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+func generate(table map[string]map[string][]key) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by metadatagen; DO NOT EDIT.\n\n")
+	buf.WriteString("package main\n\n")
+	buf.WriteString("// knownMetadata are the metadata keys read by the registered components,\n")
+	buf.WriteString("// by component kind and type.\n")
+	buf.WriteString("var knownMetadata = map[string]map[string][]metadataKey{\n")
+
+	for _, kind := range sortedKeys(table) {
+		fmt.Fprintf(&buf, "%q: {\n", kind)
+		for _, typ := range sortedKeys(table[kind]) {
+			keys := table[kind][typ]
+			if len(keys) == 0 {
+				continue
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i].name < keys[j].name })
+			fmt.Fprintf(&buf, "%q: {\n", typ)
+			for _, k := range keys {
+				fmt.Fprintf(&buf, "{%q, %q},\n", k.name, k.kind)
+			}
+			buf.WriteString("},\n")
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/config/cmd"
+	"github.com/go-gost/x/config/parsing/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// parseConfig parses the config sources (config files and -L, -F, -api
+// and -metrics arguments) with the references in their strings expanded:
+//
+//	${NAME}          the environment variable NAME, which must be set
+//	${NAME:-default} the environment variable NAME, or default if it is
+//	                 unset or empty
+//	${file:path}     the content of the file, without trailing newlines
+//
+// $${ is a literal ${, as is a reference to no valid name, e.g. the ${1}
+// of a regular expression. The replacements of the HTTP rewrite rules are
+// not expanded, they are regexp templates whose $ are their own.
+//
+// The config read from sources other than local files (URLs, stdin and
+// inline JSON) can only reference the files the local config files do, so
+// that a remote config can not read a local file.
+//
+// The arguments are expanded before they are parsed, the config files once
+// they are, each source once.
+func parseConfig() (*config.Config, error) {
+	var files []string
+	// sourced is set when a reference is resolved from the environment or
+	// from a file, rather than to its default.
+	var sourced bool
+	record := func(ref, value string) {
+		if path, ok := strings.CutPrefix(ref[2:len(ref)-1], "file:"); ok {
+			files = append(files, path)
+		}
+		sourced = true
+	}
+
+	// The values of the references of the arguments, to redact the
+	// strings of the resources built from them.
+	var argRefs []expansion
+	expandArgs := func(name string, args []string) ([]string, error) {
+		var list []string
+		for _, arg := range args {
+			s, err := expand(arg, func(ref, value string) {
+				record(ref, value)
+				if value != "" {
+					argRefs = append(argRefs, expansion{value: value, template: ref})
+				}
+			})
+			if err != nil {
+				return nil, fmt.Errorf("-%s: %w", name, err)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+
+	svcs, err := expandArgs("L", services)
+	if err != nil {
+		return nil, err
+	}
+	fwds, err := expandArgs("F", nodes)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := expandArgs("api", []string{apiAddr, metricsAddr})
+	if err != nil {
+		return nil, err
+	}
+
+	// The included files are merged in place of the include keys, and two
+	// files can not define the same resource.
+	sources, _, err := resolveIncludes(cfgFiles)
+	if err != nil {
+		return nil, err
+	}
+	src, err := indexSources(sources)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConflicts(src); err != nil {
+		return nil, err
+	}
+	// The files the references may read, all of them with only local
+	// config files.
+	var readable map[string]bool
+	if slices.ContainsFunc(sources, func(s string) bool { return !isLocalFile(strings.TrimSpace(s)) }) {
+		readable = src.fileRefs()
+	}
+	blocks, err := readTLS(src, record)
+	if err != nil {
+		return nil, err
+	}
+
+	parser.Init(parser.Args{
+		CfgFiles:    sources,
+		Services:    svcs,
+		Nodes:       fwds,
+		Debug:       debug,
+		Trace:       trace,
+		ApiAddr:     addrs[0],
+		MetricsAddr: addrs[1],
+	})
+	cfg, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	fromArgs, err := argConfigs(cfg, svcs, fwds)
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(map[fieldRef]expansion)
+	err = mapFields(cfg, func(ref fieldRef, c any) bool {
+		if !fromArgs[c] {
+			return true
+		}
+		// Already expanded, the strings containing the values of the
+		// references are recorded.
+		mapStrings(reflect.ValueOf(c), "", func(path, s string) (string, error) {
+			t := s
+			for _, e := range argRefs {
+				t = strings.ReplaceAll(t, e.value, e.template)
+			}
+			if t != s {
+				table[ref.field(path)] = expansion{value: s, template: t}
+			}
+			return s, nil
+		})
+		return false
+	}, func(ref fieldRef, path, s string) (string, error) {
+		if isReplacement(path) {
+			return s, nil
+		}
+		if readable != nil {
+			for _, file := range fileRefs(s) {
+				if !readable[file] {
+					return "", fmt.Errorf("%s: ${file:%s}: not referenced by a local config file", path, file)
+				}
+			}
+		}
+		sourced = false
+		v, err := expand(s, record)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		if sourced && v != "" {
+			table[ref] = expansion{value: v, template: s}
+		}
+		return v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expansions.set(table)
+	referencedFiles.set(files)
+	managementTLS.set(blocks)
+
+	return cfg, nil
+}
+
+// argConfigs returns the configs of cfg built from the -L, -F, -api and
+// -metrics arguments. The resources built from -L and -F are the last
+// ones of each section, after the ones of the config files.
+func argConfigs(cfg *config.Config, svcs, fwds []string) (map[any]bool, error) {
+	cmdCfg, err := cmd.BuildConfigFromCmd(svcs, fwds)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[any]bool)
+	v, cv := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(cmdCfg).Elem()
+	for i := range v.NumField() {
+		if v.Field(i).Kind() != reflect.Slice {
+			continue
+		}
+		list, n := v.Field(i), cv.Field(i).Len()
+		for j := max(list.Len()-n, 0); j < list.Len(); j++ {
+			configs[list.Index(j).Interface()] = true
+		}
+	}
+	if apiAddr != "" && cfg.API != nil {
+		configs[cfg.API] = true
+	}
+	if metricsAddr != "" && cfg.Metrics != nil {
+		configs[cfg.Metrics] = true
+	}
+	return configs, nil
+}
+
+// fieldRef locates a string of a config: by the section and name of its
+// resource and its path in the resource for the resources, by its path
+// for the other sections (e.g. api.auth.password). The paths are in lower
+// case, the keys of the config written as YAML being.
+type fieldRef struct {
+	section string
+	name    string
+	path    string
+}
+
+func (r fieldRef) field(path string) fieldRef {
+	if r.path != "" && path != "" {
+		path = r.path + "." + path
+	} else if path == "" {
+		path = r.path
+	}
+	r.path = strings.ToLower(path)
+	return r
+}
+
+func (r fieldRef) index(i int) fieldRef {
+	r.path = fmt.Sprintf("%s[%d]", r.path, i)
+	return r
+}
+
+// resourceName is the name of the i-th resource of a section, its index
+// if it has no name.
+func resourceName(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("#%d", i)
+	}
+	return name
+}
+
+// mapFields calls fn with each string of cfg, located by its fieldRef and
+// its path (e.g. services[0].handler.auth.password), and replaces it by the
+// result. visit is called first with each resource and section, which is
+// skipped if it returns false.
+func mapFields(cfg *config.Config, visit func(ref fieldRef, c any) bool, fn func(ref fieldRef, path, s string) (string, error)) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		f := v.Field(i)
+
+		if f.Kind() != reflect.Slice {
+			if f.Kind() == reflect.Pointer && f.IsNil() {
+				continue
+			}
+			ref := fieldRef{path: strings.ToLower(name)}
+			if !visit(ref, f.Interface()) {
+				continue
+			}
+			err := mapStrings(f, name, func(path, s string) (string, error) {
+				return fn(ref.field(strings.TrimPrefix(path, name+".")), path, s)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for j := range f.Len() {
+			e := f.Index(j)
+			if e.Kind() == reflect.Pointer && e.IsNil() {
+				continue
+			}
+			ref := fieldRef{section: strings.ToLower(name), name: resourceName(server.ConfigName(e.Interface()), j)}
+			if !visit(ref, e.Interface()) {
+				continue
+			}
+			prefix := fmt.Sprintf("%s[%d]", name, j)
+			err := mapStrings(e, prefix, func(path, s string) (string, error) {
+				return fn(ref.field(strings.TrimPrefix(path, prefix+".")), path, s)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces the references in s by their values, and calls record
+// with each reference resolved from the environment or from a file, and
+// its value.
+func expand(s string, record func(ref, value string)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("unterminated reference %q", s[i:])
+		}
+		ref := s[i : i+j+1]
+		if name, _, _ := strings.Cut(ref[2:len(ref)-1], ":-"); !strings.HasPrefix(name, "file:") && !isEnvName(name) {
+			b.WriteString(ref)
+			s = s[i+j+1:]
+			continue
+		}
+		v, def, err := resolve(ref[2 : len(ref)-1])
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		if !def {
+			record(ref, v)
+		}
+		b.WriteString(v)
+		s = s[i+j+1:]
+	}
+	return b.String(), nil
+}
+
+// resolve returns the value of a reference, and whether it is its default.
+func resolve(ref string) (value string, isDefault bool, err error) {
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(b), "\r\n"), false, nil
+	}
+
+	name, def, hasDef := strings.Cut(ref, ":-")
+	v, ok := os.LookupEnv(name)
+	if v == "" && hasDef {
+		return def, true, nil
+	}
+	if !ok {
+		return "", false, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, false, nil
+}
+
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isReplacement reports whether path is the replacement of a rule
+// rewriting HTTP URLs or bodies (rewrite, rewriteURL, rewriteBody,
+// rewriteRequestBody or rewriteResponseBody).
+func isReplacement(path string) bool {
+	rule, ok := strings.CutSuffix(path, ".Replacement")
+	if !ok {
+		return false
+	}
+	rule = rule[strings.LastIndexByte(rule, '.')+1:]
+	return strings.HasPrefix(rule, "rewrite")
+}
+
+// fileRefs returns the paths of the ${file:path} references of s.
+func fileRefs(s string) []string {
+	var paths []string
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			return paths
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return paths
+		}
+		if i == 0 || s[i-1] != '$' {
+			if path, ok := strings.CutPrefix(s[i+2:i+j], "file:"); ok {
+				paths = append(paths, path)
+			}
+		}
+		s = s[i+j+1:]
+	}
+}
+
+// fileRefs returns the paths of the ${file:path} references of the strings
+// of the local config files.
+func (idx *sourceIndex) fileRefs() map[string]bool {
+	refs := make(map[string]bool)
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.ScalarNode {
+			for _, path := range fileRefs(n.Value) {
+				refs[path] = true
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	for _, f := range idx.files {
+		walk(f.root)
+	}
+	return refs
+}
+
+// mapStrings replaces every string reachable from v by the result of fn,
+// called with its path (e.g. services[0].handler.auth.password).
+func mapStrings(v reflect.Value, path string, fn func(path, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return mapStrings(v.Elem(), path, fn)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		// The value of an interface can not be set in place.
+		e := reflect.New(v.Elem().Type()).Elem()
+		e.Set(v.Elem())
+		if err := mapStrings(e, path, fn); err != nil {
+			return err
+		}
+		if v.CanSet() {
+			v.Set(e)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := mapStrings(v.Field(i), name, fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := mapStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			if err := mapStrings(e, fmt.Sprintf("%s.%v", path, k), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+
+	case reflect.String:
+		s, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		if v.CanSet() {
+			v.SetString(s)
+		}
+	}
+	return nil
+}
+
+// expansions are the strings of the configs expanded from references to
+// the environment or to files, so that they can be redacted. The strings
+// expanded from defaults only are not, they are no secrets.
+var expansions = &expansionTable{}
+
+// expansion is a string expanded from template.
+type expansion struct {
+	value    string
+	template string
+}
+
+// expansionTable holds the expansions of the last two parsed configs, by
+// location: the running config is the previous one when the last one
+// failed to load.
+type expansionTable struct {
+	last, prev map[fieldRef]expansion
+	mu         sync.RWMutex
+}
+
+func (t *expansionTable) set(m map[fieldRef]expansion) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prev, t.last = t.last, m
+}
+
+// lookup returns the template value at ref is expanded from.
+func (t *expansionTable) lookup(ref fieldRef, value string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, m := range []map[fieldRef]expansion{t.last, t.prev} {
+		if e, ok := m[ref]; ok && e.value == value {
+			return e.template, true
+		}
+	}
+	return "", false
+}
+
+// referencedFiles are the files referenced by the last parsed config.
+var referencedFiles = &fileList{}
+
+type fileList struct {
+	files []string
+	mu    sync.Mutex
+}
+
+func (l *fileList) set(files []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.files = files
+}
+
+func (l *fileList) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var files []string
+	for _, f := range l.files {
+		if abs, err := filepath.Abs(f); err == nil {
+			files = append(files, abs)
+		}
+	}
+	return files
+}
+
+// redact replaces the values expanded from references by the references
+// in a config written in format, as YAML or JSON. The tls blocks of the
+// management services, not in the config types, are added back.
+func redact(b []byte, format string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return b, nil
+	}
+	redactRoot(doc.Content[0])
+	managementTLS.insert(doc.Content[0])
+
+	if format == "json" {
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, doc.Content[0]); err != nil {
+			return nil, err
+		}
+		// Keep the layout of the document.
+		if bytes.Contains(bytes.TrimSpace(b), []byte("\n")) {
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+				return nil, err
+			}
+			buf = indented
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	return buf.Bytes(), nil
+}
+
+// redactRoot redacts the strings of a config document, located as
+// mapFields does.
+func redactRoot(root *yaml.Node) {
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, v := strings.ToLower(root.Content[i].Value), root.Content[i+1]
+		if v.Kind != yaml.SequenceNode {
+			redactNode(v, fieldRef{path: name})
+			continue
+		}
+		for j, item := range v.Content {
+			var rname string
+			if item.Kind == yaml.MappingNode {
+				for k := 0; k+1 < len(item.Content); k += 2 {
+					if item.Content[k].Value == "name" {
+						rname = item.Content[k+1].Value
+					}
+				}
+			}
+			redactNode(item, fieldRef{section: name, name: resourceName(rname, j)})
+		}
+	}
+}
+
+func redactNode(node *yaml.Node, ref fieldRef) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			redactNode(node.Content[i+1], ref.field(node.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			redactNode(n, ref.index(i))
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			return
+		}
+		if template, ok := expansions.lookup(ref, node.Value); ok {
+			node.Value = template
+			node.Style = 0
+		}
+	}
+}
+
+// writeJSON writes a node parsed from a JSON document as JSON.
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONString(buf, node.Content[i].Value); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, n := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, n); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!str":
+			return writeJSONString(buf, node.Value)
+		case "!!int", "!!float", "!!bool", "!!null":
+			buf.WriteString(node.Value)
+		default:
+			return fmt.Errorf("unexpected %s value %s", node.Tag, strconv.Quote(node.Value))
+		}
+	default:
+		return fmt.Errorf("unexpected node kind %v", node.Kind)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/limiter"
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+	mdata "github.com/go-gost/core/metadata"
+	admission "github.com/go-gost/x/admission/wrapper"
+	xctx "github.com/go-gost/x/ctx"
+	climiter "github.com/go-gost/x/limiter/conn/wrapper"
+	traffic_limiter "github.com/go-gost/x/limiter/traffic"
+	limiter_wrapper "github.com/go-gost/x/limiter/traffic/wrapper"
+	mdutil "github.com/go-gost/x/metadata/util"
+	metrics "github.com/go-gost/x/metrics/wrapper"
+	stats "github.com/go-gost/x/observer/stats/wrapper"
+	"github.com/go-gost/x/registry"
+	proxyproto "github.com/pires/go-proxyproto"
+)
+
+// registerListeners replaces the tcp and udp listeners with ones that can
+// take over the sockets passed to the process (by a previous process on
+// upgrade, or by systemd socket activation), and that record their sockets
+// so that they can be handed over in turn. They behave like the listeners
+// they replace otherwise.
+//
+// The other listeners (tls, ws, kcp, ...) bind their sockets themselves,
+// which the upstream listeners give no way to pass or take over. Their
+// services are not handed over on upgrade: they stop listening once the
+// previous process releases its resources, until the new one listens again,
+// and refuse connections meanwhile.
+func registerListeners() {
+	r := registry.ListenerRegistry()
+	r.Unregister("tcp")
+	r.Register("tcp", newTCPListener)
+	r.Unregister("udp")
+	r.Register("udp", newUDPListener)
+}
+
+// filer is a socket which can be duplicated as a file.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// listenSocket is the socket a service listens on.
+type listenSocket struct {
+	service string
+	network string
+	// addr is the listen address as configured.
+	addr string
+	s    filer
+}
+
+// sockets records the listening sockets of the services.
+var sockets = &socketTable{
+	m: make(map[string]*listenSocket),
+}
+
+type socketTable struct {
+	m  map[string]*listenSocket
+	mu sync.Mutex
+}
+
+func (t *socketTable) add(s *listenSocket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.m[s.service] = s
+}
+
+func (t *socketTable) remove(service string, s filer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if v := t.m[service]; v != nil && v.s == s {
+		delete(t.m, service)
+	}
+}
+
+func (t *socketTable) list() []*listenSocket {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ss []*listenSocket
+	for _, s := range t.m {
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+// takeSocket returns the socket passed to the process for the service,
+// either by the previous process on upgrade or by the service manager,
+// and nil if there is none.
+func takeSocket(service, network, addr string) *os.File {
+	if f := inherited.take(service, network, addr); f != nil {
+		return f
+	}
+	return activated.take(service, network, addr)
+}
+
+// isIPv4 reports whether address is an IPv4 host address, rather than a
+// port only or an IPv6 address.
+func isIPv4(address string) bool {
+	return address != "" && address[0] != ':' && address[0] != '['
+}
+
+type tcpListener struct {
+	ln      net.Listener
+	raw     *net.TCPListener
+	logger  logger.Logger
+	md      tcpMetadata
+	options listener.Options
+}
+
+type tcpMetadata struct {
+	mptcp     bool
+	reuseport bool
+
+	keepalive         bool
+	keepaliveIdle     time.Duration
+	keepaliveInterval time.Duration
+	keepaliveCount    int
+}
+
+func newTCPListener(opts ...listener.Option) listener.Listener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &tcpListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *tcpListener) parseMetadata(md mdata.Metadata) (err error) {
+	l.md.mptcp = mdutil.GetBool(md, "mptcp")
+	l.md.reuseport = mdutil.GetBool(md, "reuseport")
+
+	l.md.keepalive = mdutil.GetBool(md, "keepalive")
+	l.md.keepaliveIdle = mdutil.GetDuration(md, "keepalive.idle")
+	l.md.keepaliveInterval = mdutil.GetDuration(md, "keepalive.interval")
+	l.md.keepaliveCount = mdutil.GetInt(md, "keepalive.count")
+	return
+}
+
+func (l *tcpListener) Init(md mdata.Metadata) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	network := "tcp"
+	if isIPv4(l.options.Addr) {
+		network = "tcp4"
+	}
+
+	var ln net.Listener
+	if f := takeSocket(l.options.Service, network, l.options.Addr); f != nil {
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return
+		}
+		l.logger.Debugf("inherited socket %s adopted", ln.Addr())
+	} else {
+		lc := net.ListenConfig{}
+		if l.md.mptcp {
+			lc.SetMultipathTCP(true)
+			l.logger.Debugf("mptcp enabled: %v", lc.MultipathTCP())
+		}
+		if l.md.reuseport {
+			lc.Control = l.setReusePort
+		}
+		ln, err = lc.Listen(context.Background(), network, l.options.Addr)
+		if err != nil {
+			return
+		}
+	}
+
+	if raw, ok := ln.(*net.TCPListener); ok {
+		l.raw = raw
+		sockets.add(&listenSocket{
+			service: l.options.Service,
+			network: network,
+			addr:    l.options.Addr,
+			s:       raw,
+		})
+	}
+
+	if l.md.keepalive {
+		ln = &keepaliveListener{
+			Listener: ln,
+			cfg: net.KeepAliveConfig{
+				Enable:   true,
+				Idle:     l.md.keepaliveIdle,
+				Interval: l.md.keepaliveInterval,
+				Count:    l.md.keepaliveCount,
+			},
+		}
+		l.logger.Debugf("tcp keepalive enabled: idle=%v interval=%v count=%d",
+			l.md.keepaliveIdle, l.md.keepaliveInterval, l.md.keepaliveCount)
+	}
+
+	l.logger.Debugf("pp: %d", l.options.ProxyProtocol)
+
+	ln = wrapProxyProtoListener(l.options.ProxyProtocol, ln, 10*time.Second)
+	ln = metrics.WrapListener(l.options.Service, ln)
+	ln = stats.WrapListener(ln, l.options.Stats)
+	ln = admission.WrapListener(l.options.Service, l.options.Admission, ln)
+	ln = limiter_wrapper.WrapListener(l.options.Service, ln, l.options.TrafficLimiter)
+	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
+	l.ln = ln
+
+	return
+}
+
+func (l *tcpListener) Accept() (conn net.Conn, err error) {
+	conn, err = l.ln.Accept()
+	if err != nil {
+		return
+	}
+
+	conn = limiter_wrapper.WrapConn(
+		conn,
+		l.options.TrafficLimiter,
+		conn.RemoteAddr().String(),
+		limiter.ScopeOption(limiter.ScopeConn),
+		limiter.ServiceOption(l.options.Service),
+		limiter.NetworkOption(conn.LocalAddr().Network()),
+		limiter.SrcOption(conn.RemoteAddr().String()),
+	)
+
+	return
+}
+
+func (l *tcpListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *tcpListener) Close() error {
+	if l.raw != nil {
+		sockets.remove(l.options.Service, l.raw)
+	}
+	return l.ln.Close()
+}
+
+type keepaliveListener struct {
+	net.Listener
+	cfg net.KeepAliveConfig
+}
+
+func (l *keepaliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetKeepAliveConfig(l.cfg)
+	}
+	return conn, nil
+}
+
+// wrapProxyProtoListener parses the PROXY protocol header of the accepted
+// connections if ppv > 0.
+func wrapProxyProtoListener(ppv int, ln net.Listener, readHeaderTimeout time.Duration) net.Listener {
+	if ppv <= 0 {
+		return ln
+	}
+	return &proxyProtoListener{
+		Listener: &proxyproto.Listener{
+			Listener:          ln,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+type proxyProtoListener struct {
+	net.Listener
+}
+
+func (ln *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if c, ok := conn.(xctx.Context); ok {
+		if v := c.Context(); v != nil {
+			ctx = v
+		}
+	}
+	ctx = xctx.ContextWithSrcAddr(ctx, conn.RemoteAddr())
+	ctx = xctx.ContextWithDstAddr(ctx, conn.LocalAddr())
+	return &proxyProtoConn{Conn: conn, ctx: ctx}, nil
+}
+
+// proxyProtoConn is a connection with a PROXY protocol header. The
+// addresses given by the header are kept in its context, while its own
+// addresses are the ones of the underlying connection.
+type proxyProtoConn struct {
+	net.Conn
+	ctx context.Context
+}
+
+// UnwrapConn returns the underlying connection.
+func (c *proxyProtoConn) UnwrapConn() net.Conn {
+	return c.Conn
+}
+
+func (c *proxyProtoConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	if conn, ok := c.Conn.(*proxyproto.Conn); ok {
+		return conn.Raw().RemoteAddr()
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtoConn) LocalAddr() net.Addr {
+	if conn, ok := c.Conn.(*proxyproto.Conn); ok {
+		return conn.Raw().LocalAddr()
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyProtoConn) CloseRead() error {
+	if sc, ok := c.Conn.(interface{ CloseRead() error }); ok {
+		return sc.CloseRead()
+	}
+	if conn, ok := c.Conn.(*proxyproto.Conn); ok {
+		if tc, ok := conn.TCPConn(); ok {
+			return tc.CloseRead()
+		}
+	}
+	return errors.ErrUnsupported
+}
+
+func (c *proxyProtoConn) CloseWrite() error {
+	if sc, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return sc.CloseWrite()
+	}
+	if conn, ok := c.Conn.(*proxyproto.Conn); ok {
+		if tc, ok := conn.TCPConn(); ok {
+			return tc.CloseWrite()
+		}
+	}
+	return errors.ErrUnsupported
+}
+
+type udpListener struct {
+	ln      net.Listener
+	raw     *net.UDPConn
+	logger  logger.Logger
+	md      udpMetadata
+	options listener.Options
+}
+
+type udpMetadata struct {
+	readBufferSize int
+	readQueueSize  int
+	backlog        int
+	keepalive      bool
+	ttl            time.Duration
+	stateless      bool
+}
+
+func newUDPListener(opts ...listener.Option) listener.Listener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &udpListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *udpListener) parseMetadata(md mdata.Metadata) (err error) {
+	l.md.backlog = mdutil.GetInt(md, "backlog")
+	if l.md.backlog <= 0 {
+		l.md.backlog = 128
+	}
+	l.md.keepalive = mdutil.GetBool(md, "keepalive")
+	l.md.ttl = mdutil.GetDuration(md, "ttl", "keepalive.ttl")
+	if l.md.ttl <= 0 {
+		l.md.ttl = 5 * time.Second
+	}
+	l.md.readBufferSize = mdutil.GetInt(md, "readBufferSize", "udp.bufferSize")
+	if l.md.readBufferSize <= 0 {
+		l.md.readBufferSize = 4096
+	}
+	l.md.readQueueSize = mdutil.GetInt(md, "readQueueSize", "recvQueueSize")
+	if l.md.readQueueSize <= 0 {
+		l.md.readQueueSize = 128
+	}
+	l.md.stateless = mdutil.GetBool(md, "stateless")
+	return
+}
+
+func (l *udpListener) Init(md mdata.Metadata) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	network := "udp"
+	if isIPv4(l.options.Addr) {
+		network = "udp4"
+	}
+
+	var raw *net.UDPConn
+	if f := takeSocket(l.options.Service, network, l.options.Addr); f != nil {
+		var pc net.PacketConn
+		pc, err = net.FilePacketConn(f)
+		f.Close()
+		if err != nil {
+			return
+		}
+		var ok bool
+		if raw, ok = pc.(*net.UDPConn); !ok {
+			pc.Close()
+			return errors.New("inherited socket is not a UDP socket")
+		}
+		l.logger.Debugf("inherited socket %s adopted", raw.LocalAddr())
+	} else {
+		var laddr *net.UDPAddr
+		laddr, err = net.ResolveUDPAddr(network, l.options.Addr)
+		if err != nil {
+			return
+		}
+		raw, err = net.ListenUDP(network, laddr)
+		if err != nil {
+			return
+		}
+	}
+
+	l.raw = raw
+	sockets.add(&listenSocket{
+		service: l.options.Service,
+		network: network,
+		addr:    l.options.Addr,
+		s:       raw,
+	})
+
+	var conn net.PacketConn = raw
+	conn = metrics.WrapPacketConn(l.options.Service, conn)
+	conn = stats.WrapPacketConn(conn, l.options.Stats)
+	conn = admission.WrapPacketConn(l.options.Admission, conn)
+	conn = limiter_wrapper.WrapPacketConn(
+		conn,
+		l.options.TrafficLimiter,
+		traffic_limiter.ServiceLimitKey,
+		limiter.ScopeOption(limiter.ScopeService),
+		limiter.ServiceOption(l.options.Service),
+		limiter.NetworkOption(conn.LocalAddr().Network()),
+	)
+
+	l.ln = newUDPSessionListener(conn, &udpSessionConfig{
+		backlog:        l.md.backlog,
+		readQueueSize:  l.md.readQueueSize,
+		readBufferSize: l.md.readBufferSize,
+		keepalive:      l.md.keepalive,
+		ttl:            l.md.ttl,
+		stateless:      l.md.stateless,
+		logger:         l.logger,
+	})
+	return
+}
+
+func (l *udpListener) Accept() (conn net.Conn, err error) {
+	return l.ln.Accept()
+}
+
+func (l *udpListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *udpListener) Close() error {
+	if l.raw != nil {
+		sockets.remove(l.options.Service, l.raw)
+	}
+	return l.ln.Close()
+}
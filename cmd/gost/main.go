@@ -1,20 +1,16 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"fmt"
 	"log"
-	_ "net/http/pprof"
 	"os"
-	"os/exec"
 	"runtime"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/go-gost/core/logger"
+	_ "github.com/go-gost/gost/components"
 	xlogger "github.com/go-gost/x/logger"
 	"github.com/judwhite/go-svc"
 )
@@ -32,6 +28,10 @@ func (l *stringList) Set(value string) error {
 var (
 	cfgFiles     stringList
 	outputFormat string
+	explain      bool
+	plan         bool
+	against      string
+	testConfig   bool
 	services     stringList
 	nodes        stringList
 	debug        bool
@@ -39,6 +39,13 @@ var (
 	apiAddr      string
 	metricsAddr  string
 	reload       time.Duration
+	watch        bool
+	drain        time.Duration
+	subscribe    string
+	nodeName     string
+	apiAccess    string
+	persist      bool
+	readyChains  stringList
 )
 
 func init() {
@@ -47,45 +54,11 @@ func init() {
 	args := strings.Join(os.Args[1:], "  ")
 
 	if strings.Contains(args, " -- ") {
-		var (
-			wg  sync.WaitGroup
-			ret atomic.Int32
-		)
-
-		wargsList := strings.Split(" "+args+" ", " -- ")
-
-		ctx, cancel := context.WithCancel(context.Background())
-
-		for wid, wargs := range wargsList {
-			wg.Go(func() {
-				worker(wid, strings.Split(strings.TrimSpace(wargs), "  "), ctx, &ret)
-			})
-		}
-
-		wg.Wait()
-		cancel()
-
-		os.Exit(int(ret.Load()))
-	}
-}
-
-func worker(id int, args []string, ctx context.Context, ret *atomic.Int32) {
-	cmd := exec.CommandContext(ctx, os.Args[0], args...)
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = append(os.Environ(), fmt.Sprintf("_GOST_ID=%d", id))
-
-	if err := cmd.Run(); err != nil {
-		// Context cancellation is expected when one worker exits early.
-		// Only log fatal on other errors.
-		if ctx.Err() == nil {
-			log.Fatal(err)
+		var groups [][]string
+		for _, wargs := range strings.Split(" "+args+" ", " -- ") {
+			groups = append(groups, strings.Split(strings.TrimSpace(wargs), "  "))
 		}
-		return
-	}
-	if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
-		ret.Store(int32(cmd.ProcessState.ExitCode()))
+		os.Exit(supervise(groups))
 	}
 }
 
@@ -96,12 +69,28 @@ func init() {
 	flag.Var(&nodes, "F", "chain node list")
 	flag.Var(&cfgFiles, "C", "config file(s), URL(s), or inline JSON")
 	flag.BoolVar(&printVersion, "V", false, "print version")
-	flag.StringVar(&outputFormat, "O", "", "output format, one of yaml|json format")
+	flag.StringVar(&outputFormat, "O", "", "output format, one of yaml|json format, or schema for the JSON Schema of the config")
+	flag.BoolVar(&explain, "explain", false, "with -O yaml, fill in the defaults and annotate each value with its source")
+	flag.BoolVar(&plan, "plan", false, "print the plan of reloading the instance given by --against with the config, and exit")
+	flag.StringVar(&against, "against", "", "with --plan, the API address of the running instance (e.g. http://127.0.0.1:18080)")
+	flag.BoolVar(&testConfig, "t", false, "validate the config and exit")
 	flag.BoolVar(&debug, "D", false, "debug mode")
 	flag.BoolVar(&trace, "DD", false, "trace mode")
 	flag.StringVar(&apiAddr, "api", "", "api service address")
+	flag.StringVar(&apiAccess, "api-access", "", "file of the roles of the api users, its tokens and audit log")
+	flag.BoolVar(&persist, "persist", false, "write the changes made by the api back to the config file, keeping its revisions")
 	flag.StringVar(&metricsAddr, "metrics", "", "metrics service address")
+	flag.Var(&readyChains, "ready-chain", "chain required to have a node passing in each of its hops for /readyz to report the instance ready (repeatable)")
 	flag.DurationVar(&reload, "R", 0, "auto reload period (e.g. 30s, 1m)")
+	flag.BoolVar(&watch, "W", false, "watch the config files and the files they reference, reload on change")
+	// The restart flags are used by the supervisor in worker mode (gost ... -- ...),
+	// they are only declared here.
+	flag.String("restart", string(restartNever), "worker restart policy, one of always|on-failure|never")
+	flag.Int("restart-max", 5, "maximum number of worker restarts within the restart window")
+	flag.Duration("restart-window", time.Minute, "worker restart window")
+	flag.DurationVar(&drain, "drain", 0, "grace period for draining connections on stop and reload (e.g. 30s)")
+	flag.StringVar(&subscribe, "subscribe", "", "management server to receive resources from (e.g. http://10.0.0.1:18000)")
+	flag.StringVar(&nodeName, "node", "", "with --subscribe, the node name of the instance, the host name by default")
 	flag.Parse()
 
 	if printVersion {
@@ -0,0 +1,1516 @@
+// Code generated by metadatagen; DO NOT EDIT.
+
+package main
+
+// knownMetadata are the metadata keys read by the registered components,
+// by component kind and type.
+var knownMetadata = map[string]map[string][]metadataKey{
+	"connector": {
+		"direct": {
+			{"action", "string"},
+		},
+		"http": {
+			{"header", "map"},
+			{"timeout", "duration"},
+		},
+		"http2": {
+			{"header", "map"},
+			{"timeout", "duration"},
+		},
+		"masque": {
+			{"connectTimeout", "duration"},
+			{"timeout", "duration"},
+		},
+		"relay": {
+			{"connectTimeout", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"nodelay", "bool"},
+		},
+		"router": {
+			{"connectTimeout", "duration"},
+			{"router.id", "string"},
+		},
+		"sni": {
+			{"host", "string"},
+			{"timeout", "duration"},
+		},
+		"socks": {
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"notls", "bool"},
+			{"relay", "string"},
+			{"timeout", "duration"},
+			{"udp.bufferSize", "int"},
+			{"udp.timeout", "duration"},
+			{"udpBufferSize", "int"},
+		},
+		"socks4": {
+			{"disable4a", "bool"},
+			{"timeout", "duration"},
+		},
+		"socks4a": {
+			{"disable4a", "bool"},
+			{"timeout", "duration"},
+		},
+		"socks5": {
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"notls", "bool"},
+			{"relay", "string"},
+			{"timeout", "duration"},
+			{"udp.bufferSize", "int"},
+			{"udp.timeout", "duration"},
+			{"udpBufferSize", "int"},
+		},
+		"ss": {
+			{"key", "string"},
+			{"timeout", "duration"},
+		},
+		"ssu": {
+			{"connectTimeout", "duration"},
+			{"key", "string"},
+			{"timeout", "duration"},
+			{"udp.bufferSize", "int"},
+			{"udpBufferSize", "int"},
+		},
+		"tunnel": {
+			{"connectTimeout", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"record.mode", "string"},
+			{"tunnel.id", "string"},
+			{"tunnel.weight", "int"},
+			{"tunnelID", "string"},
+		},
+		"virtual": {
+			{"action", "string"},
+		},
+	},
+	"dialer": {
+		"dtls": {
+			{"bufferSize", "int"},
+			{"dtls.bufferSize", "int"},
+			{"dtls.flightInterval", "duration"},
+			{"dtls.mtu", "int"},
+			{"flightInterval", "duration"},
+			{"mtu", "int"},
+		},
+		"grpc": {
+			{"grpc.authority", "string"},
+			{"grpc.host", "string"},
+			{"grpc.insecure", "bool"},
+			{"grpc.keepalive", "bool"},
+			{"grpc.keepalive.permitWithoutStream", "bool"},
+			{"grpc.keepalive.time", "duration"},
+			{"grpc.keepalive.timeout", "duration"},
+			{"grpc.minConnectTimeout", "duration"},
+			{"grpc.path", "string"},
+			{"grpcInsecure", "bool"},
+			{"host", "string"},
+			{"insecure", "bool"},
+			{"keepAlive", "bool"},
+			{"keepalive", "bool"},
+			{"keepalive.permitWithoutStream", "bool"},
+			{"keepalive.time", "duration"},
+			{"keepalive.timeout", "duration"},
+			{"minConnectTimeout", "duration"},
+			{"path", "string"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+		},
+		"h2": {
+			{"header", "map"},
+			{"host", "string"},
+			{"path", "string"},
+		},
+		"h2c": {
+			{"header", "map"},
+			{"host", "string"},
+			{"path", "string"},
+		},
+		"h3": {
+			{"handshakeTimeout", "duration"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"pht.pullPath", "string"},
+			{"pht.pushPath", "string"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+			{"ttl", "duration"},
+		},
+		"h3-masque": {
+			{"handshakeTimeout", "duration"},
+			{"host", "string"},
+			{"keepAlive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"ttl", "duration"},
+		},
+		"http3": {
+			{"handshakeTimeout", "duration"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"pht.pullPath", "string"},
+			{"pht.pushPath", "string"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+			{"ttl", "duration"},
+		},
+		"icmp": {
+			{"handshakeTimeout", "duration"},
+			{"keepAlive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"ttl", "duration"},
+		},
+		"icmp6": {
+			{"handshakeTimeout", "duration"},
+			{"keepAlive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"ttl", "duration"},
+		},
+		"kcp": {
+			{"c", "string"},
+			{"config", "map"},
+			{"configFile", "string"},
+			{"handshakeTimeout", "duration"},
+			{"kcp.config", "map"},
+			{"kcp.configFile", "string"},
+			{"kcp.crypt", "string"},
+			{"kcp.interval", "int"},
+			{"kcp.keepalive", "int"},
+			{"kcp.key", "string"},
+			{"kcp.mode", "string"},
+			{"kcp.mtu", "int"},
+			{"kcp.nocomp", "bool"},
+			{"kcp.rcvwnd", "int"},
+			{"kcp.smuxbuf", "int"},
+			{"kcp.smuxver", "int"},
+			{"kcp.sndwnd", "int"},
+			{"kcp.streambuf", "int"},
+			{"kcp.tcp", "bool"},
+			{"tcp", "bool"},
+		},
+		"mtcp": {
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+		},
+		"mtls": {
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+		},
+		"mws": {
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"keepalive.interval", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+			{"ttl", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.host", "string"},
+			{"ws.keepalive", "bool"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"mwss": {
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"keepalive.interval", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+			{"ttl", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.host", "string"},
+			{"ws.keepalive", "bool"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"ohttp": {
+			{"header", "map"},
+			{"host", "string"},
+			{"obfs.header", "map"},
+			{"obfs.host", "string"},
+			{"obfs.path", "string"},
+			{"path", "string"},
+		},
+		"ohttps": {
+			{"header", "map"},
+			{"host", "string"},
+			{"obfs.header", "map"},
+			{"obfs.host", "string"},
+			{"obfs.path", "string"},
+			{"path", "string"},
+		},
+		"otls": {
+			{"host", "string"},
+		},
+		"pht": {
+			{"header", "map"},
+			{"host", "string"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+		},
+		"phts": {
+			{"header", "map"},
+			{"host", "string"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+		},
+		"quic": {
+			{"cipherKey", "string"},
+			{"enableDatagram", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"keepAlive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"quic.enableDatagram", "bool"},
+			{"ttl", "duration"},
+		},
+		"ssh": {
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"keepalive.interval", "duration"},
+			{"keepalive.retries", "int"},
+			{"keepalive.timeout", "duration"},
+			{"passphrase", "string"},
+			{"passphraseFromKeyring", "bool"},
+			{"privateKeyFile", "string"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+			{"ttl", "duration"},
+		},
+		"sshd": {
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"keepalive.interval", "duration"},
+			{"keepalive.retries", "int"},
+			{"keepalive.timeout", "duration"},
+			{"passphrase", "string"},
+			{"passphraseFromKeyring", "bool"},
+			{"privateKeyFile", "string"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+			{"ttl", "duration"},
+		},
+		"tcp": {
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+		},
+		"tls": {
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+		},
+		"utls": {
+			{"fingerprint", "string"},
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+		},
+		"ws": {
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"keepalive.interval", "duration"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+			{"ttl", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.host", "string"},
+			{"ws.keepalive", "bool"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"wss": {
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"keepalive.interval", "duration"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+			{"ttl", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.host", "string"},
+			{"ws.keepalive", "bool"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"wt": {
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"host", "string"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"path", "string"},
+			{"ttl", "duration"},
+			{"wt.header", "map"},
+			{"wt.host", "string"},
+			{"wt.path", "string"},
+		},
+	},
+	"handler": {
+		"api": {
+			{"accessLog", "bool"},
+			{"api.accessLog", "bool"},
+			{"api.pathPrefix", "string"},
+			{"pathPrefix", "string"},
+		},
+		"dns": {
+			{"async", "bool"},
+			{"bufferSize", "int"},
+			{"clientIP", "string"},
+			{"dns", "string"},
+			{"readTimeout", "duration"},
+			{"timeout", "duration"},
+			{"ttl", "duration"},
+		},
+		"file": {
+			{"dir", "string"},
+			{"file.dir", "string"},
+			{"file.put", "bool"},
+			{"put", "bool"},
+		},
+		"forward": {
+			{"bufferSize", "int"},
+			{"http.keepalive", "bool"},
+			{"idleTimeout", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"proxyProtocol", "int"},
+			{"readBufferSize", "int"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"stateless", "bool"},
+			{"udp.bufferSize", "int"},
+		},
+		"http": {
+			{"authBasicRealm", "string"},
+			{"compression", "bool"},
+			{"hash", "string"},
+			{"header", "map"},
+			{"http.compression", "bool"},
+			{"http.header", "map"},
+			{"http.keepalive", "bool"},
+			{"http.proxyAgent", "string"},
+			{"idleTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"knock", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"probeResist", "string"},
+			{"probe_resist", "string"},
+			{"proxyAgent", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"udp", "bool"},
+			{"udp.bufferSize", "int"},
+			{"udpBufferSize", "int"},
+		},
+		"http2": {
+			{"authBasicRealm", "string"},
+			{"hash", "string"},
+			{"header", "map"},
+			{"http.header", "map"},
+			{"knock", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"probeResist", "string"},
+			{"probe_resist", "string"},
+			{"read.timeout", "duration"},
+			{"readTimeout", "duration"},
+		},
+		"http3": {
+			{"hash", "string"},
+			{"header", "map"},
+			{"knock", "string"},
+			{"probeResistance", "string"},
+			{"probe_resist", "string"},
+		},
+		"masque": {
+			{"authBasicRealm", "string"},
+			{"bufferSize", "int"},
+			{"hash", "string"},
+			{"idleTimeout", "duration"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"read.timeout", "duration"},
+			{"readTimeout", "duration"},
+			{"udp.bufferSize", "int"},
+		},
+		"metrics": {
+			{"metrics.path", "string"},
+			{"path", "string"},
+		},
+		"red": {
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.dialOriginalDst", "bool"},
+			{"sniffing.fallback", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"tproxy", "bool"},
+		},
+		"redir": {
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.dialOriginalDst", "bool"},
+			{"sniffing.fallback", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"tproxy", "bool"},
+		},
+		"redirect": {
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.dialOriginalDst", "bool"},
+			{"sniffing.fallback", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"tproxy", "bool"},
+		},
+		"redu": {
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+		},
+		"relay": {
+			{"bind", "bool"},
+			{"hash", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"nodelay", "bool"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"udp.bufferSize", "int"},
+			{"udpBufferSize", "int"},
+		},
+		"router": {
+			{"bufferSize", "int"},
+			{"entrypoint", "string"},
+			{"ingress", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"readTimeout", "duration"},
+			{"router", "string"},
+			{"router.bufferSize", "int"},
+			{"router.cache", "bool"},
+			{"router.cache.expiration", "duration"},
+			{"sd", "string"},
+			{"sd.cache.expiration", "duration"},
+			{"sd.renewInterval", "duration"},
+		},
+		"rtcp": {
+			{"host", "string"},
+			{"http.keepalive", "bool"},
+			{"idleTimeout", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"proxyProtocol", "int"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"rudp": {
+			{"host", "string"},
+			{"http.keepalive", "bool"},
+			{"idleTimeout", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"proxyProtocol", "int"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"runix": {
+			{"host", "string"},
+			{"http.keepalive", "bool"},
+			{"idleTimeout", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"proxyProtocol", "int"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"serial": {
+			{"serial.timeout", "duration"},
+			{"timeout", "duration"},
+		},
+		"sni": {
+			{"hash", "string"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"socks": {
+			{"bind", "bool"},
+			{"comp", "bool"},
+			{"enableTor", "bool"},
+			{"hash", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"notls", "bool"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"publicAddr", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"socks.publicAddr", "string"},
+			{"socks5.tor", "bool"},
+			{"tor", "bool"},
+			{"udp", "bool"},
+			{"udp.bindRange.max", "int"},
+			{"udp.bindRange.min", "int"},
+			{"udp.bufferSize", "int"},
+			{"udp.maxPort", "int"},
+			{"udp.minPort", "int"},
+			{"udp.resolveDomain", "bool"},
+			{"udpBufferSize", "int"},
+			{"udpResolveDomain", "bool"},
+		},
+		"socks4": {
+			{"hash", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"socks4a": {
+			{"hash", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"socks5": {
+			{"bind", "bool"},
+			{"comp", "bool"},
+			{"enableTor", "bool"},
+			{"hash", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"notls", "bool"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"publicAddr", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"socks.publicAddr", "string"},
+			{"socks5.tor", "bool"},
+			{"tor", "bool"},
+			{"udp", "bool"},
+			{"udp.bindRange.max", "int"},
+			{"udp.bindRange.min", "int"},
+			{"udp.bufferSize", "int"},
+			{"udp.maxPort", "int"},
+			{"udp.minPort", "int"},
+			{"udp.resolveDomain", "bool"},
+			{"udpBufferSize", "int"},
+			{"udpResolveDomain", "bool"},
+		},
+		"ss": {
+			{"hash", "string"},
+			{"key", "string"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"users", "map"},
+		},
+		"sshd": {
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+		},
+		"ssu": {
+			{"key", "string"},
+			{"readTimeout", "duration"},
+			{"udp.bufferSize", "int"},
+			{"udpBufferSize", "int"},
+			{"users", "map"},
+		},
+		"tap": {
+			{"key", "string"},
+		},
+		"tcp": {
+			{"bufferSize", "int"},
+			{"http.keepalive", "bool"},
+			{"idleTimeout", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"proxyProtocol", "int"},
+			{"readBufferSize", "int"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"stateless", "bool"},
+			{"udp.bufferSize", "int"},
+		},
+		"tun": {
+			{"keepalive", "bool"},
+			{"p2p", "bool"},
+			{"passphrase", "string"},
+			{"token", "string"},
+			{"ttl", "duration"},
+			{"tun.keepalive", "bool"},
+			{"tun.p2p", "bool"},
+			{"tun.token", "string"},
+			{"tun.ttl", "duration"},
+		},
+		"tungo": {
+			{"ipv6", "bool"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"multicastGroups", "string"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"sniffing", "bool"},
+			{"sniffing.fallback", "bool"},
+			{"sniffing.responseTimeout", "duration"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.udp", "bool"},
+			{"tcpModerateReceiveBuffer", "bool"},
+			{"tcpReceiveBufferSize", "int"},
+			{"tcpSendBufferSize", "int"},
+			{"tungo.multicastGroups", "string"},
+			{"tungo.tcpModerateReceiveBuffer", "bool"},
+			{"tungo.tcpReceiveBufferSize", "int"},
+			{"tungo.tcpSendBufferSize", "int"},
+			{"tungo.udpTimeout", "duration"},
+			{"udp.bufferSize", "int"},
+			{"udpBufferSize", "int"},
+			{"udpTimeout", "duration"},
+		},
+		"tunnel": {
+			{"entrypoint", "string"},
+			{"entrypoint.ProxyProtocol", "int"},
+			{"entrypoint.compression", "bool"},
+			{"entrypoint.id", "string"},
+			{"entrypoint.keepalive", "bool"},
+			{"entrypoint.readTimeout", "duration"},
+			{"ingress", "string"},
+			{"limiter.cleanupInterval", "duration"},
+			{"limiter.refreshInterval", "duration"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"observePeriod", "duration"},
+			{"observer.observePeriod", "duration"},
+			{"observer.period", "duration"},
+			{"observer.resetTraffic", "bool"},
+			{"readTimeout", "duration"},
+			{"sd", "string"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"tunnel", "string"},
+			{"tunnel.direct", "bool"},
+			{"tunnel.ttl", "duration"},
+		},
+		"udp": {
+			{"bufferSize", "int"},
+			{"http.keepalive", "bool"},
+			{"idleTimeout", "duration"},
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"proxyProtocol", "int"},
+			{"readBufferSize", "int"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+			{"sniffing.websocket", "bool"},
+			{"sniffing.websocket.sampleRate", "float"},
+			{"stateless", "bool"},
+			{"udp.bufferSize", "int"},
+		},
+		"unix": {
+			{"mitm.alpn", "string"},
+			{"mitm.bypass", "string"},
+			{"mitm.caCertFile", "string"},
+			{"mitm.caKeyFile", "string"},
+			{"mitm.certFile", "string"},
+			{"mitm.keyFile", "string"},
+			{"readTimeout", "duration"},
+			{"sniffing", "bool"},
+			{"sniffing.timeout", "duration"},
+		},
+	},
+	"listener": {
+		"dns": {
+			{"backlog", "int"},
+			{"mode", "string"},
+			{"mptcp", "bool"},
+			{"readBufferSize", "int"},
+			{"readTimeout", "duration"},
+			{"writeTimeout", "duration"},
+		},
+		"dtls": {
+			{"bufferSize", "int"},
+			{"dtls.bufferSize", "int"},
+			{"dtls.flightInterval", "duration"},
+			{"dtls.mtu", "int"},
+			{"flightInterval", "duration"},
+			{"mtu", "int"},
+		},
+		"ftcp": {
+			{"backlog", "int"},
+			{"readBufferSize", "int"},
+			{"readQueueSize", "int"},
+			{"ttl", "duration"},
+		},
+		"grpc": {
+			{"backlog", "int"},
+			{"grpc.backlog", "int"},
+			{"grpc.insecure", "bool"},
+			{"grpc.keepalive", "bool"},
+			{"grpc.keepalive.maxConnectionIdle", "duration"},
+			{"grpc.keepalive.minTime", "duration"},
+			{"grpc.keepalive.permitWithoutStream", "bool"},
+			{"grpc.keepalive.time", "duration"},
+			{"grpc.keepalive.timeout", "duration"},
+			{"grpc.path", "string"},
+			{"grpcInsecure", "bool"},
+			{"insecure", "bool"},
+			{"keepAlive", "bool"},
+			{"keepalive", "bool"},
+			{"keepalive.maxConnectionIdle", "duration"},
+			{"keepalive.minTime", "duration"},
+			{"keepalive.permitWithoutStream", "bool"},
+			{"keepalive.time", "duration"},
+			{"keepalive.timeout", "duration"},
+			{"mptcp", "bool"},
+			{"path", "string"},
+			{"tcp.keepalive", "bool"},
+			{"tcp.keepalive.count", "int"},
+			{"tcp.keepalive.idle", "duration"},
+			{"tcp.keepalive.interval", "duration"},
+		},
+		"h2": {
+			{"backlog", "int"},
+			{"mptcp", "bool"},
+			{"path", "string"},
+		},
+		"h2c": {
+			{"backlog", "int"},
+			{"mptcp", "bool"},
+			{"path", "string"},
+		},
+		"h3": {
+			{"authorizePath", "string"},
+			{"backlog", "int"},
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"pht.authorizePath", "string"},
+			{"pht.pullPath", "string"},
+			{"pht.pushPath", "string"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+			{"ttl", "duration"},
+		},
+		"http2": {
+			{"backlog", "int"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+		},
+		"http3": {
+			{"backlog", "int"},
+			{"enableDatagrams", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"keepAlive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"ttl", "duration"},
+		},
+		"icmp": {
+			{"backlog", "int"},
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"ttl", "duration"},
+		},
+		"icmp6": {
+			{"backlog", "int"},
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"ttl", "duration"},
+		},
+		"kcp": {
+			{"backlog", "int"},
+			{"c", "string"},
+			{"config", "map"},
+			{"configFile", "string"},
+			{"kcp.config", "map"},
+			{"kcp.configFile", "string"},
+			{"kcp.crypt", "string"},
+			{"kcp.interval", "int"},
+			{"kcp.keepalive", "int"},
+			{"kcp.key", "string"},
+			{"kcp.mode", "string"},
+			{"kcp.mtu", "int"},
+			{"kcp.nocomp", "bool"},
+			{"kcp.rcvwnd", "int"},
+			{"kcp.smuxbuf", "int"},
+			{"kcp.smuxver", "int"},
+			{"kcp.sndwnd", "int"},
+			{"kcp.streambuf", "int"},
+			{"kcp.tcp", "bool"},
+			{"tcp", "bool"},
+		},
+		"mtcp": {
+			{"backlog", "int"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"reuseport", "bool"},
+		},
+		"mtls": {
+			{"backlog", "int"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+		},
+		"mws": {
+			{"backlog", "int"},
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.backlog", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"mwss": {
+			{"backlog", "int"},
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"mux.keepaliveDisabled", "bool"},
+			{"mux.keepaliveInterval", "duration"},
+			{"mux.keepaliveTimeout", "duration"},
+			{"mux.maxFrameSize", "int"},
+			{"mux.maxReceiveBuffer", "int"},
+			{"mux.maxStreamBuffer", "int"},
+			{"mux.maxStreamWindow", "int"},
+			{"mux.type", "string"},
+			{"mux.version", "int"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.backlog", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"ohttp": {
+			{"header", "map"},
+			{"mptcp", "bool"},
+		},
+		"otls": {
+			{"mptcp", "bool"},
+		},
+		"pht": {
+			{"authorizePath", "string"},
+			{"backlog", "int"},
+			{"mptcp", "bool"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+		},
+		"phts": {
+			{"authorizePath", "string"},
+			{"backlog", "int"},
+			{"mptcp", "bool"},
+			{"pullPath", "string"},
+			{"pushPath", "string"},
+		},
+		"quic": {
+			{"backlog", "int"},
+			{"cipherKey", "string"},
+			{"enableDatagram", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"keepAlive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"quic.enableDatagram", "bool"},
+			{"ttl", "duration"},
+		},
+		"red": {
+			{"mptcp", "bool"},
+			{"reuseport", "bool"},
+			{"tproxy", "bool"},
+		},
+		"redir": {
+			{"mptcp", "bool"},
+			{"reuseport", "bool"},
+			{"tproxy", "bool"},
+		},
+		"redirect": {
+			{"mptcp", "bool"},
+			{"reuseport", "bool"},
+			{"tproxy", "bool"},
+		},
+		"redu": {
+			{"readBufferSize", "int"},
+			{"ttl", "duration"},
+		},
+		"rudp": {
+			{"backlog", "int"},
+			{"readBufferSize", "int"},
+			{"readQueueSize", "int"},
+			{"ttl", "duration"},
+		},
+		"serial": {
+			{"listener.serial.timeout", "duration"},
+			{"serial.timeout", "duration"},
+			{"timeout", "duration"},
+		},
+		"ssh": {
+			{"authorizedKeys", "string"},
+			{"backlog", "int"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"passphrase", "string"},
+			{"privateKeyFile", "string"},
+		},
+		"sshd": {
+			{"authorizedKeys", "string"},
+			{"backlog", "int"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"passphrase", "string"},
+			{"passphraseFromKeyring", "bool"},
+			{"privateKeyFile", "string"},
+		},
+		"tap": {
+			{"componentID", "string"},
+			{"gw", "string"},
+			{"mtu", "int"},
+			{"name", "string"},
+			{"net", "string"},
+			{"route", "string"},
+			{"routes", "strings"},
+		},
+		"tcp": {
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"reuseport", "bool"},
+		},
+		"tls": {
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+		},
+		"tun": {
+			{"dns", "string"},
+			{"guid", "string"},
+			{"gw", "string"},
+			{"mtu", "int"},
+			{"name", "string"},
+			{"net", "string"},
+			{"peer", "string"},
+			{"route", "string"},
+			{"router", "string"},
+			{"routes", "strings"},
+			{"tun.dns", "string"},
+			{"tun.guid", "string"},
+			{"tun.gw", "string"},
+			{"tun.mtu", "int"},
+			{"tun.name", "string"},
+			{"tun.net", "string"},
+			{"tun.peer", "string"},
+			{"tun.route", "string"},
+			{"tun.router", "string"},
+			{"tun.routes", "strings"},
+		},
+		"tungo": {
+			{"dns", "string"},
+			{"guid", "string"},
+			{"gw", "string"},
+			{"mtu", "int"},
+			{"name", "string"},
+			{"net", "string"},
+			{"peer", "string"},
+			{"route", "string"},
+			{"routes", "strings"},
+			{"tun.dns", "string"},
+			{"tun.guid", "string"},
+			{"tun.gw", "string"},
+			{"tun.mtu", "int"},
+			{"tun.name", "string"},
+			{"tun.net", "string"},
+			{"tun.peer", "string"},
+			{"tun.route", "string"},
+			{"tun.routes", "strings"},
+		},
+		"udp": {
+			{"backlog", "int"},
+			{"keepalive", "bool"},
+			{"keepalive.ttl", "duration"},
+			{"readBufferSize", "int"},
+			{"readQueueSize", "int"},
+			{"recvQueueSize", "int"},
+			{"stateless", "bool"},
+			{"ttl", "duration"},
+			{"udp.bufferSize", "int"},
+		},
+		"ws": {
+			{"backlog", "int"},
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.backlog", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"wss": {
+			{"backlog", "int"},
+			{"enableCompression", "bool"},
+			{"handshakeTimeout", "duration"},
+			{"header", "map"},
+			{"keepalive", "bool"},
+			{"keepalive.count", "int"},
+			{"keepalive.idle", "duration"},
+			{"keepalive.interval", "duration"},
+			{"mptcp", "bool"},
+			{"path", "string"},
+			{"readBufferSize", "int"},
+			{"readHeaderTimeout", "duration"},
+			{"writeBufferSize", "int"},
+			{"ws.backlog", "int"},
+			{"ws.enableCompression", "bool"},
+			{"ws.handshakeTimeout", "duration"},
+			{"ws.header", "map"},
+			{"ws.path", "string"},
+			{"ws.readBufferSize", "int"},
+			{"ws.readHeaderTimeout", "duration"},
+			{"ws.writeBufferSize", "int"},
+		},
+		"wt": {
+			{"backlog", "int"},
+			{"handshakeTimeout", "duration"},
+			{"keepalive", "bool"},
+			{"maxIdleTimeout", "duration"},
+			{"maxStreams", "int"},
+			{"path", "string"},
+			{"ttl", "duration"},
+			{"wt.path", "string"},
+		},
+	},
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/go-gost/gost/server"
+)
+
+// persistConfig returns the persistence of the changes made by the API for
+// --persist. They are written back to the config file, which must be the
+// only source of the config: a single local file without includes, nor
+// services, nodes, management services or resources given otherwise.
+func persistConfig() (*server.Persist, error) {
+	if len(cfgFiles) != 1 || !isLocalFile(cfgFiles[0]) {
+		return nil, errors.New("--persist requires a single config file")
+	}
+	if len(services) > 0 || len(nodes) > 0 {
+		return nil, errors.New("--persist can not be used with -L or -F")
+	}
+	if apiAddr != "" || metricsAddr != "" {
+		return nil, errors.New("--persist can not be used with -api or -metrics")
+	}
+	if subscribe != "" {
+		return nil, errors.New("--persist can not be used with --subscribe")
+	}
+	files, _, err := resolveIncludes(cfgFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, errors.New("--persist can not be used with include directives")
+	}
+
+	return &server.Persist{
+		File: cfgFiles[0],
+	}, nil
+}
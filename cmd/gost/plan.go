@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+)
+
+// requestPlan requests the plan of reloading the instance serving the API
+// at against with cfg. The config is sent redacted, the values expanded
+// from references are compared as the references.
+func requestPlan(against string, cfg *config.Config) (*server.Plan, error) {
+	var buf bytes.Buffer
+	if err := cfg.Write(&buf, "json"); err != nil {
+		return nil, err
+	}
+	body, err := redact(buf.Bytes(), "json")
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.Contains(against, "://") {
+		against = "http://" + against
+	}
+	url := strings.TrimSuffix(against, "/") + "/config/plan"
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r struct {
+		Msg  string       `json:"msg"`
+		Data *server.Plan `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK || r.Data == nil {
+		msg := r.Msg
+		if msg == "" {
+			msg = resp.Status
+		}
+		return nil, fmt.Errorf("%s: %s", url, msg)
+	}
+	return r.Data, nil
+}
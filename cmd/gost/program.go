@@ -1,197 +1,185 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"net/http"
+	"fmt"
 	"os"
 	"os/signal"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/go-gost/core/auth"
 	"github.com/go-gost/core/logger"
-	"github.com/go-gost/core/service"
-	api_service "github.com/go-gost/x/api/service"
-	xauth "github.com/go-gost/x/auth"
-	"github.com/go-gost/x/config"
-	"github.com/go-gost/x/config/loader"
-	auth_parser "github.com/go-gost/x/config/parsing/auth"
-	"github.com/go-gost/x/config/parsing/parser"
-	xmetrics "github.com/go-gost/x/metrics"
-	metrics "github.com/go-gost/x/metrics/service"
-	"github.com/go-gost/x/registry"
+	"github.com/go-gost/gost/controlplane"
+	"github.com/go-gost/gost/server"
 	"github.com/judwhite/go-svc"
 )
 
 type program struct {
-	srvApi       service.Service
-	srvMetrics   service.Service
-	srvProfiling *http.Server
+	srv *server.Server
 
 	cancel context.CancelFunc
+
+	// mu serializes the reloads of the program and of the API with the
+	// upgrades.
+	mu sync.Mutex
+
+	// ready is set once the config is loaded, the reloads are notified
+	// from then on.
+	ready atomic.Bool
+	// upgraded is set once a new process took over the services.
+	upgraded atomic.Bool
+
+	// resources are the resources received from the management server,
+	// merged into the config sources.
+	resources atomic.Pointer[[]controlplane.Resource]
 }
 
 func (p *program) Init(env svc.Environment) error {
-	parser.Init(parser.Args{
-		CfgFiles:    cfgFiles,
-		Services:    services,
-		Nodes:       nodes,
-		Debug:       debug,
-		Trace:       trace,
-		ApiAddr:     apiAddr,
-		MetricsAddr: metricsAddr,
-	})
+	registerListeners()
 
 	return nil
 }
 
 func (p *program) Start() error {
-	cfg, err := parser.Parse()
-	if err != nil {
-		return err
+	if testConfig {
+		os.Exit(validate())
 	}
-
-	if outputFormat != "" {
-		if err := cfg.Write(os.Stdout, outputFormat); err != nil {
+	if outputFormat == "schema" {
+		if err := writeSchema(os.Stdout); err != nil {
 			return err
 		}
 		os.Exit(0)
 	}
 
-	config.Set(cfg)
-
-	// Enable metrics before loading services so that listener wrappers
-	// can observe the enabled state at Init time.
-	if cfg.Metrics != nil && cfg.Metrics.Addr != "" {
-		xmetrics.Enable(true)
-	}
-
-	if err := loader.Load(cfg); err != nil {
-		return err
-	}
-
-	if err := p.run(cfg); err != nil {
+	cfg, err := parseConfig()
+	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	p.cancel = cancel
-	go p.reload(ctx)
-
-	return nil
-}
-
-func (p *program) run(cfg *config.Config) error {
-	for _, svc := range registry.ServiceRegistry().GetAll() {
-		svc := svc
-		go func() {
-			svc.Serve()
-		}()
+	if plan {
+		if against == "" {
+			return errors.New("--plan requires --against")
+		}
+		pl, err := requestPlan(against, cfg)
+		if err != nil {
+			return err
+		}
+		pl.WriteTo(os.Stdout)
+		os.Exit(0)
 	}
-
-	if p.srvApi != nil {
-		p.srvApi.Close()
-		p.srvApi = nil
+	if explain {
+		if outputFormat != "yaml" {
+			return errors.New("--explain requires -O yaml")
+		}
+		b, err := explainConfig(cfg)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(b)
+		os.Exit(0)
 	}
-	if cfg.API != nil {
-		s, err := buildApiService(cfg.API)
+	if outputFormat != "" {
+		var buf bytes.Buffer
+		if err := cfg.Write(&buf, outputFormat); err != nil {
+			return err
+		}
+		b, err := redact(buf.Bytes(), outputFormat)
 		if err != nil {
 			return err
 		}
-
-		p.srvApi = s
-
-		go func() {
-			defer s.Close()
-
-			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@api"})
-
-			log.Info("listening on ", s.Addr())
-			if err := s.Serve(); !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err)
-			}
-		}()
+		os.Stdout.Write(b)
+		os.Exit(0)
 	}
 
-	if p.srvMetrics != nil {
-		p.srvMetrics.Close()
-		p.srvMetrics = nil
+	opts := []server.Option{
+		server.LoaderOption(p.loadConfig),
+		server.ReloadLockOption(&p.mu),
+		server.RedactOption(redact),
+		server.TLSOption(managementTLS.get),
+		server.DrainTimeoutOption(drain),
+		server.ReloadHooksOption(
+			func() {
+				if p.ready.Load() {
+					notify("RELOADING=1", fmt.Sprintf("MONOTONIC_USEC=%d", monotonicUsec()))
+				}
+			},
+			func(st server.ReloadStatus) {
+				if p.ready.Load() {
+					notify("READY=1", "STATUS=reload "+st.Status)
+				}
+			},
+		),
+	}
+	if apiAccess != "" {
+		access, err := loadAccess(apiAccess)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, server.AccessOption(access))
 	}
-	if cfg.Metrics != nil && cfg.Metrics.Addr != "" {
-		s, err := buildMetricsService(cfg.Metrics)
+	if persist {
+		persist, err := persistConfig()
 		if err != nil {
 			return err
 		}
-
-		p.srvMetrics = s
-
-		go func() {
-			defer s.Close()
-
-			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@metrics"})
-
-			log.Info("listening on ", s.Addr())
-			if err := s.Serve(); !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err)
-			}
-		}()
+		opts = append(opts, server.PersistOption(persist))
 	}
-
-	if p.srvProfiling != nil {
-		p.srvProfiling.Close()
-		p.srvProfiling = nil
+	if len(readyChains) > 0 {
+		opts = append(opts, server.ReadyChainsOption(readyChains...))
+	}
+	if inherited.upgrading() {
+		p.srv, err = adopt(cfg, opts...)
+	} else {
+		p.srv = server.New(cfg, opts...)
+		err = p.srv.Start(context.Background())
+	}
+	if err != nil {
+		return err
 	}
-	if cfg.Profiling != nil {
-		addr := cfg.Profiling.Addr
-		if addr == "" {
-			addr = ":6060"
-		}
-		s := &http.Server{
-			Addr: addr,
-		}
-		p.srvProfiling = s
-
-		go func() {
-			defer s.Close()
-
-			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@profiling"})
 
-			log.Info("listening on ", addr)
-			if err := s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err)
-			}
-		}()
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.reload(ctx)
+	if watch {
+		go p.watch(ctx)
+	}
+	go p.handleUpgrade(ctx)
+	if subscribe != "" {
+		go p.subscribe(ctx)
 	}
 
+	p.ready.Store(true)
+	notify("READY=1")
+	go watchdog(ctx)
+
 	return nil
 }
 
 func (p *program) Stop() error {
+	notify("STOPPING=1")
+
 	if p.cancel != nil {
 		p.cancel()
 	}
-
-	for name, srv := range registry.ServiceRegistry().GetAll() {
-		srv.Close()
-		logger.Default().Debugf("service %s shutdown", name)
+	if p.srv == nil {
+		return nil
 	}
 
-	if p.srvApi != nil {
-		p.srvApi.Close()
-		logger.Default().Debug("service @api shutdown")
-	}
-	if p.srvMetrics != nil {
-		p.srvMetrics.Close()
-		logger.Default().Debug("service @metrics shutdown")
+	timeout := drain
+	if timeout == 0 && p.upgraded.Load() {
+		timeout = upgradeDrain
 	}
-	if p.srvProfiling != nil {
-		p.srvProfiling.Close()
-		logger.Default().Debug("service @profiling shutdown")
+	if timeout == 0 {
+		return p.srv.Close()
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	return nil
+	return p.srv.Shutdown(ctx)
 }
 
 func (p *program) reload(ctx context.Context) {
@@ -208,17 +196,19 @@ func (p *program) reload(ctx context.Context) {
 	for {
 		select {
 		case <-c:
-			if err := p.reloadConfig(); err != nil {
+			if d, err := p.reloadConfig(); err != nil {
 				logger.Default().Error(err)
 			} else {
-				logger.Default().Info("config reloaded")
+				logger.Default().Infof("config reloaded: %s", d)
 			}
 
 		case <-ticker:
-			if err := p.reloadConfig(); err != nil {
+			if d, err := p.reloadConfig(); err != nil {
 				logger.Default().Errorf("auto reload: %v", err)
+			} else if d.Empty() {
+				logger.Default().Debug("config auto reloaded: no changes")
 			} else {
-				logger.Default().Debug("config auto reloaded")
+				logger.Default().Infof("config auto reloaded: %s", d)
 			}
 
 		case <-ctx.Done():
@@ -227,67 +217,12 @@ func (p *program) reload(ctx context.Context) {
 	}
 }
 
-func (p *program) reloadConfig() error {
-	cfg, err := parser.Parse()
-	if err != nil {
-		return err
-	}
-	config.Set(cfg)
-
-	if err := loader.Load(cfg); err != nil {
-		return err
-	}
-
-	if err := p.run(cfg); err != nil {
-		return err
-	}
-
-	return nil
-}
+// reloadConfig applies the current config files. Either the new config is
+// applied completely, or the running one is kept. The touched resources are
+// rebuilt even if their config is unchanged.
+func (p *program) reloadConfig(touched ...server.Resource) (*server.Diff, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-func buildApiService(cfg *config.APIConfig) (service.Service, error) {
-	var authers []auth.Authenticator
-	if auther := auth_parser.ParseAutherFromAuth(cfg.Auth); auther != nil {
-		authers = append(authers, auther)
-	}
-	if cfg.Auther != "" {
-		authers = append(authers, registry.AutherRegistry().Get(cfg.Auther))
-	}
-
-	var auther auth.Authenticator
-	if len(authers) > 0 {
-		auther = xauth.AuthenticatorGroup(authers...)
-	}
-
-	network := "tcp"
-	addr := cfg.Addr
-	if strings.HasPrefix(addr, "unix://") {
-		network = "unix"
-		addr = strings.TrimPrefix(addr, "unix://")
-	}
-	return api_service.NewService(
-		network, addr,
-		api_service.PathPrefixOption(cfg.PathPrefix),
-		api_service.AccessLogOption(cfg.AccessLog),
-		api_service.AutherOption(auther),
-	)
-}
-
-func buildMetricsService(cfg *config.MetricsConfig) (service.Service, error) {
-	auther := auth_parser.ParseAutherFromAuth(cfg.Auth)
-	if cfg.Auther != "" {
-		auther = registry.AutherRegistry().Get(cfg.Auther)
-	}
-
-	network := "tcp"
-	addr := cfg.Addr
-	if strings.HasPrefix(addr, "unix://") {
-		network = "unix"
-		addr = strings.TrimPrefix(addr, "unix://")
-	}
-	return metrics.NewService(
-		network, addr,
-		metrics.PathOption(cfg.Path),
-		metrics.AutherOption(auther),
-	)
+	return p.srv.ReloadConfig(touched...)
 }
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+)
+
+func (l *tcpListener) setReusePort(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+//go:generate go run ./internal/metadatagen -o metadata_gen.go
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+)
+
+// metadataKey is a metadata key read by a component, with the kind of its
+// value: bool, int, float, duration, string, strings or map.
+type metadataKey struct {
+	name string
+	kind string
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	chainGroupEntryType = reflect.TypeOf(config.ChainGroupEntry{})
+)
+
+// writeSchema writes the JSON Schema (draft 2020-12) of the config files.
+//
+// The schema is generated from config.Config: the properties are named as
+// in the JSON config written by -O json, with the YAML names as aliases
+// where they differ. The handler, listener, dialer and connector types are
+// the registered ones, and the metadata keys known to be read by a type
+// are described, other keys are still allowed.
+func writeSchema(w io.Writer) error {
+	b := &schemaBuilder{defs: make(map[string]map[string]any)}
+
+	root := b.object(reflect.TypeOf(config.Config{}))
+	root["properties"].(map[string]any)["include"] = map[string]any{
+		"description": "config files to include, paths or glob patterns relative to this file",
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+
+	// The tls block of the management services is read from the files.
+	tls := b.object(reflect.TypeOf(server.TLS{}))
+	tls["description"] = "TLS of the service, read again when its files change"
+	tls["required"] = []string{"certFile", "keyFile"}
+	tls["properties"].(map[string]any)["clientAuth"] = map[string]any{
+		"type": "string",
+		"enum": []string{server.ClientAuthNone, server.ClientAuthRequest, server.ClientAuthRequire},
+	}
+	b.defs["ManagementTLS"] = tls
+	for _, def := range []string{"APIConfig", "MetricsConfig"} {
+		if d := b.defs[def]; d != nil {
+			d["properties"].(map[string]any)["tls"] = map[string]any{"$ref": "#/$defs/ManagementTLS"}
+		}
+	}
+
+	for _, c := range []struct {
+		def  string
+		kind string
+		list func() []string
+	}{
+		{"HandlerConfig", "handler", func() []string { return registered(registry.HandlerRegistry().GetAll()) }},
+		{"ListenerConfig", "listener", func() []string { return registered(registry.ListenerRegistry().GetAll()) }},
+		{"DialerConfig", "dialer", func() []string { return registered(registry.DialerRegistry().GetAll()) }},
+		{"ConnectorConfig", "connector", func() []string { return registered(registry.ConnectorRegistry().GetAll()) }},
+	} {
+		if def := b.defs[c.def]; def != nil {
+			componentSchema(def, c.kind, c.list())
+		}
+	}
+
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "gost config"
+	root["$defs"] = b.defs
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(root)
+}
+
+func registered[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// componentSchema restricts the type of a component config to the
+// registered types, and describes the metadata keys of each type.
+func componentSchema(def map[string]any, kind string, types []string) {
+	props := def["properties"].(map[string]any)
+	props["type"] = map[string]any{
+		"type": "string",
+		"anyOf": []any{
+			map[string]any{"enum": types},
+			// The type may be given by a reference, e.g. ${LISTENER:-tcp}.
+			map[string]any{"pattern": `\$\{`},
+		},
+	}
+
+	var conds []any
+	for _, typ := range types {
+		keys := knownMetadata[kind][typ]
+		if len(keys) == 0 {
+			continue
+		}
+		mdProps := make(map[string]any)
+		for _, k := range keys {
+			mdProps[k.name] = metadataSchema(k.kind)
+		}
+		conds = append(conds, map[string]any{
+			"if": map[string]any{
+				"properties": map[string]any{"type": map[string]any{"const": typ}},
+				"required":   []string{"type"},
+			},
+			"then": map[string]any{
+				"properties": map[string]any{
+					"metadata": map[string]any{"properties": mdProps},
+				},
+			},
+		})
+	}
+	if len(conds) > 0 {
+		def["allOf"] = conds
+	}
+}
+
+// metadataSchema returns the schema of a metadata value. The values are
+// converted when read, e.g. a bool may be given as a string.
+func metadataSchema(kind string) map[string]any {
+	switch kind {
+	case "bool":
+		return map[string]any{"type": []string{"boolean", "integer", "string"}}
+	case "int":
+		return map[string]any{"type": []string{"integer", "boolean", "string"}}
+	case "float":
+		return map[string]any{"type": []string{"number", "string"}}
+	case "duration":
+		return map[string]any{
+			"type":        []string{"string", "integer"},
+			"description": "a duration such as 30s or 1m30s, or a number of seconds",
+		}
+	case "strings":
+		return map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+	case "map":
+		return map[string]any{"type": "object"}
+	default:
+		return map[string]any{"type": []string{"string", "number", "boolean"}}
+	}
+}
+
+// schemaBuilder builds the schemas of Go types, with the struct types as
+// definitions referenced by name.
+type schemaBuilder struct {
+	defs map[string]map[string]any
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case durationType:
+		return map[string]any{
+			"type":        []string{"string", "integer"},
+			"description": "a duration such as 30s or 1m30s, or a number of nanoseconds",
+		}
+	case chainGroupEntryType:
+		// A chain group entry may be given as the chain name.
+		return map[string]any{
+			"anyOf": []any{
+				map[string]any{"type": "string"},
+				b.ref(t),
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Struct:
+		return b.ref(t)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		// Any value.
+		return map[string]any{}
+	}
+}
+
+// ref returns a reference to the definition of the struct type t, defining
+// it first if needed.
+func (b *schemaBuilder) ref(t reflect.Type) map[string]any {
+	name := t.Name()
+	if _, ok := b.defs[name]; !ok {
+		// Defined before building it for the recursive types.
+		b.defs[name] = nil
+		b.defs[name] = b.object(t)
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+// object returns the schema of the struct type t.
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := b.schema(f.Type)
+		props[name] = s
+		// The config is also written in YAML, with the YAML names.
+		if yname := yamlName(f); yname != name {
+			props[yname] = s
+		}
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// yamlName returns the YAML name of a struct field, the field name in lower
+// case unless the yaml tag names it.
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name
+}
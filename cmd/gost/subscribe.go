@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/gost/controlplane"
+	"github.com/go-gost/x/config"
+)
+
+// loadConfig parses the config sources, with the resources received from
+// the management server added.
+func (p *program) loadConfig() (*config.Config, error) {
+	cfg, err := parseConfig()
+	if err != nil {
+		return nil, err
+	}
+	if resources := p.resources.Load(); resources != nil {
+		return controlplane.Merge(cfg, *resources)
+	}
+	return cfg, nil
+}
+
+// subscribe applies the resources of the management server given by
+// --subscribe as they are updated. An update is applied as a reload, it is
+// rejected if the reload fails.
+func (p *program) subscribe(ctx context.Context) {
+	node := nodeName
+	if node == "" {
+		node, _ = os.Hostname()
+	}
+	c := controlplane.NewClient(subscribe, node)
+
+	c.Run(ctx, func(resources []controlplane.Resource) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		old := p.resources.Swap(&resources)
+		d, err := p.srv.ReloadConfig()
+		if err != nil {
+			p.resources.Store(old)
+			return err
+		}
+		logger.Default().Infof("config updated by %s: %s", subscribe, d)
+		return nil
+	})
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	restartBackoffMin = time.Second
+	restartBackoffMax = 30 * time.Second
+)
+
+type restartPolicy string
+
+const (
+	restartAlways    restartPolicy = "always"
+	restartOnFailure restartPolicy = "on-failure"
+	restartNever     restartPolicy = "never"
+)
+
+// workerOptions are the supervision options of a worker, given by the
+// -restart, -restart-max and -restart-window flags of its argument group.
+type workerOptions struct {
+	restart restartPolicy
+	// maxRestarts is the maximum number of restarts within window,
+	// the worker is given up when it is exceeded.
+	maxRestarts int
+	window      time.Duration
+}
+
+func parseWorkerOptions(args []string) (workerOptions, error) {
+	opts := workerOptions{
+		restart:     restartNever,
+		maxRestarts: 5,
+		window:      time.Minute,
+	}
+
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		switch name {
+		case "restart", "restart-max", "restart-window":
+		default:
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		var err error
+		switch name {
+		case "restart":
+			switch p := restartPolicy(value); p {
+			case restartAlways, restartOnFailure, restartNever:
+				opts.restart = p
+			default:
+				err = fmt.Errorf("invalid restart policy %q", value)
+			}
+		case "restart-max":
+			opts.maxRestarts, err = strconv.Atoi(value)
+		case "restart-window":
+			opts.window, err = time.ParseDuration(value)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+
+	return opts, nil
+}
+
+// supervise runs one worker process per argument group, restarting them
+// according to their restart policy, until all of them are finished.
+// SIGHUP, SIGINT and SIGTERM are forwarded to the workers, the latter two
+// also stop the supervision. The workers share the process group of the
+// supervisor: in the foreground of a terminal, they get its SIGINT directly,
+// which is not forwarded then. The returned exit status is the highest one
+// of the workers.
+func supervise(groups [][]string) int {
+	s := &supervisor{
+		stop: make(chan struct{}),
+	}
+	for id, args := range groups {
+		opts, err := parseWorkerOptions(args)
+		if err != nil {
+			log.Printf("worker %d: %v", id, err)
+			return 2
+		}
+		s.workers = append(s.workers, &worker{
+			id:   id,
+			args: args,
+			opts: opts,
+		})
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				if sig != syscall.SIGHUP {
+					s.shutdown()
+				}
+				if sig == syscall.SIGINT && foreground() {
+					continue
+				}
+				for _, w := range s.workers {
+					w.signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	codes := make([]int, len(s.workers))
+	for i, w := range s.workers {
+		wg.Go(func() {
+			codes[i] = w.supervise(s.stop)
+		})
+	}
+	wg.Wait()
+	close(done)
+
+	code := 0
+	for _, c := range codes {
+		code = max(code, c)
+	}
+	return code
+}
+
+type supervisor struct {
+	workers  []*worker
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (s *supervisor) shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+type worker struct {
+	id   int
+	args []string
+	opts workerOptions
+
+	mu   sync.Mutex
+	proc *os.Process
+}
+
+// supervise runs the worker until it is not to be restarted any more, and
+// returns its last exit status.
+func (w *worker) supervise(stop <-chan struct{}) int {
+	backoff := restartBackoffMin
+	var restarts []time.Time
+
+	code := 0
+	for {
+		start := time.Now()
+		c, started := w.run(stop)
+		if !started {
+			return code
+		}
+		code = c
+
+		select {
+		case <-stop:
+			return code
+		default:
+		}
+
+		switch w.opts.restart {
+		case restartAlways:
+		case restartOnFailure:
+			if code == 0 {
+				return code
+			}
+		default:
+			if code != 0 {
+				log.Printf("worker %d exited with status %d", w.id, code)
+			}
+			return code
+		}
+
+		now := time.Now()
+		for len(restarts) > 0 && now.Sub(restarts[0]) > w.opts.window {
+			restarts = restarts[1:]
+		}
+		if len(restarts) >= w.opts.maxRestarts {
+			log.Printf("worker %d exited with status %d, restarted %d times in %s, giving up",
+				w.id, code, len(restarts), w.opts.window)
+			return code
+		}
+
+		// A worker that ran long enough is considered healthy again.
+		if now.Sub(start) > restartBackoffMax {
+			backoff = restartBackoffMin
+		}
+		log.Printf("worker %d exited with status %d, restarting in %s", w.id, code, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return code
+		}
+		backoff = min(backoff*2, restartBackoffMax)
+		restarts = append(restarts, time.Now())
+	}
+}
+
+// run runs the worker process once and returns its exit status, and
+// whether it was started: it is not once stop is closed, the signals
+// stopping the supervision being forwarded to the running processes only.
+func (w *worker) run(stop <-chan struct{}) (int, bool) {
+	prefix := fmt.Sprintf("[%d] ", w.id)
+	stdout := &prefixWriter{w: os.Stdout, prefix: prefix}
+	stderr := &prefixWriter{w: os.Stderr, prefix: prefix}
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command(os.Args[0], w.args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("_GOST_ID=%d", w.id))
+
+	w.mu.Lock()
+	select {
+	case <-stop:
+		w.mu.Unlock()
+		return 0, false
+	default:
+	}
+	err := cmd.Start()
+	if err == nil {
+		w.proc = cmd.Process
+	}
+	w.mu.Unlock()
+	if err != nil {
+		log.Printf("worker %d: %v", w.id, err)
+		return 1, true
+	}
+
+	cmd.Wait()
+
+	w.mu.Lock()
+	w.proc = nil
+	w.mu.Unlock()
+
+	return exitStatus(cmd.ProcessState), true
+}
+
+func (w *worker) signal(sig os.Signal) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.proc != nil {
+		w.proc.Signal(sig)
+	}
+}
+
+// exitStatus returns the exit code of a process, or 128 plus the signal
+// number if it was killed by a signal, as shells do.
+func exitStatus(ps *os.ProcessState) int {
+	if ps == nil {
+		return 1
+	}
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
+
+// prefixWriter writes every line written to it prefixed.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+// outputMu serializes the lines written by the workers.
+var outputMu sync.Mutex
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		pw.writeLine(pw.buf[:i+1])
+		pw.buf = pw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line if it is not terminated.
+func (pw *prefixWriter) Flush() {
+	if len(pw.buf) > 0 {
+		pw.writeLine(append(pw.buf, '\n'))
+		pw.buf = nil
+	}
+}
+
+func (pw *prefixWriter) writeLine(line []byte) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	io.WriteString(pw.w, pw.prefix)
+	pw.w.Write(line)
+}
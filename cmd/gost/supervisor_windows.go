@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+// foreground reports true, the console sends its Ctrl+C to all the
+// processes attached to it.
+func foreground() bool {
+	return true
+}
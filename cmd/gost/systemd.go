@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+// sdNotify sends state to the service manager, as sd_notify(3) does. It
+// does nothing if the program is not run by systemd with a notification
+// socket (Type=notify).
+func sdNotify(state ...string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	// An abstract socket.
+	if strings.HasPrefix(addr, "@") {
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(state, "\n")))
+	return err
+}
+
+// notify is sdNotify with the error logged.
+func notify(state ...string) {
+	if err := sdNotify(state...); err != nil {
+		logger.Default().Warnf("sd_notify: %v", err)
+	}
+}
+
+// sdWatchdog returns the watchdog timeout set by the service manager
+// (WatchdogSec=), or 0 if there is none for this process.
+func sdWatchdog() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// watchdog keeps the service manager watchdog from firing, pinging it at
+// half its timeout.
+func watchdog(ctx context.Context) {
+	timeout := sdWatchdog()
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		notify("WATCHDOG=1")
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// activated holds the sockets passed by the service manager
+// (socket activation).
+var activated = activateSockets()
+
+// activatedSocket is a socket passed by the service manager.
+type activatedSocket struct {
+	// name is the FileDescriptorName= of the socket.
+	name string
+	// network is tcp or udp.
+	network string
+	addr    net.Addr
+	f       *os.File
+}
+
+type activatedSockets struct {
+	sockets []*activatedSocket
+	mu      sync.Mutex
+}
+
+// activateSockets reads the sockets passed by the service manager, as
+// sd_listen_fds_with_names(3) does. Only TCP and UDP sockets are used.
+func activateSockets() *activatedSockets {
+	s := &activatedSockets{}
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return s
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil {
+		return s
+	}
+
+	for i := range n {
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(3+i), name)
+		if ln, err := net.FileListener(f); err == nil {
+			if _, ok := ln.(*net.TCPListener); ok {
+				s.sockets = append(s.sockets, &activatedSocket{name: name, network: "tcp", addr: ln.Addr(), f: f})
+			}
+			ln.Close()
+			continue
+		}
+		if pc, err := net.FilePacketConn(f); err == nil {
+			if _, ok := pc.(*net.UDPConn); ok {
+				s.sockets = append(s.sockets, &activatedSocket{name: name, network: "udp", addr: pc.LocalAddr(), f: f})
+			}
+			pc.Close()
+		}
+	}
+
+	return s
+}
+
+// take returns a copy of the socket for the service, the socket named
+// after the service or else the one bound to its address, and nil if there
+// is none. The caller owns the file. The activated sockets are kept open,
+// so that the services can take them again when they are reloaded.
+func (s *activatedSockets) take(service, network, addr string) *os.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	network = strings.TrimRight(network, "46")
+
+	var found *activatedSocket
+	for _, sock := range s.sockets {
+		if sock.network == network && sock.name == service {
+			found = sock
+			break
+		}
+	}
+	if found == nil {
+		for _, sock := range s.sockets {
+			if sock.network == network && sameAddr(sock.addr, addr) {
+				found = sock
+				break
+			}
+		}
+	}
+	if found == nil {
+		return nil
+	}
+
+	f, err := dupFile(found.f)
+	if err != nil {
+		logger.Default().Errorf("socket %s: %v", found.addr, err)
+		return nil
+	}
+	return f
+}
+
+// sameAddr reports whether a socket bound to sa is one listening on addr.
+// Unspecified hosts (:8080, 0.0.0.0:8080, [::]:8080) are considered the
+// same.
+func sameAddr(sa net.Addr, addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	saHost, saPort, err := net.SplitHostPort(sa.String())
+	if err != nil || port != saPort {
+		return false
+	}
+
+	ip, saIP := net.ParseIP(host), net.ParseIP(saHost)
+	if host == "" || ip != nil && ip.IsUnspecified() {
+		return saIP != nil && saIP.IsUnspecified()
+	}
+	return ip != nil && ip.Equal(saIP)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func dupFile(f *os.File) (*os.File, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func monotonicUsec() int64 {
+	return 0
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-gost/gost/server"
+	"gopkg.in/yaml.v3"
+)
+
+// The config sections of the management services with a tls block.
+var tlsSections = []string{"api", "metrics"}
+
+// readTLS returns the TLS of the API and metrics services, by section: the
+// tls blocks of their sections in the config files, such as
+//
+//	api:
+//	  addr: :18080
+//	  tls:
+//	    certFile: /etc/gost/api.crt
+//	    keyFile: /etc/gost/api.key
+//	    caFile: /etc/gost/clients-ca.crt
+//	    clientAuth: require
+//
+// The config types have no such block, it is read from the files as the
+// include key is, with the references of its strings expanded. As the
+// sections are, the block of the last file setting the section applies,
+// none if the section is given by -api or -metrics. With a client CA, the
+// clients are authenticated by the common name of the subject of their
+// certificate, given roles by --api-access.
+func readTLS(src *sourceIndex, record func(ref, value string)) (map[string]tlsBlock, error) {
+	blocks := make(map[string]tlsBlock)
+	for _, section := range tlsSections {
+		if section == "api" && apiAddr != "" || section == "metrics" && metricsAddr != "" {
+			continue
+		}
+
+		var block tlsBlock
+		for _, f := range src.files {
+			sec := mappingValue(f.root, section)
+			if sec == nil {
+				continue
+			}
+			block = tlsBlock{}
+			n := mappingValue(sec, "tls")
+			if n == nil || n.Tag == "!!null" {
+				continue
+			}
+
+			pos := fmt.Sprintf("%s:%d:%d: %s.tls", f.name, n.Line, n.Column, section)
+			b, err := yaml.Marshal(n)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", pos, err)
+			}
+			t := &server.TLS{}
+			if err := decodeStrict(b, t); err != nil {
+				return nil, fmt.Errorf("%s: %w", pos, err)
+			}
+			for _, s := range []*string{&t.CertFile, &t.KeyFile, &t.CAFile, &t.ClientAuth} {
+				if *s, err = expand(*s, record); err != nil {
+					return nil, fmt.Errorf("%s: %w", pos, err)
+				}
+			}
+			if err := t.Validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", pos, err)
+			}
+			block = tlsBlock{node: n, tls: t}
+		}
+		if block.tls != nil {
+			blocks[section] = block
+		}
+	}
+	return blocks, nil
+}
+
+// tlsBlock is the tls block of a section.
+type tlsBlock struct {
+	// node is the block as written, with its references.
+	node *yaml.Node
+	tls  *server.TLS
+}
+
+// managementTLS is the TLS of the management services of the last parsed
+// config.
+var managementTLS = &tlsBlocks{}
+
+type tlsBlocks struct {
+	blocks map[string]tlsBlock
+	mu     sync.Mutex
+}
+
+func (b *tlsBlocks) set(blocks map[string]tlsBlock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.blocks = blocks
+}
+
+// get returns the TLS of the service, @api or @metrics.
+func (b *tlsBlocks) get(service string) *server.TLS {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.blocks[strings.TrimPrefix(service, "@")].tls
+}
+
+// insert adds the tls blocks to the sections of root, a config written
+// from the config types.
+func (b *tlsBlocks) insert(root *yaml.Node) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, section := range tlsSections {
+		block, ok := b.blocks[section]
+		sec := mappingValue(root, section)
+		if !ok || sec == nil || sec.Kind != yaml.MappingNode || mappingValue(sec, "tls") != nil {
+			continue
+		}
+		sec.Content = append(sec.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "tls"}, block.node)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+)
+
+type udpSessionConfig struct {
+	backlog        int
+	readQueueSize  int
+	readBufferSize int
+	ttl            time.Duration
+	keepalive      bool
+	stateless      bool
+	logger         logger.Logger
+}
+
+// udpSessionListener demultiplexes the datagrams read from a packet
+// connection into one connection per client. Idle connections are closed
+// after ttl.
+//
+// In stateless mode, each accepted connection holds a single datagram.
+type udpSessionListener struct {
+	conn    net.PacketConn
+	cqueue  chan net.Conn
+	conns   sync.Map
+	closed  chan struct{}
+	errChan chan error
+	config  *udpSessionConfig
+}
+
+func newUDPSessionListener(conn net.PacketConn, cfg *udpSessionConfig) net.Listener {
+	ln := &udpSessionListener{
+		conn:    conn,
+		closed:  make(chan struct{}),
+		errChan: make(chan error, 1),
+		config:  cfg,
+	}
+	if cfg.stateless {
+		return ln
+	}
+
+	ln.cqueue = make(chan net.Conn, cfg.backlog)
+	go ln.listenLoop()
+	go ln.idleCheck()
+
+	return ln
+}
+
+func (ln *udpSessionListener) Accept() (conn net.Conn, err error) {
+	if ln.config.stateless {
+		return ln.acceptStateless()
+	}
+
+	select {
+	case conn = <-ln.cqueue:
+		return
+	case <-ln.closed:
+		return nil, net.ErrClosed
+	case err = <-ln.errChan:
+		if err == nil {
+			err = net.ErrClosed
+		}
+		return
+	}
+}
+
+func (ln *udpSessionListener) acceptStateless() (net.Conn, error) {
+	b := bufpool.Get(ln.config.readBufferSize)
+
+	n, raddr, err := ln.conn.ReadFrom(b)
+	if err != nil {
+		bufpool.Put(b)
+		select {
+		case <-ln.closed:
+			return nil, net.ErrClosed
+		default:
+		}
+		return nil, err
+	}
+
+	return &udpDatagramConn{
+		pc:         ln.conn,
+		data:       b[:n],
+		localAddr:  ln.Addr(),
+		remoteAddr: raddr,
+	}, nil
+}
+
+func (ln *udpSessionListener) listenLoop() {
+	for {
+		b := bufpool.Get(ln.config.readBufferSize)
+
+		n, raddr, err := ln.conn.ReadFrom(b)
+		if err != nil {
+			bufpool.Put(b)
+			ln.errChan <- err
+			close(ln.errChan)
+			return
+		}
+
+		c := ln.getConn(raddr)
+		if c == nil {
+			bufpool.Put(b)
+			continue
+		}
+
+		if err := c.writeQueue(b[:n]); err != nil {
+			bufpool.Put(b)
+			ln.config.logger.Warn("data discarded: ", err)
+		}
+	}
+}
+
+func (ln *udpSessionListener) getConn(raddr net.Addr) *udpSessionConn {
+	if v, ok := ln.conns.Load(raddr.String()); ok {
+		if c := v.(*udpSessionConn); !c.isClosed() {
+			return c
+		}
+	}
+
+	c := &udpSessionConn{
+		PacketConn: ln.conn,
+		localAddr:  ln.Addr(),
+		remoteAddr: raddr,
+		rc:         make(chan []byte, ln.config.readQueueSize),
+		closed:     make(chan struct{}),
+		keepalive:  ln.config.keepalive,
+	}
+	select {
+	case ln.cqueue <- c:
+		ln.conns.Store(raddr.String(), c)
+		return c
+	default:
+		c.Close()
+		ln.config.logger.Warnf("connection queue is full, client %s discarded", raddr)
+		return nil
+	}
+}
+
+// idleCheck closes the connections which had no traffic for ttl.
+func (ln *udpSessionListener) idleCheck() {
+	ticker := time.NewTicker(ln.config.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			size, idles := 0, 0
+			ln.conns.Range(func(key, value any) bool {
+				c := value.(*udpSessionConn)
+				size++
+				if c.idle.Load() {
+					idles++
+					ln.conns.Delete(key)
+					c.Close()
+					return true
+				}
+				c.idle.Store(true)
+				return true
+			})
+			if idles > 0 {
+				ln.config.logger.Debugf("connection pool: size=%d, idle=%d", size, idles)
+			}
+		case <-ln.closed:
+			return
+		}
+	}
+}
+
+func (ln *udpSessionListener) Addr() net.Addr {
+	return ln.conn.LocalAddr()
+}
+
+func (ln *udpSessionListener) Close() error {
+	select {
+	case <-ln.closed:
+	default:
+		close(ln.closed)
+		ln.conn.Close()
+		ln.conns.Range(func(key, value any) bool {
+			value.(*udpSessionConn).Close()
+			return true
+		})
+	}
+	return nil
+}
+
+// udpSessionConn is the server side connection of a UDP client.
+type udpSessionConn struct {
+	net.PacketConn
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	rc         chan []byte
+	idle       atomic.Bool
+	closed     chan struct{}
+	closeOnce  sync.Once
+	keepalive  bool
+}
+
+func (c *udpSessionConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	select {
+	case bb := <-c.rc:
+		n = copy(b, bb)
+		c.idle.Store(false)
+		bufpool.Put(bb)
+	case <-c.closed:
+		err = net.ErrClosed
+		return
+	}
+
+	addr = c.remoteAddr
+	return
+}
+
+func (c *udpSessionConn) Read(b []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+func (c *udpSessionConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if !c.keepalive {
+		defer c.Close()
+	}
+	c.idle.Store(false)
+
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func (c *udpSessionConn) Write(b []byte) (n int, err error) {
+	return c.WriteTo(b, c.remoteAddr)
+}
+
+func (c *udpSessionConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *udpSessionConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *udpSessionConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *udpSessionConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *udpSessionConn) writeQueue(b []byte) error {
+	select {
+	case c.rc <- b:
+		c.idle.Store(false)
+		return nil
+	case <-c.closed:
+		return net.ErrClosed
+	default:
+		return errors.New("recv queue is full")
+	}
+}
+
+// udpDatagramConn is a connection holding a single datagram.
+type udpDatagramConn struct {
+	pc         net.PacketConn
+	data       []byte
+	offset     int
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *udpDatagramConn) Read(b []byte) (n int, err error) {
+	if c.data == nil || c.offset >= len(c.data) {
+		return 0, net.ErrClosed
+	}
+	n = copy(b, c.data[c.offset:])
+	c.offset += n
+	return
+}
+
+func (c *udpDatagramConn) Write(b []byte) (n int, err error) {
+	return c.pc.WriteTo(b, c.remoteAddr)
+}
+
+func (c *udpDatagramConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, err = c.Read(b)
+	addr = c.remoteAddr
+	return
+}
+
+func (c *udpDatagramConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	return c.pc.WriteTo(b, addr)
+}
+
+func (c *udpDatagramConn) Close() error {
+	bufpool.Put(c.data)
+	c.data = nil
+	return nil
+}
+
+func (c *udpDatagramConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *udpDatagramConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *udpDatagramConn) SetDeadline(t time.Time) error {
+	return c.pc.SetReadDeadline(t)
+}
+
+func (c *udpDatagramConn) SetReadDeadline(t time.Time) error {
+	return c.pc.SetReadDeadline(t)
+}
+
+func (c *udpDatagramConn) SetWriteDeadline(t time.Time) error {
+	return c.pc.SetWriteDeadline(t)
+}
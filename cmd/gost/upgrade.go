@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+)
+
+const (
+	// envListenFds lists the sockets passed to a process started by an
+	// upgrade, as comma separated service|network|addr entries with escaped
+	// fields. The sockets are the file descriptors from 3 on, in order.
+	envListenFds = "_GOST_LISTEN_FDS"
+	// envUpgradeFd is the file descriptor of the connection to the process
+	// which started the upgrade.
+	envUpgradeFd = "_GOST_UPGRADE_FD"
+
+	// upgradeTimeout is how long each side of an upgrade waits for the other.
+	upgradeTimeout = 30 * time.Second
+	// upgradeDrain is how long the upgraded process waits for its
+	// connections to finish when no -drain timeout is set.
+	upgradeDrain = 30 * time.Second
+)
+
+// The messages of the upgrade handshake. The new process tells the old one
+// it is ready once it serves on the inherited sockets, the old one then
+// releases its services (the sockets of the management services and of the
+// services which were not passed among them) and tells the new one so. The
+// new process tells the old one it serves once its whole config is applied,
+// the old one exits then. If the new process fails to apply its config, it
+// closes its sockets and the connection instead, and the old one serves
+// its config again.
+const (
+	upgradeReady    = "ready"
+	upgradeReleased = "released"
+	upgradeServing  = "serving"
+)
+
+func socketID(service, network, addr string) string {
+	return url.QueryEscape(service) + "|" + network + "|" + url.QueryEscape(addr)
+}
+
+// inherited holds the sockets passed by the previous process, if this one
+// is started by an upgrade.
+var inherited = inheritSockets()
+
+type inheritedSockets struct {
+	files map[string]*os.File
+	// conn is the connection to the previous process.
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func inheritSockets() *inheritedSockets {
+	s := &inheritedSockets{
+		files: make(map[string]*os.File),
+	}
+
+	fd, err := strconv.Atoi(os.Getenv(envUpgradeFd))
+	if err != nil {
+		return s
+	}
+	ids := os.Getenv(envListenFds)
+	os.Unsetenv(envUpgradeFd)
+	os.Unsetenv(envListenFds)
+
+	if ids != "" {
+		for i, id := range strings.Split(ids, ",") {
+			s.files[id] = os.NewFile(uintptr(3+i), id)
+		}
+	}
+
+	f := os.NewFile(uintptr(fd), "upgrade")
+	if s.conn, err = net.FileConn(f); err != nil {
+		// The sockets are kept, the handshake fails later on.
+		fmt.Fprintf(os.Stderr, "upgrade: %v\n", err)
+	}
+	f.Close()
+
+	return s
+}
+
+// upgrading reports whether this process is started by an upgrade.
+func (s *inheritedSockets) upgrading() bool {
+	return len(s.files) > 0 || s.conn != nil
+}
+
+// take returns the socket of the service if one was passed for the same
+// network and address, and nil otherwise. The caller owns the file.
+func (s *inheritedSockets) take(service, network, addr string) *os.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := socketID(service, network, addr)
+	f := s.files[id]
+	delete(s.files, id)
+	return f
+}
+
+// has reports whether a socket was passed for the service.
+func (s *inheritedSockets) has(service string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := url.QueryEscape(service) + "|"
+	for id := range s.files {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// handshake tells the previous process that this one is ready and waits
+// for it to release its resources.
+func (s *inheritedSockets) handshake() error {
+	if s.conn == nil {
+		return fmt.Errorf("no connection to the previous process")
+	}
+
+	s.conn.SetDeadline(time.Now().Add(upgradeTimeout))
+	if _, err := fmt.Fprintln(s.conn, upgradeReady); err != nil {
+		return err
+	}
+	return expect(bufio.NewReader(s.conn), upgradeReleased)
+}
+
+// serving tells the previous process that this one serves its whole
+// config.
+func (s *inheritedSockets) serving() error {
+	s.conn.SetDeadline(time.Now().Add(upgradeTimeout))
+	_, err := fmt.Fprintln(s.conn, upgradeServing)
+	return err
+}
+
+// close closes the connection to the previous process and the sockets
+// no service took.
+func (s *inheritedSockets) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	for id, f := range s.files {
+		f.Close()
+		logger.Default().Debugf("inherited socket %s unused, closed", id)
+	}
+	clear(s.files)
+}
+
+// expect reads a message of the upgrade handshake.
+func expect(r *bufio.Reader, msg string) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line = strings.TrimSpace(line); line != msg {
+		return fmt.Errorf("unexpected message %q", line)
+	}
+	return nil
+}
+
+// adopt starts a server for cfg in a process started by an upgrade. The
+// services whose sockets are inherited are started first, the rest of cfg
+// once the previous process has released its resources, with a warning as
+// they refuse connections meanwhile. If cfg can not be applied then, the
+// server is closed for the previous process to serve again.
+func adopt(cfg *config.Config, opts ...server.Option) (*server.Server, error) {
+	defer inherited.close()
+
+	partial := *cfg
+	partial.Services = nil
+	partial.API = nil
+	partial.Metrics = nil
+	partial.Profiling = nil
+	var pending []*config.ServiceConfig
+	for _, svc := range cfg.Services {
+		if svc == nil {
+			continue
+		}
+		if inherited.has(svc.Name) {
+			partial.Services = append(partial.Services, svc)
+		} else {
+			pending = append(pending, svc)
+		}
+	}
+
+	srv := server.New(&partial, opts...)
+	if err := srv.Start(context.Background()); err != nil {
+		return nil, err
+	}
+
+	// Only the sockets of the tcp and udp listeners are passed.
+	for _, svc := range pending {
+		listener := "tcp"
+		if svc.Listener != nil && svc.Listener.Type != "" {
+			listener = svc.Listener.Type
+		}
+		logger.Default().Warnf("upgrade: service %s (%s listener) has no inherited socket, it listens once the previous process has released it", svc.Name, listener)
+	}
+
+	if err := inherited.handshake(); err != nil {
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	logger.Default().Infof("upgrade: serving on %d inherited socket(s)", len(partial.Services))
+
+	if _, err := srv.Reload(cfg); err != nil {
+		srv.Close()
+		return nil, err
+	}
+	if err := inherited.serving(); err != nil {
+		srv.Close()
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	return srv, nil
+}
@@ -0,0 +1,177 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+// handleUpgrade upgrades the program on SIGUSR2.
+func (p *program) handleUpgrade(ctx context.Context) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR2)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-c:
+			if err := p.upgrade(); err != nil {
+				logger.Default().Errorf("upgrade: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// upgrade starts the executable again, which may have been replaced by a
+// new version, passing it the listening sockets of the services. Once the
+// new process serves on them, this one stops accepting connections and
+// releases its other resources. Once the new process serves its whole
+// config, this one exits after draining. If the new process fails to start
+// or to serve its config, this one keeps running, serving its config again.
+func (p *program) upgrade() error {
+	log := logger.Default().WithFields(map[string]any{"kind": "upgrade"})
+
+	// No reload while the sockets are handed over.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	defer closeFiles()
+
+	var ids []string
+	for _, s := range sockets.list() {
+		f, err := s.s.File()
+		if err != nil {
+			return fmt.Errorf("service %s: %w", s.service, err)
+		}
+		files = append(files, f)
+		ids = append(ids, socketID(s.service, s.network, s.addr))
+	}
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+
+	local := os.NewFile(uintptr(fds[0]), "upgrade")
+	remote := os.NewFile(uintptr(fds[1]), "upgrade")
+	conn, err := net.FileConn(local)
+	local.Close()
+	if err != nil {
+		remote.Close()
+		return err
+	}
+	defer conn.Close()
+
+	// The new process becomes the main process of the service, the
+	// watchdog applies to it.
+	var env []string
+	for _, v := range os.Environ() {
+		name, _, _ := strings.Cut(v, "=")
+		switch name {
+		case envListenFds, envUpgradeFd, "WATCHDOG_PID":
+		default:
+			env = append(env, v)
+		}
+	}
+	env = append(env,
+		envListenFds+"="+strings.Join(ids, ","),
+		envUpgradeFd+"="+strconv.Itoa(3+len(files)),
+	)
+
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = append(files, remote)
+
+	// The copies of the sockets are closed once passed, for the addresses
+	// to be free once the services are released, if the new process fails.
+	err = cmd.Start()
+	remote.Close()
+	closeFiles()
+	if err != nil {
+		return err
+	}
+	log.Infof("process %d started with %d socket(s), waiting for it to be ready", cmd.Process.Pid, len(files))
+
+	conn.SetDeadline(time.Now().Add(upgradeTimeout))
+	r := bufio.NewReader(conn)
+	if err := expect(r, upgradeReady); err != nil {
+		// The new process closes the connection when it fails to start.
+		if !errors.Is(err, io.EOF) {
+			cmd.Process.Kill()
+		}
+		cmd.Wait()
+		return fmt.Errorf("process %d not ready (%s): %w", cmd.Process.Pid, cmd.ProcessState, err)
+	}
+
+	// The services and the management services are released, the
+	// connections being handled are left running.
+	prev := p.srv.Config()
+	released := *prev
+	released.Services = nil
+	released.API = nil
+	released.Metrics = nil
+	released.Profiling = nil
+	if _, err := p.srv.Reload(&released); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("releasing the services: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(upgradeTimeout))
+	_, err = fmt.Fprintln(conn, upgradeReleased)
+	if err == nil {
+		err = expect(r, upgradeServing)
+	}
+	if err != nil {
+		// The new process closes its sockets and the connection when it
+		// fails to serve its config.
+		if !errors.Is(err, io.EOF) {
+			cmd.Process.Kill()
+		}
+		cmd.Wait()
+		if _, rerr := p.srv.Reload(prev); rerr != nil {
+			return fmt.Errorf("process %d not serving (%s): %w (serving again failed: %v)", cmd.Process.Pid, cmd.ProcessState, err, rerr)
+		}
+		return fmt.Errorf("process %d not serving (%s): %w", cmd.Process.Pid, cmd.ProcessState, err)
+	}
+
+	// From now on the new process serves, this one exits.
+	p.upgraded.Store(true)
+	notify(fmt.Sprintf("MAINPID=%d", cmd.Process.Pid))
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	p.srv.Close()
+	log.Infof("upgraded to process %d, exiting", cmd.Process.Pid)
+
+	// go-svc stops the program on SIGTERM, draining the connections.
+	return syscall.Kill(os.Getpid(), syscall.SIGTERM)
+}
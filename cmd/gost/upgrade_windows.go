@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+)
+
+// handleUpgrade does nothing, upgrades are not supported on Windows.
+func (p *program) handleUpgrade(ctx context.Context) {}
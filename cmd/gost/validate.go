@@ -0,0 +1,417 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// validate checks the config for -t, reporting the problems found on stderr.
+// It returns the exit code of the program.
+func validate() int {
+	files, _, err := resolveIncludes(cfgFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	src, err := indexSources(files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	cfg, err := parseConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	errs := validateConfig(cfg, src)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "config test failed: %d error(s)\n", len(errs))
+		return 1
+	}
+
+	fmt.Fprintln(os.Stdout, "config test passed")
+	return 0
+}
+
+// configError is a problem found in a config, located in the config files
+// when possible.
+type configError struct {
+	pos position
+	// key is the resource the problem is found in, if any.
+	key *server.Resource
+	// path is the location of the problem within the resource config or,
+	// without resource, within the config.
+	path string
+	msg  string
+}
+
+func (e *configError) Error() string {
+	var b strings.Builder
+	if e.pos.file != "" {
+		fmt.Fprintf(&b, "%s: ", e.pos)
+	}
+	if e.key != nil {
+		b.WriteString(e.key.String())
+		if e.path != "" {
+			b.WriteString(" ")
+		}
+	}
+	if e.path != "" {
+		b.WriteString(e.path)
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	b.WriteString(e.msg)
+	return b.String()
+}
+
+// validateConfig checks cfg without building anything: every reference by
+// name must resolve and every component type must be registered. The
+// returned errors are located in files by src and sorted by position.
+func validateConfig(cfg *config.Config, src *sourceIndex) []*configError {
+	var errs []*configError
+
+	resourceErr := func(key server.Resource, path string, format string, args ...any) {
+		errs = append(errs, &configError{
+			pos:  src.locate(key.Kind, key.Name, path),
+			key:  &key,
+			path: path,
+			msg:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	defined := make(map[server.Resource]bool)
+	for _, kind := range server.Kinds() {
+		seen := make(map[string]int)
+		for _, c := range server.Configs(cfg, kind) {
+			name := server.ConfigName(c)
+			key := server.Resource{Kind: kind, Name: name}
+			if name == "" {
+				errs = append(errs, &configError{
+					pos:  src.locate(kind, "", ""),
+					path: kind,
+					msg:  "resource without name",
+				})
+				continue
+			}
+			if seen[name] > 0 {
+				errs = append(errs, &configError{
+					pos:  src.locateNth(kind, name, "name", seen[name]),
+					key:  &key,
+					path: "name",
+					msg:  "duplicate name",
+				})
+			}
+			seen[name]++
+			defined[key] = true
+		}
+	}
+
+	for _, ref := range server.References(cfg) {
+		if !defined[ref.To] {
+			resourceErr(ref.From, ref.Path, "reference to undefined %s", ref.To)
+		}
+	}
+
+	for _, v := range []struct {
+		section string
+		auther  string
+	}{
+		{"api", autherOf(cfg.API)},
+		{"metrics", autherOf(cfg.Metrics)},
+	} {
+		to := server.Resource{Kind: "authers", Name: v.auther}
+		if v.auther != "" && !defined[to] {
+			errs = append(errs, &configError{
+				pos:  src.locateSection(v.section, "auther"),
+				path: v.section + ".auther",
+				msg:  fmt.Sprintf("reference to undefined %s", to),
+			})
+		}
+	}
+
+	checkType := func(key server.Resource, path, kind, typ string, registered func(string) bool) {
+		if typ == "" || registered(typ) {
+			return
+		}
+		resourceErr(key, path, "unknown %s type %q", kind, typ)
+	}
+	checkNodes := func(key server.Resource, nodes []*config.NodeConfig, path string) {
+		for i, node := range nodes {
+			if node == nil {
+				continue
+			}
+			if node.Connector != nil {
+				checkType(key, fmt.Sprintf("%snodes[%d].connector.type", path, i), "connector",
+					node.Connector.Type, registry.ConnectorRegistry().IsRegistered)
+			}
+			if node.Dialer != nil {
+				checkType(key, fmt.Sprintf("%snodes[%d].dialer.type", path, i), "dialer",
+					node.Dialer.Type, registry.DialerRegistry().IsRegistered)
+			}
+		}
+	}
+
+	for _, svc := range cfg.Services {
+		if svc == nil {
+			continue
+		}
+		key := server.Resource{Kind: "services", Name: svc.Name}
+		if svc.Handler != nil {
+			checkType(key, "handler.type", "handler",
+				svc.Handler.Type, registry.HandlerRegistry().IsRegistered)
+		}
+		if svc.Listener != nil {
+			checkType(key, "listener.type", "listener",
+				svc.Listener.Type, registry.ListenerRegistry().IsRegistered)
+		}
+	}
+	for _, c := range cfg.Chains {
+		if c == nil {
+			continue
+		}
+		for i, hop := range c.Hops {
+			if hop != nil {
+				checkNodes(server.Resource{Kind: "chains", Name: c.Name}, hop.Nodes, fmt.Sprintf("hops[%d].", i))
+			}
+		}
+	}
+	for _, hop := range cfg.Hops {
+		if hop != nil {
+			checkNodes(server.Resource{Kind: "hops", Name: hop.Name}, hop.Nodes, "")
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].pos.less(errs[j].pos)
+	})
+	return errs
+}
+
+func autherOf(c any) string {
+	switch c := c.(type) {
+	case *config.APIConfig:
+		if c != nil {
+			return c.Auther
+		}
+	case *config.MetricsConfig:
+		if c != nil {
+			return c.Auther
+		}
+	}
+	return ""
+}
+
+// position is a location in a config file.
+type position struct {
+	file string
+	line int
+	col  int
+}
+
+func (p position) String() string {
+	if p.line == 0 {
+		return p.file
+	}
+	return fmt.Sprintf("%s:%d:%d", p.file, p.line, p.col)
+}
+
+func (p position) less(o position) bool {
+	if p.file != o.file {
+		return p.file < o.file
+	}
+	if p.line != o.line {
+		return p.line < o.line
+	}
+	return p.col < o.col
+}
+
+// sourceIndex locates config items in the YAML (or JSON) documents of the
+// config files they are read from.
+type sourceIndex struct {
+	files []sourceFile
+}
+
+type sourceFile struct {
+	name string
+	root *yaml.Node
+}
+
+// indexSources parses the local config files among the -C arguments.
+// Other sources (stdin, URLs and inline JSON) are not indexed.
+func indexSources(cfgFiles []string) (*sourceIndex, error) {
+	idx := &sourceIndex{}
+	for _, name := range cfgFiles {
+		name = strings.TrimSpace(name)
+		if !isLocalFile(name) {
+			continue
+		}
+
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var root yaml.Node
+		if err := yaml.Unmarshal(b, &root); err != nil {
+			// Syntax errors are reported as "yaml: line N: ...".
+			msg := err.Error()
+			if s, ok := strings.CutPrefix(msg, "yaml: line "); ok {
+				if line, rest, ok := strings.Cut(s, ": "); ok {
+					return nil, fmt.Errorf("%s:%s: %s", name, line, rest)
+				}
+			}
+			return nil, fmt.Errorf("%s: %s", name, msg)
+		}
+		if len(root.Content) == 0 {
+			continue
+		}
+		idx.files = append(idx.files, sourceFile{name: filepath.Clean(name), root: root.Content[0]})
+	}
+	return idx, nil
+}
+
+// isLocalFile reports whether a config source is a file, rather than
+// stdin, an URL or inline JSON.
+func isLocalFile(source string) bool {
+	return source != "" && source != "-" && !strings.HasPrefix(source, "{") && !isURL(source)
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// locate returns the position of path within the config of the named
+// resource, or the closest enclosing position found. The resource is
+// searched in the order of the files, as they are merged.
+func (idx *sourceIndex) locate(kind, name, path string) position {
+	return idx.locateNth(kind, name, path, 0)
+}
+
+// locateNth is like locate for the n-th (from 0) resource of that name.
+func (idx *sourceIndex) locateNth(kind, name, path string, n int) position {
+	if idx == nil {
+		return position{}
+	}
+	for _, f := range idx.files {
+		list := mappingValue(f.root, kind)
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range list.Content {
+			itemName := ""
+			if n := mappingValue(item, "name"); n != nil {
+				itemName = n.Value
+			}
+			if itemName != name {
+				continue
+			}
+			if n > 0 {
+				n--
+				continue
+			}
+			node := walk(item, path)
+			return position{file: f.name, line: node.Line, col: node.Column}
+		}
+	}
+	return position{}
+}
+
+// locateSection returns the position of path within a top-level section.
+func (idx *sourceIndex) locateSection(section, path string) position {
+	if idx == nil {
+		return position{}
+	}
+	for _, f := range idx.files {
+		if v := mappingValue(f.root, section); v != nil {
+			node := walk(v, path)
+			return position{file: f.name, line: node.Line, col: node.Column}
+		}
+	}
+	return position{}
+}
+
+// walk follows path (e.g. handler.chainGroup.chains[0].chain) from node
+// and returns the deepest node reached. Metadata keys may contain dots,
+// the longest matching key is used.
+func walk(node *yaml.Node, path string) *yaml.Node {
+	var segs []string
+	for _, s := range strings.Split(path, ".") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+
+	for i := 0; i < len(segs); {
+		if node.Kind == yaml.SequenceNode {
+			break
+		}
+
+		var next *yaml.Node
+		var n int
+		for j := len(segs); j > i; j-- {
+			key, index := splitIndex(strings.Join(segs[i:j], "."))
+			if v := mappingValue(node, key); v != nil {
+				next, n = v, j-i
+				if index >= 0 {
+					if v.Kind != yaml.SequenceNode || index >= len(v.Content) {
+						return v
+					}
+					next = v.Content[index]
+				}
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+		i += n
+	}
+	return node
+}
+
+// splitIndex splits a path segment such as nodes[1] into its key and index.
+// The index is -1 if there is none.
+func splitIndex(s string) (string, int) {
+	if !strings.HasSuffix(s, "]") {
+		return s, -1
+	}
+	i := strings.LastIndexByte(s, '[')
+	if i < 0 {
+		return s, -1
+	}
+	n, err := strconv.Atoi(s[i+1 : len(s)-1])
+	if err != nil {
+		return s, -1
+	}
+	return s[:i], n
+}
+
+// mappingValue returns the value of key in a mapping node. Keys are matched
+// case-insensitively, as they are when the config is read.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+)
+
+// watchDebounce is how long the watcher waits for the file events to settle
+// before reloading. Editors and deployment tools usually write a file in
+// several steps (truncate and write, or write a temporary file and rename).
+const watchDebounce = 500 * time.Millisecond
+
+// watch reloads the config when the content of the config files and of the
+// files they include, or of the
+// files the running config reads (TLS certificates and keys, bypass and
+// hosts data files, secret files referenced by the config), changes.
+//
+// The parent directories are watched rather than the files, so that a file
+// replaced by renaming another one over it is still followed.
+func (p *program) watch(ctx context.Context) {
+	log := logger.Default().WithFields(map[string]any{"kind": "watcher"})
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer w.Close()
+
+	files, patterns := watchedFiles(config.Global())
+	hashes := hashFiles(files)
+	dirs := make(map[string]bool)
+	updateWatches(w, dirs, files, patterns, log)
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(ev.Name)
+			if _, ok := files[name]; !ok && !matchAny(patterns, name) {
+				continue
+			}
+			log.Tracef("%s: %s", ev.Name, ev.Op)
+			timer.Reset(watchDebounce)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Error(err)
+
+		case <-timer.C:
+			// Files may have been added to or removed from the
+			// included ones.
+			newFiles, _ := watchedFiles(config.Global())
+			newHashes := hashFiles(newFiles)
+
+			var changedFiles []string
+			var touched []server.Resource
+			for file, keys := range newFiles {
+				if newHashes[file] != hashes[file] {
+					changedFiles = append(changedFiles, file)
+					touched = append(touched, keys...)
+				}
+			}
+			for file, keys := range files {
+				if _, ok := newFiles[file]; !ok {
+					changedFiles = append(changedFiles, file)
+					touched = append(touched, keys...)
+				}
+			}
+			files, hashes = newFiles, newHashes
+
+			if len(changedFiles) == 0 {
+				log.Debug("files unchanged, reload skipped")
+				continue
+			}
+
+			sort.Strings(changedFiles)
+			log.Debugf("%s changed, reloading", strings.Join(changedFiles, ", "))
+
+			if d, err := p.reloadConfig(touched...); err != nil {
+				log.Errorf("reload: %v", err)
+			} else if d.Empty() {
+				log.Debug("config reloaded: no changes")
+			} else {
+				log.Infof("config reloaded: %s", d)
+			}
+
+			// The new config may refer to other files.
+			files, patterns = watchedFiles(config.Global())
+			for file, h := range hashFiles(files) {
+				if _, ok := hashes[file]; !ok {
+					hashes[file] = h
+				}
+			}
+			updateWatches(w, dirs, files, patterns, log)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchedFiles returns the absolute paths of the local config files, with
+// the files they include, and of the files read by the resources of cfg,
+// with the resources reading them. It also returns the include patterns,
+// matching the files which would be included if they were created.
+func watchedFiles(cfg *config.Config) (map[string][]server.Resource, []string) {
+	files := make(map[string][]server.Resource)
+
+	add := func(file string, key *server.Resource) {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			return
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return
+		}
+		if key == nil {
+			if _, ok := files[abs]; !ok {
+				files[abs] = nil
+			}
+			return
+		}
+		files[abs] = append(files[abs], *key)
+	}
+	addTLS := func(tls *config.TLSConfig, key server.Resource) {
+		if tls == nil {
+			return
+		}
+		add(tls.CertFile, &key)
+		add(tls.KeyFile, &key)
+		add(tls.CAFile, &key)
+	}
+	addNodes := func(nodes []*config.NodeConfig, key server.Resource) {
+		for _, node := range nodes {
+			if node == nil {
+				continue
+			}
+			if node.Connector != nil {
+				addTLS(node.Connector.TLS, key)
+			}
+			if node.Dialer != nil {
+				addTLS(node.Dialer.TLS, key)
+			}
+		}
+	}
+
+	sources, patterns, err := resolveIncludes(cfgFiles)
+	if err != nil {
+		// Keep watching the config files until they are fixed.
+		sources = cfgFiles
+	}
+	for _, file := range sources {
+		if isLocalFile(file) {
+			add(file, nil)
+		}
+	}
+	// The files referenced by ${file:...}.
+	for _, file := range referencedFiles.list() {
+		add(file, nil)
+	}
+
+	if cfg == nil {
+		return files, patterns
+	}
+
+	addTLS(cfg.TLS, server.DefaultTLS)
+
+	for _, svc := range cfg.Services {
+		if svc == nil {
+			continue
+		}
+		key := server.Resource{Kind: "services", Name: svc.Name}
+		if svc.Listener != nil {
+			addTLS(svc.Listener.TLS, key)
+		}
+		if svc.Handler != nil {
+			addTLS(svc.Handler.TLS, key)
+		}
+	}
+	for _, c := range cfg.Chains {
+		if c == nil {
+			continue
+		}
+		for _, hop := range c.Hops {
+			if hop != nil {
+				addNodes(hop.Nodes, server.Resource{Kind: "chains", Name: c.Name})
+			}
+		}
+	}
+	for _, hop := range cfg.Hops {
+		if hop != nil {
+			addNodes(hop.Nodes, server.Resource{Kind: "hops", Name: hop.Name})
+		}
+	}
+	for _, b := range cfg.Bypasses {
+		if b != nil && b.File != nil {
+			add(b.File.Path, &server.Resource{Kind: "bypasses", Name: b.Name})
+		}
+	}
+	for _, h := range cfg.Hosts {
+		if h != nil && h.File != nil {
+			add(h.File.Path, &server.Resource{Kind: "hosts", Name: h.Name})
+		}
+	}
+
+	return files, patterns
+}
+
+// matchAny reports whether file matches one of the patterns.
+func matchAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// updateWatches watches the parent directories of files and of the files
+// matching patterns, and stops watching the directories in dirs no longer
+// needed.
+func updateWatches(w *fsnotify.Watcher, dirs map[string]bool, files map[string][]server.Resource, patterns []string, log logger.Logger) {
+	needed := make(map[string]bool)
+	for file := range files {
+		needed[filepath.Dir(file)] = true
+	}
+	for _, pattern := range patterns {
+		// A pattern matching directories (e.g. */gost.yaml) is only
+		// followed in the directories of the files already included.
+		if dir := filepath.Dir(pattern); !hasGlobMeta(dir) {
+			needed[dir] = true
+		}
+	}
+
+	for dir := range dirs {
+		if !needed[dir] {
+			w.Remove(dir)
+			delete(dirs, dir)
+			log.Debugf("stop watching %s", dir)
+		}
+	}
+	for dir := range needed {
+		if dirs[dir] {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			log.Warnf("watch %s: %v", dir, err)
+			continue
+		}
+		dirs[dir] = true
+		log.Debugf("watching %s", dir)
+	}
+}
+
+// hashFiles returns the SHA-256 of the content of each file. A missing or
+// unreadable file has an empty hash.
+func hashFiles(files map[string][]server.Resource) map[string]string {
+	hashes := make(map[string]string, len(files))
+	for file := range files {
+		hashes[file] = hashFile(file)
+	}
+	return hashes
+}
+
+func hashFile(file string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -1,4 +1,8 @@
-package main
+// Package components registers the handlers, listeners, dialers and
+// connectors of gost, for the programs running it with the server package:
+//
+//	import _ "github.com/go-gost/gost/components"
+package components
 
 import (
 	// Register connectors
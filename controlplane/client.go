@@ -0,0 +1,149 @@
+package controlplane
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// Client subscribes an instance to a management server.
+type Client struct {
+	url    string
+	node   string
+	client *http.Client
+
+	version   string
+	resources map[key]Resource
+}
+
+// NewClient returns the subscription of node to the server at url, e.g.
+// http://10.0.0.1:18000.
+func NewClient(url, node string) *Client {
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	return &Client{
+		url:  strings.TrimSuffix(url, "/") + "/v1/discovery",
+		node: node,
+		client: &http.Client{
+			// Longer than the wait of the requests by the server.
+			Timeout: 4 * DefaultWaitTimeout,
+		},
+	}
+}
+
+// Version returns the version of the resources applied last.
+func (c *Client) Version() string {
+	return c.version
+}
+
+// Run polls the server until ctx is done. On each update, apply is called
+// with the whole set of resources received, sorted by kind and name. The
+// update is acknowledged if apply succeeds, rejected with its error
+// otherwise. The server is retried when unreachable.
+func (c *Client) Run(ctx context.Context, apply func(resources []Resource) error) error {
+	log := logger.Default().WithFields(map[string]any{"kind": "controlplane", "node": c.node})
+
+	var rejected, reason string
+	delay := minRetryDelay
+	for {
+		resp, err := c.poll(ctx, &DiscoveryRequest{
+			Node:     c.node,
+			Version:  c.version,
+			Rejected: rejected,
+			Error:    reason,
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Errorf("%s: %v, retrying in %s", c.url, err, delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay = min(2*delay, maxRetryDelay)
+			continue
+		}
+		delay = minRetryDelay
+
+		if resp == nil {
+			continue
+		}
+
+		resources := c.update(resp)
+		list := slices.Collect(maps.Values(resources))
+		sortResources(list)
+		if err := apply(list); err != nil {
+			log.Errorf("version %s rejected: %v", resp.Version, err)
+			rejected, reason = resp.Version, err.Error()
+			continue
+		}
+
+		log.Infof("version %s applied", resp.Version)
+		c.version, c.resources = resp.Version, resources
+		rejected, reason = "", ""
+	}
+}
+
+// update returns the resources of the client updated with resp.
+func (c *Client) update(resp *DiscoveryResponse) map[key]Resource {
+	resources := make(map[key]Resource)
+	if !resp.Full {
+		maps.Copy(resources, c.resources)
+	}
+	for _, r := range resp.Resources {
+		resources[key{r.Kind, r.Name}] = r
+	}
+	for _, r := range resp.Removed {
+		delete(resources, key{r.Kind, r.Name})
+	}
+	return resources
+}
+
+// poll sends req, it returns nil if the server had no update.
+func (c *Client) poll(ctx context.Context, req *DiscoveryRequest) (*DiscoveryResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	var dr DiscoveryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+		return nil, err
+	}
+	return &dr, nil
+}
@@ -0,0 +1,108 @@
+// Package controlplane distributes the named resources of gost configs
+// (services, chains, hops and their nodes, bypasses, authers, ...) from a
+// management server to a fleet of gost instances.
+//
+// The protocol is a long-poll over HTTP, after xDS. An instance posts a
+// DiscoveryRequest with the version of the resources it runs to
+// /v1/discovery. The server answers when it has a newer version, with the
+// resources changed and removed since the version of the instance, or with
+// 204 No Content when nothing changed for a while. The next request of the
+// instance acknowledges the response (ACK): its version is the one
+// received, or rejects it (NACK): its version is still the previous one
+// and Rejected and Error tell which version was rejected and why. A
+// rejected version is not sent again, the next update is.
+//
+// Server is a reference management server, Client is the subscription of
+// an instance.
+package controlplane
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+)
+
+// Resource is a named resource of a config. Kind is the config section of
+// the resource, e.g. chains, Config its config as found in that section.
+type Resource struct {
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type DiscoveryRequest struct {
+	// Node identifies the instance.
+	Node string `json:"node"`
+	// Version is the version of the resources applied by the instance,
+	// empty at first.
+	Version string `json:"version,omitempty"`
+	// Rejected is the version the instance failed to apply, Error why.
+	Rejected string `json:"rejected,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type DiscoveryResponse struct {
+	Version string `json:"version"`
+	// Full is set when Resources is the whole set of resources, replacing
+	// the ones of the instance, e.g. on the first request.
+	Full      bool       `json:"full,omitempty"`
+	Resources []Resource `json:"resources,omitempty"`
+	// Removed holds the kind and name of the resources removed.
+	Removed []Resource `json:"removed,omitempty"`
+}
+
+// Merge returns cfg with the resources added, a resource replacing the one
+// of the same kind and name of cfg.
+func Merge(cfg *config.Config, resources []Resource) (*config.Config, error) {
+	if len(resources) == 0 {
+		return cfg, nil
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	kinds := server.Kinds()
+	for _, r := range resources {
+		if !slices.Contains(kinds, r.Kind) {
+			return nil, fmt.Errorf("%s/%s: unknown resource kind", r.Kind, r.Name)
+		}
+		var c map[string]any
+		if err := json.Unmarshal(r.Config, &c); err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", r.Kind, r.Name, err)
+		}
+		if c == nil {
+			c = make(map[string]any)
+		}
+		c["name"] = r.Name
+
+		list, _ := m[r.Kind].([]any)
+		i := slices.IndexFunc(list, func(v any) bool {
+			v2, _ := v.(map[string]any)
+			return v2 != nil && v2["name"] == r.Name
+		})
+		if i >= 0 {
+			list[i] = c
+		} else {
+			list = append(list, c)
+		}
+		m[r.Kind] = list
+	}
+
+	if b, err = json.Marshal(m); err != nil {
+		return nil, err
+	}
+	merged := &config.Config{}
+	if err := json.Unmarshal(b, merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
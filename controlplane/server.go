@@ -0,0 +1,336 @@
+package controlplane
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/gost/server"
+)
+
+// DefaultWaitTimeout is how long a discovery request waits for a change
+// before the server answers 204 No Content.
+const DefaultWaitTimeout = 30 * time.Second
+
+type Options struct {
+	WaitTimeout time.Duration
+}
+
+type Option func(opts *Options)
+
+func WaitTimeoutOption(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.WaitTimeout = timeout
+	}
+}
+
+// NodeStatus is the state of an instance as reported by its last
+// discovery request.
+type NodeStatus struct {
+	// Version is the version applied by the instance.
+	Version string `json:"version,omitempty"`
+	// Rejected is the last version the instance failed to apply, Error
+	// why. They are cleared when a later version is applied.
+	Rejected string    `json:"rejected,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type entry struct {
+	config  json.RawMessage
+	version int64
+	removed bool
+}
+
+type key struct {
+	kind, name string
+}
+
+// Server is a reference management server. The resources are set with
+// Set and Delete, or through its HTTP API:
+//
+//	POST   /v1/discovery              the long-poll of the instances
+//	GET    /v1/resources              the resources, as a DiscoveryResponse
+//	PUT    /v1/resources/{kind}/{name} set a resource, the body is its config
+//	DELETE /v1/resources/{kind}/{name} remove a resource
+//	GET    /v1/nodes                  the NodeStatus of each instance
+//
+// Every change bumps the version. A version is only meaningful to the
+// server that issued it, an instance presenting a version of another one
+// (or of a previous run) receives the whole set of resources.
+type Server struct {
+	// id prefixes the versions of the server.
+	id      string
+	options Options
+	mux     *http.ServeMux
+
+	mu        sync.Mutex
+	version   int64
+	resources map[key]*entry
+	nodes     map[string]*NodeStatus
+	// changed is closed, and replaced, on each change.
+	changed chan struct{}
+}
+
+func NewServer(opts ...Option) *Server {
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	s := &Server{
+		id:        hex.EncodeToString(b),
+		resources: make(map[key]*entry),
+		nodes:     make(map[string]*NodeStatus),
+		changed:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	if s.options.WaitTimeout <= 0 {
+		s.options.WaitTimeout = DefaultWaitTimeout
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("POST /v1/discovery", s.discover)
+	s.mux.HandleFunc("GET /v1/resources", s.getResources)
+	s.mux.HandleFunc("PUT /v1/resources/{kind}/{name}", s.setResource)
+	s.mux.HandleFunc("DELETE /v1/resources/{kind}/{name}", s.deleteResource)
+	s.mux.HandleFunc("GET /v1/nodes", s.getNodes)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Set sets the resource kind/name to cfg, a config of the kind section,
+// e.g. a *config.ChainConfig for chains. Its name is set to name.
+func (s *Server) Set(kind, name string, cfg any) error {
+	if !slices.Contains(server.Kinds(), kind) {
+		return fmt.Errorf("%s: unknown resource kind", kind)
+	}
+	if name == "" {
+		return errors.New("resource without name")
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]any)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("%s/%s: %w", kind, name, err)
+	}
+	m["name"] = name
+	if b, err = json.Marshal(m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{kind, name}
+	if e := s.resources[k]; e != nil && !e.removed && bytes.Equal(e.config, b) {
+		return nil
+	}
+	s.resources[k] = &entry{config: b, version: s.bump()}
+	return nil
+}
+
+// Delete removes the resource kind/name, it reports whether it existed.
+func (s *Server) Delete(kind, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.resources[key{kind, name}]
+	if e == nil || e.removed {
+		return false
+	}
+	*e = entry{version: s.bump(), removed: true}
+	return true
+}
+
+// Version returns the current version of the resources.
+func (s *Server) Version() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.versionString(s.version)
+}
+
+// Nodes returns the status of the instances by node.
+func (s *Server) Nodes() map[string]NodeStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := make(map[string]NodeStatus, len(s.nodes))
+	for node, st := range s.nodes {
+		nodes[node] = *st
+	}
+	return nodes
+}
+
+// bump increments the version and wakes up the waiting requests.
+func (s *Server) bump() int64 {
+	s.version++
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return s.version
+}
+
+func (s *Server) versionString(v int64) string {
+	return fmt.Sprintf("%s:%d", s.id, v)
+}
+
+// parseVersion returns the number of a version issued by the server, or -1.
+func (s *Server) parseVersion(v string) int64 {
+	id, n, ok := strings.Cut(v, ":")
+	if !ok || id != s.id {
+		return -1
+	}
+	i, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || i < 0 || i > s.version {
+		return -1
+	}
+	return i
+}
+
+// updateNode records the ACK or NACK carried by req.
+func (s *Server) updateNode(req *DiscoveryRequest) {
+	st := s.nodes[req.Node]
+	if st == nil {
+		st = &NodeStatus{}
+		s.nodes[req.Node] = st
+	}
+	if req.Version != st.Version {
+		st.Rejected, st.Error = "", ""
+	}
+	st.Version = req.Version
+	if req.Rejected != "" {
+		st.Rejected, st.Error = req.Rejected, req.Error
+	}
+	st.Time = time.Now()
+}
+
+// response returns the response to req, nil if the instance is up to date
+// or rejected the current version.
+func (s *Server) response(req *DiscoveryRequest) *DiscoveryResponse {
+	current := s.versionString(s.version)
+	if req.Version == current || req.Rejected == current {
+		return nil
+	}
+
+	resp := &DiscoveryResponse{Version: current}
+	since := s.parseVersion(req.Version)
+	if since < 0 {
+		resp.Full = true
+	}
+	for k, e := range s.resources {
+		if e.version <= since {
+			continue
+		}
+		switch {
+		case !e.removed:
+			resp.Resources = append(resp.Resources, Resource{Kind: k.kind, Name: k.name, Config: e.config})
+		case !resp.Full:
+			resp.Removed = append(resp.Removed, Resource{Kind: k.kind, Name: k.name})
+		}
+	}
+	sortResources(resp.Resources)
+	sortResources(resp.Removed)
+
+	return resp
+}
+
+func (s *Server) discover(w http.ResponseWriter, r *http.Request) {
+	var req DiscoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Node == "" {
+		http.Error(w, "missing node", http.StatusBadRequest)
+		return
+	}
+
+	timer := time.NewTimer(s.options.WaitTimeout)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	s.updateNode(&req)
+	for {
+		if resp := s.response(&req); resp != nil {
+			s.mu.Unlock()
+			writeJSON(w, resp)
+			return
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+			s.mu.Lock()
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) getResources(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := s.response(&DiscoveryRequest{})
+	s.mu.Unlock()
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) setResource(w http.ResponseWriter, r *http.Request) {
+	var cfg map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Set(r.PathValue("kind"), r.PathValue("name"), cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]string{"version": s.Version()})
+}
+
+func (s *Server) deleteResource(w http.ResponseWriter, r *http.Request) {
+	if !s.Delete(r.PathValue("kind"), r.PathValue("name")) {
+		http.Error(w, "resource not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]string{"version": s.Version()})
+}
+
+func (s *Server) getNodes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Nodes())
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func sortResources(resources []Resource) {
+	slices.SortFunc(resources, func(a, b Resource) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/x/api"
+)
+
+// The roles of the API, each allowed what the previous ones are.
+const (
+	// RoleViewer reads the config and the runtime state.
+	RoleViewer = "viewer"
+	// RoleOperator changes the resources, reloads the config and closes
+	// connections.
+	RoleOperator = "operator"
+	// RoleAdmin saves the config, manages the tokens and reads the audit
+	// log.
+	RoleAdmin = "admin"
+)
+
+// Access is the access control of the API service. Without it, the users
+// authenticated by the API are allowed everything.
+type Access struct {
+	// Grants give roles to the users authenticated by the API.
+	Grants []Grant `yaml:"grants" json:"grants"`
+	// Tokens is the file the API tokens are kept in, they are lost on
+	// restart if empty.
+	Tokens string `yaml:"tokens,omitempty" json:"tokens,omitempty"`
+	// Audit is the file the mutating API calls are appended to, as JSON
+	// lines. They are not recorded if empty.
+	Audit string `yaml:"audit,omitempty" json:"audit,omitempty"`
+}
+
+// Grant gives a role on some resources to a user.
+type Grant struct {
+	// User is the user name, or the common name of the subject of a client
+	// certificate, * for every user, the anonymous one included when the
+	// API has no authentication.
+	User string `yaml:"user,omitempty" json:"user,omitempty"`
+	Role string `json:"role"`
+	// Resources are the resources the role is given on: kind/name, or kind
+	// (or kind/*) for all the resources of a config section, e.g.
+	// bypasses/bypass-0 or services. The role is given on everything if
+	// empty, the requests not about a resource (e.g. reloading the config)
+	// are only allowed by such grants.
+	Resources []string `yaml:"resources,omitempty" json:"resources,omitempty"`
+}
+
+// Validate checks the roles and the resources of the grants.
+func (a *Access) Validate() error {
+	if a == nil {
+		return nil
+	}
+	for i := range a.Grants {
+		if err := a.Grants[i].validate(); err != nil {
+			return fmt.Errorf("grants[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (g *Grant) validate() error {
+	if roleLevel(g.Role) == 0 {
+		return fmt.Errorf("unknown role %q", g.Role)
+	}
+	for _, r := range g.Resources {
+		if r == "*" {
+			continue
+		}
+		kind, _, _ := strings.Cut(r, "/")
+		if resourceKindOf(kind) == nil {
+			return fmt.Errorf("resource %s: unknown kind %s", r, kind)
+		}
+	}
+	return nil
+}
+
+// The permission levels of the requests.
+const (
+	permRead = iota + 1
+	permWrite
+	permAdmin
+)
+
+func roleLevel(role string) int {
+	switch role {
+	case RoleViewer:
+		return permRead
+	case RoleOperator:
+		return permWrite
+	case RoleAdmin:
+		return permAdmin
+	default:
+		return 0
+	}
+}
+
+// allows reports whether g allows a request of the given level about
+// target, a resource, all the resources of a kind when the name is empty,
+// or none in particular when the kind is empty too.
+func (g *Grant) allows(level int, target Resource) bool {
+	if roleLevel(g.Role) < level {
+		return false
+	}
+	if len(g.Resources) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(g.Resources, func(r string) bool {
+		if r == "*" {
+			return true
+		}
+		if target.Kind == "" {
+			return false
+		}
+		kind, name, _ := strings.Cut(r, "/")
+		if kind != target.Kind {
+			return false
+		}
+		return name == "" || name == "*" || (target.Name != "" && name == target.Name)
+	})
+}
+
+// grantsOf returns the grants of user.
+func (a *Access) grantsOf(user string) []Grant {
+	var grants []Grant
+	for _, g := range a.Grants {
+		if g.User == user || g.User == "*" {
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+// permission returns the level required by the request to the API and the
+// resources it is about. path is relative to the path prefix of the API.
+func permission(r *http.Request, path string) (int, []Resource) {
+	// A batch is allowed if each of its changes is.
+	if r.Method == http.MethodPost && strings.Trim(path, "/") == "config/batch" {
+		if list := batchResources(r); len(list) > 0 {
+			return permWrite, list
+		}
+		return permWrite, []Resource{{}}
+	}
+	level, target := permissionOf(r, path)
+	return level, []Resource{target}
+}
+
+// permissionOf returns the level required by the request and the resource
+// it is about.
+func permissionOf(r *http.Request, path string) (int, Resource) {
+	level := permWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		level = permRead
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch parts[0] {
+	case "config":
+		if len(parts) == 1 {
+			if level == permRead {
+				return level, Resource{}
+			}
+			// Saving the config writes to a file.
+			return permAdmin, Resource{}
+		}
+		if resourceKindOf(parts[1]) == nil {
+			// reload, plan and revisions.
+			return level, Resource{}
+		}
+		target := Resource{Kind: parts[1]}
+		if len(parts) > 2 {
+			target.Name = parts[2]
+		} else if r.Method == http.MethodPost {
+			target.Name = createdName(r)
+		}
+		return level, target
+
+	case "connections":
+		target := Resource{Kind: "services"}
+		if len(parts) > 1 {
+			if sess := sessions.get(parts[1]); sess != nil {
+				target.Name = sess.service
+			}
+			return level, target
+		}
+		if target.Name = r.URL.Query().Get("service"); target.Name == "" {
+			return level, Resource{}
+		}
+		return level, target
+
+	case "tokens", "audit":
+		return permAdmin, Resource{}
+	}
+
+	if level == permWrite {
+		return permAdmin, Resource{}
+	}
+	return level, Resource{}
+}
+
+// createdName returns the name of the resource created by r, read from its
+// body, which is left to be read again.
+func createdName(r *http.Request) string {
+	var v struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(peekBody(r), &v)
+	return v.Name
+}
+
+// peekBody returns the body of r, which is left to be read again.
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// userKey is the key of the gin context the user of a request is kept
+// under.
+const userKey = "gost.user"
+
+// mwAccess authenticates the requests to the API, by API token, client
+// certificate or with auther, and checks their permission against access,
+// if any.
+func mwAccess(auther auth.Authenticator, access *Access, tokens *tokenStore, pathPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user string
+		var grants []Grant
+
+		if secret, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && tokens != nil {
+			t := tokens.lookup(secret)
+			if t == nil {
+				unauthorized(c, "")
+				return
+			}
+			user, grants = "token:"+t.ID, t.Grants
+		} else {
+			var ok bool
+			if user, ok = authenticate(c.Request, auther, "@api"); !ok {
+				unauthorized(c, user)
+				return
+			}
+			if access != nil {
+				grants = access.grantsOf(user)
+			}
+		}
+		c.Set(userKey, user)
+
+		if access == nil {
+			return
+		}
+
+		path := c.Request.URL.Path
+		if pathPrefix != "" {
+			path = strings.TrimPrefix(path, pathPrefix)
+		}
+		level, targets := permission(c.Request, path)
+		for _, target := range targets {
+			if !slices.ContainsFunc(grants, func(g Grant) bool { return g.allows(level, target) }) {
+				c.JSON(http.StatusForbidden, api.Response{
+					Code: http.StatusForbidden,
+					Msg:  "Forbidden",
+				})
+				c.Abort()
+				return
+			}
+		}
+	}
+}
+
+func unauthorized(c *gin.Context, user string) {
+	events.publishThrottled(EventAuthFailed+"/@api/"+hostOf(c.Request.RemoteAddr)+"/"+user, Event{
+		Type:    EventAuthFailed,
+		Service: "@api",
+		Client:  c.Request.RemoteAddr,
+		User:    user,
+	})
+
+	c.Writer.Header().Set("WWW-Authenticate", "Basic")
+	c.JSON(http.StatusUnauthorized, api.Response{
+		Code: http.StatusUnauthorized,
+		Msg:  "Unauthorized",
+	})
+	c.Abort()
+}
@@ -0,0 +1,577 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/x/api"
+	xauth "github.com/go-gost/x/auth"
+	"github.com/go-gost/x/config"
+	auth_parser "github.com/go-gost/x/config/parsing/auth"
+	"github.com/go-gost/x/registry"
+)
+
+// apiService serves the config API of x/api extended with the runtime
+// endpoints of the server. Requests not matched by the runtime routes are
+// passed through to the config API.
+type apiService struct {
+	s  *http.Server
+	ln net.Listener
+}
+
+func buildApiService(cfg *config.APIConfig, tlsCfg *TLS, srv *Server) (service.Service, error) {
+	var authers []auth.Authenticator
+	if auther := auth_parser.ParseAutherFromAuth(cfg.Auth); auther != nil {
+		authers = append(authers, auther)
+	}
+	if cfg.Auther != "" {
+		authers = append(authers, registry.AutherRegistry().Get(cfg.Auther))
+	}
+
+	var auther auth.Authenticator
+	if len(authers) > 0 {
+		auther = xauth.AuthenticatorGroup(authers...)
+	}
+
+	network := "tcp"
+	addr := cfg.Addr
+	if strings.HasPrefix(addr, "unix://") {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, "unix://")
+	}
+	var tlsConfig *tls.Config
+	if tlsCfg != nil {
+		var err error
+		if tlsConfig, err = newTLSConfig(tlsCfg, "@api"); err != nil {
+			return nil, fmt.Errorf("api tls: %w", err)
+		}
+	}
+
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+
+	// The requests passed through to the config API are authenticated
+	// and logged here already.
+	xr := gin.New()
+	api.Register(xr, &api.Options{
+		PathPrefix: cfg.PathPrefix,
+	})
+
+	// The same middlewares as the config API, for all the routes.
+	r := gin.New()
+	r.Use(
+		cors.New(cors.Config{
+			AllowAllOrigins:     true,
+			AllowMethods:        []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowHeaders:        []string{"*"},
+			AllowPrivateNetwork: true,
+		}),
+		gin.Recovery(),
+	)
+	if cfg.AccessLog {
+		r.Use(mwLogger())
+	}
+	// The health is neither authenticated nor audited, its routes are added
+	// before the middlewares.
+	health := r.Group(cfg.PathPrefix)
+	health.GET("/healthz", gin.WrapH(healthHandler(srv, false)))
+	health.GET("/readyz", gin.WrapH(healthHandler(srv, true)))
+	// The calls denied are audited too.
+	if srv.audit != nil {
+		r.Use(mwAudit(srv, srv.audit))
+	}
+	r.Use(mwAccess(auther, srv.options.Access, srv.tokens, cfg.PathPrefix))
+
+	router := r.Group("")
+	if cfg.PathPrefix != "" {
+		router = router.Group(cfg.PathPrefix)
+	}
+
+	router.GET("/openapi.json", getOpenAPI(cfg.PathPrefix))
+	router.GET("/ui/*filepath", dashboard(cfg.PathPrefix+"/ui"))
+	router.GET("/drain", getDrainStatus)
+	router.GET("/connections", getConnections(srv))
+	router.DELETE("/connections", closeConnections(srv))
+	router.DELETE("/connections/:id", closeConnection(srv))
+	router.GET("/events", streamEvents(srv))
+	if srv.tokens != nil {
+		router.GET("/tokens", getTokens(srv))
+		router.POST("/tokens", issueToken(srv))
+		router.DELETE("/tokens/:id", revokeToken(srv))
+	}
+	if srv.audit != nil {
+		router.GET("/audit", getAuditLog(srv))
+	}
+	router.GET("/nodes", getNodes(srv))
+	router.GET("/tunnels", getTunnels(srv))
+	router.GET("/reload", getReloadStatus(srv))
+	router.GET("/reloads", getReloads(srv))
+	// Replaces the reload of the config API with the transactional one.
+	router.POST("/config/reload", reloadConfig(srv))
+	// Replaces the config saving of the config API, to save the config
+	// redacted.
+	router.POST("/config", saveConfig(srv))
+	router.POST("/config/plan", planConfig(srv))
+
+	var persist []gin.HandlerFunc
+	if srv.persist != nil {
+		router.GET("/config/revisions", getRevisions(srv))
+		router.GET("/config/revisions/diff", diffRevisions(srv))
+		router.POST("/config/revisions/:revision/rollback", rollbackRevision(srv))
+		persist = append(persist, mwPersist(srv, srv.persist))
+	}
+	router.POST("/config/batch", append(persist, batchConfig(srv))...)
+
+	r.NoRoute(append(persist,
+		mwVersions(srv, cfg.PathPrefix),
+		redactConfig(cfg.PathPrefix+"/config", srv),
+		gin.WrapH(xr),
+	)...)
+
+	return &apiService{
+		s: &http.Server{
+			Handler:   r,
+			TLSConfig: tlsConfig,
+		},
+		ln: ln,
+	}, nil
+}
+
+func (s *apiService) Serve() error {
+	if s.s.TLSConfig != nil {
+		return s.s.ServeTLS(s.ln, "", "")
+	}
+	return s.s.Serve(s.ln)
+}
+
+func (s *apiService) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close closes the listener too, which the server does not track until
+// it serves, for the address to be free once it returns.
+func (s *apiService) Close() error {
+	err := s.s.Close()
+	s.ln.Close()
+	return err
+}
+
+// mwLogger logs the requests as the access log of the config API does.
+func mwLogger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		duration := time.Since(start)
+
+		logger.Default().WithFields(map[string]any{
+			"kind":     "api",
+			"method":   ctx.Request.Method,
+			"uri":      ctx.Request.RequestURI,
+			"code":     ctx.Writer.Status(),
+			"client":   ctx.ClientIP(),
+			"duration": duration,
+		}).Infof("| %3d | %13v | %15s | %-7s %s",
+			ctx.Writer.Status(), duration, ctx.ClientIP(), ctx.Request.Method, ctx.Request.RequestURI)
+	}
+}
+
+func getDrainStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, api.Response{
+		Data: tracker.status(),
+	})
+}
+
+func getReloadStatus(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: srv.LastReload(),
+		})
+	}
+}
+
+func reloadConfig(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		unlock := srv.lockReload()
+		_, err := srv.ReloadConfig()
+		unlock()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg:  "OK",
+			Data: srv.LastReload(),
+		})
+	}
+}
+
+func saveConfig(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req struct {
+			Format string `form:"format"`
+			Path   string `form:"path"`
+		}
+		ctx.ShouldBindQuery(&req)
+
+		file := "gost.yaml"
+		switch req.Format {
+		case "json":
+			file = "gost.json"
+		default:
+			req.Format = "yaml"
+		}
+		if req.Path != "" {
+			file = req.Path
+		}
+
+		var buf bytes.Buffer
+		if err := config.Global().Write(&buf, req.Format); err != nil {
+			ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeSaveConfigFailed,
+				fmt.Sprintf("save config: %s", err)))
+			return
+		}
+		b, err := srv.redact(buf.Bytes(), req.Format)
+		if err == nil {
+			err = os.WriteFile(file, b, 0644)
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeSaveConfigFailed,
+				fmt.Sprintf("save config: %s", err)))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg: "OK",
+		})
+	}
+}
+
+// planConfig returns the plan of reloading the running config with the
+// config in the request body, redacted as the running config is.
+func planConfig(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var cfg config.Config
+		if err := ctx.ShouldBindJSON(&cfg); err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+
+		running, err := srv.redactedConfig(config.Global())
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeFailed, err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: buildPlan(running, &cfg, tracker.connections()),
+		})
+	}
+}
+
+// redactConfig redacts the responses of the config API to the GET requests
+// under path: the config, or the resources of a kind (under path/<kind>),
+// located in the config by their kind and name.
+func redactConfig(path string, srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodGet || !strings.HasPrefix(ctx.Request.URL.Path, path) {
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: ctx.Writer, status: http.StatusOK}
+		ctx.Writer = w
+		ctx.Next()
+		ctx.Writer = w.ResponseWriter
+
+		body := w.buf.Bytes()
+		if len(body) > 0 {
+			format := "json"
+			if strings.Contains(w.Header().Get("Content-Type"), "yaml") {
+				format = "yaml"
+			}
+			var b []byte
+			var err error
+			if kind, name, _ := strings.Cut(strings.Trim(strings.TrimPrefix(ctx.Request.URL.Path, path), "/"), "/"); kind != "" {
+				b, err = srv.redactResources(body, kind, name != "")
+			} else {
+				b, err = srv.redact(body, format)
+			}
+			if err != nil {
+				// Never return the unredacted response.
+				ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeFailed, err.Error()))
+				return
+			}
+			body = b
+		}
+
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(body)
+	}
+}
+
+// redactResources redacts a response of the config API with the resources
+// of kind, one or a list of them, as the section of a config.
+func (s *Server) redactResources(body []byte, kind string, one bool) ([]byte, error) {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	data := resp["data"]
+	if len(data) == 0 || string(data) == "null" {
+		return body, nil
+	}
+
+	var list map[string]json.RawMessage
+	items := []json.RawMessage{data}
+	if !one {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		items = nil
+		if v := list["list"]; len(v) > 0 {
+			if err := json.Unmarshal(v, &items); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	doc, err := json.Marshal(map[string][]json.RawMessage{kind: items})
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = s.redact(doc, "json"); err != nil {
+		return nil, err
+	}
+	var section map[string][]json.RawMessage
+	if err := json.Unmarshal(doc, &section); err != nil {
+		return nil, err
+	}
+	items = section[kind]
+
+	if one {
+		if len(items) != 1 {
+			return nil, fmt.Errorf("redact %s: unexpected response", kind)
+		}
+		resp["data"] = items[0]
+	} else {
+		if list["list"], err = json.Marshal(items); err != nil {
+			return nil, err
+		}
+		if resp["data"], err = json.Marshal(list); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(resp)
+}
+
+// bufferedWriter holds the response written to it.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.buf.Len() > 0
+}
+
+type connectionsRequest struct {
+	Service string `form:"service"`
+	User    string `form:"user"`
+	Client  string `form:"client"`
+	Host    string `form:"host"`
+	// Page starts at 1, Size is the number of connections per page, all of
+	// them if 0.
+	Page int `form:"page"`
+	Size int `form:"size"`
+}
+
+func (r *connectionsRequest) filter() SessionFilter {
+	return SessionFilter{
+		Service: r.Service,
+		User:    r.User,
+		Client:  r.Client,
+		Host:    r.Host,
+	}
+}
+
+type connectionList struct {
+	Count int       `json:"count"`
+	List  []Session `json:"list"`
+}
+
+// getConnections lists the connections being handled by the services,
+// the oldest first, filtered and paginated by the query. Count is the
+// number of connections matched.
+func getConnections(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req connectionsRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil || req.Page < 0 || req.Size < 0 {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "invalid query"))
+			return
+		}
+
+		list := srv.Sessions(req.filter())
+		count := len(list)
+		if req.Size > 0 {
+			page := max(req.Page, 1)
+			start := min((page-1)*req.Size, count)
+			list = list[start:min(start+req.Size, count)]
+		}
+		if list == nil {
+			list = []Session{}
+		}
+
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: connectionList{
+				Count: count,
+				List:  list,
+			},
+		})
+	}
+}
+
+// closeConnections closes the connections matched by the query, at least
+// one filter is required.
+func closeConnections(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req connectionsRequest
+		ctx.ShouldBindQuery(&req)
+
+		f := req.filter()
+		if f.Empty() {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid,
+				"one of service, user, client or host is required"))
+			return
+		}
+
+		list := srv.CloseSessions(f)
+		if list == nil {
+			list = []Session{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg: "OK",
+			Data: connectionList{
+				Count: len(list),
+				List:  list,
+			},
+		})
+	}
+}
+
+func closeConnection(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if !srv.CloseSession(id) {
+			ctx.JSON(http.StatusNotFound, api.NewError(http.StatusNotFound, api.ErrCodeNotFound,
+				fmt.Sprintf("connection %s not found", id)))
+			return
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg: "OK",
+		})
+	}
+}
+
+// eventKeepAlive is the period of the comments sent on an idle event
+// stream, for the proxies not to close it.
+const eventKeepAlive = 15 * time.Second
+
+// streamEvents streams the events as server-sent events, the event field is
+// the type and the data the event in JSON. The type query, repeated or
+// comma-separated, selects the event types. A client reconnecting with the
+// Last-Event-ID header, or the since query, receives the events it missed
+// first, as far as they are retained.
+func streamEvents(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var f EventFilter
+		for _, v := range ctx.QueryArray("type") {
+			for _, t := range strings.Split(v, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					f.Types = append(f.Types, t)
+				}
+			}
+		}
+		since := ctx.GetHeader("Last-Event-ID")
+		if since == "" {
+			since = ctx.Query("since")
+		}
+		if since != "" {
+			n, err := strconv.ParseUint(since, 10, 64)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "invalid event ID"))
+				return
+			}
+			f.Since = n
+		}
+
+		ch := srv.Events(ctx.Request.Context(), f)
+
+		w := ctx.Writer
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+
+		ticker := time.NewTicker(eventKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				b, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, b)
+				w.Flush()
+			case <-ticker.C:
+				io.WriteString(w, ": keepalive\n\n")
+				w.Flush()
+			case <-ctx.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
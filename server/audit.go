@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/x/api"
+	"github.com/go-gost/x/config"
+)
+
+// AuditEntry records a mutating call to the API.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// User is the user who made the call, token:ID for an API token.
+	User   string `json:"user"`
+	Client string `json:"client"`
+	Method string `json:"method"`
+	// Path is the path of the call, with the query.
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	// Changes are the resources of the running config the call changed,
+	// redacted as the config written by the API is.
+	Changes []ResourceChange `json:"changes,omitempty"`
+}
+
+// auditLog appends the entries to a file, as JSON lines.
+type auditLog struct {
+	path string
+	// mu serializes the audited calls, for their changes not to be mixed.
+	mu sync.Mutex
+}
+
+func newAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &auditLog{path: path}, nil
+}
+
+// append is called with the lock held.
+func (l *auditLog) append(e *AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// entries returns the entries of the log, the oldest first.
+func (l *auditLog) entries() ([]AuditEntry, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []AuditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		list = append(list, e)
+	}
+	return list, sc.Err()
+}
+
+// AuditLog returns the entries of the audit log, the oldest first.
+func (s *Server) AuditLog() ([]AuditEntry, error) {
+	if s.audit == nil {
+		return nil, nil
+	}
+	return s.audit.entries()
+}
+
+// mwAudit records the calls to the API not reading only, with the changes
+// they made to the running config.
+func mwAudit(srv *Server, l *auditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		before, _ := srv.redactedConfig(config.Global())
+		c.Next()
+		after, _ := srv.redactedConfig(config.Global())
+
+		e := &AuditEntry{
+			Time:    time.Now(),
+			User:    c.GetString(userKey),
+			Client:  c.Request.RemoteAddr,
+			Method:  c.Request.Method,
+			Path:    c.Request.URL.RequestURI(),
+			Status:  c.Writer.Status(),
+			Changes: resourceChanges(before, after),
+		}
+		if err := l.append(e); err != nil {
+			logger.Default().WithFields(map[string]any{"kind": "api"}).Errorf("audit: %v", err)
+		}
+	}
+}
+
+type auditRequest struct {
+	// Page starts at 1, Size is the number of entries per page, all of
+	// them if 0.
+	Page int `form:"page"`
+	Size int `form:"size"`
+}
+
+type auditList struct {
+	Count int          `json:"count"`
+	List  []AuditEntry `json:"list"`
+}
+
+// getAuditLog lists the entries of the audit log, the newest first,
+// paginated by the query.
+func getAuditLog(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req auditRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil || req.Page < 0 || req.Size < 0 {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "invalid query"))
+			return
+		}
+
+		list, err := srv.AuditLog()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeFailed, err.Error()))
+			return
+		}
+		slices.Reverse(list)
+		count := len(list)
+		if req.Size > 0 {
+			page := max(req.Page, 1)
+			start := min((page-1)*req.Size, count)
+			list = list[start:min(start+req.Size, count)]
+		}
+		if list == nil {
+			list = []AuditEntry{}
+		}
+
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: auditList{
+				Count: count,
+				List:  list,
+			},
+		})
+	}
+}
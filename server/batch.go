@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/x/api"
+	"github.com/go-gost/x/config"
+)
+
+// The operations of a batch.
+const (
+	BatchAdd    = "add"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// ErrPreconditionFailed is returned when a resource has changed since the
+// version a change expects.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// BatchOp is a change of a resource in a batch.
+type BatchOp struct {
+	// Op is add, update or delete.
+	Op   string `json:"op"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// IfMatch is the ETag the resource must have in the running config, as
+	// the If-Match header of a single change.
+	IfMatch string `json:"ifMatch,omitempty"`
+	// Config is the config of the resource added or updated, its name is
+	// set to Name.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+func (op *BatchOp) resource() Resource {
+	return Resource{Kind: op.Kind, Name: op.Name}
+}
+
+// BatchResult is the outcome of a batch applied.
+type BatchResult struct {
+	// Changes summarizes the resources affected, as a reload.
+	Changes string `json:"changes"`
+	// Versions are the ETags of the resources added or updated.
+	Versions map[string]string `json:"versions"`
+}
+
+// Batch applies the changes of ops to the running config as one reload:
+// all of them are applied, or none if one of them is invalid, expects
+// another version of its resource or can not be applied. It holds the
+// reload lock.
+func (s *Server) Batch(ops []BatchOp) (*BatchResult, error) {
+	s.edits.Lock()
+	defer s.edits.Unlock()
+	unlock := s.lockReload()
+	defer unlock()
+
+	running := config.Global()
+	cfg, err := applyBatch(running, ops)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.Reload(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchResult{
+		Changes:  d.String(),
+		Versions: make(map[string]string),
+	}
+	for _, op := range ops {
+		if etag := s.Version(op.resource()); etag != "" {
+			result.Versions[op.resource().String()] = etag
+		}
+	}
+	return result, nil
+}
+
+// applyBatch returns a copy of cfg with the changes of ops applied.
+func applyBatch(cfg *config.Config, ops []BatchOp) (*config.Config, error) {
+	if len(ops) == 0 {
+		return nil, errors.New("no operations")
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if err := applyOp(doc, cfg, &op); err != nil {
+			return nil, fmt.Errorf("operations[%d]: %w", i, err)
+		}
+	}
+
+	if b, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	c := &config.Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// applyOp applies op to doc, the JSON form of the config, checking its
+// precondition against running.
+func applyOp(doc map[string]any, running *config.Config, op *BatchOp) error {
+	if resourceKindOf(op.Kind) == nil {
+		return fmt.Errorf("unknown kind %q", op.Kind)
+	}
+	if op.Name == "" {
+		return errors.New("name is required")
+	}
+	if op.IfMatch != "" && !matchETag(op.IfMatch, resourceVersion(running, op.resource())) {
+		return fmt.Errorf("%s has changed: %w", op.resource(), ErrPreconditionFailed)
+	}
+
+	list, _ := doc[op.Kind].([]any)
+	i := slices.IndexFunc(list, func(v any) bool {
+		m, ok := v.(map[string]any)
+		return ok && m["name"] == op.Name
+	})
+
+	var rc map[string]any
+	if op.Op != BatchDelete {
+		if len(op.Config) == 0 {
+			return errors.New("config is required")
+		}
+		dec := json.NewDecoder(bytes.NewReader(op.Config))
+		dec.UseNumber()
+		if err := dec.Decode(&rc); err != nil || rc == nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+		rc["name"] = op.Name
+	}
+
+	switch op.Op {
+	case BatchAdd:
+		if i >= 0 {
+			return fmt.Errorf("%s already exists", op.resource())
+		}
+		list = append(list, rc)
+	case BatchUpdate:
+		if i < 0 {
+			return fmt.Errorf("%s not found", op.resource())
+		}
+		list[i] = rc
+	case BatchDelete:
+		if i < 0 {
+			return fmt.Errorf("%s not found", op.resource())
+		}
+		list = slices.Delete(list, i, i+1)
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	doc[op.Kind] = list
+	return nil
+}
+
+type batchRequest struct {
+	Operations []BatchOp `json:"operations"`
+}
+
+// batchConfig applies the operations of the request body as one reload.
+func batchConfig(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req batchRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+
+		result, err := srv.Batch(req.Operations)
+		if errors.Is(err, ErrPreconditionFailed) {
+			ctx.JSON(http.StatusPreconditionFailed, api.NewError(http.StatusPreconditionFailed, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg:  "OK",
+			Data: result,
+		})
+	}
+}
+
+// batchResources returns the resources changed by the batch of r, read
+// from its body, which is left to be read again.
+func batchResources(r *http.Request) []Resource {
+	var req batchRequest
+	if json.Unmarshal(peekBody(r), &req) != nil {
+		return nil
+	}
+	var list []Resource
+	for _, op := range req.Operations {
+		list = append(list, op.resource())
+	}
+	return list
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The dashboard is a static web app calling the API, from the origin of
+// the API and with its authentication.
+//
+//go:embed dashboard
+var dashboardFS embed.FS
+
+// dashboard serves the assets of the dashboard under path.
+func dashboard(path string) gin.HandlerFunc {
+	assets, _ := fs.Sub(dashboardFS, "dashboard")
+	h := http.StripPrefix(path, http.FileServer(http.FS(assets)))
+	return func(ctx *gin.Context) {
+		ctx.Header("X-Content-Type-Options", "nosniff")
+		ctx.Header("X-Frame-Options", "DENY")
+		h.ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
@@ -0,0 +1,261 @@
+// The dashboard polls the API it is served by, under the same path prefix,
+// with the credentials of the browser or with an API token.
+"use strict";
+
+const base = location.pathname.replace(/\/ui(\/.*)?$/, "");
+const period = 2000;
+
+// The traffic of the services at the last poll, for the rates.
+let traffic = new Map();
+
+function token() {
+  return sessionStorage.getItem("gost.token") || "";
+}
+
+async function call(method, path) {
+  const headers = {};
+  if (token()) {
+    headers["Authorization"] = "Bearer " + token();
+  }
+  const resp = await fetch(base + path, { method, headers, credentials: "same-origin" });
+  const body = await resp.json().catch(() => ({}));
+  if (!resp.ok) {
+    throw new Error(`${method} ${path}: ${resp.status} ${body.msg || resp.statusText}`);
+  }
+  return body.data;
+}
+
+// el returns a new element with the attributes and the children, strings
+// being text.
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k.startsWith("on")) {
+      e.addEventListener(k.slice(2), v);
+    } else {
+      e.setAttribute(k, v);
+    }
+  }
+  for (const c of children) {
+    e.append(c instanceof Node ? c : String(c ?? ""));
+  }
+  return e;
+}
+
+function num(v) {
+  return el("td", { class: "num" }, v);
+}
+
+function fill(id, rows, columns) {
+  const tbody = document.querySelector(`#${id} tbody`);
+  if (rows.length === 0) {
+    rows = [el("tr", {}, el("td", { class: "empty", colspan: columns }, "none"))];
+  }
+  tbody.replaceChildren(...rows);
+}
+
+function bytes(n) {
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let i = 0;
+  for (n = n || 0; n >= 1024 && i < units.length - 1; i++) {
+    n /= 1024;
+  }
+  return (i === 0 ? n : n.toFixed(1)) + " " + units[i];
+}
+
+function since(t) {
+  const s = Math.max(0, Math.round((Date.now() - new Date(t)) / 1000));
+  if (s < 60) return s + "s";
+  if (s < 3600) return Math.floor(s / 60) + "m";
+  if (s < 86400) return Math.floor(s / 3600) + "h";
+  return Math.floor(s / 86400) + "d";
+}
+
+function state(text, cls) {
+  return el("td", { class: cls }, text);
+}
+
+function renderServices(services) {
+  const now = Date.now();
+  const next = new Map();
+  let conns = 0;
+  const rows = services.map((svc) => {
+    const stats = (svc.status && svc.status.stats) || {};
+    conns += stats.currentConns || 0;
+
+    const prev = traffic.get(svc.name);
+    const cur = { t: now, in: stats.inputBytes || 0, out: stats.outputBytes || 0 };
+    next.set(svc.name, cur);
+    let rateIn = "", rateOut = "";
+    if (prev && cur.t > prev.t && cur.in >= prev.in && cur.out >= prev.out) {
+      const dt = (cur.t - prev.t) / 1000;
+      rateIn = bytes((cur.in - prev.in) / dt) + "/s";
+      rateOut = bytes((cur.out - prev.out) / dt) + "/s";
+    }
+
+    const st = (svc.status && svc.status.state) || "unknown";
+    return el("tr", {},
+      el("td", {}, svc.name),
+      el("td", {}, svc.addr),
+      el("td", {}, (svc.listener && svc.listener.type) || ""),
+      el("td", {}, (svc.handler && svc.handler.type) || ""),
+      state(st, st === "ready" || st === "running" ? "ok" : st === "failed" ? "failed" : "unknown"),
+      num(stats.currentConns || 0),
+      num(stats.totalConns || 0),
+      num(stats.totalErrs || 0),
+      num(rateIn),
+      num(rateOut));
+  });
+  traffic = next;
+  fill("services", rows, 10);
+  return conns;
+}
+
+// nodeState returns the cells of the state of a node, known once it is
+// watched.
+function nodeState(st) {
+  if (!st) {
+    return [state("not used yet", "unknown"), num(""), el("td")];
+  }
+  let probe = "";
+  if (st.probe) {
+    probe = st.probe.success ? (st.probe.latency / 1e6).toFixed(1) + " ms" : "failed: " + st.probe.error;
+  }
+  return [
+    st.failed ? state("failed (" + st.reason + ")", "failed") : state("ok", "ok"),
+    num(st.fails),
+    el("td", { class: st.probe && !st.probe.success ? "failed" : "" }, probe),
+  ];
+}
+
+function renderChains(chains, hops, nodes) {
+  const hopNodes = new Map(hops.map((h) => [h.name, h.nodes || []]));
+  const states = new Map();
+  for (const st of nodes) {
+    states.set([st.chain, st.hop, st.node].join("/"), st);
+    if (!states.has(["", st.hop, st.node].join("/"))) {
+      states.set(["", st.hop, st.node].join("/"), st);
+    }
+  }
+
+  const rows = [];
+  for (const c of chains) {
+    for (const h of c.hops || []) {
+      const list = h.nodes || hopNodes.get(h.name) || [];
+      if (list.length === 0) {
+        rows.push(el("tr", {}, el("td", {}, c.name), el("td", {}, h.name), el("td", { colspan: 5, class: "unknown" }, "no nodes")));
+      }
+      for (const n of list) {
+        const st = states.get([c.name, h.name, n.name].join("/")) || states.get(["", h.name, n.name].join("/"));
+        rows.push(el("tr", {}, el("td", {}, c.name), el("td", {}, h.name), el("td", {}, n.name), el("td", {}, n.addr), ...nodeState(st)));
+      }
+    }
+  }
+  // The nodes the services forward to.
+  for (const st of nodes.filter((st) => st.service && !st.chain)) {
+    rows.push(el("tr", {}, el("td", {}, "forward: " + st.service), el("td", {}, st.hop || ""), el("td", {}, st.node), el("td", {}, st.addr), ...nodeState(st)));
+  }
+  fill("chains", rows, 7);
+}
+
+function renderConnections(conns) {
+  document.getElementById("connection-count").textContent = `(${conns.count})`;
+  const rows = conns.list.map((c) => el("tr", {},
+    el("td", {}, c.service),
+    el("td", {}, c.client),
+    el("td", {}, c.user || ""),
+    el("td", {}, c.host || ""),
+    el("td", {}, [c.chain, ...(c.path || []).map((n) => n.node)].filter(Boolean).join(" → ")),
+    num(bytes(c.inputBytes)),
+    num(bytes(c.outputBytes)),
+    el("td", {}, since(c.start)),
+    el("td", {}, el("button", { type: "button", onclick: () => act("DELETE", "/connections/" + encodeURIComponent(c.id)) }, "Close"))));
+  fill("connections", rows, 9);
+}
+
+function renderIngresses(ingresses, tunnels) {
+  const connectors = new Map();
+  for (const t of tunnels) {
+    connectors.set(t.tunnel, (connectors.get(t.tunnel) || 0) + 1);
+  }
+  const rows = [];
+  for (const ing of ingresses) {
+    for (const r of ing.rules || []) {
+      const n = connectors.get(r.endpoint) || 0;
+      rows.push(el("tr", {}, el("td", {}, ing.name), el("td", {}, r.hostname), el("td", {}, r.endpoint),
+        state(n, n > 0 ? "ok" : "failed")));
+    }
+  }
+  fill("ingresses", rows, 4);
+
+  fill("tunnels", tunnels.map((t) => el("tr", {},
+    el("td", {}, t.service),
+    el("td", {}, t.tunnel),
+    el("td", {}, t.connector),
+    el("td", {}, t.node || ""),
+    el("td", {}, t.client || ""),
+    el("td", {}, t.network || ""),
+    el("td", {}, since(t.since)))), 7);
+}
+
+function renderReloads(reloads) {
+  fill("reloads", reloads.map((r) => el("tr", {},
+    el("td", {}, new Date(r.time).toLocaleString()),
+    state(r.status, r.status === "success" ? "ok" : "failed"),
+    el("td", {}, r.error || r.changes || ""))), 3);
+}
+
+function showError(err) {
+  const e = document.getElementById("error");
+  e.hidden = !err;
+  e.textContent = err ? err.message : "";
+}
+
+async function refresh() {
+  try {
+    const [services, chains, hops, nodes, conns, ingresses, tunnels, reloads] = await Promise.all([
+      call("GET", "/config/services"),
+      call("GET", "/config/chains"),
+      call("GET", "/config/hops"),
+      call("GET", "/nodes"),
+      call("GET", "/connections?size=100"),
+      call("GET", "/config/ingresses"),
+      call("GET", "/tunnels"),
+      call("GET", "/reloads"),
+    ]);
+    const current = renderServices(services.list || []);
+    renderChains(chains.list || [], hops.list || [], nodes.list);
+    renderConnections(conns);
+    renderIngresses(ingresses.list || [], tunnels.list);
+    renderReloads(reloads.list);
+
+    const failed = nodes.list.filter((n) => n.failed).length;
+    document.getElementById("summary").textContent =
+      `${(services.list || []).length} services, ${current} connections, ${failed} nodes failed`;
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// act makes a change and refreshes the dashboard.
+async function act(method, path) {
+  try {
+    await call(method, path);
+    await refresh();
+  } catch (err) {
+    showError(err);
+  }
+}
+
+document.getElementById("reload").addEventListener("click", () => act("POST", "/config/reload"));
+document.getElementById("auth").addEventListener("submit", (e) => {
+  e.preventDefault();
+  const input = document.getElementById("token");
+  sessionStorage.setItem("gost.token", input.value);
+  input.value = "";
+  refresh();
+});
+
+refresh();
+setInterval(refresh, period);
@@ -0,0 +1,564 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-gost/x/config"
+)
+
+// Change is how a resource differs between two configs.
+type Change int
+
+const (
+	Added Change = iota
+	Changed
+	Removed
+)
+
+func (c Change) String() string {
+	switch c {
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	case Removed:
+		return "removed"
+	default:
+		return ""
+	}
+}
+
+// Resource identifies a named resource by the config section it is defined
+// in and its name, e.g. chains/chain-0.
+type Resource struct {
+	Kind string
+	Name string
+}
+
+func (r Resource) String() string {
+	return r.Kind + "/" + r.Name
+}
+
+// Diff is the structural difference between two configs
+// in terms of named resources.
+type Diff struct {
+	changes map[Resource]Change
+	// causes records, for the resources that are changed only because
+	// a resource they depend on has changed, that dependency.
+	causes map[Resource]Resource
+}
+
+// DefaultTLS stands for the default TLS config in a diff.
+var DefaultTLS = Resource{Kind: "tls", Name: "default"}
+
+// diffConfig compares the named resources of old and cfg. A resource is
+// also considered changed when any resource it depends on is added,
+// changed or removed. The touched resources are considered changed even if
+// their config is the same, e.g. when the content of a file they read has
+// changed.
+func diffConfig(old, cfg *config.Config, touched ...Resource) *Diff {
+	d := &Diff{
+		changes: make(map[Resource]Change),
+		causes:  make(map[Resource]Resource),
+	}
+
+	for _, kind := range allKinds() {
+		oldConfigs := kind.configs(old)
+		configs := kind.configs(cfg)
+		for name, c := range configs {
+			key := Resource{Kind: kind.name, Name: name}
+			if o, ok := oldConfigs[name]; !ok {
+				d.changes[key] = Added
+			} else if !equalConfig(o, c) {
+				d.changes[key] = Changed
+			}
+		}
+		for name := range oldConfigs {
+			if _, ok := configs[name]; !ok {
+				d.changes[Resource{Kind: kind.name, Name: name}] = Removed
+			}
+		}
+	}
+
+	if old != nil && !equalConfig(old.TLS, cfg.TLS) {
+		d.changes[DefaultTLS] = Changed
+	}
+
+	for _, key := range touched {
+		if _, ok := d.changes[key]; ok {
+			continue
+		}
+		if kind := resourceKindOf(key.Kind); key == DefaultTLS ||
+			kind != nil && kind.configs(cfg)[key.Name] != nil {
+			d.changes[key] = Changed
+		}
+	}
+
+	// The default TLS config is used by every service, hop and chain
+	// that does not set up its own.
+	if _, ok := d.changes[DefaultTLS]; ok {
+		for _, kind := range []*resourceKind{serviceKind, resourceKindOf("hops"), resourceKindOf("chains")} {
+			for name := range kind.configs(cfg) {
+				key := Resource{Kind: kind.name, Name: name}
+				if _, ok := d.changes[key]; !ok {
+					d.changes[key] = Changed
+					d.causes[key] = DefaultTLS
+				}
+			}
+		}
+	}
+
+	dependents := make(map[Resource][]Resource)
+	for _, ref := range References(cfg) {
+		dependents[ref.To] = append(dependents[ref.To], ref.From)
+	}
+
+	var queue []Resource
+	for key := range d.changes {
+		queue = append(queue, key)
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for _, dep := range dependents[key] {
+			if _, ok := d.changes[dep]; ok {
+				continue
+			}
+			d.changes[dep] = Changed
+			d.causes[dep] = key
+			queue = append(queue, dep)
+		}
+	}
+
+	return d
+}
+
+// Resources returns the resources with any of the given changes, in load
+// order.
+func (d *Diff) Resources(changes ...Change) []Resource {
+	var keys []Resource
+	for key, c := range d.changes {
+		for _, v := range changes {
+			if c == v {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+
+	// Resources not built from a config section (the default TLS config)
+	// come first.
+	order := make(map[string]int)
+	for i, kind := range allKinds() {
+		order[kind.name] = i + 1
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Kind != keys[j].Kind {
+			if order[keys[i].Kind] != order[keys[j].Kind] {
+				return order[keys[i].Kind] < order[keys[j].Kind]
+			}
+			return keys[i].Kind < keys[j].Kind
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// Names returns the names of the resources of the given kind with any of
+// the given changes.
+func (d *Diff) Names(kind string, changes ...Change) []string {
+	var names []string
+	for _, key := range d.Resources(changes...) {
+		if key.Kind == kind {
+			names = append(names, key.Name)
+		}
+	}
+	return names
+}
+
+// Has reports whether r is added, changed or removed.
+func (d *Diff) Has(r Resource) bool {
+	_, ok := d.changes[r]
+	return ok
+}
+
+// Change returns how r differs, if it does.
+func (d *Diff) Change(r Resource) (Change, bool) {
+	c, ok := d.changes[r]
+	return c, ok
+}
+
+// Cause returns the resource r depends on which has changed, when r is
+// changed only because of it.
+func (d *Diff) Cause(r Resource) (Resource, bool) {
+	cause, ok := d.causes[r]
+	return cause, ok
+}
+
+// Empty reports whether there is no difference.
+func (d *Diff) Empty() bool {
+	return len(d.changes) == 0
+}
+
+func (d *Diff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+
+	var parts []string
+	for _, c := range []Change{Added, Changed, Removed} {
+		keys := d.Resources(c)
+		if len(keys) == 0 {
+			parts = append(parts, fmt.Sprintf("0 %s", c))
+			continue
+		}
+
+		var ss []string
+		for _, key := range keys {
+			if cause, ok := d.causes[key]; ok {
+				ss = append(ss, fmt.Sprintf("%s (via %s)", key, cause))
+			} else {
+				ss = append(ss, key.String())
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d %s [%s]", len(keys), c, strings.Join(ss, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ResourceChange is a resource changed from a config to another.
+type ResourceChange struct {
+	Resource string `json:"resource"`
+	// Change is added, changed or removed.
+	Change string `json:"change"`
+	// Before and After are the config of the resource.
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// resourceChanges returns the resources changed from before to after, not
+// the ones changed only because of their dependencies.
+func resourceChanges(before, after *config.Config) []ResourceChange {
+	if before == nil || after == nil {
+		return nil
+	}
+
+	d := diffConfig(before, after)
+	var changes []ResourceChange
+	for _, r := range d.Resources(Added, Changed, Removed) {
+		if _, ok := d.Cause(r); ok {
+			continue
+		}
+		kind := resourceKindOf(r.Kind)
+		if kind == nil {
+			continue
+		}
+		c, _ := d.Change(r)
+		rc := ResourceChange{
+			Resource: r.String(),
+			Change:   c.String(),
+		}
+		if v, ok := kind.configs(before)[r.Name]; ok {
+			rc.Before, _ = json.Marshal(v)
+		}
+		if v, ok := kind.configs(after)[r.Name]; ok {
+			rc.After, _ = json.Marshal(v)
+		}
+		changes = append(changes, rc)
+	}
+	return changes
+}
+
+func allKinds() []*resourceKind {
+	return append(resourceKinds[:len(resourceKinds):len(resourceKinds)], serviceKind)
+}
+
+func resourceKindOf(name string) *resourceKind {
+	for _, kind := range allKinds() {
+		if kind.name == name {
+			return kind
+		}
+	}
+	return nil
+}
+
+// equalConfig reports whether two configs are structurally equal, ignoring
+// runtime status and the defaults filled in when a resource is parsed.
+func equalConfig(a, b any) bool {
+	return fingerprint(a) == fingerprint(b)
+}
+
+func fingerprint(c any) string {
+	b, _ := json.Marshal(normalized(c))
+	return string(b)
+}
+
+// normalized returns the JSON form of a config, without the runtime status
+// and with the defaults filled in when the resource is parsed.
+func normalized(c any) any {
+	if svc, ok := c.(*config.ServiceConfig); ok && svc != nil {
+		c = normalizeService(svc)
+	}
+
+	b, _ := json.Marshal(c)
+
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return string(b)
+	}
+	if m, ok := v.(map[string]any); ok {
+		delete(m, "status")
+	}
+	normalizeNodes(v)
+	return v
+}
+
+// normalizeNodes applies the connector and dialer defaults filled in by the
+// hop parser to every node list found in the JSON form of a config.
+func normalizeNodes(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if nodes, ok := v["nodes"].([]any); ok {
+			for _, node := range nodes {
+				if node, ok := node.(map[string]any); ok {
+					setDefaultType(node, "connector", "http")
+					setDefaultType(node, "dialer", "tcp")
+				}
+			}
+		}
+		for _, vv := range v {
+			normalizeNodes(vv)
+		}
+	case []any:
+		for _, vv := range v {
+			normalizeNodes(vv)
+		}
+	}
+}
+
+func setDefaultType(m map[string]any, key, typ string) {
+	c, _ := m[key].(map[string]any)
+	if c == nil {
+		c = make(map[string]any)
+		m[key] = c
+	}
+	if t, _ := c["type"].(string); strings.TrimSpace(t) == "" {
+		c["type"] = typ
+	}
+}
+
+// normalizeService returns a copy of cfg with the listener and handler
+// defaults applied by the service parser.
+func normalizeService(cfg *config.ServiceConfig) *config.ServiceConfig {
+	c := *cfg
+
+	ln := config.ListenerConfig{}
+	if c.Listener != nil {
+		ln = *c.Listener
+	}
+	if strings.TrimSpace(ln.Type) == "" {
+		ln.Type = "tcp"
+	}
+	c.Listener = &ln
+
+	h := config.HandlerConfig{}
+	if c.Handler != nil {
+		h = *c.Handler
+	}
+	if strings.TrimSpace(h.Type) == "" {
+		h.Type = "auto"
+	}
+	c.Handler = &h
+
+	return &c
+}
+
+// Reference is a reference by name from one resource to another.
+type Reference struct {
+	From Resource
+	To   Resource
+	// Path is the location of the reference within the referencing
+	// resource config, e.g. handler.chain.
+	Path string
+}
+
+// References returns all the references between the named resources of cfg.
+func References(cfg *config.Config) []Reference {
+	var refs []Reference
+
+	ref := func(from Resource, kind, name, path string) {
+		if name == "" {
+			return
+		}
+		refs = append(refs, Reference{
+			From: from,
+			To:   Resource{Kind: kind, Name: name},
+			Path: path,
+		})
+	}
+	refList := func(from Resource, kind string, names []string, path string) {
+		for i, name := range names {
+			ref(from, kind, name, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	refMetadata := func(from Resource, kind string, md map[string]any, path string, keys ...string) {
+		for _, k := range keys {
+			if name, ok := md[k].(string); ok {
+				ref(from, kind, name, path+"."+k)
+			}
+		}
+	}
+	refChainGroup := func(from Resource, cg *config.ChainGroupConfig, path string) {
+		if cg == nil {
+			return
+		}
+		for i, c := range cg.Chains {
+			if c != nil {
+				ref(from, "chains", c.Chain, fmt.Sprintf("%s.chains[%d].chain", path, i))
+			}
+		}
+	}
+	refNode := func(from Resource, node *config.NodeConfig, path string) {
+		ref(from, "bypasses", node.Bypass, path+".bypass")
+		refList(from, "bypasses", node.Bypasses, path+".bypasses")
+		ref(from, "resolvers", node.Resolver, path+".resolver")
+		ref(from, "hosts", node.Hosts, path+".hosts")
+		if node.HTTP == nil {
+			return
+		}
+		for field, rewrites := range map[string][]config.HTTPBodyRewriteConfig{
+			"rewriteBody":         node.HTTP.RewriteBody,
+			"rewriteRequestBody":  node.HTTP.RewriteRequestBody,
+			"rewriteResponseBody": node.HTTP.RewriteResponseBody,
+		} {
+			for i, rw := range rewrites {
+				ref(from, "rewriters", rw.Rewriter, fmt.Sprintf("%s.http.%s[%d].rewriter", path, field, i))
+			}
+		}
+	}
+	refHop := func(from Resource, hop *config.HopConfig, path string) {
+		ref(from, "bypasses", hop.Bypass, path+"bypass")
+		refList(from, "bypasses", hop.Bypasses, path+"bypasses")
+		ref(from, "resolvers", hop.Resolver, path+"resolver")
+		ref(from, "hosts", hop.Hosts, path+"hosts")
+		for i, node := range hop.Nodes {
+			if node != nil {
+				refNode(from, node, fmt.Sprintf("%snodes[%d]", path, i))
+			}
+		}
+	}
+
+	for _, svc := range cfg.Services {
+		if svc == nil {
+			continue
+		}
+		from := Resource{Kind: "services", Name: svc.Name}
+
+		ref(from, "admissions", svc.Admission, "admission")
+		refList(from, "admissions", svc.Admissions, "admissions")
+		ref(from, "bypasses", svc.Bypass, "bypass")
+		refList(from, "bypasses", svc.Bypasses, "bypasses")
+		ref(from, "resolvers", svc.Resolver, "resolver")
+		ref(from, "hosts", svc.Hosts, "hosts")
+		ref(from, "limiters", svc.Limiter, "limiter")
+		refList(from, "quotas", svc.Quotas, "quotas")
+		ref(from, "climiters", svc.CLimiter, "climiter")
+		ref(from, "rlimiters", svc.RLimiter, "rlimiter")
+		ref(from, "loggers", svc.Logger, "logger")
+		refList(from, "loggers", svc.Loggers, "loggers")
+		ref(from, "observers", svc.Observer, "observer")
+		ref(from, "rewriters", svc.Rewriter, "rewriter")
+		ref(from, "caches", svc.Cache, "cache")
+		for i, rec := range svc.Recorders {
+			if rec != nil {
+				ref(from, "recorders", rec.Name, fmt.Sprintf("recorders[%d].name", i))
+			}
+		}
+
+		if h := svc.Handler; h != nil {
+			ref(from, "chains", h.Chain, "handler.chain")
+			refChainGroup(from, h.ChainGroup, "handler.chainGroup")
+			ref(from, "authers", h.Auther, "handler.auther")
+			refList(from, "authers", h.Authers, "handler.authers")
+			ref(from, "limiters", h.Limiter, "handler.limiter")
+			ref(from, "observers", h.Observer, "handler.observer")
+			refMetadata(from, "ingresses", h.Metadata, "handler.metadata", "ingress")
+			refMetadata(from, "sds", h.Metadata, "handler.metadata", "sd")
+			refMetadata(from, "routers", h.Metadata, "handler.metadata", "router")
+			refMetadata(from, "bypasses", h.Metadata, "handler.metadata", "mitm.bypass")
+		}
+
+		if ln := svc.Listener; ln != nil {
+			ref(from, "chains", ln.Chain, "listener.chain")
+			refChainGroup(from, ln.ChainGroup, "listener.chainGroup")
+			ref(from, "authers", ln.Auther, "listener.auther")
+			refList(from, "authers", ln.Authers, "listener.authers")
+			refMetadata(from, "routers", ln.Metadata, "listener.metadata", "router", "tun.router")
+		}
+
+		if fwd := svc.Forwarder; fwd != nil {
+			switch {
+			case fwd.HopGroup != nil:
+				for i, h := range fwd.HopGroup.Hops {
+					if h != nil {
+						ref(from, "hops", h.Hop, fmt.Sprintf("forwarder.hopGroup.hops[%d].hop", i))
+					}
+				}
+			case fwd.Hop != "":
+				ref(from, "hops", fwd.Hop, "forwarder.hop")
+			case fwd.Name != "":
+				ref(from, "hops", fwd.Name, "forwarder.name")
+			default:
+				for i, node := range fwd.Nodes {
+					if node == nil {
+						continue
+					}
+					path := fmt.Sprintf("forwarder.nodes[%d]", i)
+					ref(from, "bypasses", node.Bypass, path+".bypass")
+					refList(from, "bypasses", node.Bypasses, path+".bypasses")
+				}
+			}
+		}
+	}
+
+	for _, c := range cfg.Chains {
+		if c == nil {
+			continue
+		}
+		from := Resource{Kind: "chains", Name: c.Name}
+		for i, hop := range c.Hops {
+			if hop == nil {
+				continue
+			}
+			path := fmt.Sprintf("hops[%d].", i)
+			if hop.Nodes == nil && hop.Plugin == nil {
+				ref(from, "hops", hop.Name, path+"name")
+				continue
+			}
+			refHop(from, hop, path)
+		}
+	}
+
+	for _, hop := range cfg.Hops {
+		if hop != nil {
+			refHop(Resource{Kind: "hops", Name: hop.Name}, hop, "")
+		}
+	}
+
+	for _, r := range cfg.Resolvers {
+		if r == nil {
+			continue
+		}
+		from := Resource{Kind: "resolvers", Name: r.Name}
+		for i, ns := range r.Nameservers {
+			if ns != nil {
+				ref(from, "chains", ns.Chain, fmt.Sprintf("nameservers[%d].chain", i))
+			}
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/x/registry"
+)
+
+// tracker records the in-flight connections of every handler built from the
+// handler registry, so that closed services can be drained gracefully.
+var tracker = &connTracker{
+	handlers:  make(map[*trackedHandler]struct{}),
+	factories: make(map[string]bool),
+}
+
+// trackHandlers replaces every registered handler factory not replaced yet
+// with one that wraps the created handler in a trackedHandler, its router
+// in one recording the sessions while they are tracked and its
+// authenticator in one publishing the failures. It must be called before
+// any service is parsed.
+func trackHandlers() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	r := registry.HandlerRegistry()
+	for name, newHandler := range r.GetAll() {
+		if tracker.factories[name] {
+			continue
+		}
+		tracker.factories[name] = true
+		r.Unregister(name)
+		r.Register(name, func(opts ...handler.Option) handler.Handler {
+			var options handler.Options
+			for _, opt := range opts {
+				opt(&options)
+			}
+			track := trackSessions.Load()
+			if track && options.Router != nil {
+				opts = append(opts, handler.RouterOption(trackRouter(options.Service, options.Router)))
+			}
+			if options.Auther != nil {
+				opts = append(opts, handler.AutherOption(&eventAuther{Authenticator: options.Auther, service: options.Service}))
+			}
+			return tracker.track(options.Service, newHandler(opts...), name == "tunnel", track)
+		})
+	}
+}
+
+type connTracker struct {
+	handlers map[*trackedHandler]struct{}
+	// factories are the names of the handler factories replaced.
+	factories map[string]bool
+	mu        sync.Mutex
+}
+
+func (t *connTracker) track(service string, h handler.Handler, tunnel, sessions bool) handler.Handler {
+	th := &trackedHandler{
+		Handler:  h,
+		service:  service,
+		tunnel:   tunnel,
+		sessions: sessions,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	t.mu.Lock()
+	t.handlers[th] = struct{}{}
+	t.mu.Unlock()
+
+	// Only expose Forward when the wrapped handler supports it, the service
+	// parser uses the interface to decide whether to build a forwarder hop.
+	if _, ok := h.(handler.Forwarder); ok {
+		return &trackedForwarder{trackedHandler: th}
+	}
+	return th
+}
+
+func (t *connTracker) remove(h *trackedHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.handlers, h)
+}
+
+// discard closes the handlers of service not closed yet, built for a
+// service that failed to be built.
+func (t *connTracker) discard(service string) {
+	t.mu.Lock()
+	var handlers []*trackedHandler
+	for h := range t.handlers {
+		if closed, _ := h.state(); !closed && h.service == service {
+			handlers = append(handlers, h)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, h := range handlers {
+		h.Close()
+	}
+}
+
+// serve starts svc, marking the handlers of the service not closed yet, the
+// ones svc is built with, as serving until it stops accepting connections
+// and the ones it accepted are finished: a closed handler is only released
+// once its service can no longer hand it a connection.
+func (t *connTracker) serve(name string, svc service.Service) {
+	var handlers []*trackedHandler
+	t.mu.Lock()
+	for h := range t.handlers {
+		h.mu.Lock()
+		if !h.closed && h.service == name {
+			h.serving = true
+			handlers = append(handlers, h)
+		}
+		h.mu.Unlock()
+	}
+	t.mu.Unlock()
+
+	go func() {
+		svc.Serve()
+		for _, h := range handlers {
+			h.stopServing()
+		}
+	}()
+}
+
+// draining returns the closed handlers not released yet.
+func (t *connTracker) draining() []*trackedHandler {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var handlers []*trackedHandler
+	for h := range t.handlers {
+		if closed, _ := h.state(); closed {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+func (t *connTracker) tracking(h *trackedHandler) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.handlers[h]
+	return ok
+}
+
+// connections returns the number of live connections of the running
+// services, by service.
+func (t *connTracker) connections() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	conns := make(map[string]int)
+	for h := range t.handlers {
+		if closed, n := h.state(); !closed {
+			conns[h.service] += n
+		}
+	}
+	return conns
+}
+
+// drain waits until all currently closed handlers are released, their
+// connections finished, or ctx is done, logging the progress periodically.
+// Connections still active then are closed.
+func (t *connTracker) drain(ctx context.Context) {
+	handlers := t.draining()
+	if len(handlers) == 0 {
+		return
+	}
+
+	deadline, _ := ctx.Deadline()
+	for _, h := range handlers {
+		h.setDeadline(deadline)
+	}
+
+	log := logger.Default().WithFields(map[string]any{"kind": "drain"})
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	last := -1
+	for {
+		conns, released := 0, true
+		for _, h := range handlers {
+			_, n := h.state()
+			conns += n
+			released = released && !t.tracking(h)
+		}
+		if released {
+			log.Info("draining finished")
+			return
+		}
+		if conns != last {
+			if deadline.IsZero() {
+				log.Infof("%d connections still draining", conns)
+			} else {
+				log.Infof("%d connections still draining, %s left",
+					conns, time.Until(deadline).Round(time.Second))
+			}
+			last = conns
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Warnf("drain deadline exceeded, closing %d connections", conns)
+			for _, h := range handlers {
+				h.closeConns()
+				h.release()
+			}
+			return
+		}
+	}
+}
+
+// trackedHandler is a handler.Handler that keeps track of the connections
+// it is handling.
+type trackedHandler struct {
+	handler.Handler
+	service string
+	// tunnel is set for the tunnel handlers, their connectors binding and
+	// leaving are published.
+	tunnel bool
+	// sd is the name of the eventSD of the tunnel handlers.
+	sd string
+	// sessions is set if the sessions of the connections are recorded.
+	sessions bool
+	conns    map[net.Conn]struct{}
+	closed   bool
+	// serving is set while the service may hand connections over, from
+	// the moment it starts until its accept loop is done.
+	serving  bool
+	deadline time.Time
+	mu       sync.Mutex
+	// released is done once the wrapped handler is closed.
+	released sync.Once
+}
+
+func (h *trackedHandler) Init(md metadata.Metadata) error {
+	if h.tunnel {
+		md, h.sd = tunnelMetadata(h.service, md)
+	}
+	return h.Handler.Init(md)
+}
+
+func (h *trackedHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	h.mu.Lock()
+	h.conns[conn] = struct{}{}
+	h.mu.Unlock()
+
+	var sess *session
+	if h.sessions {
+		sess = sessions.add(ctx, h.service, conn)
+	}
+
+	defer func() {
+		if sess != nil {
+			sessions.remove(sess)
+		}
+
+		h.mu.Lock()
+		delete(h.conns, conn)
+		done := h.closed && !h.serving && len(h.conns) == 0
+		h.mu.Unlock()
+
+		if done {
+			h.release()
+		}
+	}()
+
+	return h.Handler.Handle(ctx, conn, opts...)
+}
+
+// Close marks the handler as draining. The service is being closed at this
+// point, a connection it has just accepted may still arrive, while the
+// existing ones are left alone: the wrapped handler, whose state they may
+// use, is closed once the service is done and they are finished, or once
+// they are closed by the drain deadline.
+func (h *trackedHandler) Close() error {
+	nodes.forget(h.service)
+
+	h.mu.Lock()
+	h.closed = true
+	done := !h.serving && len(h.conns) == 0
+	h.mu.Unlock()
+
+	if done {
+		return h.release()
+	}
+	return nil
+}
+
+// stopServing is called once the service is done, all the connections it
+// accepted are finished.
+func (h *trackedHandler) stopServing() {
+	h.mu.Lock()
+	h.serving = false
+	done := h.closed && len(h.conns) == 0
+	h.mu.Unlock()
+
+	if done {
+		h.release()
+	}
+}
+
+// release closes the wrapped handler and stops tracking it, once.
+func (h *trackedHandler) release() (err error) {
+	h.released.Do(func() {
+		tracker.remove(h)
+		if closer, ok := h.Handler.(io.Closer); ok {
+			err = closer.Close()
+		}
+		if h.sd != "" {
+			registry.SDRegistry().Unregister(h.sd)
+		}
+	})
+	return
+}
+
+func (h *trackedHandler) state() (closed bool, conns int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.closed, len(h.conns)
+}
+
+func (h *trackedHandler) setDeadline(deadline time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.deadline = deadline
+}
+
+func (h *trackedHandler) closeConns() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for conn := range h.conns {
+		conn.Close()
+	}
+}
+
+type trackedForwarder struct {
+	*trackedHandler
+}
+
+func (h *trackedForwarder) Forward(hop hop.Hop) {
+	nodes.forward(h.service, hop)
+	h.Handler.(handler.Forwarder).Forward(hop)
+}
+
+type drainStatus struct {
+	Connections int                  `json:"connections"`
+	Services    []drainServiceStatus `json:"services,omitempty"`
+}
+
+type drainServiceStatus struct {
+	Service     string    `json:"service"`
+	Connections int       `json:"connections"`
+	Deadline    time.Time `json:"deadline,omitzero"`
+}
+
+func (t *connTracker) status() drainStatus {
+	var st drainStatus
+	for _, h := range t.draining() {
+		h.mu.Lock()
+		ss := drainServiceStatus{
+			Service:     h.service,
+			Connections: len(h.conns),
+			Deadline:    h.deadline,
+		}
+		h.mu.Unlock()
+
+		st.Connections += ss.Connections
+		st.Services = append(st.Services, ss)
+	}
+	sort.Slice(st.Services, func(i, j int) bool {
+		return st.Services[i].Service < st.Services[j].Service
+	})
+	return st
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The types of the events.
+const (
+	EventServiceStarted = "service.started"
+	EventServiceStopped = "service.stopped"
+	// EventReloadSucceeded and EventReloadFailed are published after each
+	// reload, Status tells a rejected config from a rolled back one.
+	EventReloadSucceeded = "reload.succeeded"
+	EventReloadFailed    = "reload.failed"
+	// EventNodeFailed is published when a node is marked failed, either by
+	// its failures (Reason fails) until the fail filter of its hop skips it,
+	// or by its probe (Reason probe). EventNodeRecovered is published when
+	// the node can be selected again.
+	EventNodeFailed    = "node.failed"
+	EventNodeRecovered = "node.recovered"
+	EventAuthFailed    = "auth.failed"
+	// EventAdmissionDenied and EventLimiterRejected are published when a
+	// connection is refused by an admission or by a connection or rate
+	// limiter.
+	EventAdmissionDenied = "admission.denied"
+	EventLimiterRejected = "limiter.rejected"
+	// EventTunnelConnected and EventTunnelDisconnected are published when
+	// a connector binds to or leaves a tunnel of a tunnel handler.
+	EventTunnelConnected    = "tunnel.connected"
+	EventTunnelDisconnected = "tunnel.disconnected"
+)
+
+// Event is something that happened in the server, the fields not relevant
+// to the type are empty.
+type Event struct {
+	// ID increases with each event published.
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Service string    `json:"service,omitempty"`
+	// Resource is the admission or the limiter refusing a connection, as
+	// kind/name.
+	Resource string `json:"resource,omitempty"`
+	// Chain, Hop, Node and Addr locate a node.
+	Chain string `json:"chain,omitempty"`
+	Hop   string `json:"hop,omitempty"`
+	Node  string `json:"node,omitempty"`
+	Addr  string `json:"addr,omitempty"`
+	// Client is the address of the client, User the user name it tried.
+	Client string `json:"client,omitempty"`
+	User   string `json:"user,omitempty"`
+	// Key is the key of the limiter, usually the client IP.
+	Key       string `json:"key,omitempty"`
+	Tunnel    string `json:"tunnel,omitempty"`
+	Connector string `json:"connector,omitempty"`
+	Network   string `json:"network,omitempty"`
+	// Status, Changes and Error describe a reload, Reason and Error a node
+	// failure.
+	Status  string `json:"status,omitempty"`
+	Changes string `json:"changes,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Count is the number of occurrences the event stands for, the
+	// refusals of a client are published at most once per second.
+	Count int `json:"count,omitempty"`
+}
+
+// EventFilter selects events.
+type EventFilter struct {
+	// Types are the event types, or their prefixes up to a dot, e.g. node
+	// for node.failed and node.recovered. Empty matches every type.
+	Types []string
+	// Since is the ID of the last event received, the retained events
+	// published after it are sent first. Zero starts with the next event.
+	Since uint64
+}
+
+func (f EventFilter) match(e *Event) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(f.Types, func(t string) bool {
+		return t == e.Type || strings.HasPrefix(e.Type, t+".")
+	})
+}
+
+// Events returns the events matched by f, until ctx is done. The channel
+// is closed then, or as soon as the receiver falls behind, when it can
+// resume with Since set to the ID of the last event it received.
+func (s *Server) Events(ctx context.Context, f EventFilter) <-chan Event {
+	return events.subscribe(ctx, f)
+}
+
+const (
+	// eventBacklog is the number of events retained for the subscribers
+	// resuming, eventBuffer how many can be pending for a subscriber.
+	eventBacklog = 1024
+	eventBuffer  = 256
+	// eventThrottle is the period the refusals of a key are published at
+	// most once in.
+	eventThrottle = time.Second
+)
+
+// events dispatches the events to the subscribers.
+var events = &eventBus{
+	subscribers: make(map[*eventSubscriber]struct{}),
+	throttled:   make(map[string]*throttledEvent),
+}
+
+type eventBus struct {
+	mu          sync.Mutex
+	seq         uint64
+	backlog     []Event
+	subscribers map[*eventSubscriber]struct{}
+	throttled   map[string]*throttledEvent
+}
+
+type eventSubscriber struct {
+	filter EventFilter
+	ch     chan Event
+}
+
+type throttledEvent struct {
+	last  time.Time
+	count int
+}
+
+func (b *eventBus) subscribe(ctx context.Context, f EventFilter) <-chan Event {
+	sub := &eventSubscriber{
+		filter: f,
+		ch:     make(chan Event, eventBuffer+eventBacklog),
+	}
+
+	b.mu.Lock()
+	if f.Since > 0 {
+		for _, e := range b.backlog {
+			if e.ID > f.Since && f.match(&e) {
+				sub.ch <- e
+			}
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(sub)
+	}()
+
+	return sub.ch
+}
+
+func (b *eventBus) unsubscribe(sub *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.ID = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if len(b.backlog) == eventBacklog {
+		b.backlog = slices.Delete(b.backlog, 0, 1)
+	}
+	b.backlog = append(b.backlog, e)
+
+	for sub := range b.subscribers {
+		if !sub.filter.match(&e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// The subscriber is behind, it is dropped rather than
+			// blocking the connections publishing.
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// publishThrottled publishes e unless an event of the same key was
+// published less than eventThrottle ago, the events skipped are counted in
+// the next one.
+func (b *eventBus) publishThrottled(key string, e Event) {
+	now := time.Now()
+
+	b.mu.Lock()
+	t := b.throttled[key]
+	if t == nil {
+		if len(b.throttled) >= eventBacklog {
+			for k, t := range b.throttled {
+				if now.Sub(t.last) >= eventThrottle {
+					delete(b.throttled, k)
+				}
+			}
+		}
+		t = &throttledEvent{}
+		b.throttled[key] = t
+	}
+	t.count++
+	if now.Sub(t.last) < eventThrottle {
+		b.mu.Unlock()
+		return
+	}
+	e.Count = t.count
+	t.last, t.count = now, 0
+	b.mu.Unlock()
+
+	b.publish(e)
+}
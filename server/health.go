@@ -0,0 +1,303 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/logger"
+	xchain "github.com/go-gost/x/chain"
+	"github.com/go-gost/x/config"
+	chain_parser "github.com/go-gost/x/config/parsing/chain"
+	hop_parser "github.com/go-gost/x/config/parsing/hop"
+	node_parser "github.com/go-gost/x/config/parsing/node"
+	"github.com/go-gost/x/registry"
+	xservice "github.com/go-gost/x/service"
+)
+
+// Health is the health of the server, served by the API and metrics
+// services on /healthz and /readyz.
+type Health struct {
+	// Ready reports whether every service is serving and every required
+	// chain has a node passing in each of its hops.
+	Ready    bool            `json:"ready"`
+	Services []ServiceHealth `json:"services"`
+	Chains   []ChainHealth   `json:"chains"`
+}
+
+// ServiceHealth is the health of a service.
+type ServiceHealth struct {
+	Name string `json:"name"`
+	// State is running until the service accepts connections, then ready,
+	// failed while accepting fails, and closed.
+	State string `json:"state"`
+	Ready bool   `json:"ready"`
+}
+
+// ChainHealth is the health of a chain, ready when each of its hops has a
+// node passing.
+type ChainHealth struct {
+	Name string `json:"name"`
+	// Required reports whether the server is ready only once the chain is.
+	Required bool        `json:"required"`
+	Ready    bool        `json:"ready"`
+	Error    string      `json:"error,omitempty"`
+	Hops     []HopHealth `json:"hops,omitempty"`
+}
+
+// HopHealth is the health of a hop of a chain. The nodes passing are the
+// ones not failed and, if probed, whose last probe succeeded.
+type HopHealth struct {
+	Name    string `json:"name"`
+	Nodes   int    `json:"nodes"`
+	Passing int    `json:"passing"`
+}
+
+// Health returns the health of the services and of the chains, by name.
+func (s *Server) Health() *Health {
+	cfg := config.Global()
+	h := &Health{
+		Ready:    true,
+		Services: []ServiceHealth{},
+		Chains:   []ChainHealth{},
+	}
+
+	for name, svc := range registry.ServiceRegistry().GetAll() {
+		st := ServiceHealth{Name: name, State: "unknown", Ready: true}
+		if ss, ok := svc.(interface{ Status() *xservice.Status }); ok && ss.Status() != nil {
+			state := ss.Status().State()
+			st.State, st.Ready = string(state), state == xservice.StateReady
+		}
+		h.Ready = h.Ready && st.Ready
+		h.Services = append(h.Services, st)
+	}
+
+	hops := registry.HopRegistry().GetAll()
+	chains := registry.ChainRegistry().GetAll()
+	for name, c := range chains {
+		st := chainHealth(cfg, hops, name, c)
+		st.Required = slices.Contains(s.options.ReadyChains, name)
+		h.Chains = append(h.Chains, st)
+	}
+	for _, name := range s.options.ReadyChains {
+		if _, ok := chains[name]; !ok {
+			h.Chains = append(h.Chains, ChainHealth{Name: name, Required: true, Error: "not found"})
+		}
+	}
+	for _, st := range h.Chains {
+		if st.Required {
+			h.Ready = h.Ready && st.Ready
+		}
+	}
+
+	slices.SortFunc(h.Services, func(a, b ServiceHealth) int { return strings.Compare(a.Name, b.Name) })
+	slices.SortFunc(h.Chains, func(a, b ChainHealth) int { return strings.Compare(a.Name, b.Name) })
+	return h
+}
+
+// chainHealth returns the health of chain c, with hops the registered
+// hops. The hops are only known for the chains of the config, the other
+// chains are reported ready, as are the hops whose nodes are not known,
+// e.g. of plugins.
+func chainHealth(cfg *config.Config, hops map[string]hop.Hop, name string, c chain.Chainer) ChainHealth {
+	st := ChainHealth{Name: name, Ready: true}
+	hc, ok := c.(*hopChain)
+	if !ok {
+		return st
+	}
+	for _, ch := range hc.hops {
+		nl, ok := hops[ch.registered].(hop.NodeList)
+		if !ok {
+			continue
+		}
+		hs := HopHealth{Name: ch.name}
+		for _, node := range nl.Nodes() {
+			if node == nil {
+				continue
+			}
+			hs.Nodes++
+			if nodePassing(cfg, node, nodeSource{chain: name, hop: ch.name}) {
+				hs.Passing++
+			}
+		}
+		st.Ready = st.Ready && hs.Passing > 0
+		st.Hops = append(st.Hops, hs)
+	}
+	return st
+}
+
+// nodePassing reports whether node is not failed and, if it is probed,
+// its last probe succeeded. The nodes whose probe is invalid are not
+// probed.
+func nodePassing(cfg *config.Config, node *chain.Node, src nodeSource) bool {
+	if reason, _ := nodeFailure(cfg, node, src); reason != "" {
+		return false
+	}
+	if node.ProbeResult() != nil {
+		return true
+	}
+	nc := nodeConfig(cfg, src, node.Name)
+	return nc == nil || node_parser.ParseProbeConfig(nc.Probe) == nil
+}
+
+// nodeConfig returns the config of the node name of the hop of a chain, or
+// nil, e.g. for the nodes loaded by the hop.
+func nodeConfig(cfg *config.Config, src nodeSource, name string) *config.NodeConfig {
+	if cfg == nil {
+		return nil
+	}
+
+	var hops []*config.HopConfig
+	for _, c := range cfg.Chains {
+		if c != nil && c.Name == src.chain {
+			hops = append(hops, c.Hops...)
+		}
+	}
+	hops = append(hops, cfg.Hops...)
+	for _, h := range hops {
+		if h == nil || h.Name != src.hop || h.Nodes == nil {
+			continue
+		}
+		for _, n := range h.Nodes {
+			if n != nil && n.Name == name {
+				return n
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// hopChain is a chain of the config, its inline hops registered as the
+// other ones once it is, so that its hops are looked up by name, e.g. for
+// its health.
+type hopChain struct {
+	*xchain.Chain
+	hops []chainHop
+	// registered is set once the inline hops are registered.
+	registered bool
+}
+
+type chainHop struct {
+	name string
+	// registered is the name the hop is registered with, its name unless
+	// it is an inline hop.
+	registered string
+	// inline is the inline hop, parsed with the chain.
+	inline hop.Hop
+}
+
+// register registers the inline hops of the chain, which refers to them
+// by their registered names.
+func (c *hopChain) register() error {
+	for i, h := range c.hops {
+		if h.inline == nil {
+			continue
+		}
+		if err := registry.HopRegistry().Register(h.registered, h.inline); err != nil {
+			for _, h := range c.hops[:i] {
+				if h.inline != nil {
+					registry.HopRegistry().Unregister(h.registered)
+				}
+			}
+			return err
+		}
+	}
+	c.registered = true
+	return nil
+}
+
+// Close closes the chain and unregisters its inline hops, or closes them if
+// they are not registered.
+func (c *hopChain) Close() error {
+	for _, h := range c.hops {
+		if h.inline == nil {
+			continue
+		}
+		if c.registered {
+			registry.HopRegistry().Unregister(h.registered)
+		} else if closer, ok := h.inline.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	if c.Chain != nil {
+		return c.Chain.Close()
+	}
+	return nil
+}
+
+// inlineHopSeq numbers the inline hops.
+var inlineHopSeq atomic.Uint64
+
+// parseChain parses a chain by chain_parser.ParseChain, its inline hops
+// parsed first and named uniquely, as @chain/hop/seq, the chain referring
+// to them by these names. They are registered along with the chain.
+func parseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	log := logger.Default()
+
+	c := &hopChain{}
+	cc := *cfg
+	cc.Hops = nil
+	for _, hc := range cfg.Hops {
+		if hc == nil {
+			continue
+		}
+
+		h := chainHop{name: hc.Name, registered: hc.Name}
+		if hc.Nodes != nil || hc.Plugin != nil {
+			v, err := hop_parser.ParseHop(hc, log)
+			if err != nil {
+				c.Close()
+				return nil, err
+			}
+			if v == nil {
+				continue
+			}
+			h.registered = fmt.Sprintf("@%s/%s/%d", cfg.Name, hc.Name, inlineHopSeq.Add(1))
+			h.inline = v
+		}
+		c.hops = append(c.hops, h)
+		cc.Hops = append(cc.Hops, &config.HopConfig{Name: h.registered})
+	}
+
+	ch, err := chain_parser.ParseChain(&cc, log)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	xc, ok := ch.(*xchain.Chain)
+	if !ok {
+		c.Close()
+		return nil, fmt.Errorf("unexpected chain type %T", ch)
+	}
+	c.Chain = xc
+	return c, nil
+}
+
+// healthHandler serves the health of srv. With ready, the status is 503
+// Service Unavailable until the server is ready.
+//
+// The health is served without authentication, for the load balancers and
+// orchestrators, and only names the services and chains.
+func healthHandler(srv *Server, ready bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := srv.Health()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if ready && !h.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/logger"
+	reg "github.com/go-gost/core/registry"
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/config/parsing"
+	auth_parser "github.com/go-gost/x/config/parsing/auth"
+	bypass_parser "github.com/go-gost/x/config/parsing/bypass"
+	cache_parser "github.com/go-gost/x/config/parsing/cache"
+	hop_parser "github.com/go-gost/x/config/parsing/hop"
+	hosts_parser "github.com/go-gost/x/config/parsing/hosts"
+	ingress_parser "github.com/go-gost/x/config/parsing/ingress"
+	limiter_parser "github.com/go-gost/x/config/parsing/limiter"
+	logger_parser "github.com/go-gost/x/config/parsing/logger"
+	observer_parser "github.com/go-gost/x/config/parsing/observer"
+	quota_parser "github.com/go-gost/x/config/parsing/quota"
+	recorder_parser "github.com/go-gost/x/config/parsing/recorder"
+	resolver_parser "github.com/go-gost/x/config/parsing/resolver"
+	rewriter_parser "github.com/go-gost/x/config/parsing/rewriter"
+	router_parser "github.com/go-gost/x/config/parsing/router"
+	sd_parser "github.com/go-gost/x/config/parsing/sd"
+	service_parser "github.com/go-gost/x/config/parsing/service"
+	"github.com/go-gost/x/registry"
+)
+
+// resourceKind describes how the named resources of one config section are
+// built and registered.
+type resourceKind struct {
+	// name is the config section (and API path) of the resources, e.g. "chains".
+	name string
+	// list returns the resource configs of the section.
+	list func(cfg *config.Config) []any
+	// configs returns the named resource configs of the section by name.
+	configs func(cfg *config.Config) map[string]any
+	// parse builds a resource from its config without registering it.
+	parse func(c any) (any, error)
+	// register registers a parsed resource, replacing (and closing) the
+	// previously registered resource of the same name.
+	register func(name string, v any) error
+	// unload unregisters (and closes) a resource.
+	unload func(name string)
+	// get returns the registered resource, or nil.
+	get func(name string) any
+}
+
+func newResourceKind[C, V any](name string, list func(*config.Config) []C, r reg.Registry[V], parse func(C) (V, error)) *resourceKind {
+	return &resourceKind{
+		name: name,
+		list: func(cfg *config.Config) []any {
+			var cs []any
+			for _, c := range list(cfg) {
+				cs = append(cs, c)
+			}
+			return cs
+		},
+		configs: func(cfg *config.Config) map[string]any {
+			m := make(map[string]any)
+			if cfg == nil {
+				return m
+			}
+			for _, c := range list(cfg) {
+				if name := ConfigName(c); name != "" {
+					m[name] = c
+				}
+			}
+			return m
+		},
+		parse: func(c any) (any, error) {
+			return parse(c.(C))
+		},
+		register: func(name string, v any) error {
+			r.Unregister(name)
+			return r.Register(name, v.(V))
+		},
+		unload: r.Unregister,
+		get: func(name string) any {
+			if !r.IsRegistered(name) {
+				return nil
+			}
+			return r.Get(name)
+		},
+	}
+}
+
+// chainKind returns the kind of the chains, whose inline hops are
+// registered along with them.
+func chainKind() *resourceKind {
+	kind := newResourceKind("chains",
+		func(c *config.Config) []*config.ChainConfig { return c.Chains },
+		registry.ChainRegistry(), parseChain)
+	register := kind.register
+	kind.register = func(name string, v any) error {
+		c, ok := v.(*hopChain)
+		if !ok {
+			return register(name, v)
+		}
+		if err := c.register(); err != nil {
+			return err
+		}
+		if err := register(name, v); err != nil {
+			c.Close()
+			return err
+		}
+		return nil
+	}
+	return kind
+}
+
+func infallible[C, V any](parse func(C) V) func(C) (V, error) {
+	return func(c C) (V, error) {
+		return parse(c), nil
+	}
+}
+
+// Kinds returns the config sections of the named resources, e.g. chains,
+// in load order.
+func Kinds() []string {
+	var kinds []string
+	for _, kind := range allKinds() {
+		kinds = append(kinds, kind.name)
+	}
+	return kinds
+}
+
+// Configs returns the configs of the resources of the given kind defined in
+// cfg, in order, named or not.
+func Configs(cfg *config.Config, kind string) []any {
+	k := resourceKindOf(kind)
+	if k == nil || cfg == nil {
+		return nil
+	}
+	return k.list(cfg)
+}
+
+// ConfigName returns the Name field of a resource config.
+func ConfigName(c any) string {
+	v := reflect.ValueOf(c)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if f := v.FieldByName("Name"); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+var (
+	serviceKind = newResourceKind("services",
+		func(c *config.Config) []*config.ServiceConfig { return c.Services },
+		registry.ServiceRegistry(), service_parser.ParseService)
+
+	// resourceKinds are all the named resources except services, in the
+	// order they are registered: leaf components first, then hops and chains,
+	// so that a component's dependencies are available when it is parsed.
+	resourceKinds = []*resourceKind{
+		newResourceKind("loggers",
+			func(c *config.Config) []*config.LoggerConfig { return c.Loggers },
+			registry.LoggerRegistry(), infallible(logger_parser.ParseLogger)),
+		newResourceKind("authers",
+			func(c *config.Config) []*config.AutherConfig { return c.Authers },
+			registry.AutherRegistry(), infallible(auth_parser.ParseAuther)),
+		newResourceKind("admissions",
+			func(c *config.Config) []*config.AdmissionConfig { return c.Admissions },
+			registry.AdmissionRegistry(), infallible(parseAdmission)),
+		newResourceKind("bypasses",
+			func(c *config.Config) []*config.BypassConfig { return c.Bypasses },
+			registry.BypassRegistry(), infallible(bypass_parser.ParseBypass)),
+		newResourceKind("resolvers",
+			func(c *config.Config) []*config.ResolverConfig { return c.Resolvers },
+			registry.ResolverRegistry(), resolver_parser.ParseResolver),
+		newResourceKind("hosts",
+			func(c *config.Config) []*config.HostsConfig { return c.Hosts },
+			registry.HostsRegistry(), infallible(hosts_parser.ParseHostMapper)),
+		newResourceKind("ingresses",
+			func(c *config.Config) []*config.IngressConfig { return c.Ingresses },
+			registry.IngressRegistry(), infallible(ingress_parser.ParseIngress)),
+		newResourceKind("routers",
+			func(c *config.Config) []*config.RouterConfig { return c.Routers },
+			registry.RouterRegistry(), infallible(router_parser.ParseRouter)),
+		newResourceKind("sds",
+			func(c *config.Config) []*config.SDConfig { return c.SDs },
+			registry.SDRegistry(), infallible(sd_parser.ParseSD)),
+		newResourceKind("observers",
+			func(c *config.Config) []*config.ObserverConfig { return c.Observers },
+			registry.ObserverRegistry(), infallible(observer_parser.ParseObserver)),
+		newResourceKind("recorders",
+			func(c *config.Config) []*config.RecorderConfig { return c.Recorders },
+			registry.RecorderRegistry(), infallible(recorder_parser.ParseRecorder)),
+		newResourceKind("rewriters",
+			func(c *config.Config) []*config.RewriterConfig { return c.Rewriters },
+			registry.RewriterRegistry(), infallible(rewriter_parser.ParseRewriter)),
+		newResourceKind("caches",
+			func(c *config.Config) []*config.CacheConfig { return c.Caches },
+			registry.CacheRegistry(), infallible(cache_parser.ParseCache)),
+		newResourceKind("limiters",
+			func(c *config.Config) []*config.LimiterConfig { return c.Limiters },
+			registry.TrafficLimiterRegistry(), infallible(limiter_parser.ParseTrafficLimiter)),
+		newResourceKind("quotas",
+			func(c *config.Config) []*config.QuotaConfig { return c.Quotas },
+			registry.QuotaLimiterRegistry(), infallible(quota_parser.ParseQuotaLimiter)),
+		newResourceKind("climiters",
+			func(c *config.Config) []*config.LimiterConfig { return c.CLimiters },
+			registry.ConnLimiterRegistry(), infallible(parseConnLimiter)),
+		newResourceKind("rlimiters",
+			func(c *config.Config) []*config.LimiterConfig { return c.RLimiters },
+			registry.RateLimiterRegistry(), infallible(parseRateLimiter)),
+		newResourceKind("hops",
+			func(c *config.Config) []*config.HopConfig { return c.Hops },
+			registry.HopRegistry(), func(c *config.HopConfig) (hop.Hop, error) {
+				return hop_parser.ParseHop(c, logger.Default())
+			}),
+		chainKind(),
+	}
+)
+
+// rollbackError is returned when a config could not be applied and the
+// previous one was restored.
+type rollbackError struct {
+	err error
+}
+
+func (e *rollbackError) Error() string {
+	return fmt.Sprintf("%v (rolled back)", e.err)
+}
+
+func (e *rollbackError) Unwrap() error {
+	return e.err
+}
+
+// load brings the registries from the state described by old to the one
+// described by cfg, rebuilding only the resources that differ between the
+// two, the touched ones and the resources depending on them. A nil old loads
+// cfg from scratch.
+// The returned diff lists the affected resources, the added and changed
+// services are started.
+//
+// Loading is all-or-nothing: every resource except the services is built
+// before anything is registered, so an invalid config leaves the registries
+// untouched. Services can only be built once the ones they replace have
+// released their addresses, if one of them fails the previous state is
+// restored and a *rollbackError is returned.
+func load(old, cfg *config.Config, touched ...Resource) (*Diff, error) {
+	d := diffConfig(old, cfg, touched...)
+
+	tx, err := stage(old, cfg, d)
+	if err != nil {
+		return d, err
+	}
+	if err = tx.commit(); err == nil || old == nil {
+		return d, err
+	}
+
+	rtx, rerr := stage(cfg, old, diffConfig(cfg, old))
+	if rerr == nil {
+		rerr = rtx.commit()
+	}
+	if rerr != nil {
+		return d, fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+	}
+	return d, &rollbackError{err: err}
+}
+
+// stagedResource is a resource built but not registered yet.
+type stagedResource struct {
+	kind *resourceKind
+	name string
+	v    any
+}
+
+// transaction holds everything built from a config before it is applied.
+type transaction struct {
+	cfg       *config.Config
+	diff      *Diff
+	logger    logger.Logger
+	tls       *tls.Config
+	setTLS    bool
+	resources []stagedResource
+}
+
+// stage builds the resources added or changed by d without applying them.
+func stage(old, cfg *config.Config, d *Diff) (*transaction, error) {
+	tx := &transaction{
+		cfg:  cfg,
+		diff: d,
+	}
+
+	if old == nil || !equalConfig(old.Log, cfg.Log) {
+		logCfg := cfg.Log
+		if logCfg == nil {
+			logCfg = &config.LogConfig{}
+		}
+		tx.logger = logger_parser.ParseLogger(&config.LoggerConfig{Log: logCfg})
+	}
+
+	if old == nil || d.Has(DefaultTLS) {
+		tlsCfg, err := parsing.BuildDefaultTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		tx.tls = tlsCfg
+		tx.setTLS = true
+	}
+
+	for _, kind := range resourceKinds {
+		configs := kind.configs(cfg)
+		for _, name := range d.Names(kind.name, Added, Changed) {
+			v, err := kind.parse(configs[name])
+			if err != nil {
+				tx.abort()
+				return nil, fmt.Errorf("%s: %w", Resource{Kind: kind.name, Name: name}, err)
+			}
+			tx.resources = append(tx.resources, stagedResource{kind: kind, name: name, v: v})
+		}
+	}
+
+	return tx, nil
+}
+
+// abort releases the staged resources.
+func (tx *transaction) abort() {
+	for _, r := range tx.resources {
+		if closer, ok := r.v.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// commit registers the staged resources, then builds and starts the
+// services.
+func (tx *transaction) commit() error {
+	d := tx.diff
+
+	if tx.logger != nil {
+		logger.SetDefault(tx.logger)
+	}
+	if tx.setTLS {
+		parsing.SetDefaultTLSConfig(tx.tls)
+	}
+
+	// Services are the only resources binding a port, the replaced ones are
+	// closed first so that their addresses can be reused by the new ones.
+	for _, name := range d.Names(serviceKind.name, Removed, Changed) {
+		if serviceKind.get(name) != nil {
+			serviceKind.unload(name)
+			events.publish(Event{Type: EventServiceStopped, Service: name})
+		}
+	}
+
+	for _, kind := range resourceKinds {
+		for _, name := range d.Names(kind.name, Removed) {
+			kind.unload(name)
+		}
+	}
+	for i, r := range tx.resources {
+		if err := r.kind.register(r.name, r.v); err != nil {
+			(&transaction{resources: tx.resources[i+1:]}).abort()
+			return fmt.Errorf("%s: %w", Resource{Kind: r.kind.name, Name: r.name}, err)
+		}
+	}
+
+	configs := serviceKind.configs(tx.cfg)
+	for _, name := range d.Names(serviceKind.name, Added, Changed) {
+		v, err := serviceKind.parse(configs[name])
+		if err != nil {
+			// The handler may be built before the service fails.
+			tracker.discard(name)
+			return fmt.Errorf("%s: %w", Resource{Kind: serviceKind.name, Name: name}, err)
+		}
+		if err := serviceKind.register(name, v); err != nil {
+			v.(service.Service).Close()
+			return fmt.Errorf("%s: %w", Resource{Kind: serviceKind.name, Name: name}, err)
+		}
+
+		svc := v.(service.Service)
+		tracker.serve(name, svc)
+		events.publish(Event{Type: EventServiceStarted, Service: name, Addr: svc.Addr().String()})
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/x/config"
+	auth_parser "github.com/go-gost/x/config/parsing/auth"
+	xmetrics "github.com/go-gost/x/metrics"
+	"github.com/go-gost/x/registry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const defaultMetricsPath = "/metrics"
+
+// metricsService serves the metrics as the metrics service of x does, over
+// TLS if configured, and the health of the server.
+type metricsService struct {
+	s  *http.Server
+	ln net.Listener
+}
+
+func buildMetricsService(cfg *config.MetricsConfig, tlsCfg *TLS, srv *Server) (service.Service, error) {
+	auther := auth_parser.ParseAutherFromAuth(cfg.Auth)
+	if cfg.Auther != "" {
+		auther = registry.AutherRegistry().Get(cfg.Auther)
+	}
+
+	var tlsConfig *tls.Config
+	if tlsCfg != nil {
+		var err error
+		if tlsConfig, err = newTLSConfig(tlsCfg, "@metrics"); err != nil {
+			return nil, fmt.Errorf("metrics tls: %w", err)
+		}
+	}
+
+	network := "tcp"
+	addr := cfg.Addr
+	if strings.HasPrefix(addr, "unix://") {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, "unix://")
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = defaultMetricsPath
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", healthHandler(srv, false))
+	mux.Handle("/readyz", healthHandler(srv, true))
+	mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if _, ok := authenticate(r, auther, "@metrics"); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		reg := xmetrics.Registry()
+		if reg == nil {
+			reg = prometheus.DefaultRegisterer.(*prometheus.Registry)
+		}
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}))
+
+	return &metricsService{
+		s: &http.Server{
+			Handler:   mux,
+			TLSConfig: tlsConfig,
+		},
+		ln: ln,
+	}, nil
+}
+
+func (s *metricsService) Serve() error {
+	if s.s.TLSConfig != nil {
+		return s.s.ServeTLS(s.ln, "", "")
+	}
+	return s.s.Serve(s.ln)
+}
+
+func (s *metricsService) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close closes the listener too, which the server does not track until
+// it serves, for the address to be free once it returns.
+func (s *metricsService) Close() error {
+	err := s.s.Close()
+	s.ln.Close()
+	return err
+}
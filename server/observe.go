@@ -0,0 +1,606 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/admission"
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/hop"
+	conn_limiter "github.com/go-gost/core/limiter/conn"
+	rate_limiter "github.com/go-gost/core/limiter/rate"
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/sd"
+	"github.com/go-gost/x/config"
+	admission_parser "github.com/go-gost/x/config/parsing/admission"
+	limiter_parser "github.com/go-gost/x/config/parsing/limiter"
+	xctx "github.com/go-gost/x/ctx"
+	mdutil "github.com/go-gost/x/metadata/util"
+	"github.com/go-gost/x/registry"
+	xs "github.com/go-gost/x/selector"
+)
+
+// clientOf returns the address of the client of the connection ctx belongs
+// to.
+func clientOf(ctx context.Context) string {
+	if sess := sessions.fromContext(ctx); sess != nil {
+		return sess.client
+	}
+	if addr := xctx.SrcAddrFromContext(ctx); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
+
+// eventAuther publishes the authentication failures of a handler.
+type eventAuther struct {
+	auth.Authenticator
+	service string
+}
+
+func (a *eventAuther) Authenticate(ctx context.Context, user, password string, opts ...auth.Option) (string, bool) {
+	id, ok := a.Authenticator.Authenticate(ctx, user, password, opts...)
+	if !ok {
+		client := clientOf(ctx)
+		events.publishThrottled(EventAuthFailed+"/"+a.service+"/"+hostOf(client)+"/"+user, Event{
+			Type:    EventAuthFailed,
+			Service: a.service,
+			Client:  client,
+			User:    user,
+		})
+	}
+	return id, ok
+}
+
+func parseAdmission(cfg *config.AdmissionConfig) admission.Admission {
+	adm := admission_parser.ParseAdmission(cfg)
+	if adm == nil {
+		return nil
+	}
+	return &eventAdmission{Admission: adm, name: cfg.Name}
+}
+
+// eventAdmission publishes the connections denied by an admission.
+type eventAdmission struct {
+	admission.Admission
+	name string
+}
+
+func (a *eventAdmission) Admit(ctx context.Context, network, addr string, opts ...admission.Option) bool {
+	if a.Admission.Admit(ctx, network, addr, opts...) {
+		return true
+	}
+
+	var options admission.Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	resource := Resource{Kind: "admissions", Name: a.name}.String()
+	events.publishThrottled(resource+"/"+hostOf(addr), Event{
+		Type:     EventAdmissionDenied,
+		Service:  options.Service,
+		Resource: resource,
+		Client:   addr,
+		Network:  network,
+	})
+	return false
+}
+
+func (a *eventAdmission) Close() error {
+	if closer, ok := a.Admission.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func parseConnLimiter(cfg *config.LimiterConfig) conn_limiter.ConnLimiter {
+	lim := limiter_parser.ParseConnLimiter(cfg)
+	if lim == nil {
+		return nil
+	}
+	return &eventConnLimiter{ConnLimiter: lim, resource: Resource{Kind: "climiters", Name: cfg.Name}.String()}
+}
+
+// eventConnLimiter publishes the connections rejected by a connection
+// limiter.
+type eventConnLimiter struct {
+	conn_limiter.ConnLimiter
+	resource string
+}
+
+func (l *eventConnLimiter) Limiter(key string) conn_limiter.Limiter {
+	lim := l.ConnLimiter.Limiter(key)
+	if lim == nil {
+		return nil
+	}
+	return &eventConnLimiterKey{Limiter: lim, resource: l.resource, key: key}
+}
+
+func (l *eventConnLimiter) Close() error {
+	if closer, ok := l.ConnLimiter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type eventConnLimiterKey struct {
+	conn_limiter.Limiter
+	resource string
+	key      string
+}
+
+func (l *eventConnLimiterKey) Allow(n int) bool {
+	ok := l.Limiter.Allow(n)
+	if !ok && n > 0 {
+		publishLimiterRejected(l.resource, l.key)
+	}
+	return ok
+}
+
+func parseRateLimiter(cfg *config.LimiterConfig) rate_limiter.RateLimiter {
+	lim := limiter_parser.ParseRateLimiter(cfg)
+	if lim == nil {
+		return nil
+	}
+	return &eventRateLimiter{RateLimiter: lim, resource: Resource{Kind: "rlimiters", Name: cfg.Name}.String()}
+}
+
+// eventRateLimiter publishes the requests rejected by a rate limiter.
+type eventRateLimiter struct {
+	rate_limiter.RateLimiter
+	resource string
+}
+
+func (l *eventRateLimiter) Limiter(key string) rate_limiter.Limiter {
+	lim := l.RateLimiter.Limiter(key)
+	if lim == nil {
+		return nil
+	}
+	return &eventRateLimiterKey{Limiter: lim, resource: l.resource, key: key}
+}
+
+func (l *eventRateLimiter) Close() error {
+	if closer, ok := l.RateLimiter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type eventRateLimiterKey struct {
+	rate_limiter.Limiter
+	resource string
+	key      string
+}
+
+func (l *eventRateLimiterKey) Allow(n int) bool {
+	ok := l.Limiter.Allow(n)
+	if !ok && n > 0 {
+		publishLimiterRejected(l.resource, l.key)
+	}
+	return ok
+}
+
+func publishLimiterRejected(resource, key string) {
+	events.publishThrottled(resource+"/"+key, Event{
+		Type:     EventLimiterRejected,
+		Resource: resource,
+		Key:      key,
+	})
+}
+
+// tunnelSDPrefix prefixes the names the eventSDs are registered under.
+const tunnelSDPrefix = "@events/"
+
+// tunnelSDSeq numbers the eventSDs, one per tunnel handler.
+var tunnelSDSeq atomic.Uint64
+
+// tunnelMetadata returns md with the service discovery of the tunnel
+// handler of service replaced by an eventSD, which publishes the connectors
+// binding and leaving before passing them to the one configured, if any.
+// The handler finds the eventSD in the SD registry, under the name
+// returned, to be unregistered once it is closed.
+func tunnelMetadata(service string, md metadata.Metadata) (metadata.Metadata, string) {
+	r := registry.SDRegistry()
+
+	var next sd.SD
+	if name := mdutil.GetString(md, "sd"); name != "" {
+		next = r.Get(name)
+	}
+
+	name := tunnelSDPrefix + service + "/" + strconv.FormatUint(tunnelSDSeq.Add(1), 10)
+	r.Register(name, &eventSD{
+		next:       next,
+		service:    service,
+		connectors: make(map[string]Event),
+	})
+	return &sdMetadata{Metadata: md, sd: name}, name
+}
+
+// sdMetadata overrides the sd entry of the metadata.
+type sdMetadata struct {
+	metadata.Metadata
+	sd string
+}
+
+func (md *sdMetadata) IsExists(key string) bool {
+	if strings.EqualFold(key, "sd") {
+		return true
+	}
+	return md.Metadata != nil && md.Metadata.IsExists(key)
+}
+
+func (md *sdMetadata) Get(key string) any {
+	if strings.EqualFold(key, "sd") {
+		return md.sd
+	}
+	if md.Metadata == nil {
+		return nil
+	}
+	return md.Metadata.Get(key)
+}
+
+func (md *sdMetadata) Set(key string, value any) {
+	if md.Metadata != nil {
+		md.Metadata.Set(key, value)
+	}
+}
+
+// eventSD is the service discovery of a tunnel handler, the connectors are
+// registered when they bind to a tunnel and deregistered when they leave.
+type eventSD struct {
+	next    sd.SD
+	service string
+
+	mu         sync.Mutex
+	connectors map[string]Event
+}
+
+func (s *eventSD) Register(ctx context.Context, service *sd.Service, opts ...sd.Option) error {
+	e := Event{
+		Type:      EventTunnelConnected,
+		Time:      time.Now(),
+		Service:   s.service,
+		Node:      service.Node,
+		Client:    clientOf(ctx),
+		Tunnel:    service.Name,
+		Connector: service.ID,
+		Network:   service.Network,
+	}
+
+	s.mu.Lock()
+	s.connectors[service.ID] = e
+	s.mu.Unlock()
+
+	events.publish(e)
+
+	if s.next == nil {
+		return nil
+	}
+	return s.next.Register(ctx, service, opts...)
+}
+
+func (s *eventSD) Deregister(ctx context.Context, service *sd.Service) error {
+	s.mu.Lock()
+	e, ok := s.connectors[service.ID]
+	delete(s.connectors, service.ID)
+	s.mu.Unlock()
+
+	if ok {
+		e.Type, e.Time = EventTunnelDisconnected, time.Time{}
+		events.publish(e)
+	}
+
+	if s.next == nil {
+		return nil
+	}
+	return s.next.Deregister(ctx, service)
+}
+
+// tunnels returns the connectors bound to the tunnels of the service.
+func (s *eventSD) tunnels() []TunnelConnector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []TunnelConnector
+	for _, e := range s.connectors {
+		list = append(list, TunnelConnector{
+			Service:   e.Service,
+			Tunnel:    e.Tunnel,
+			Connector: e.Connector,
+			Node:      e.Node,
+			Client:    e.Client,
+			Network:   e.Network,
+			Since:     e.Time,
+		})
+	}
+	return list
+}
+
+func (s *eventSD) Renew(ctx context.Context, service *sd.Service) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.Renew(ctx, service)
+}
+
+func (s *eventSD) Get(ctx context.Context, name string) ([]*sd.Service, error) {
+	if s.next == nil {
+		return nil, nil
+	}
+	return s.next.Get(ctx, name)
+}
+
+// nodeWatchPeriod is how often the state of the nodes is checked.
+const nodeWatchPeriod = time.Second
+
+// nodes watches the nodes of the registered hops, of the hops of the
+// forwarders and of the routes of the chains for failures.
+var nodes = &nodeWatcher{
+	forwarders: make(map[hop.Hop]nodeSource),
+	routed:     make(map[*chain.Node]nodeSource),
+	failed:     make(map[*chain.Node]string),
+}
+
+// nodeSource is where a node is selected from.
+type nodeSource struct {
+	service string
+	chain   string
+	hop     string
+}
+
+type nodeWatcher struct {
+	mu         sync.Mutex
+	forwarders map[hop.Hop]nodeSource
+	routed     map[*chain.Node]nodeSource
+	// failed are the nodes failed, with the reason.
+	failed map[*chain.Node]string
+}
+
+// forward watches the nodes of the forwarder hop of service.
+func (w *nodeWatcher) forward(service string, h hop.Hop) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.forwarders[h] = nodeSource{service: service}
+}
+
+// route watches the nodes of a route of service.
+func (w *nodeWatcher) route(service string, nodes []*chain.Node) {
+	chainName, hops := routeChain(config.Global(), service, nodes)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, node := range nodes {
+		if node == nil {
+			continue
+		}
+		src := nodeSource{service: service, chain: chainName}
+		if i < len(hops) {
+			src.hop = hops[i]
+		}
+		w.routed[node] = src
+	}
+}
+
+// forget stops watching the nodes found through service, once closed.
+func (w *nodeWatcher) forget(service string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for h, src := range w.forwarders {
+		if src.service == service {
+			delete(w.forwarders, h)
+		}
+	}
+	for node, src := range w.routed {
+		if src.service == service {
+			delete(w.routed, node)
+		}
+	}
+}
+
+// run checks the nodes periodically until ctx is done.
+func (w *nodeWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(nodeWatchPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check(config.Global())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watched returns the nodes watched, by where they are selected from. w.mu
+// is held.
+func (w *nodeWatcher) watched() map[*chain.Node]nodeSource {
+	watched := make(map[*chain.Node]nodeSource)
+	for name, h := range registry.HopRegistry().GetAll() {
+		// The inline hops of the chains are watched once routed.
+		if strings.HasPrefix(name, "@") {
+			continue
+		}
+		if nl, ok := h.(hop.NodeList); ok {
+			for _, node := range nl.Nodes() {
+				watched[node] = nodeSource{hop: name}
+			}
+		}
+	}
+	for h, src := range w.forwarders {
+		if nl, ok := h.(hop.NodeList); ok {
+			for _, node := range nl.Nodes() {
+				watched[node] = src
+			}
+		}
+	}
+	for node, src := range w.routed {
+		watched[node] = src
+	}
+	return watched
+}
+
+// check publishes the nodes failed or recovered since the last check.
+func (w *nodeWatcher) check(cfg *config.Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watched := w.watched()
+	for node, src := range watched {
+		if node == nil {
+			continue
+		}
+		reason, errMsg := nodeFailure(cfg, node, src)
+		_, failed := w.failed[node]
+
+		e := Event{
+			Service: src.service,
+			Chain:   src.chain,
+			Hop:     src.hop,
+			Node:    node.Name,
+			Addr:    node.Addr,
+		}
+		switch {
+		case reason != "" && !failed:
+			w.failed[node] = reason
+			e.Type, e.Reason, e.Error = EventNodeFailed, reason, errMsg
+			events.publish(e)
+		case reason == "" && failed:
+			delete(w.failed, node)
+			e.Type = EventNodeRecovered
+			events.publish(e)
+		}
+	}
+	for node := range w.failed {
+		if _, ok := watched[node]; !ok {
+			delete(w.failed, node)
+		}
+	}
+}
+
+// status returns the state of the nodes watched, by chain, hop and name.
+func (w *nodeWatcher) status(cfg *config.Config) []NodeStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var list []NodeStatus
+	for node, src := range w.watched() {
+		if node == nil {
+			continue
+		}
+		st := NodeStatus{
+			Service: src.service,
+			Chain:   src.chain,
+			Hop:     src.hop,
+			Node:    node.Name,
+			Addr:    node.Addr,
+		}
+		st.Reason, st.Error = nodeFailure(cfg, node, src)
+		st.Failed = st.Reason != ""
+		if marker := node.Marker(); marker != nil {
+			st.Fails = marker.Count()
+		}
+		if r := node.ProbeResult(); r != nil {
+			st.Probe = &NodeProbe{
+				Success: r.Success,
+				Latency: r.Latency,
+				Error:   r.Error,
+				Time:    r.Timestamp,
+			}
+		}
+		list = append(list, st)
+	}
+	slices.SortFunc(list, func(a, b NodeStatus) int {
+		return cmp.Or(
+			strings.Compare(a.Chain, b.Chain),
+			strings.Compare(a.Hop, b.Hop),
+			strings.Compare(a.Service, b.Service),
+			strings.Compare(a.Node, b.Node),
+		)
+	})
+	return list
+}
+
+// nodeFailure returns why node is failed, probe when its last probe failed
+// or fails when it failed as many times as the fail filter of its hop
+// allows, or empty if it is not.
+func nodeFailure(cfg *config.Config, node *chain.Node, src nodeSource) (reason string, errMsg string) {
+	if r := node.ProbeResult(); r != nil && !r.Success {
+		return "probe", r.Error
+	}
+
+	marker := node.Marker()
+	if marker == nil || marker.Count() == 0 {
+		return "", ""
+	}
+
+	// The same settings as the fail filter of the selector of the hop.
+	maxFails, failTimeout := xs.DefaultMaxFails, xs.DefaultFailTimeout
+	if sel := hopSelector(cfg, src); sel != nil {
+		maxFails, failTimeout = sel.MaxFails, sel.FailTimeout
+	}
+	if md := node.Metadata(); md != nil {
+		if md.IsExists("maxFails") {
+			maxFails = mdutil.GetInt(md, "maxFails")
+		}
+		if md.IsExists("failTimeout") {
+			failTimeout = mdutil.GetDuration(md, "failTimeout")
+		}
+	}
+	if maxFails <= 0 {
+		maxFails = 1
+	}
+	if failTimeout <= 0 {
+		failTimeout = xs.DefaultFailTimeout
+	}
+
+	if marker.Count() >= int64(maxFails) && time.Since(marker.Time()) < failTimeout {
+		return "fails", ""
+	}
+	return "", ""
+}
+
+// hopSelector returns the selector config of the hop a node is selected
+// from.
+func hopSelector(cfg *config.Config, src nodeSource) *config.SelectorConfig {
+	if cfg == nil {
+		return nil
+	}
+
+	if src.hop == "" {
+		for _, svc := range cfg.Services {
+			if svc != nil && svc.Name == src.service && svc.Forwarder != nil {
+				return svc.Forwarder.Selector
+			}
+		}
+		return nil
+	}
+
+	for _, c := range cfg.Chains {
+		if c == nil || (src.chain != "" && c.Name != src.chain) {
+			continue
+		}
+		for _, h := range c.Hops {
+			if h != nil && h.Name == src.hop && h.Nodes != nil {
+				return h.Selector
+			}
+		}
+	}
+	for _, h := range cfg.Hops {
+		if h != nil && h.Name == src.hop {
+			return h.Selector
+		}
+	}
+	return nil
+}
@@ -0,0 +1,687 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/x/config"
+)
+
+const configPkgPath = "github.com/go-gost/x/config"
+
+var (
+	durationType   = reflect.TypeFor[time.Duration]()
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// apiKind is a config section served by the config API.
+type apiKind struct {
+	kind string
+	// name is the resource in the operation IDs, e.g. Bypass for
+	// getBypass.
+	name string
+	// param is the path parameter naming the resource.
+	param string
+}
+
+// apiKinds are the config sections served by the config API, in the order
+// of its routes.
+var apiKinds = []apiKind{
+	{"services", "Service", "service"},
+	{"chains", "Chain", "chain"},
+	{"hops", "Hop", "hop"},
+	{"authers", "Auther", "auther"},
+	{"admissions", "Admission", "admission"},
+	{"bypasses", "Bypass", "bypass"},
+	{"resolvers", "Resolver", "resolver"},
+	{"hosts", "Hosts", "hosts"},
+	{"ingresses", "Ingress", "ingress"},
+	{"routers", "Router", "router"},
+	{"observers", "Observer", "observer"},
+	{"recorders", "Recorder", "recorder"},
+	{"sds", "SD", "sd"},
+	{"limiters", "Limiter", "limiter"},
+	{"quotas", "Quota", "quota"},
+	{"climiters", "ConnLimiter", "limiter"},
+	{"rlimiters", "RateLimiter", "limiter"},
+	{"caches", "Cache", "cache"},
+}
+
+// quotaReset is the body of a quota reset.
+type quotaReset struct {
+	// Used is the new value of the counter, 0 if not set.
+	Used *uint64 `json:"used,omitempty"`
+}
+
+// operation is an operation of the API in the OpenAPI document.
+type operation struct {
+	id      string
+	summary string
+	tag     string
+	params  []any
+	// body is the type of the JSON request body, if any.
+	body reflect.Type
+	// data is the type of the data of the response, if any, or list the
+	// name of its schema.
+	data reflect.Type
+	list string
+	// raw responses are data itself, not wrapped in a Response. stream
+	// responses are server-sent events of data.
+	raw    bool
+	stream bool
+	// etag reports whether the response carries the ETag of the resource.
+	etag bool
+	// unavailable reports whether the response is also sent with the
+	// status 503 Service Unavailable.
+	unavailable bool
+}
+
+// OpenAPI returns the OpenAPI document of the API served under pathPrefix:
+// the config API with the resources of every config section, and the
+// runtime endpoints of the server. The endpoints of the revisions, of the
+// tokens and of the audit log are only served when the server persists
+// its config and has an access control with tokens and an audit log.
+//
+// The config types are described by their JSON form, with the Go type they
+// are decoded into as x-go-type.
+func OpenAPI(pathPrefix string) map[string]any {
+	b := &openAPIBuilder{
+		schemas: make(map[string]any),
+		paths:   make(map[string]any),
+	}
+	b.schemas["Response"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code": map[string]any{"type": "integer"},
+			"msg":  map[string]any{"type": "string"},
+			"data": map[string]any{},
+		},
+	}
+	b.schemas["Error"] = map[string]any{
+		"type":     "object",
+		"required": []string{"code", "msg"},
+		"properties": map[string]any{
+			"code": map[string]any{"type": "integer"},
+			"msg":  map[string]any{"type": "string"},
+		},
+	}
+
+	b.configPaths()
+	b.runtimePaths()
+
+	server := pathPrefix
+	if server == "" {
+		server = "/"
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "GOST API",
+			"description": "The config API of GOST and the runtime endpoints of the server.",
+			"version":     "1.0.0",
+		},
+		"servers": []any{map[string]any{"url": server}},
+		"security": []any{
+			map[string]any{"basicAuth": []string{}},
+			map[string]any{"bearerAuth": []string{}},
+		},
+		"paths": b.paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"parameters": map[string]any{
+				"IfMatch":     headerParam("If-Match", "The change is only made if the resource has one of these ETags, * if it exists."),
+				"IfNoneMatch": headerParam("If-None-Match", "A read answers 304 if the resource has one of these ETags, a change is only made if it has none of them, * if it does not exist."),
+			},
+			"headers": map[string]any{
+				"ETag": map[string]any{
+					"description": "The version of the resource.",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+			"securitySchemes": map[string]any{
+				"basicAuth":  map[string]any{"type": "http", "scheme": "basic"},
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "description": "An API token."},
+			},
+		},
+	}
+}
+
+// configPaths adds the operations of the config and of its resources.
+func (b *openAPIBuilder) configPaths() {
+	b.add(http.MethodGet, "/config", &operation{
+		id:      "getConfig",
+		summary: "Get the running config, redacted.",
+		tag:     "Config",
+		params:  []any{queryParam("format", enumSchema("json", "yaml"), "The format of the config, json by default.")},
+		data:    reflect.TypeFor[config.Config](),
+		raw:     true,
+		etag:    true,
+	})
+	b.add(http.MethodPost, "/config", &operation{
+		id:      "saveConfig",
+		summary: "Save the running config, redacted, to a file of the server.",
+		tag:     "Config",
+		params: []any{
+			queryParam("format", enumSchema("yaml", "json"), "The format of the file, yaml by default."),
+			queryParam("path", map[string]any{"type": "string"}, "The file, gost.yaml or gost.json by default."),
+		},
+	})
+	b.add(http.MethodPost, "/config/reload", &operation{
+		id:      "reloadConfig",
+		summary: "Reload the config from its source, as one transaction.",
+		tag:     "Config",
+		data:    reflect.TypeFor[ReloadStatus](),
+	})
+	b.add(http.MethodPost, "/config/plan", &operation{
+		id:      "planConfig",
+		summary: "Plan the reload of the running config with the config of the body.",
+		tag:     "Config",
+		body:    reflect.TypeFor[config.Config](),
+		data:    reflect.TypeFor[Plan](),
+	})
+	b.add(http.MethodPost, "/config/batch", &operation{
+		id:      "batchConfig",
+		summary: "Apply the changes of the operations as one reload, all of them or none.",
+		tag:     "Config",
+		body:    reflect.TypeFor[batchRequest](),
+		data:    reflect.TypeFor[BatchResult](),
+	})
+	b.add(http.MethodGet, "/config/revisions", &operation{
+		id:      "getRevisions",
+		summary: "List the revisions of the config file, the newest first.",
+		tag:     "Revisions",
+		data:    reflect.TypeFor[revisionList](),
+	})
+	b.add(http.MethodGet, "/config/revisions/diff", &operation{
+		id:      "diffRevisions",
+		summary: "Compare two revisions of the config file by resource.",
+		tag:     "Revisions",
+		params: []any{
+			queryParam("from", map[string]any{"type": "integer"}, "The revision compared, the one before to by default."),
+			queryParam("to", map[string]any{"type": "integer"}, "The revision compared to, the last one by default."),
+		},
+		data: reflect.TypeFor[revisionDiff](),
+	})
+	b.add(http.MethodPost, "/config/revisions/{revision}/rollback", &operation{
+		id:      "rollbackRevision",
+		summary: "Restore a revision of the config file, as a new revision.",
+		tag:     "Revisions",
+		params:  []any{pathParam("revision", map[string]any{"type": "integer"})},
+		data:    reflect.TypeFor[Revision](),
+	})
+
+	for _, k := range apiKinds {
+		t := configType(k.kind)
+		name := map[string]any{"type": "string"}
+		list := k.name + "List"
+		b.schemas[list] = map[string]any{
+			"type":     "object",
+			"required": []string{"count", "list"},
+			"properties": map[string]any{
+				"count": map[string]any{"type": "integer", "x-go-name": "Count"},
+				"list":  map[string]any{"type": "array", "items": b.schema(t), "x-go-name": "List"},
+				"versions": map[string]any{
+					"type":                 "object",
+					"description":          "The ETags of the resources, by name.",
+					"additionalProperties": map[string]any{"type": "string"},
+					"x-go-name":            "Versions",
+				},
+			},
+		}
+
+		path := "/config/" + k.kind
+		b.add(http.MethodGet, path, &operation{
+			id:      "get" + list,
+			summary: "List the " + k.kind + ".",
+			tag:     k.name,
+			params:  []any{ref("parameters", "IfNoneMatch")},
+			list:    list,
+			etag:    true,
+		})
+		b.add(http.MethodPost, path, &operation{
+			id:      "create" + k.name,
+			summary: "Create a resource of the " + k.kind + ", its name must be unique.",
+			tag:     k.name,
+			params:  []any{ref("parameters", "IfNoneMatch")},
+			body:    t,
+			etag:    true,
+		})
+
+		path += "/{" + k.param + "}"
+		param := pathParam(k.param, name)
+		b.add(http.MethodGet, path, &operation{
+			id:      "get" + k.name,
+			summary: "Get a resource of the " + k.kind + ".",
+			tag:     k.name,
+			params:  []any{param, ref("parameters", "IfNoneMatch")},
+			data:    t,
+			etag:    true,
+		})
+		b.add(http.MethodPut, path, &operation{
+			id:      "update" + k.name,
+			summary: "Replace a resource of the " + k.kind + ".",
+			tag:     k.name,
+			params:  []any{param, ref("parameters", "IfMatch")},
+			body:    t,
+			etag:    true,
+		})
+		b.add(http.MethodDelete, path, &operation{
+			id:      "delete" + k.name,
+			summary: "Delete a resource of the " + k.kind + ".",
+			tag:     k.name,
+			params:  []any{param, ref("parameters", "IfMatch")},
+		})
+	}
+
+	b.add(http.MethodPost, "/config/quotas/{quota}/reset", &operation{
+		id:      "resetQuota",
+		summary: "Overwrite the counter of a quota, 0 by default.",
+		tag:     "Quota",
+		params:  []any{pathParam("quota", map[string]any{"type": "string"})},
+		body:    reflect.TypeFor[quotaReset](),
+	})
+}
+
+// runtimePaths adds the operations of the runtime endpoints.
+func (b *openAPIBuilder) runtimePaths() {
+	filter := []any{
+		queryParam("service", map[string]any{"type": "string"}, "The service of the connections."),
+		queryParam("user", map[string]any{"type": "string"}, "The user of the connections."),
+		queryParam("client", map[string]any{"type": "string"}, "The client address or IP of the connections."),
+		queryParam("host", map[string]any{"type": "string"}, "The target host of the connections."),
+	}
+	page := []any{
+		queryParam("page", map[string]any{"type": "integer", "minimum": 0}, "The page, starting at 1."),
+		queryParam("size", map[string]any{"type": "integer", "minimum": 0}, "The size of the pages, all in one if 0."),
+	}
+
+	b.add(http.MethodGet, "/reload", &operation{
+		id:      "getReloadStatus",
+		summary: "Get the outcome of the last reload.",
+		tag:     "Runtime",
+		data:    reflect.TypeFor[ReloadStatus](),
+	})
+	b.add(http.MethodGet, "/reloads", &operation{
+		id:      "getReloads",
+		summary: "List the outcomes of the last reloads, the newest first.",
+		tag:     "Runtime",
+		data:    reflect.TypeFor[reloadList](),
+	})
+	b.add(http.MethodGet, "/nodes", &operation{
+		id:      "getNodes",
+		summary: "List the state of the nodes of the hops, of the chains and of the forwarders.",
+		tag:     "Runtime",
+		data:    reflect.TypeFor[nodeList](),
+	})
+	b.add(http.MethodGet, "/tunnels", &operation{
+		id:      "getTunnels",
+		summary: "List the connectors bound to the tunnels of the services.",
+		tag:     "Runtime",
+		data:    reflect.TypeFor[tunnelList](),
+	})
+	b.add(http.MethodGet, "/drain", &operation{
+		id:      "getDrainStatus",
+		summary: "Get the connections of the services being drained.",
+		tag:     "Runtime",
+		data:    reflect.TypeFor[drainStatus](),
+	})
+	b.add(http.MethodGet, "/connections", &operation{
+		id:      "getConnections",
+		summary: "List the connections handled by the services, the oldest first.",
+		tag:     "Runtime",
+		params:  append(filter, page...),
+		data:    reflect.TypeFor[connectionList](),
+	})
+	b.add(http.MethodDelete, "/connections", &operation{
+		id:      "closeConnections",
+		summary: "Close the connections matched, one filter at least is required.",
+		tag:     "Runtime",
+		params:  filter,
+		data:    reflect.TypeFor[connectionList](),
+	})
+	b.add(http.MethodDelete, "/connections/{id}", &operation{
+		id:      "closeConnection",
+		summary: "Close a connection.",
+		tag:     "Runtime",
+		params:  []any{pathParam("id", map[string]any{"type": "string"})},
+	})
+	b.add(http.MethodGet, "/events", &operation{
+		id:      "streamEvents",
+		summary: "Stream the events as server-sent events, the event field is the type and the data the event.",
+		tag:     "Runtime",
+		params: []any{
+			queryParam("type", map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"The list of the event types, or of their prefixes up to a dot."),
+			queryParam("since", map[string]any{"type": "integer"}, "The ID of the last event received, the events retained after it are sent first."),
+			headerParam("Last-Event-ID", "The ID of the last event received, as since, set by the clients reconnecting."),
+		},
+		data:   reflect.TypeFor[Event](),
+		stream: true,
+	})
+	b.add(http.MethodGet, "/tokens", &operation{
+		id:      "getTokens",
+		summary: "List the API tokens.",
+		tag:     "Access",
+		data:    reflect.TypeFor[tokenList](),
+	})
+	b.add(http.MethodPost, "/tokens", &operation{
+		id:      "issueToken",
+		summary: "Issue an API token, its secret is only returned now.",
+		tag:     "Access",
+		body:    reflect.TypeFor[tokenRequest](),
+		data:    reflect.TypeFor[issuedToken](),
+	})
+	b.add(http.MethodDelete, "/tokens/{id}", &operation{
+		id:      "revokeToken",
+		summary: "Revoke an API token.",
+		tag:     "Access",
+		params:  []any{pathParam("id", map[string]any{"type": "string"})},
+	})
+	b.add(http.MethodGet, "/audit", &operation{
+		id:      "getAuditLog",
+		summary: "List the entries of the audit log, the newest first.",
+		tag:     "Access",
+		params:  page,
+		data:    reflect.TypeFor[auditList](),
+	})
+	b.add(http.MethodGet, "/openapi.json", &operation{
+		id:      "getOpenAPI",
+		summary: "Get this document.",
+		tag:     "Runtime",
+		data:    reflect.TypeFor[map[string]any](),
+		raw:     true,
+	})
+	b.add(http.MethodGet, "/healthz", &operation{
+		id:      "getHealth",
+		summary: "Get the health of the services and of the chains, without authentication.",
+		tag:     "Health",
+		data:    reflect.TypeFor[Health](),
+		raw:     true,
+	})
+	b.add(http.MethodGet, "/readyz", &operation{
+		id:          "getReadiness",
+		summary:     "Get the health of the services and of the chains, without authentication, unavailable until the server is ready.",
+		tag:         "Health",
+		data:        reflect.TypeFor[Health](),
+		raw:         true,
+		unavailable: true,
+	})
+}
+
+// openAPIBuilder builds the paths of the OpenAPI document and the schemas
+// of the Go types they use, the struct types as components referenced by
+// name.
+type openAPIBuilder struct {
+	schemas map[string]any
+	paths   map[string]any
+}
+
+func (b *openAPIBuilder) ops(path string) map[string]any {
+	ops, ok := b.paths[path].(map[string]any)
+	if !ok {
+		ops = make(map[string]any)
+		b.paths[path] = ops
+	}
+	return ops
+}
+
+func (b *openAPIBuilder) add(method, path string, op *operation) {
+	o := map[string]any{
+		"operationId": op.id,
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+	}
+	if len(op.params) > 0 {
+		o["parameters"] = op.params
+	}
+	if op.body != nil {
+		o["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": b.schema(op.body)},
+			},
+		}
+	}
+	o["responses"] = b.responses(op)
+	b.ops(path)[strings.ToLower(method)] = o
+}
+
+// responses returns the responses of op.
+func (b *openAPIBuilder) responses(op *operation) map[string]any {
+	var data map[string]any
+	switch {
+	case op.list != "":
+		data = ref("schemas", op.list)
+	case op.data != nil:
+		data = b.schema(op.data)
+	}
+
+	schema := ref("schemas", "Response")
+	if op.raw || op.stream {
+		schema = data
+	} else if data != nil {
+		schema = map[string]any{
+			"allOf": []any{
+				ref("schemas", "Response"),
+				map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"data": data},
+				},
+			},
+		}
+	}
+
+	content := map[string]any{
+		"application/json": map[string]any{"schema": schema},
+	}
+	switch {
+	case op.stream:
+		content = map[string]any{
+			"text/event-stream": map[string]any{"schema": schema},
+		}
+	case op.raw && op.data == reflect.TypeFor[config.Config]():
+		content["application/yaml"] = map[string]any{"schema": schema}
+	}
+	ok := map[string]any{
+		"description": "Successful operation.",
+		"content":     content,
+	}
+	if op.etag {
+		ok["headers"] = map[string]any{"ETag": ref("headers", "ETag")}
+	}
+
+	responses := map[string]any{
+		"200":     ok,
+		"default": b.errorResponse(),
+	}
+	if op.unavailable {
+		responses["503"] = map[string]any{
+			"description": "The server is not ready.",
+			"content":     content,
+		}
+	}
+	if op.etag {
+		responses["304"] = map[string]any{"description": "The resource matches If-None-Match."}
+		responses["412"] = map[string]any{
+			"description": "The resource does not match If-Match, or matches If-None-Match.",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": ref("schemas", "Error")},
+			},
+		}
+	}
+	return responses
+}
+
+func (b *openAPIBuilder) errorResponse() map[string]any {
+	return map[string]any{
+		"description": "The call failed.",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": ref("schemas", "Error")},
+		},
+	}
+}
+
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case durationType:
+		return map[string]any{"type": "integer", "description": "A duration in nanoseconds."}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		// Any value.
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Struct:
+		return b.ref(t)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "uint64", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		// Any value.
+		return map[string]any{}
+	}
+}
+
+// ref returns a reference to the schema of the struct type t, defining it
+// first if needed. The schemas of the config types name their Go type.
+func (b *openAPIBuilder) ref(t reflect.Type) map[string]any {
+	if t.Name() == "" {
+		return map[string]any{"type": "object"}
+	}
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, ok := b.schemas[name]; !ok {
+		// Defined before building it for the recursive types.
+		b.schemas[name] = nil
+		s := b.object(t)
+		if t.PkgPath() == configPkgPath {
+			s["x-go-type"] = "config." + t.Name()
+			s["x-go-type-import"] = map[string]any{"path": configPkgPath}
+			delete(s, "required")
+		}
+		b.schemas[name] = s
+	}
+	return ref("schemas", name)
+}
+
+// object returns the schema of the struct type t, the fields of its
+// embedded structs included. The fields not omitted when empty are
+// required.
+func (b *openAPIBuilder) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	b.fields(t, props, &required)
+
+	s := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (b *openAPIBuilder) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			b.fields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := b.schema(f.Type)
+		s["x-go-name"] = f.Name
+		s["x-order"] = len(props)
+		props[name] = s
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// configType returns the type of the resource configs of the config
+// section kind.
+func configType(kind string) reflect.Type {
+	t := reflect.TypeFor[config.Config]()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name == kind {
+			return f.Type.Elem().Elem()
+		}
+	}
+	panic("unknown config section " + kind)
+}
+
+func ref(section, name string) map[string]any {
+	return map[string]any{"$ref": "#/components/" + section + "/" + name}
+}
+
+func pathParam(name string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"name":     name,
+		"in":       "path",
+		"required": true,
+		"schema":   schema,
+	}
+}
+
+func queryParam(name string, schema map[string]any, description string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+func headerParam(name, description string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "header",
+		"description": description,
+		"schema":      map[string]any{"type": "string"},
+	}
+}
+
+func enumSchema(values ...string) map[string]any {
+	return map[string]any{"type": "string", "enum": values}
+}
+
+// getOpenAPI serves the OpenAPI document of the API.
+func getOpenAPI(pathPrefix string) gin.HandlerFunc {
+	b, _ := json.Marshal(OpenAPI(pathPrefix))
+	return func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", b)
+	}
+}
@@ -0,0 +1,494 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/x/api"
+	"github.com/go-gost/x/config"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultKeepRevisions is the number of revisions kept by default.
+const defaultKeepRevisions = 100
+
+// The sources of the revisions.
+const (
+	// RevisionFile is the config read from the file, on start or when it
+	// was changed by other means than the API.
+	RevisionFile = "file"
+	// RevisionAPI is a change of the config made by the API.
+	RevisionAPI = "api"
+	// RevisionRollback is the config of a previous revision restored.
+	RevisionRollback = "rollback"
+)
+
+var errRevisionNotFound = errors.New("revision not found")
+
+// Persist writes the changes made to the config by the API back to the
+// config file, redacted, and keeps its revisions. The file is rewritten as
+// a whole, its comments are not kept.
+type Persist struct {
+	// File is the config file, also read by the loader of the server if
+	// any.
+	File string
+	// Format is yaml or json, by the extension of File if empty.
+	Format string
+	// Dir is the directory the revisions are kept in, File.revisions if
+	// empty.
+	Dir string
+	// Keep is the number of revisions kept, 100 if 0.
+	Keep int
+}
+
+// Revision is a numbered version of the config file.
+type Revision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// Source is file, api or rollback.
+	Source string `json:"source"`
+	// User, Method and Path are the API call the revision was made by.
+	User   string `json:"user,omitempty"`
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Rollback is the revision restored by a rollback.
+	Rollback int `json:"rollback,omitempty"`
+}
+
+// Revisions returns the revisions of the config file, the oldest first.
+func (s *Server) Revisions() []Revision {
+	if s.persist == nil {
+		return nil
+	}
+
+	s.persist.mu.Lock()
+	defer s.persist.mu.Unlock()
+
+	return slices.Clone(s.persist.revisions)
+}
+
+// RevisionDiff returns the resources changed from the revision from to the
+// revision to.
+func (s *Server) RevisionDiff(from, to int) ([]ResourceChange, error) {
+	if s.persist == nil {
+		return nil, errRevisionNotFound
+	}
+
+	s.persist.mu.Lock()
+	defer s.persist.mu.Unlock()
+
+	before, err := s.persist.config(from)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", from, err)
+	}
+	after, err := s.persist.config(to)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", to, err)
+	}
+	return resourceChanges(before, after), nil
+}
+
+// Rollback restores the config file to the revision rev and reloads it, by
+// the loader of the server if any, holding the reload lock. It is recorded
+// as a new revision, on behalf of the user of the API call method path, if
+// any.
+func (s *Server) Rollback(rev int, user, method, path string) (Revision, error) {
+	p := s.persist
+	if p == nil {
+		return Revision{}, errRevisionNotFound
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.edits.Lock()
+	defer s.edits.Unlock()
+
+	b, err := p.content(rev)
+	if err != nil {
+		return Revision{}, err
+	}
+	if err := p.sync(); err != nil {
+		return Revision{}, err
+	}
+	prev, err := os.ReadFile(p.file)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	unlock := s.lockReload()
+	defer unlock()
+
+	if s.options.Loader != nil {
+		if err := p.write(b); err != nil {
+			return Revision{}, err
+		}
+		if _, err := s.ReloadConfig(); err != nil {
+			if werr := p.write(prev); werr != nil {
+				return Revision{}, fmt.Errorf("%w (restoring %s failed: %v)", err, p.file, werr)
+			}
+			return Revision{}, err
+		}
+	} else {
+		cfg, err := parseRevision(b, p.format)
+		if err != nil {
+			return Revision{}, err
+		}
+		if _, err := s.Reload(cfg); err != nil {
+			return Revision{}, err
+		}
+		if err := p.write(b); err != nil {
+			return Revision{}, err
+		}
+	}
+
+	return p.record(b, Revision{
+		Source:   RevisionRollback,
+		User:     user,
+		Method:   method,
+		Path:     path,
+		Rollback: rev,
+	})
+}
+
+// persister writes the config to the file and keeps its revisions in dir,
+// as the files N.format, indexed by index.json.
+type persister struct {
+	file   string
+	format string
+	dir    string
+	keep   int
+
+	// mu serializes the changes of the file.
+	mu        sync.Mutex
+	revisions []Revision
+}
+
+func newPersister(p *Persist) (*persister, error) {
+	if p.File == "" {
+		return nil, errors.New("no config file")
+	}
+
+	ps := &persister{
+		file:   p.File,
+		format: p.Format,
+		dir:    p.Dir,
+		keep:   p.Keep,
+	}
+	if ps.format == "" {
+		ps.format = "yaml"
+		if strings.EqualFold(filepath.Ext(p.File), ".json") {
+			ps.format = "json"
+		}
+	}
+	if ps.format != "yaml" && ps.format != "json" {
+		return nil, fmt.Errorf("unknown format %q", ps.format)
+	}
+	if ps.dir == "" {
+		ps.dir = p.File + ".revisions"
+	}
+	if ps.keep <= 0 {
+		ps.keep = defaultKeepRevisions
+	}
+
+	if err := os.MkdirAll(ps.dir, 0700); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(ps.dir, "index.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &ps.revisions); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(ps.dir, "index.json"), err)
+		}
+	}
+
+	if err := ps.sync(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (p *persister) revisionFile(rev int) string {
+	return filepath.Join(p.dir, strconv.Itoa(rev)+"."+p.format)
+}
+
+// content returns the content of the revision rev.
+func (p *persister) content(rev int) ([]byte, error) {
+	if !slices.ContainsFunc(p.revisions, func(r Revision) bool { return r.Revision == rev }) {
+		return nil, errRevisionNotFound
+	}
+	return os.ReadFile(p.revisionFile(rev))
+}
+
+func (p *persister) config(rev int) (*config.Config, error) {
+	b, err := p.content(rev)
+	if err != nil {
+		return nil, err
+	}
+	return parseRevision(b, p.format)
+}
+
+// sync records the file as a revision if it differs from the last one,
+// changed by other means than the API.
+func (p *persister) sync() error {
+	b, err := os.ReadFile(p.file)
+	if err != nil {
+		return err
+	}
+	if n := len(p.revisions); n > 0 {
+		if last, err := p.content(p.revisions[n-1].Revision); err == nil && bytes.Equal(last, b) {
+			return nil
+		}
+	}
+	_, err = p.record(b, Revision{Source: RevisionFile})
+	return err
+}
+
+// persist writes b, the config changed by an API call, to the file and
+// records it as a revision.
+func (p *persister) persist(b []byte, rev Revision) error {
+	if err := p.sync(); err != nil {
+		return err
+	}
+	if last, err := p.content(p.revisions[len(p.revisions)-1].Revision); err == nil && bytes.Equal(last, b) {
+		return nil
+	}
+	if err := p.write(b); err != nil {
+		return err
+	}
+	_, err := p.record(b, rev)
+	return err
+}
+
+// write replaces the file with b, keeping its mode.
+func (p *persister) write(b []byte) error {
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(p.file); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	return writeFile(p.file, b, perm)
+}
+
+// record adds b as a new revision and drops the revisions beyond the
+// number kept.
+func (p *persister) record(b []byte, rev Revision) (Revision, error) {
+	rev.Revision = 1
+	if n := len(p.revisions); n > 0 {
+		rev.Revision = p.revisions[n-1].Revision + 1
+	}
+	rev.Time = time.Now()
+
+	if err := writeFile(p.revisionFile(rev.Revision), b, 0600); err != nil {
+		return Revision{}, err
+	}
+	revisions := append(p.revisions, rev)
+	var dropped []Revision
+	if n := len(revisions) - p.keep; n > 0 {
+		dropped, revisions = revisions[:n], revisions[n:]
+	}
+
+	index, err := json.MarshalIndent(revisions, "", "  ")
+	if err != nil {
+		return Revision{}, err
+	}
+	if err := writeFile(filepath.Join(p.dir, "index.json"), index, 0600); err != nil {
+		os.Remove(p.revisionFile(rev.Revision))
+		return Revision{}, err
+	}
+	p.revisions = revisions
+
+	for _, r := range dropped {
+		os.Remove(p.revisionFile(r.Revision))
+	}
+	return rev, nil
+}
+
+// parseRevision parses the config of a revision, as written by the API.
+func parseRevision(b []byte, format string) (*config.Config, error) {
+	cfg := &config.Config{}
+	var err error
+	if format == "json" {
+		err = json.Unmarshal(b, cfg)
+	} else {
+		err = yaml.Unmarshal(b, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// writeFile replaces the file name with b atomically, by renaming a
+// temporary file of the same directory.
+func writeFile(name string, b []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// mwPersist persists the changes made to the running config by the calls
+// to the config API.
+func mwPersist(srv *Server, p *persister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusMultipleChoices {
+			return
+		}
+		log := logger.Default().WithFields(map[string]any{"kind": "api"})
+
+		var buf bytes.Buffer
+		if err := config.Global().Write(&buf, p.format); err != nil {
+			log.Errorf("persist: %v", err)
+			return
+		}
+		b, err := srv.redact(buf.Bytes(), p.format)
+		if err != nil {
+			log.Errorf("persist: %v", err)
+			return
+		}
+		err = p.persist(b, Revision{
+			Source: RevisionAPI,
+			User:   c.GetString(userKey),
+			Method: c.Request.Method,
+			Path:   c.Request.URL.RequestURI(),
+		})
+		if err != nil {
+			log.Errorf("persist: %v", err)
+		}
+	}
+}
+
+type revisionList struct {
+	Count int        `json:"count"`
+	List  []Revision `json:"list"`
+}
+
+// getRevisions lists the revisions of the config file, the newest first.
+func getRevisions(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		list := srv.Revisions()
+		slices.Reverse(list)
+		if list == nil {
+			list = []Revision{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: revisionList{
+				Count: len(list),
+				List:  list,
+			},
+		})
+	}
+}
+
+type revisionDiff struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []ResourceChange `json:"changes"`
+}
+
+// diffRevisions returns the resources changed between the revisions from
+// and to of the query. to is the last revision if not set, from the one
+// before to.
+func diffRevisions(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req struct {
+			From int `form:"from"`
+			To   int `form:"to"`
+		}
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "invalid query"))
+			return
+		}
+		if req.To == 0 {
+			if list := srv.Revisions(); len(list) > 0 {
+				req.To = list[len(list)-1].Revision
+			}
+		}
+		if req.From == 0 {
+			req.From = req.To - 1
+		}
+
+		changes, err := srv.RevisionDiff(req.From, req.To)
+		if errors.Is(err, errRevisionNotFound) {
+			ctx.JSON(http.StatusNotFound, api.NewError(http.StatusNotFound, api.ErrCodeNotFound, err.Error()))
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeFailed, err.Error()))
+			return
+		}
+		if changes == nil {
+			changes = []ResourceChange{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: revisionDiff{
+				From:    req.From,
+				To:      req.To,
+				Changes: changes,
+			},
+		})
+	}
+}
+
+// rollbackRevision restores the revision of the path and reloads it.
+func rollbackRevision(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		n, err := strconv.Atoi(ctx.Param("revision"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "invalid revision"))
+			return
+		}
+
+		rev, err := srv.Rollback(n, ctx.GetString(userKey), ctx.Request.Method, ctx.Request.URL.RequestURI())
+		if errors.Is(err, errRevisionNotFound) {
+			ctx.JSON(http.StatusNotFound, api.NewError(http.StatusNotFound, api.ErrCodeNotFound,
+				fmt.Sprintf("revision %d not found", n)))
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg:  "OK",
+			Data: rev,
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-gost/x/config"
+)
+
+// Plan is what reloading a running server with a candidate config would
+// do: the resources it would add, change and remove, and the live
+// connections carried by the services it would rebind or stop.
+type Plan struct {
+	Changes     []PlanChange `json:"changes,omitempty"`
+	Add         int          `json:"add"`
+	Change      int          `json:"change"`
+	Remove      int          `json:"remove"`
+	Connections int          `json:"connections"`
+}
+
+// PlanChange is the change of one resource, e.g. services/proxy, or of one
+// of the log, api, metrics and profiling sections.
+type PlanChange struct {
+	Resource string `json:"resource"`
+	// Action is add, change or remove.
+	Action string `json:"action"`
+	// Cause is the resource this one is changed for, if its own config
+	// is the same.
+	Cause string `json:"cause,omitempty"`
+	// Rebind reports whether the listener is closed and bound again.
+	Rebind      bool   `json:"rebind,omitempty"`
+	Addr        string `json:"addr,omitempty"`
+	Connections int    `json:"connections,omitempty"`
+	// Details are the changed values, one per line: "~ addr: :8080 -> :8081",
+	// "+ matchers: 10.0.0.0/8", "- hops[hop-0].nodes[node-1]".
+	Details []string `json:"details,omitempty"`
+}
+
+var planActions = map[Change]string{
+	Added:   "add",
+	Changed: "change",
+	Removed: "remove",
+}
+
+// buildPlan returns the plan of reloading the running config old with cfg.
+// conns are the live connections of the running services, by service.
+func buildPlan(old, cfg *config.Config, conns map[string]int) *Plan {
+	p := &Plan{}
+
+	d := diffConfig(old, cfg)
+	for _, key := range d.Resources(Added, Changed, Removed) {
+		c := PlanChange{
+			Resource: key.String(),
+			Action:   planActions[d.changes[key]],
+		}
+		if cause, ok := d.causes[key]; ok {
+			c.Cause = cause.String()
+		}
+
+		var oc, nc any
+		if kind := resourceKindOf(key.Kind); kind != nil {
+			oc, nc = kind.configs(old)[key.Name], kind.configs(cfg)[key.Name]
+		} else if key == DefaultTLS {
+			oc, nc = old.TLS, cfg.TLS
+		}
+		if d.changes[key] == Changed {
+			planDetails("", normalized(oc), normalized(nc), &c.Details)
+		}
+
+		if key.Kind == serviceKind.name {
+			// A changed service is closed and built again.
+			c.Rebind = d.changes[key] == Changed
+			if svc, _ := nc.(*config.ServiceConfig); svc != nil {
+				c.Addr = svc.Addr
+			} else if svc, _ := oc.(*config.ServiceConfig); svc != nil {
+				c.Addr = svc.Addr
+			}
+			if d.changes[key] != Added {
+				c.Connections = conns[key.Name]
+				p.Connections += c.Connections
+			}
+		}
+
+		p.add(c)
+	}
+
+	// The management sections are not named resources, the services of
+	// the changed ones are restarted.
+	for _, s := range []struct {
+		name     string
+		old, new any
+		addr     func(*config.Config) string
+	}{
+		{"log", old.Log, cfg.Log, nil},
+		{"api", old.API, cfg.API, func(c *config.Config) string {
+			if c.API != nil {
+				return c.API.Addr
+			}
+			return ""
+		}},
+		{"metrics", old.Metrics, cfg.Metrics, func(c *config.Config) string {
+			if c.Metrics != nil {
+				return c.Metrics.Addr
+			}
+			return ""
+		}},
+		{"profiling", old.Profiling, cfg.Profiling, func(c *config.Config) string {
+			if c.Profiling != nil {
+				return c.Profiling.Addr
+			}
+			return ""
+		}},
+	} {
+		if equalConfig(s.old, s.new) {
+			continue
+		}
+		ov, nv := normalized(s.old), normalized(s.new)
+		c := PlanChange{Resource: s.name, Action: planActions[Changed]}
+		switch {
+		case ov == nil:
+			c.Action = planActions[Added]
+		case nv == nil:
+			c.Action = planActions[Removed]
+		default:
+			planDetails("", ov, nv, &c.Details)
+		}
+		if s.addr != nil {
+			c.Rebind = c.Action == planActions[Changed]
+			if c.Addr = s.addr(cfg); c.Addr == "" {
+				c.Addr = s.addr(old)
+			}
+		}
+		p.add(c)
+	}
+
+	return p
+}
+
+func (p *Plan) add(c PlanChange) {
+	p.Changes = append(p.Changes, c)
+	switch c.Action {
+	case planActions[Added]:
+		p.Add++
+	case planActions[Changed]:
+		p.Change++
+	case planActions[Removed]:
+		p.Remove++
+	}
+}
+
+// WriteTo writes the plan in text form, one resource per line followed by
+// its changed values.
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	p.write(&buf)
+	return buf.WriteTo(w)
+}
+
+func (p *Plan) write(w io.Writer) {
+	if len(p.Changes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	var rebinds int
+	for _, c := range p.Changes {
+		symbol := "~"
+		var notes []string
+		switch c.Action {
+		case planActions[Added]:
+			symbol = "+"
+			if c.Addr != "" {
+				notes = append(notes, "bind "+c.Addr)
+			}
+		case planActions[Removed]:
+			symbol = "-"
+			if c.Addr != "" {
+				notes = append(notes, "stop "+c.Addr)
+			}
+		default:
+			if c.Rebind {
+				notes = append(notes, "rebind "+c.Addr)
+			}
+		}
+		if c.Cause != "" {
+			notes = append(notes, "via "+c.Cause)
+		}
+		if strings.HasPrefix(c.Resource, serviceKind.name+"/") && c.Action != planActions[Added] {
+			notes = append(notes, plural(c.Connections, "live connection"))
+			rebinds++
+		}
+
+		line := symbol + " " + c.Resource
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+		for _, d := range c.Details {
+			fmt.Fprintln(w, "    "+d)
+		}
+	}
+
+	fmt.Fprintf(w, "\nPlan: %d to add, %d to change, %d to remove.\n", p.Add, p.Change, p.Remove)
+	if rebinds > 0 {
+		fmt.Fprintf(w, "%s to rebind or stop, carrying %s.\n",
+			plural(rebinds, "service"), plural(p.Connections, "live connection"))
+	}
+}
+
+func plural(n int, s string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, s)
+	}
+	return fmt.Sprintf("%d %ss", n, s)
+}
+
+// planDetails appends the differences between the JSON forms old and cfg
+// of a config under path to details. The items of the lists of named
+// configs are matched by name, the lists of values are compared as sets.
+func planDetails(path string, old, cfg any, details *[]string) {
+	if reflect.DeepEqual(old, cfg) {
+		return
+	}
+
+	switch o := old.(type) {
+	case map[string]any:
+		n, ok := cfg.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range o {
+			keys[k] = true
+		}
+		for k := range n {
+			keys[k] = true
+		}
+		for _, k := range sortedNames(keys) {
+			p := joinPath(path, k)
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			switch {
+			case !inOld:
+				*details = append(*details, "+ "+describeValue(p, nv))
+			case !inNew:
+				*details = append(*details, "- "+describeValue(p, ov))
+			default:
+				planDetails(p, ov, nv, details)
+			}
+		}
+		return
+
+	case []any:
+		n, ok := cfg.([]any)
+		if !ok {
+			break
+		}
+		if on, nn := namedItems(o), namedItems(n); on != nil && nn != nil {
+			for _, item := range n {
+				name := item.(map[string]any)["name"].(string)
+				p := fmt.Sprintf("%s[%s]", path, name)
+				if ov, ok := on[name]; ok {
+					planDetails(p, ov, item, details)
+				} else {
+					*details = append(*details, "+ "+p)
+				}
+			}
+			for _, item := range o {
+				name := item.(map[string]any)["name"].(string)
+				if _, ok := nn[name]; !ok {
+					*details = append(*details, fmt.Sprintf("- %s[%s]", path, name))
+				}
+			}
+			return
+		}
+		if isValues(o) && isValues(n) {
+			for _, v := range n {
+				if !containsValue(o, v) {
+					*details = append(*details, "+ "+describeValue(path, v))
+				}
+			}
+			for _, v := range o {
+				if !containsValue(n, v) {
+					*details = append(*details, "- "+describeValue(path, v))
+				}
+			}
+			return
+		}
+	}
+
+	if isValue(old) && isValue(cfg) {
+		*details = append(*details, fmt.Sprintf("~ %s: %s -> %s", path, formatValue(path, old), formatValue(path, cfg)))
+		return
+	}
+	*details = append(*details, "~ "+path)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// namedItems returns the items of a list of configs by name, or nil if
+// they are not all named or the names are not unique.
+func namedItems(l []any) map[string]any {
+	m := make(map[string]any)
+	for _, item := range l {
+		c, _ := item.(map[string]any)
+		name, _ := c["name"].(string)
+		if name == "" {
+			return nil
+		}
+		if _, ok := m[name]; ok {
+			return nil
+		}
+		m[name] = c
+	}
+	return m
+}
+
+func isValue(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	return true
+}
+
+func isValues(l []any) bool {
+	for _, v := range l {
+		if !isValue(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsValue(l []any, v any) bool {
+	for _, item := range l {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func describeValue(path string, v any) string {
+	if !isValue(v) {
+		return path
+	}
+	return path + ": " + formatValue(path, v)
+}
+
+// formatValue formats a value of the JSON form of a config. The secrets
+// not given by references are not shown.
+func formatValue(path string, v any) string {
+	s := fmt.Sprint(v)
+	if v == nil {
+		s = "null"
+	}
+	key := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	for _, secret := range []string{"password", "secret", "token"} {
+		if strings.Contains(key, secret) && !strings.Contains(s, "${") {
+			return "(sensitive)"
+		}
+	}
+	return s
+}
+
+func sortedNames(m map[string]bool) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactedConfig returns a copy of cfg redacted as written by the API.
+func (s *Server) redactedConfig(cfg *config.Config) (*config.Config, error) {
+	var buf bytes.Buffer
+	if err := cfg.Write(&buf, "json"); err != nil {
+		return nil, err
+	}
+	b, err := s.redact(buf.Bytes(), "json")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &config.Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *Server) redact(b []byte, format string) ([]byte, error) {
+	if s.options.Redact == nil {
+		return b, nil
+	}
+	return s.options.Redact(b, format)
+}
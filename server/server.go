@@ -0,0 +1,610 @@
+// Package server runs gost in-process: the services, chains and the other
+// named resources of a config, the API, metrics and profiling services, and
+// the transactional reloads of the config.
+//
+// The resources are registered in the registries of
+// github.com/go-gost/x/registry, which are global, so there is one Server
+// per process. Custom handlers, listeners, dialers and connectors can be
+// registered there before the server is started, and the running resources
+// are found there or with Server.Resource.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	_ "net/http/pprof"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/x/config"
+	xlogger "github.com/go-gost/x/logger"
+	xmetrics "github.com/go-gost/x/metrics"
+	"github.com/go-gost/x/registry"
+)
+
+// DefaultProfilingAddr is the address of the profiling service when the
+// profiling section does not set one.
+const DefaultProfilingAddr = ":6060"
+
+// reloadHistory is the number of reload outcomes kept.
+const reloadHistory = 20
+
+// ErrServerClosed is returned by the reloads of a closed server.
+var ErrServerClosed = errors.New("server closed")
+
+type Options struct {
+	// Loader loads the config applied by ReloadConfig and by the reload
+	// endpoint of the API.
+	Loader func() (*config.Config, error)
+	// ReloadLock is held by the reloads the API starts, a rollback writing
+	// the config file included, so that they are serialized with the ones
+	// of the program running the server and with its other changes, e.g.
+	// an upgrade. The callers of ReloadConfig hold it as they need.
+	ReloadLock sync.Locker
+	// Redact rewrites the config written by the API in the given format
+	// (json or yaml), e.g. to hide secrets.
+	Redact func(b []byte, format string) ([]byte, error)
+	// DrainTimeout is how long the connections of the services replaced by
+	// a reload, or of all the services when the context of Start is done,
+	// are left to finish before being closed.
+	DrainTimeout time.Duration
+	// OnReload is called before a reload, OnReloaded after it.
+	OnReload   func()
+	OnReloaded func(ReloadStatus)
+	// Access is the access control of the API service.
+	Access *Access
+	// TLS returns the TLS of the API (@api) or metrics (@metrics) service,
+	// served over plain HTTP if nil. It is called when the server starts
+	// and after the loader on each ReloadConfig, the TLS belonging to the
+	// loaded config; Reload keeps the running one. The service is
+	// restarted when its TLS changes.
+	TLS func(service string) *TLS
+	// Persist writes the changes made by the API to the config file, with
+	// revisions.
+	Persist *Persist
+	// ReadyChains are the chains required to have a node passing in each
+	// of their hops for the server to be ready.
+	ReadyChains []string
+}
+
+type Option func(opts *Options)
+
+func LoaderOption(loader func() (*config.Config, error)) Option {
+	return func(opts *Options) {
+		opts.Loader = loader
+	}
+}
+
+func ReloadLockOption(lock sync.Locker) Option {
+	return func(opts *Options) {
+		opts.ReloadLock = lock
+	}
+}
+
+func RedactOption(redact func(b []byte, format string) ([]byte, error)) Option {
+	return func(opts *Options) {
+		opts.Redact = redact
+	}
+}
+
+func DrainTimeoutOption(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.DrainTimeout = timeout
+	}
+}
+
+func ReloadHooksOption(onReload func(), onReloaded func(ReloadStatus)) Option {
+	return func(opts *Options) {
+		opts.OnReload = onReload
+		opts.OnReloaded = onReloaded
+	}
+}
+
+func AccessOption(access *Access) Option {
+	return func(opts *Options) {
+		opts.Access = access
+	}
+}
+
+func TLSOption(tls func(service string) *TLS) Option {
+	return func(opts *Options) {
+		opts.TLS = tls
+	}
+}
+
+func PersistOption(persist *Persist) Option {
+	return func(opts *Options) {
+		opts.Persist = persist
+	}
+}
+
+func ReadyChainsOption(chains ...string) Option {
+	return func(opts *Options) {
+		opts.ReadyChains = chains
+	}
+}
+
+// ReloadStatus is the outcome of the last config (re)load.
+type ReloadStatus struct {
+	// Status is one of "success", "failed" (the new config was rejected
+	// before anything was changed) and "rolledback" (the new config could
+	// not be applied and the previous one was restored).
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+	// Changes summarizes the resources affected by the reload.
+	Changes string `json:"changes,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Server runs the resources of a config.
+type Server struct {
+	cfg     *config.Config
+	options Options
+
+	srvApi       service.Service
+	srvMetrics   service.Service
+	srvProfiling *http.Server
+	// apiTLS and metricsTLS are the TLS the API and metrics services are
+	// running with.
+	apiTLS     *TLS
+	metricsTLS *TLS
+	// tlsConfig is the TLS of the management services of the running
+	// config, restored when a reload is rolled back.
+	tlsConfig managementTLS
+
+	// tokens and audit are the API tokens and the audit log of the API,
+	// with an access control.
+	tokens *tokenStore
+	audit  *auditLog
+	// persist writes the changes made by the API to the config file.
+	persist *persister
+
+	// edits serializes the changes made to the config by the API, for
+	// their preconditions to hold.
+	edits sync.Mutex
+
+	// stopWatch stops watching the nodes.
+	stopWatch context.CancelFunc
+
+	// mu serializes reloads.
+	mu         sync.Mutex
+	lastReload ReloadStatus
+	// reloads are the outcomes of the last reloads, the oldest first.
+	reloads []ReloadStatus
+	closed  bool
+}
+
+// New returns a server running cfg once started.
+func New(cfg *config.Config, opts ...Option) *Server {
+	s := &Server{cfg: cfg}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return s
+}
+
+// Start builds the resources of the config and starts the services. When
+// ctx is done the server is shut down, draining the connections for the
+// drain timeout.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrServerClosed
+	}
+
+	if access := s.options.Access; access != nil {
+		if err := access.Validate(); err != nil {
+			return fmt.Errorf("api access: %w", err)
+		}
+		tokens, err := newTokenStore(access.Tokens)
+		if err != nil {
+			return fmt.Errorf("api tokens: %w", err)
+		}
+		s.tokens = tokens
+		if access.Audit != "" {
+			if s.audit, err = newAuditLog(access.Audit); err != nil {
+				return fmt.Errorf("api audit: %w", err)
+			}
+		}
+	}
+
+	if s.options.Persist != nil {
+		p, err := newPersister(s.options.Persist)
+		if err != nil {
+			return fmt.Errorf("config persistence: %w", err)
+		}
+		s.persist = p
+	}
+
+	trackHandlers()
+
+	// The resources log to the default logger while they are built, before
+	// the one of the config is set.
+	if logger.Default() == nil {
+		logger.SetDefault(xlogger.NewLogger())
+	}
+
+	cfg := s.cfg
+	config.Set(cfg)
+
+	// Enable metrics before loading services so that listener wrappers
+	// can observe the enabled state at Init time.
+	if cfg.Metrics != nil && cfg.Metrics.Addr != "" {
+		xmetrics.Enable(true)
+	}
+
+	trackSessions.Store(cfg.API != nil)
+	d, err := load(nil, cfg)
+	if err != nil {
+		return err
+	}
+	s.setReloadStatus(d, nil)
+
+	s.tlsConfig = s.loadTLS()
+	if err := s.run(nil, cfg, s.tlsConfig); err != nil {
+		return err
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	s.stopWatch = cancel
+	go nodes.run(watchCtx)
+
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			ctx, cancel := context.WithTimeout(context.Background(), s.options.DrainTimeout)
+			defer cancel()
+			s.Shutdown(ctx)
+		}()
+	}
+
+	return nil
+}
+
+// Reload applies cfg to the running server. Either cfg is applied
+// completely, or the running config is kept. Only the resources that differ
+// are rebuilt, the touched ones are rebuilt even if their config is
+// unchanged, e.g. when a file they read has changed.
+func (s *Server) Reload(cfg *config.Config, touched ...Resource) (*Diff, error) {
+	return s.reload(func() (*config.Config, managementTLS, error) { return cfg, s.tlsConfig, nil }, touched...)
+}
+
+// ReloadConfig applies the config returned by the loader of the server, as
+// Reload.
+func (s *Server) ReloadConfig(touched ...Resource) (*Diff, error) {
+	if s.options.Loader == nil {
+		return nil, errors.New("no config loader")
+	}
+	return s.reload(func() (*config.Config, managementTLS, error) {
+		cfg, err := s.options.Loader()
+		if err != nil {
+			return nil, managementTLS{}, err
+		}
+		return cfg, s.loadTLS(), nil
+	}, touched...)
+}
+
+// lockReload holds the reload lock, if any, until unlock is called.
+func (s *Server) lockReload() (unlock func()) {
+	if s.options.ReloadLock == nil {
+		return func() {}
+	}
+	s.options.ReloadLock.Lock()
+	return s.options.ReloadLock.Unlock
+}
+
+func (s *Server) reload(loader func() (*config.Config, managementTLS, error), touched ...Resource) (d *Diff, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrServerClosed
+	}
+
+	if s.options.OnReload != nil {
+		s.options.OnReload()
+	}
+	defer func() {
+		s.setReloadStatus(d, err)
+		events.publish(reloadEvent(s.lastReload))
+		if s.options.OnReloaded != nil {
+			s.options.OnReloaded(s.lastReload)
+		}
+	}()
+
+	cfg, tls, err := loader()
+	if err != nil {
+		return nil, err
+	}
+
+	old := config.Global()
+
+	trackSessions.Store(cfg.API != nil)
+	d, err = load(old, cfg, touched...)
+	if err != nil {
+		trackSessions.Store(old != nil && old.API != nil)
+	}
+
+	// The services replaced by the reload (or by its rollback) are closed
+	// at this point, their connections are drained in the background.
+	if timeout := s.options.DrainTimeout; timeout > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			tracker.drain(ctx)
+		}()
+	}
+
+	if err != nil {
+		return d, err
+	}
+
+	if err = s.run(old, cfg, tls); err != nil {
+		trackSessions.Store(old != nil && old.API != nil)
+		if _, rerr := load(cfg, old); rerr != nil {
+			return d, fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+		}
+		if rerr := s.run(cfg, old, s.tlsConfig); rerr != nil {
+			return d, fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+		}
+		return d, &rollbackError{err: err}
+	}
+
+	config.Set(cfg)
+	s.cfg = cfg
+	s.tlsConfig = tls
+
+	return d, nil
+}
+
+func (s *Server) setReloadStatus(d *Diff, err error) {
+	st := ReloadStatus{
+		Status: "success",
+		Time:   time.Now(),
+	}
+	if err != nil {
+		st.Status = "failed"
+		if errors.As(err, new(*rollbackError)) {
+			st.Status = "rolledback"
+		}
+		st.Error = err.Error()
+	} else if d != nil {
+		st.Changes = d.String()
+	}
+
+	s.lastReload = st
+	if len(s.reloads) == reloadHistory {
+		s.reloads = slices.Delete(s.reloads, 0, 1)
+	}
+	s.reloads = append(s.reloads, st)
+}
+
+func reloadEvent(st ReloadStatus) Event {
+	e := Event{
+		Type:    EventReloadSucceeded,
+		Time:    st.Time,
+		Changes: st.Changes,
+	}
+	if st.Status != "success" {
+		e.Type, e.Status, e.Error = EventReloadFailed, st.Status, st.Error
+	}
+	return e
+}
+
+// LastReload returns the outcome of the last config (re)load.
+func (s *Server) LastReload() ReloadStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastReload
+}
+
+// Reloads returns the outcomes of the last config (re)loads, the newest
+// first.
+func (s *Server) Reloads() []ReloadStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := slices.Clone(s.reloads)
+	slices.Reverse(list)
+	return list
+}
+
+// Config returns the running config, including the changes made through
+// the API.
+func (s *Server) Config() *config.Config {
+	return config.Global()
+}
+
+// Resource returns the registered resource r, e.g. the chain.Chainer of
+// chains/chain-0, or nil.
+func (s *Server) Resource(r Resource) any {
+	kind := resourceKindOf(r.Kind)
+	if kind == nil {
+		return nil
+	}
+	return kind.get(r.Name)
+}
+
+// Plan returns what reloading the server with cfg would do.
+func (s *Server) Plan(cfg *config.Config) *Plan {
+	return buildPlan(config.Global(), cfg, tracker.connections())
+}
+
+// Close closes the services and the management services at once. The
+// connections being handled are left running, Shutdown drains them.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	for name, srv := range registry.ServiceRegistry().GetAll() {
+		srv.Close()
+		events.publish(Event{Type: EventServiceStopped, Service: name})
+		logger.Default().Debugf("service %s released", name)
+	}
+	s.stopManagement()
+
+	return nil
+}
+
+// Shutdown closes the services, then waits for their connections to finish
+// until ctx is done, closing the remaining ones, and closes the management
+// services.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	for name, srv := range registry.ServiceRegistry().GetAll() {
+		srv.Close()
+		events.publish(Event{Type: EventServiceStopped, Service: name})
+		logger.Default().Debugf("service %s shutdown", name)
+	}
+
+	// The management services stay up while draining,
+	// so that the progress can be observed through the API.
+	tracker.drain(ctx)
+
+	s.mu.Lock()
+	s.stopManagement()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// tls returns the TLS of the management service, if any.
+func (s *Server) tls(service string) *TLS {
+	if s.options.TLS == nil {
+		return nil
+	}
+	return s.options.TLS(service)
+}
+
+// managementTLS is the TLS of the API and metrics services of a config.
+type managementTLS struct {
+	api     *TLS
+	metrics *TLS
+}
+
+// loadTLS returns the TLS of the management services of the config last
+// returned by the loader.
+func (s *Server) loadTLS() managementTLS {
+	return managementTLS{api: s.tls("@api"), metrics: s.tls("@metrics")}
+}
+
+// run (re)starts the management services whose config differs between
+// old and cfg, or whose TLS differs from tls.
+func (s *Server) run(old, cfg *config.Config, tls managementTLS) error {
+	if old == nil {
+		old = &config.Config{}
+	}
+
+	apiTLS := tls.api
+	if s.srvApi != nil && (!equalConfig(old.API, cfg.API) || !equalConfig(s.apiTLS, apiTLS)) {
+		s.srvApi.Close()
+		s.srvApi = nil
+	}
+	if cfg.API != nil && s.srvApi == nil {
+		srv, err := buildApiService(cfg.API, apiTLS, s)
+		if err != nil {
+			return err
+		}
+
+		s.srvApi, s.apiTLS = srv, apiTLS
+
+		go func() {
+			defer srv.Close()
+
+			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@api"})
+
+			log.Info("listening on ", srv.Addr())
+			if err := srv.Serve(); !errors.Is(err, http.ErrServerClosed) {
+				log.Error(err)
+			}
+		}()
+	}
+
+	metricsTLS := tls.metrics
+	if s.srvMetrics != nil && (!equalConfig(old.Metrics, cfg.Metrics) || !equalConfig(s.metricsTLS, metricsTLS)) {
+		s.srvMetrics.Close()
+		s.srvMetrics = nil
+	}
+	if cfg.Metrics != nil && cfg.Metrics.Addr != "" && s.srvMetrics == nil {
+		srv, err := buildMetricsService(cfg.Metrics, metricsTLS, s)
+		if err != nil {
+			return err
+		}
+
+		s.srvMetrics, s.metricsTLS = srv, metricsTLS
+
+		go func() {
+			defer srv.Close()
+
+			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@metrics"})
+
+			log.Info("listening on ", srv.Addr())
+			if err := srv.Serve(); !errors.Is(err, http.ErrServerClosed) {
+				log.Error(err)
+			}
+		}()
+	}
+
+	if s.srvProfiling != nil && !equalConfig(old.Profiling, cfg.Profiling) {
+		s.srvProfiling.Close()
+		s.srvProfiling = nil
+	}
+	if cfg.Profiling != nil && s.srvProfiling == nil {
+		addr := cfg.Profiling.Addr
+		if addr == "" {
+			addr = DefaultProfilingAddr
+		}
+		srv := &http.Server{
+			Addr: addr,
+		}
+		s.srvProfiling = srv
+
+		go func() {
+			defer srv.Close()
+
+			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@profiling"})
+
+			log.Info("listening on ", addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Error(err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (s *Server) stopManagement() {
+	if s.stopWatch != nil {
+		s.stopWatch()
+		s.stopWatch = nil
+	}
+	if s.srvApi != nil {
+		s.srvApi.Close()
+		s.srvApi = nil
+		logger.Default().Debug("service @api shutdown")
+	}
+	if s.srvMetrics != nil {
+		s.srvMetrics.Close()
+		s.srvMetrics = nil
+		logger.Default().Debug("service @metrics shutdown")
+	}
+	if s.srvProfiling != nil {
+		s.srvProfiling.Close()
+		s.srvProfiling = nil
+		logger.Default().Debug("service @profiling shutdown")
+	}
+}
@@ -0,0 +1,517 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	dissector "github.com/go-gost/tls-dissector"
+	xchain "github.com/go-gost/x/chain"
+	"github.com/go-gost/x/config"
+	xctx "github.com/go-gost/x/ctx"
+)
+
+// Session is a connection being handled by a service.
+type Session struct {
+	// ID is the session ID of the connection, as found in the logs.
+	ID      string `json:"id"`
+	Service string `json:"service"`
+	// Client is the address of the client.
+	Client string `json:"client"`
+	// User is the client ID set by the authentication of the handler,
+	// usually the user name.
+	User string `json:"user,omitempty"`
+	// Network and Host are the target of the connection, once dialed.
+	Network string `json:"network,omitempty"`
+	Host    string `json:"host,omitempty"`
+	// Chain and Path are the chain and the nodes the target is reached
+	// through, if any.
+	Chain string        `json:"chain,omitempty"`
+	Path  []SessionNode `json:"path,omitempty"`
+	// Proto is the protocol sniffed from the first bytes sent by the
+	// client to the target, tls or http, SNI the server name of the TLS
+	// client hello.
+	Proto string `json:"proto,omitempty"`
+	SNI   string `json:"sni,omitempty"`
+	// InputBytes are the bytes sent by the client to the target,
+	// OutputBytes the bytes received from it.
+	InputBytes  uint64    `json:"inputBytes"`
+	OutputBytes uint64    `json:"outputBytes"`
+	Start       time.Time `json:"start"`
+}
+
+// SessionNode is a node of the route of a session.
+type SessionNode struct {
+	Hop  string `json:"hop,omitempty"`
+	Node string `json:"node"`
+	Addr string `json:"addr"`
+}
+
+// SessionFilter selects sessions, the empty fields match any session.
+type SessionFilter struct {
+	Service string
+	User    string
+	// Client matches the client address, or its IP.
+	Client string
+	// Host matches the target address, its host, or the SNI.
+	Host string
+}
+
+// Empty reports whether f matches every session.
+func (f SessionFilter) Empty() bool {
+	return f == SessionFilter{}
+}
+
+func (f SessionFilter) match(s *Session) bool {
+	if f.Service != "" && f.Service != s.Service {
+		return false
+	}
+	if f.User != "" && f.User != s.User {
+		return false
+	}
+	if f.Client != "" && f.Client != s.Client && f.Client != hostOf(s.Client) {
+		return false
+	}
+	if f.Host != "" && f.Host != s.Host && f.Host != hostOf(s.Host) && f.Host != s.SNI {
+		return false
+	}
+	return true
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// Sessions returns the sessions matched by f, the oldest first. They are
+// only recorded for the services started while an API service is
+// configured.
+func (s *Server) Sessions(f SessionFilter) []Session {
+	var list []Session
+	for _, sess := range sessions.all() {
+		if v := sess.snapshot(); f.match(&v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// CloseSession closes the connections of the session id, it reports
+// whether the session exists.
+func (s *Server) CloseSession(id string) bool {
+	sess := sessions.get(id)
+	if sess == nil {
+		return false
+	}
+	sess.close()
+	return true
+}
+
+// CloseSessions closes the sessions matched by f and returns them.
+func (s *Server) CloseSessions(f SessionFilter) []Session {
+	var closed []Session
+	for _, sess := range sessions.all() {
+		if v := sess.snapshot(); f.match(&v) {
+			sess.close()
+			closed = append(closed, v)
+		}
+	}
+	return closed
+}
+
+// trackSessions is set while an API service is configured, the only way
+// the sessions are looked at. The handlers built meanwhile record the
+// sessions of their connections, the dials of their routers included.
+var trackSessions atomic.Bool
+
+// sessions records the connections being handled by the tracked handlers.
+var sessions = &sessionTable{
+	sessions: make(map[string]*session),
+}
+
+type sessionTable struct {
+	sessions map[string]*session
+	seq      atomic.Uint64
+	mu       sync.Mutex
+}
+
+func (t *sessionTable) add(ctx context.Context, service string, conn net.Conn) *session {
+	id := string(xctx.SidFromContext(ctx))
+	if id == "" {
+		id = strconv.FormatUint(t.seq.Add(1), 10)
+	}
+
+	client := conn.RemoteAddr()
+	if addr := xctx.SrcAddrFromContext(ctx); addr != nil {
+		client = addr
+	}
+
+	sess := &session{
+		id:        id,
+		service:   service,
+		start:     time.Now(),
+		conn:      conn,
+		upstreams: make(map[net.Conn]struct{}),
+	}
+	if client != nil {
+		sess.client = client.String()
+	}
+
+	t.mu.Lock()
+	t.sessions[id] = sess
+	t.mu.Unlock()
+
+	return sess
+}
+
+func (t *sessionTable) remove(sess *session) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sessions[sess.id] == sess {
+		delete(t.sessions, sess.id)
+	}
+}
+
+func (t *sessionTable) get(id string) *session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.sessions[id]
+}
+
+// fromContext returns the session of the connection ctx belongs to.
+func (t *sessionTable) fromContext(ctx context.Context) *session {
+	sid := xctx.SidFromContext(ctx)
+	if sid == "" {
+		return nil
+	}
+	return t.get(string(sid))
+}
+
+func (t *sessionTable) all() []*session {
+	t.mu.Lock()
+	list := make([]*session, 0, len(t.sessions))
+	for _, sess := range t.sessions {
+		list = append(list, sess)
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(list, func(a, b *session) int {
+		if c := a.start.Compare(b.start); c != 0 {
+			return c
+		}
+		return strings.Compare(a.id, b.id)
+	})
+	return list
+}
+
+type session struct {
+	id      string
+	service string
+	client  string
+	start   time.Time
+	conn    net.Conn
+
+	in, out atomic.Uint64
+
+	mu        sync.Mutex
+	user      string
+	network   string
+	host      string
+	chain     string
+	path      []SessionNode
+	proto     string
+	sni       string
+	upstreams map[net.Conn]struct{}
+}
+
+func (s *session) snapshot() Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Session{
+		ID:          s.id,
+		Service:     s.service,
+		Client:      s.client,
+		User:        s.user,
+		Network:     s.network,
+		Host:        s.host,
+		Chain:       s.chain,
+		Path:        slices.Clone(s.path),
+		Proto:       s.proto,
+		SNI:         s.sni,
+		InputBytes:  s.in.Load(),
+		OutputBytes: s.out.Load(),
+		Start:       s.start,
+	}
+}
+
+// dialing records the target dialed for the session.
+func (s *session) dialing(ctx context.Context, network, address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.network, s.host = network, address
+	if id := xctx.ClientIDFromContext(ctx); id != "" {
+		s.user = string(id)
+	}
+}
+
+// routed records the route of the session, the nodes selected in each hop
+// of a chain.
+func (s *session) routed(nodes []*chain.Node) {
+	chainName, hops := routeChain(config.Global(), s.service, nodes)
+
+	path := make([]SessionNode, 0, len(nodes))
+	for i, node := range nodes {
+		if node == nil {
+			continue
+		}
+		n := SessionNode{Node: node.Name, Addr: node.Addr}
+		if i < len(hops) {
+			n.Hop = hops[i]
+		}
+		path = append(path, n)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chain, s.path = chainName, path
+}
+
+// routeChain returns the chain of the handler of service (or of its chain
+// group) the nodes of a route were selected from, and the names of its
+// hops.
+func routeChain(cfg *config.Config, service string, nodes []*chain.Node) (string, []string) {
+	if cfg == nil {
+		return "", nil
+	}
+
+	var names []string
+	for _, svc := range cfg.Services {
+		if svc == nil || svc.Name != service || svc.Handler == nil {
+			continue
+		}
+		names = append(names, svc.Handler.Chain)
+		if cg := svc.Handler.ChainGroup; cg != nil {
+			for _, c := range cg.Chains {
+				if c != nil {
+					names = append(names, c.Chain)
+				}
+			}
+		}
+	}
+
+	hopNodes := func(hop *config.HopConfig) []*config.NodeConfig {
+		if hop.Nodes != nil {
+			return hop.Nodes
+		}
+		for _, h := range cfg.Hops {
+			if h != nil && h.Name == hop.Name {
+				return h.Nodes
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		for _, c := range cfg.Chains {
+			if c == nil || c.Name != name || len(c.Hops) < len(nodes) {
+				continue
+			}
+
+			hops := make([]string, 0, len(nodes))
+			for i, node := range nodes {
+				hop := c.Hops[i]
+				if hop == nil {
+					break
+				}
+				// The nodes of the hops loaded from elsewhere are not
+				// known, they match any node.
+				list := hopNodes(hop)
+				if node != nil && list != nil && !slices.ContainsFunc(list, func(n *config.NodeConfig) bool {
+					return n != nil && n.Name == node.Name
+				}) {
+					break
+				}
+				hops = append(hops, hop.Name)
+			}
+			if len(hops) == len(nodes) {
+				return name, hops
+			}
+		}
+	}
+	return "", nil
+}
+
+// track returns conn, a connection to the target of the session, counting
+// its bytes and sniffing the protocol of the first ones sent.
+func (s *session) track(conn net.Conn) net.Conn {
+	s.mu.Lock()
+	s.upstreams[conn] = struct{}{}
+	s.mu.Unlock()
+
+	// The packet connections are left alone, their type matters.
+	if _, ok := conn.(net.PacketConn); ok {
+		return conn
+	}
+	sc := &sessionConn{Conn: conn, session: s}
+	// The half-close of the connection is kept, the pipes closing it fully
+	// otherwise.
+	if _, ok := conn.(closeWriter); ok {
+		return &halfClosingSessionConn{sessionConn: sc}
+	}
+	return sc
+}
+
+func (s *session) sniff(b []byte) {
+	var proto, sni string
+	switch {
+	case len(b) > 0 && b[0] == 0x16:
+		proto = "tls"
+		if info, err := dissector.ParseClientHello(bytes.NewReader(b)); err == nil {
+			sni = info.ServerName
+		}
+	case bytes.HasPrefix(b, []byte("PRI * HTTP/2.0")):
+		proto = "http2"
+	default:
+		if req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(b))); err == nil {
+			proto = "http"
+			req.Body.Close()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.proto, s.sni = proto, sni
+}
+
+// close closes the connections of the session and stops listing it, its
+// handler may take a while to return.
+func (s *session) close() {
+	sessions.remove(s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.Close()
+	for conn := range s.upstreams {
+		conn.Close()
+	}
+}
+
+type sessionConn struct {
+	net.Conn
+	session *session
+	sniffed atomic.Bool
+}
+
+func (c *sessionConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.session.out.Add(uint64(n))
+	return n, err
+}
+
+func (c *sessionConn) Write(b []byte) (int, error) {
+	if c.sniffed.CompareAndSwap(false, true) {
+		c.session.sniff(b)
+	}
+	n, err := c.Conn.Write(b)
+	c.session.in.Add(uint64(n))
+	return n, err
+}
+
+func (c *sessionConn) Close() error {
+	c.session.mu.Lock()
+	delete(c.session.upstreams, c.Conn)
+	c.session.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+type closeReader interface {
+	CloseRead() error
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// halfClosingSessionConn is a sessionConn of a connection that can be
+// half-closed.
+type halfClosingSessionConn struct {
+	*sessionConn
+}
+
+func (c *halfClosingSessionConn) CloseRead() error {
+	if cr, ok := c.Conn.(closeReader); ok {
+		return cr.CloseRead()
+	}
+	return nil
+}
+
+func (c *halfClosingSessionConn) CloseWrite() error {
+	return c.Conn.(closeWriter).CloseWrite()
+}
+
+// trackRouter returns a router dialing as r does for service, recording the
+// target and the route of the dials made for sessions, and watching the
+// nodes routed through. r and its chain are still owned by the service,
+// which closes them.
+func trackRouter(service string, r chain.Router) chain.Router {
+	opts := *r.Options()
+	if opts.Chain != nil {
+		opts.Chain = &trackedChainer{Chainer: opts.Chain, service: service}
+	}
+	return &trackedRouter{
+		Router: xchain.NewRouter(func(o *chain.RouterOptions) { *o = opts }),
+	}
+}
+
+type trackedRouter struct {
+	chain.Router
+}
+
+func (r *trackedRouter) Dial(ctx context.Context, network, address string, opts ...chain.DialOption) (net.Conn, error) {
+	sess := sessions.fromContext(ctx)
+	if sess != nil {
+		sess.dialing(ctx, network, address)
+	}
+
+	conn, err := r.Router.Dial(ctx, network, address, opts...)
+	if err != nil || sess == nil {
+		return conn, err
+	}
+	return sess.track(conn), nil
+}
+
+type trackedChainer struct {
+	chain.Chainer
+	service string
+}
+
+func (c *trackedChainer) Route(ctx context.Context, network, address string, opts ...chain.RouteOption) chain.Route {
+	route := c.Chainer.Route(ctx, network, address, opts...)
+	if route == nil {
+		return nil
+	}
+	nodes.route(c.service, route.Nodes())
+	if sess := sessions.fromContext(ctx); sess != nil {
+		sess.routed(route.Nodes())
+	}
+	return route
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/x/api"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+)
+
+// NodeStatus is the state of a node of a hop, of a chain or of the
+// forwarder of a service. The nodes of the hops defined in a chain are
+// known once a connection is routed through them.
+type NodeStatus struct {
+	// Service is the service forwarding to the node, Chain and Hop where
+	// it is selected from otherwise.
+	Service string `json:"service,omitempty"`
+	Chain   string `json:"chain,omitempty"`
+	Hop     string `json:"hop,omitempty"`
+	Node    string `json:"node"`
+	Addr    string `json:"addr"`
+	// Failed reports whether the node is skipped by the selection, Reason
+	// is fails or probe.
+	Failed bool   `json:"failed"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Fails is the number of failures of the node since its last success.
+	Fails int64 `json:"fails"`
+	// Probe is the last probe of the node, if it is probed.
+	Probe *NodeProbe `json:"probe,omitempty"`
+}
+
+// NodeProbe is the outcome of a probe of a node.
+type NodeProbe struct {
+	Success bool          `json:"success"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+	Time    time.Time     `json:"time"`
+}
+
+// Nodes returns the state of the nodes of the running config, by chain,
+// hop and name.
+func (s *Server) Nodes() []NodeStatus {
+	return nodes.status(config.Global())
+}
+
+// TunnelConnector is a connector bound to a tunnel of the tunnel handler of
+// a service.
+type TunnelConnector struct {
+	Service   string `json:"service"`
+	Tunnel    string `json:"tunnel"`
+	Connector string `json:"connector"`
+	// Node is the node of the service the connector is bound to, Client
+	// its address.
+	Node    string    `json:"node,omitempty"`
+	Client  string    `json:"client,omitempty"`
+	Network string    `json:"network,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// Tunnels returns the connectors bound to the tunnels of the services, by
+// service, tunnel and time.
+func (s *Server) Tunnels() []TunnelConnector {
+	var list []TunnelConnector
+	for name, v := range registry.SDRegistry().GetAll() {
+		if sd, ok := v.(*eventSD); ok && strings.HasPrefix(name, tunnelSDPrefix) {
+			list = append(list, sd.tunnels()...)
+		}
+	}
+	slices.SortFunc(list, func(a, b TunnelConnector) int {
+		return cmp.Or(
+			strings.Compare(a.Service, b.Service),
+			strings.Compare(a.Tunnel, b.Tunnel),
+			a.Since.Compare(b.Since),
+		)
+	})
+	return list
+}
+
+type nodeList struct {
+	Count int          `json:"count"`
+	List  []NodeStatus `json:"list"`
+}
+
+func getNodes(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		list := srv.Nodes()
+		if list == nil {
+			list = []NodeStatus{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: nodeList{
+				Count: len(list),
+				List:  list,
+			},
+		})
+	}
+}
+
+type tunnelList struct {
+	Count int               `json:"count"`
+	List  []TunnelConnector `json:"list"`
+}
+
+func getTunnels(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		list := srv.Tunnels()
+		if list == nil {
+			list = []TunnelConnector{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: tunnelList{
+				Count: len(list),
+				List:  list,
+			},
+		})
+	}
+}
+
+type reloadList struct {
+	Count int            `json:"count"`
+	List  []ReloadStatus `json:"list"`
+}
+
+func getReloads(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		list := srv.Reloads()
+		if list == nil {
+			list = []ReloadStatus{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: reloadList{
+				Count: len(list),
+				List:  list,
+			},
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/core/logger"
+)
+
+// The client authentications of the TLS of the API and metrics services.
+const (
+	// ClientAuthNone does not ask for client certificates.
+	ClientAuthNone = "none"
+	// ClientAuthRequest verifies the client certificates given, if any.
+	ClientAuthRequest = "request"
+	// ClientAuthRequire requires a verified client certificate.
+	ClientAuthRequire = "require"
+)
+
+// TLS is the TLS of the API or metrics service, the tls block of its config
+// section. The files are read again when they change, for the certificates
+// to be renewed without restart.
+type TLS struct {
+	CertFile string `yaml:"certFile" json:"certFile"`
+	KeyFile  string `yaml:"keyFile" json:"keyFile"`
+	// CAFile is the CA certificates the client certificates are verified
+	// with.
+	CAFile string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+	// ClientAuth is none (the default), request or require. The user of a
+	// request with a verified client certificate is the common name of its
+	// subject, given roles by the grants of the API access.
+	ClientAuth string `yaml:"clientAuth,omitempty" json:"clientAuth,omitempty"`
+}
+
+// Validate checks the files and the client authentication of t.
+func (t *TLS) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("certFile and keyFile are required")
+	}
+	switch t.ClientAuth {
+	case "", ClientAuthNone:
+	case ClientAuthRequest, ClientAuthRequire:
+		if t.CAFile == "" {
+			return fmt.Errorf("clientAuth %s: caFile is required", t.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("unknown clientAuth %q", t.ClientAuth)
+	}
+	return nil
+}
+
+// tlsLoader builds the TLS config of t, built again on handshake when its
+// files have changed.
+type tlsLoader struct {
+	tls     *TLS
+	service string
+
+	mu     sync.Mutex
+	stamps []fileStamp
+	config *tls.Config
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newTLSConfig returns the TLS config of the service, whose certificates
+// are loaded by the handshakes.
+func newTLSConfig(t *TLS, service string) (*tls.Config, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &tlsLoader{
+		tls:     t,
+		service: service,
+	}
+	l.stamps = l.stat()
+	config, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.config = config
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return l.get(), nil
+		},
+	}, nil
+}
+
+func (l *tlsLoader) files() []string {
+	files := []string{l.tls.CertFile, l.tls.KeyFile}
+	if l.tls.CAFile != "" {
+		files = append(files, l.tls.CAFile)
+	}
+	return files
+}
+
+func (l *tlsLoader) stat() []fileStamp {
+	var stamps []fileStamp
+	for _, name := range l.files() {
+		var stamp fileStamp
+		if fi, err := os.Stat(name); err == nil {
+			stamp = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+		}
+		stamps = append(stamps, stamp)
+	}
+	return stamps
+}
+
+// get returns the TLS config, loaded again if the files have changed. The
+// previous one is kept if they can not be loaded, until they change again.
+func (l *tlsLoader) get() *tls.Config {
+	stamps := l.stat()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changed := false
+	for i := range stamps {
+		if !stamps[i].modTime.Equal(l.stamps[i].modTime) || stamps[i].size != l.stamps[i].size {
+			changed = true
+		}
+	}
+	if !changed {
+		return l.config
+	}
+	l.stamps = stamps
+
+	log := logger.Default().WithFields(map[string]any{"kind": "service", "service": l.service})
+	config, err := l.load()
+	if err != nil {
+		log.Errorf("tls: %v", err)
+		return l.config
+	}
+	log.Info("tls: certificates reloaded")
+	l.config = config
+	return config
+}
+
+func (l *tlsLoader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(l.tls.CertFile, l.tls.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	switch l.tls.ClientAuth {
+	case ClientAuthRequest:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if l.tls.CAFile != "" {
+		b, err := os.ReadFile(l.tls.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificate found", l.tls.CAFile)
+		}
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+// certUser returns the user of the verified client certificate of r, if
+// any.
+func certUser(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// authenticate returns the user of r, a request to the management service,
+// and whether it is authenticated. A verified client certificate stands
+// for the password, the other requests are authenticated by auther, if
+// any, with their basic auth. The user is the one of the basic auth if
+// not authenticated.
+func authenticate(r *http.Request, auther auth.Authenticator, service string) (string, bool) {
+	if user := certUser(r); user != "" || auther == nil {
+		return user, true
+	}
+
+	u, p, _ := r.BasicAuth()
+	id, ok := auther.Authenticate(r.Context(), u, p, auth.WithService(service))
+	if ok && id != "" {
+		return id, true
+	}
+	return u, ok
+}
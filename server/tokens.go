@@ -0,0 +1,321 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/x/api"
+)
+
+// tokenPrefix prefixes the secrets of the API tokens.
+const tokenPrefix = "gost_"
+
+// Token is an API token, used as a bearer token. It is allowed what its
+// grants allow, the user of the grants is ignored.
+type Token struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// CreatedBy is the user the token was issued by.
+	CreatedBy string    `json:"createdBy,omitempty"`
+	Grants    []Grant   `json:"grants"`
+	Created   time.Time `json:"created"`
+	// Expires is when the token expires, never if zero.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func (t *Token) expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// IssueToken issues an API token valid for ttl, forever if zero, and
+// returns it with its secret, which is not kept.
+func (s *Server) IssueToken(name string, grants []Grant, ttl time.Duration, createdBy string) (string, Token, error) {
+	if s.tokens == nil {
+		return "", Token{}, errors.New("no API access control")
+	}
+	return s.tokens.issue(name, grants, ttl, createdBy)
+}
+
+// RevokeToken revokes the API token id, it reports whether it existed.
+func (s *Server) RevokeToken(id string) (bool, error) {
+	if s.tokens == nil {
+		return false, nil
+	}
+	return s.tokens.revoke(id)
+}
+
+// Tokens returns the API tokens not expired, the oldest first.
+func (s *Server) Tokens() []Token {
+	if s.tokens == nil {
+		return nil
+	}
+	return s.tokens.list()
+}
+
+// tokenStore keeps the API tokens, with the hashes of their secrets, in a
+// file if path is set.
+type tokenStore struct {
+	path   string
+	mu     sync.Mutex
+	tokens map[string]*storedToken
+}
+
+type storedToken struct {
+	Token
+	Hash string `json:"hash"`
+}
+
+func newTokenStore(path string) (*tokenStore, error) {
+	ts := &tokenStore{
+		path:   path,
+		tokens: make(map[string]*storedToken),
+	}
+	if path == "" {
+		return ts, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*storedToken
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, t := range list {
+		ts.tokens[t.ID] = t
+	}
+	return ts, nil
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+func (ts *tokenStore) issue(name string, grants []Grant, ttl time.Duration, createdBy string) (string, Token, error) {
+	for i := range grants {
+		if err := grants[i].validate(); err != nil {
+			return "", Token{}, fmt.Errorf("grants[%d]: %w", i, err)
+		}
+	}
+	if ttl < 0 {
+		return "", Token{}, errors.New("negative ttl")
+	}
+
+	b := make([]byte, 40)
+	if _, err := rand.Read(b); err != nil {
+		return "", Token{}, err
+	}
+	id := hex.EncodeToString(b[:8])
+	secret := tokenPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(b[8:])
+
+	t := &storedToken{
+		Token: Token{
+			ID:        id,
+			Name:      name,
+			CreatedBy: createdBy,
+			Grants:    grants,
+			Created:   time.Now(),
+		},
+		Hash: hashSecret(secret),
+	}
+	if ttl > 0 {
+		t.Expires = t.Created.Add(ttl)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.tokens[id] = t
+	if err := ts.save(); err != nil {
+		delete(ts.tokens, id)
+		return "", Token{}, err
+	}
+	return secret, t.Token, nil
+}
+
+func (ts *tokenStore) revoke(id string) (bool, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	t, ok := ts.tokens[id]
+	if !ok {
+		return false, nil
+	}
+	delete(ts.tokens, id)
+	if err := ts.save(); err != nil {
+		ts.tokens[id] = t
+		return false, err
+	}
+	return true, nil
+}
+
+// lookup returns the token of secret, if it is valid.
+func (ts *tokenStore) lookup(secret string) *Token {
+	rest, ok := strings.CutPrefix(secret, tokenPrefix)
+	if !ok {
+		return nil
+	}
+	id, _, _ := strings.Cut(rest, "_")
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	t := ts.tokens[id]
+	if t == nil || t.expired(time.Now()) ||
+		subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hashSecret(secret))) != 1 {
+		return nil
+	}
+	return &t.Token
+}
+
+func (ts *tokenStore) list() []Token {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	now := time.Now()
+	var list []Token
+	for _, t := range ts.tokens {
+		if !t.expired(now) {
+			list = append(list, t.Token)
+		}
+	}
+	slices.SortFunc(list, func(a, b Token) int {
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return list
+}
+
+// save writes the tokens not expired to the file of the store, it is
+// called with the lock held.
+func (ts *tokenStore) save() error {
+	if ts.path == "" {
+		return nil
+	}
+
+	now := time.Now()
+	list := []*storedToken{}
+	for id, t := range ts.tokens {
+		if t.expired(now) {
+			delete(ts.tokens, id)
+			continue
+		}
+		list = append(list, t)
+	}
+	slices.SortFunc(list, func(a, b *storedToken) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(ts.path, b, 0600)
+}
+
+type tokenRequest struct {
+	Name   string  `json:"name,omitempty"`
+	Grants []Grant `json:"grants"`
+	// TTL is how long the token is valid, e.g. 24h, forever if empty.
+	TTL string `json:"ttl,omitempty"`
+}
+
+type tokenList struct {
+	Count int     `json:"count"`
+	List  []Token `json:"list"`
+}
+
+type issuedToken struct {
+	Token
+	// Secret is the bearer token, only returned when the token is issued.
+	Secret string `json:"secret"`
+}
+
+func getTokens(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		list := srv.Tokens()
+		if list == nil {
+			list = []Token{}
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Data: tokenList{
+				Count: len(list),
+				List:  list,
+			},
+		})
+	}
+}
+
+func issueToken(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req tokenRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+		if len(req.Grants) == 0 {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "grants are required"))
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, "invalid ttl"))
+				return
+			}
+		}
+
+		secret, t, err := srv.IssueToken(req.Name, req.Grants, ttl, ctx.GetString(userKey))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, api.NewError(http.StatusBadRequest, api.ErrCodeInvalid, err.Error()))
+			return
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg: "OK",
+			Data: issuedToken{
+				Token:  t,
+				Secret: secret,
+			},
+		})
+	}
+}
+
+func revokeToken(srv *Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		ok, err := srv.RevokeToken(id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, api.NewError(http.StatusInternalServerError, api.ErrCodeFailed, err.Error()))
+			return
+		}
+		if !ok {
+			ctx.JSON(http.StatusNotFound, api.NewError(http.StatusNotFound, api.ErrCodeNotFound,
+				fmt.Sprintf("token %s not found", id)))
+			return
+		}
+		ctx.JSON(http.StatusOK, api.Response{
+			Msg: "OK",
+		})
+	}
+}
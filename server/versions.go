@@ -0,0 +1,183 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-gost/x/api"
+	"github.com/go-gost/x/config"
+)
+
+// version returns the ETag of a config, which changes when its normalized
+// form does.
+func version(c any) string {
+	h := sha256.Sum256([]byte(fingerprint(c)))
+	return `"` + hex.EncodeToString(h[:8]) + `"`
+}
+
+// resourceVersion returns the ETag of the resource r of cfg, empty if it
+// does not exist.
+func resourceVersion(cfg *config.Config, r Resource) string {
+	kind := resourceKindOf(r.Kind)
+	if kind == nil {
+		return ""
+	}
+	c, ok := kind.configs(cfg)[r.Name]
+	if !ok {
+		return ""
+	}
+	return version(c)
+}
+
+// Version returns the ETag of the resource r of the running config, empty
+// if it does not exist.
+func (s *Server) Version(r Resource) string {
+	return resourceVersion(config.Global(), r)
+}
+
+// matchETag reports whether etag, empty if the resource does not exist, is
+// matched by the If-Match or If-None-Match header h.
+func matchETag(h, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, v := range strings.Split(h, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed answers that the resource has changed since it was
+// read.
+func preconditionFailed(c *gin.Context, r Resource) {
+	c.JSON(http.StatusPreconditionFailed, api.NewError(http.StatusPreconditionFailed, api.ErrCodeInvalid,
+		fmt.Sprintf("%s has changed", r)))
+	c.Abort()
+}
+
+// mwVersions versions the resources of the config API by ETag: the config,
+// the resource lists, whose responses also carry the version of each
+// resource, and the resources, whose changes honor If-Match and
+// If-None-Match. The changes are serialized by edits.
+func mwVersions(srv *Server, pathPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := strings.TrimPrefix(c.Request.URL.Path, pathPrefix)
+		parts := strings.Split(strings.Trim(path, "/"), "/")
+		if parts[0] != "config" || len(parts) > 3 {
+			return
+		}
+		var target Resource
+		if len(parts) > 1 {
+			if resourceKindOf(parts[1]) == nil {
+				return
+			}
+			target.Kind = parts[1]
+		}
+		if len(parts) > 2 {
+			target.Name = parts[2]
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			getVersioned(c, target)
+			return
+		case http.MethodOptions:
+			return
+		}
+		if target.Kind == "" {
+			return
+		}
+
+		srv.edits.Lock()
+		defer srv.edits.Unlock()
+
+		if target.Name == "" && c.Request.Method == http.MethodPost {
+			target.Name = createdName(c.Request)
+		}
+		etag := srv.Version(target)
+		if h := c.GetHeader("If-Match"); h != "" && !matchETag(h, etag) {
+			preconditionFailed(c, target)
+			return
+		}
+		if h := c.GetHeader("If-None-Match"); h != "" && matchETag(h, etag) {
+			preconditionFailed(c, target)
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.status < http.StatusMultipleChoices {
+			if etag := srv.Version(target); etag != "" {
+				c.Header("ETag", etag)
+			}
+		}
+		w.Header().Del("Content-Length")
+		c.Writer.WriteHeader(w.status)
+		c.Writer.Write(w.buf.Bytes())
+	}
+}
+
+// getVersioned sets the ETag of the config, of a resource list or of a
+// resource read, answering 304 if it matches If-None-Match. The versions
+// of the resources of a list are added to its data, by name.
+func getVersioned(c *gin.Context, target Resource) {
+	cfg := config.Global()
+
+	var etag string
+	var versions map[string]string
+	switch {
+	case target.Kind == "":
+		etag = version(cfg)
+	case target.Name == "":
+		kind := resourceKindOf(target.Kind)
+		etag = version(kind.list(cfg))
+		versions = make(map[string]string)
+		for name, rc := range kind.configs(cfg) {
+			versions[name] = version(rc)
+		}
+	default:
+		etag = resourceVersion(cfg, target)
+	}
+	if etag == "" {
+		return
+	}
+	if h := c.GetHeader("If-None-Match"); h != "" && matchETag(h, etag) {
+		c.Header("ETag", etag)
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Header("ETag", etag)
+	if versions == nil {
+		return
+	}
+
+	w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+
+	body := w.buf.Bytes()
+	var resp map[string]json.RawMessage
+	var data map[string]json.RawMessage
+	if json.Unmarshal(body, &resp) == nil && json.Unmarshal(resp["data"], &data) == nil {
+		data["versions"], _ = json.Marshal(versions)
+		resp["data"], _ = json.Marshal(data)
+		if b, err := json.Marshal(resp); err == nil {
+			body = b
+		}
+	}
+	w.Header().Del("Content-Length")
+	c.Writer.WriteHeader(w.status)
+	c.Writer.Write(body)
+}
@@ -0,0 +1,222 @@
+package e2e
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/stretchr/testify/suite"
+)
+
+// AccessSuite covers the roles, the tokens and the audit log of the API,
+// given with --api-access. gost runs on the host, on the loopback.
+type AccessSuite struct {
+	suite.Suite
+	dir string
+	// api is the address of the API.
+	api string
+}
+
+func (s *AccessSuite) SetupSuite() {
+	s.dir = s.T().TempDir()
+	access := `grants:
+- user: admin
+  role: admin
+- user: alice
+  role: viewer
+- user: alice
+  role: operator
+  resources: [bypasses/bypass-0]
+- user: bob
+  role: viewer
+  resources: [bypasses]
+tokens: ` + filepath.Join(s.dir, "tokens.json") + `
+audit: ` + filepath.Join(s.dir, "audit.log") + `
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "access.yaml"), []byte(access), 0600))
+
+	addrs := freeAddrs(s.T(), "proxy", "api")
+	s.api = addrs["api"]
+	config := filepath.Join(s.dir, "gost.yaml")
+	writeConfig(s.T(), "testdata/access/gost.yaml", config, addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", config,
+		"--api-access", filepath.Join(s.dir, "access.yaml"))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		conn, err := net.Dial("tcp", s.api)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// do makes a request to the API as user, with the password of the same
+// name, or with the bearer token user if it is one.
+func (s *AccessSuite) do(user, method, path, body string) (int, []byte) {
+	req, err := http.NewRequest(method, "http://"+s.api+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	if strings.HasPrefix(user, "gost_") {
+		req.Header.Set("Authorization", "Bearer "+user)
+	} else if user != "" {
+		req.SetBasicAuth(user, user)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	return resp.StatusCode, b
+}
+
+func (s *AccessSuite) status(user, method, path, body string) int {
+	code, _ := s.do(user, method, path, body)
+	return code
+}
+
+// TestRoles verifies that the requests are allowed by the roles of the user
+// on the resources they are about.
+func (s *AccessSuite) TestRoles() {
+	s.Assert().Equal(http.StatusUnauthorized, s.status("", http.MethodGet, "/config", ""))
+	s.Assert().Equal(http.StatusUnauthorized, s.status("mallory", http.MethodGet, "/config", ""))
+
+	s.Assert().Equal(http.StatusOK, s.status("alice", http.MethodGet, "/config", ""))
+	s.Assert().Equal(http.StatusOK, s.status("alice", http.MethodGet, "/connections", ""))
+	s.Assert().Equal(http.StatusOK, s.status("alice", http.MethodPut, "/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com"]}`))
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodPut, "/config/bypasses/bypass-1",
+		`{"name": "bypass-1", "matchers": ["example.org"]}`))
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodPost, "/config/bypasses",
+		`{"name": "bypass-2", "matchers": ["example.net"]}`))
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodDelete, "/config/services/proxy", ""))
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodPost, "/config/reload", ""))
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodGet, "/tokens", ""))
+	s.Assert().Equal(http.StatusOK, s.status("alice", http.MethodPost, "/config/batch",
+		`{"operations": [{"op": "update", "kind": "bypasses", "name": "bypass-0", "config": {"matchers": ["example.com"]}}]}`))
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodPost, "/config/batch",
+		`{"operations": [{"op": "update", "kind": "bypasses", "name": "bypass-0", "config": {"matchers": ["example.com"]}},
+		 {"op": "update", "kind": "bypasses", "name": "bypass-1", "config": {"matchers": ["example.org"]}}]}`))
+
+	s.Assert().Equal(http.StatusForbidden, s.status("bob", http.MethodGet, "/config", ""))
+	s.Assert().Equal(http.StatusOK, s.status("bob", http.MethodGet, "/config/bypasses", ""))
+	s.Assert().Equal(http.StatusOK, s.status("bob", http.MethodGet, "/config/bypasses/bypass-1", ""))
+	s.Assert().Equal(http.StatusForbidden, s.status("bob", http.MethodGet, "/config/services/proxy", ""))
+	s.Assert().Equal(http.StatusForbidden, s.status("bob", http.MethodPut, "/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com"]}`))
+
+	s.Assert().Equal(http.StatusOK, s.status("admin", http.MethodPost, "/config/reload", ""))
+}
+
+// TestTokens verifies that the tokens are allowed what their grants allow
+// until they expire or are revoked, and that their secrets are not kept.
+func (s *AccessSuite) TestTokens() {
+	s.Assert().Equal(http.StatusForbidden, s.status("alice", http.MethodPost, "/tokens",
+		`{"grants": [{"role": "admin"}]}`))
+	s.Assert().Equal(http.StatusBadRequest, s.status("admin", http.MethodPost, "/tokens",
+		`{"grants": [{"role": "root"}]}`))
+
+	issue := func(body string) (string, server.Token) {
+		code, b := s.do("admin", http.MethodPost, "/tokens", body)
+		s.Require().Equal(http.StatusOK, code, string(b))
+		var r struct {
+			Data struct {
+				server.Token
+				Secret string `json:"secret"`
+			} `json:"data"`
+		}
+		s.Require().NoError(json.Unmarshal(b, &r))
+		s.Require().True(strings.HasPrefix(r.Data.Secret, "gost_"))
+		return r.Data.Secret, r.Data.Token
+	}
+
+	secret, t := issue(`{"name": "ci", "grants": [{"role": "operator", "resources": ["bypasses/bypass-1"]}], "ttl": "1h"}`)
+	s.Assert().Equal("admin", t.CreatedBy)
+	s.Assert().WithinDuration(time.Now().Add(time.Hour), t.Expires, time.Minute)
+
+	s.Assert().Equal(http.StatusOK, s.status(secret, http.MethodPut, "/config/bypasses/bypass-1",
+		`{"name": "bypass-1", "matchers": ["example.org"]}`))
+	s.Assert().Equal(http.StatusForbidden, s.status(secret, http.MethodPut, "/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com"]}`))
+	s.Assert().Equal(http.StatusUnauthorized, s.status(secret+"x", http.MethodGet, "/config/bypasses/bypass-1", ""))
+
+	code, b := s.do("admin", http.MethodGet, "/tokens", "")
+	s.Require().Equal(http.StatusOK, code)
+	s.Assert().Contains(string(b), `"name":"ci"`)
+	s.Assert().NotContains(string(b), secret)
+
+	stored, err := os.ReadFile(filepath.Join(s.dir, "tokens.json"))
+	s.Require().NoError(err)
+	s.Assert().Contains(string(stored), t.ID)
+	s.Assert().NotContains(string(stored), secret)
+
+	s.Assert().Equal(http.StatusOK, s.status("admin", http.MethodDelete, "/tokens/"+t.ID, ""))
+	s.Assert().Equal(http.StatusNotFound, s.status("admin", http.MethodDelete, "/tokens/"+t.ID, ""))
+	s.Assert().Equal(http.StatusUnauthorized, s.status(secret, http.MethodGet, "/config/bypasses/bypass-1", ""))
+
+	short, _ := issue(`{"grants": [{"role": "viewer"}], "ttl": "1s"}`)
+	s.Assert().Equal(http.StatusOK, s.status(short, http.MethodGet, "/config", ""))
+	time.Sleep(1500 * time.Millisecond)
+	s.Assert().Equal(http.StatusUnauthorized, s.status(short, http.MethodGet, "/config", ""))
+}
+
+// TestAudit verifies that the mutating calls are recorded with their user
+// and the changes they made.
+func (s *AccessSuite) TestAudit() {
+	s.Require().Equal(http.StatusOK, s.status("alice", http.MethodPut, "/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com", "audit.example"]}`))
+	s.Require().Equal(http.StatusForbidden, s.status("alice", http.MethodDelete, "/config/bypasses/bypass-1", ""))
+
+	code, b := s.do("admin", http.MethodGet, "/audit?size=2", "")
+	s.Require().Equal(http.StatusOK, code)
+	var r struct {
+		Data struct {
+			Count int                 `json:"count"`
+			List  []server.AuditEntry `json:"list"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal(b, &r))
+	s.Require().Len(r.Data.List, 2)
+
+	denied := r.Data.List[0]
+	s.Assert().Equal("alice", denied.User)
+	s.Assert().Equal(http.MethodDelete, denied.Method)
+	s.Assert().Equal(http.StatusForbidden, denied.Status)
+	s.Assert().Empty(denied.Changes)
+
+	e := r.Data.List[1]
+	s.Assert().Equal("alice", e.User)
+	s.Assert().Equal(http.MethodPut, e.Method)
+	s.Assert().Equal("/config/bypasses/bypass-0", e.Path)
+	s.Assert().Equal(http.StatusOK, e.Status)
+	s.Assert().False(e.Time.IsZero())
+	s.Require().Len(e.Changes, 1)
+	s.Assert().Equal("bypasses/bypass-0", e.Changes[0].Resource)
+	s.Assert().Equal("changed", e.Changes[0].Change)
+	s.Assert().NotContains(string(e.Changes[0].Before), "audit.example")
+	s.Assert().Contains(string(e.Changes[0].After), "audit.example")
+
+	log, err := os.ReadFile(filepath.Join(s.dir, "audit.log"))
+	s.Require().NoError(err)
+	s.Assert().Contains(string(log), "audit.example")
+}
+
+func TestAccessSuite(t *testing.T) {
+	suite.Run(t, new(AccessSuite))
+}
@@ -0,0 +1,342 @@
+package e2e
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// APITLSSuite covers the TLS of the API and metrics services, given by the
+// tls blocks of their config sections, and the client certificates mapped
+// to the roles of the API. gost runs on the host, on the loopback.
+type APITLSSuite struct {
+	suite.Suite
+	dir    string
+	config string
+	cmd    *exec.Cmd
+	// api and metrics are the addresses of the API and metrics services.
+	api     string
+	metrics string
+	ca      *testCA
+	// roots trusts the CA of the server certificates.
+	roots *x509.CertPool
+	// ops is a client certificate of the CA, other one of another CA.
+	ops   tls.Certificate
+	other tls.Certificate
+}
+
+// testCA issues the certificates of the suite.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(cn string) (*testCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// issue returns the PEM certificate and key of cn, for a server if
+// server is set, for a client otherwise.
+func (ca *testCA) issue(cn string, server bool) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), nil
+}
+
+func (s *APITLSSuite) keyPair(ca *testCA, cn string) tls.Certificate {
+	certPEM, keyPEM, err := ca.issue(cn, false)
+	s.Require().NoError(err)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	s.Require().NoError(err)
+	return cert
+}
+
+// writeServerCert issues the server certificate of cn to the files of the
+// suite.
+func (s *APITLSSuite) writeServerCert(cn string) {
+	certPEM, keyPEM, err := s.ca.issue(cn, true)
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "server.key"), keyPEM, 0600))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "server.crt"), certPEM, 0600))
+}
+
+func (s *APITLSSuite) SetupSuite() {
+	s.dir = s.T().TempDir()
+
+	var err error
+	s.ca, err = newTestCA("gost test CA")
+	s.Require().NoError(err)
+	other, err := newTestCA("other CA")
+	s.Require().NoError(err)
+
+	s.roots = x509.NewCertPool()
+	s.roots.AddCert(s.ca.cert)
+	s.ops = s.keyPair(s.ca, "ops")
+	s.other = s.keyPair(other, "ops")
+
+	s.writeServerCert("gost")
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "ca.crt"), s.ca.pem, 0600))
+
+	// The TLS blocks are added to the api and metrics sections of the
+	// config, with the files of the suite.
+	addrs := freeAddrs(s.T(), "proxy", "api", "metrics")
+	s.api, s.metrics = addrs["api"], addrs["metrics"]
+	s.config = filepath.Join(s.dir, "gost.yaml")
+	writeConfig(s.T(), "testdata/api-tls/gost.yaml", s.config, addrs)
+	b, err := os.ReadFile(s.config)
+	s.Require().NoError(err)
+	block := func(clientAuth string) string {
+		return "  tls:\n" +
+			"    certFile: " + filepath.Join(s.dir, "server.crt") + "\n" +
+			"    keyFile: " + filepath.Join(s.dir, "server.key") + "\n" +
+			"    caFile: " + filepath.Join(s.dir, "ca.crt") + "\n" +
+			"    clientAuth: " + clientAuth + "\n"
+	}
+	cfg := strings.Replace(string(b), "  auther: api-users\n", "  auther: api-users\n"+block("request"), 1)
+	cfg = strings.Replace(cfg, "  path: /metrics\n", "  path: /metrics\n"+block("require"), 1)
+	s.Require().NoError(os.WriteFile(s.config, []byte(cfg), 0600))
+
+	access := `grants:
+- user: admin
+  role: admin
+- user: ops
+  role: operator
+  resources: [bypasses]
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "access.yaml"), []byte(access), 0600))
+
+	cmd := exec.Command(GostBinPath, "-C", s.config,
+		"--api-access", filepath.Join(s.dir, "access.yaml"))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.cmd = cmd
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		for _, addr := range []string{s.api, s.metrics} {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				return false
+			}
+			conn.Close()
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// apiURL returns the URL of the API.
+func (s *APITLSSuite) apiURL() string {
+	return "https://" + s.api
+}
+
+// metricsURL returns the URL of the metrics.
+func (s *APITLSSuite) metricsURL() string {
+	return "https://" + s.metrics + "/metrics"
+}
+
+// client returns a client trusting the CA of the suite, with the client
+// certificate cert if not nil, on new connections.
+func (s *APITLSSuite) client(cert *tls.Certificate) *http.Client {
+	config := &tls.Config{RootCAs: s.roots}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   config,
+			DisableKeepAlives: true,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// status makes a request with the client, as the basic auth user of the
+// same password if user is set.
+func (s *APITLSSuite) status(client *http.Client, user, method, url, body string) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	s.Require().NoError(err)
+	if user != "" {
+		req.SetBasicAuth(user, user)
+	}
+	resp, err := client.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+// TestAPI verifies that the API is served over TLS only, and that the
+// client certificates stand for the passwords of the users they name.
+func (s *APITLSSuite) TestAPI() {
+	resp, err := http.Get("http://" + s.api + "/config")
+	if s.Assert().NoError(err) {
+		resp.Body.Close()
+		s.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	}
+
+	anonymous := s.client(nil)
+	s.Assert().Equal(http.StatusUnauthorized, s.status(anonymous, "", http.MethodGet, s.apiURL()+"/config", ""))
+	s.Assert().Equal(http.StatusOK, s.status(anonymous, "admin", http.MethodGet, s.apiURL()+"/config", ""))
+
+	ops := s.client(&s.ops)
+	s.Assert().Equal(http.StatusOK, s.status(ops, "", http.MethodGet, s.apiURL()+"/config/bypasses", ""))
+	s.Assert().Equal(http.StatusOK, s.status(ops, "", http.MethodPut, s.apiURL()+"/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com", "example.org"]}`))
+	s.Assert().Equal(http.StatusForbidden, s.status(ops, "", http.MethodGet, s.apiURL()+"/config", ""))
+
+	// The certificates of other CAs are not sent, the request is anonymous.
+	s.Assert().Equal(http.StatusUnauthorized, s.status(s.client(&s.other), "", http.MethodGet, s.apiURL()+"/config/bypasses", ""))
+}
+
+// TestMetrics verifies that the metrics require a client certificate of
+// the CA.
+func (s *APITLSSuite) TestMetrics() {
+	_, err := s.client(nil).Get(s.metricsURL())
+	s.Assert().Error(err)
+	_, err = s.client(&s.other).Get(s.metricsURL())
+	s.Assert().Error(err)
+
+	resp, err := s.client(&s.ops).Get(s.metricsURL())
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Assert().Equal(http.StatusOK, resp.StatusCode)
+	b, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	s.Assert().Contains(string(b), "# HELP")
+}
+
+// TestReload verifies that the certificates are renewed when their files
+// change, without restart.
+func (s *APITLSSuite) TestReload() {
+	peer := func() string {
+		resp, err := s.client(&s.ops).Get(s.apiURL() + "/config/bypasses")
+		if err != nil {
+			return ""
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	s.Require().Equal("gost", peer())
+
+	s.writeServerCert("gost renewed")
+	s.Assert().Eventually(func() bool {
+		return peer() == "gost renewed"
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// TestRolledBackTLS verifies that the API is served with the TLS of the
+// running config again when a reload changing it is rolled back.
+func (s *APITLSSuite) TestRolledBackTLS() {
+	b, err := os.ReadFile(s.config)
+	s.Require().NoError(err)
+	defer os.WriteFile(s.config, b, 0600)
+
+	// The API requires a client certificate, and the metrics service can
+	// not listen, once the API has been restarted.
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer taken.Close()
+	cfg := strings.Replace(string(b), "clientAuth: request", "clientAuth: require", 1)
+	cfg = strings.Replace(cfg, "addr: "+s.metrics, "addr: "+taken.Addr().String(), 1)
+	s.Require().NoError(os.WriteFile(s.config, []byte(cfg), 0600))
+	s.Require().NoError(s.cmd.Process.Signal(syscall.SIGHUP))
+
+	// Without a client certificate, the status of the reload can only be
+	// read once the API has its former TLS back.
+	s.Assert().Eventually(func() bool {
+		req, err := http.NewRequest(http.MethodGet, s.apiURL()+"/reload", nil)
+		s.Require().NoError(err)
+		req.SetBasicAuth("admin", "admin")
+		resp, err := s.client(nil).Do(req)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return strings.Contains(string(b), `"status":"rolledback"`)
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// TestOutput verifies that the config written keeps the tls blocks, which
+// the config types do not have.
+func (s *APITLSSuite) TestOutput() {
+	out, err := exec.Command(GostBinPath, "-C", s.config, "-O", "yaml").Output()
+	s.Require().NoError(err)
+	s.Assert().Equal(2, strings.Count(string(out), "certFile: "+filepath.Join(s.dir, "server.crt")))
+	s.Assert().Contains(string(out), "clientAuth: request")
+	s.Assert().Contains(string(out), "clientAuth: require")
+}
+
+func TestAPITLSSuite(t *testing.T) {
+	suite.Run(t, new(APITLSSuite))
+}
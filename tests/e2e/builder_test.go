@@ -0,0 +1,88 @@
+package e2e
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/builder"
+	"github.com/stretchr/testify/suite"
+)
+
+// BuilderSuite covers the configs built with the builder package. No
+// socket is bound, so gost runs on the host.
+type BuilderSuite struct {
+	suite.Suite
+}
+
+// TestSameAsParsed verifies that the built config is the one parsed from
+// the equivalent config file.
+func (s *BuilderSuite) TestSameAsParsed() {
+	bp := builder.Bypass("bp").Matchers("10.0.0.0/8")
+
+	cfg, err := builder.New().
+		Service(
+			builder.Service("proxy").
+				Listen(":8080").
+				Listener(builder.Listener("tcp")).
+				Handler(builder.HTTP().Auth("user", "pass")).
+				Bypass(bp).
+				Chain(builder.Chain("chain-0").Hop(
+					builder.Hop("hop-0").
+						Selector("fifo", 1, 10*time.Second).
+						Node(builder.Node("node-0", "192.168.1.1:1080").
+							Connector(builder.Connector("socks5").Auth("user", "pass")).
+							Dialer(builder.Dialer("tls"))),
+					builder.HopRef("hop-1"),
+				)),
+			builder.Service("forward").
+				Listen(":2222").
+				Listener(builder.Listener("tcp")).
+				Handler(builder.Handler("tcp")).
+				Forward("192.168.1.2:22"),
+		).
+		Hop(builder.Hop("hop-1").
+			Node(builder.Node("node-0", "192.168.1.1:8443").
+				Connector(builder.Connector("http")).
+				Dialer(builder.Dialer("tcp")))).
+		Bypass(bp).
+		API(":18080").
+		Log("debug").
+		Build()
+	s.Require().NoError(err)
+
+	var built bytes.Buffer
+	s.Require().NoError(cfg.Write(&built, "json"))
+
+	parsed, err := exec.Command(GostBinPath, "-C", "testdata/builder/gost.yaml", "-O", "json").Output()
+	s.Require().NoError(err)
+
+	s.Assert().JSONEq(string(parsed), built.String())
+}
+
+// TestReferences verifies that the references to undefined resources and
+// the names defined twice are reported by Build.
+func (s *BuilderSuite) TestReferences() {
+	_, err := builder.New().
+		Service(builder.Service("proxy").
+			Listen(":8080").
+			Handler(builder.HTTP().Auther(builder.AutherRef("users"))).
+			Chain(builder.ChainRef("chain-0")).
+			Bypass(builder.Bypass("bp"))).
+		Bypass(builder.Bypass("bp")).
+		Build()
+	s.Require().Error(err)
+
+	for _, want := range []string{
+		"services/proxy: handler.chain: reference to undefined chains/chain-0",
+		"services/proxy: handler.auther: reference to undefined authers/users",
+		"bypasses/bp: defined twice",
+	} {
+		s.Assert().Contains(err.Error(), want)
+	}
+}
+
+func TestBuilderSuite(t *testing.T) {
+	suite.Run(t, new(BuilderSuite))
+}
@@ -0,0 +1,225 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/builder"
+	"github.com/go-gost/gost/client"
+	_ "github.com/go-gost/gost/components"
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/api"
+	"github.com/go-gost/x/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// ClientSuite covers the API client generated from the OpenAPI document of
+// the API, against a server running in the test process, on the loopback,
+// with every endpoint of the API enabled.
+type ClientSuite struct {
+	suite.Suite
+	srv    *server.Server
+	client *client.Client
+	// api is the URL of the API.
+	api string
+}
+
+func (s *ClientSuite) SetupSuite() {
+	api := freeAddr(s.T())
+	s.api = "http://" + api + "/api"
+
+	cfg, err := builder.New().
+		Service(builder.Service("proxy").
+			Listen("127.0.0.1:0").
+			Listener(builder.Listener("tcp")).
+			Handler(builder.HTTP()).
+			Bypass(builder.Bypass("bypass-0").Matchers("example.com"))).
+		API(api).
+		Build()
+	s.Require().NoError(err)
+	cfg.API.PathPrefix = "/api"
+
+	dir := s.T().TempDir()
+	file := filepath.Join(dir, "gost.yaml")
+	f, err := os.Create(file)
+	s.Require().NoError(err)
+	s.Require().NoError(cfg.Write(f, "yaml"))
+	s.Require().NoError(f.Close())
+
+	s.srv = server.New(cfg,
+		server.AccessOption(&server.Access{
+			Grants: []server.Grant{{User: "*", Role: server.RoleAdmin}},
+			Tokens: filepath.Join(dir, "tokens.json"),
+			Audit:  filepath.Join(dir, "audit.log"),
+		}),
+		server.PersistOption(&server.Persist{File: file}),
+	)
+	s.Require().NoError(s.srv.Start(context.Background()))
+	s.T().Cleanup(func() { s.srv.Close() })
+
+	s.client = client.New(s.api)
+	s.Require().Eventually(func() bool {
+		_, err := s.client.GetReloadStatus(context.Background())
+		return err == nil
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// apiError returns the API error err.
+func (s *ClientSuite) apiError(err error) *client.Error {
+	var e *client.Error
+	s.Require().True(errors.As(err, &e), "not an API error: %v", err)
+	return e
+}
+
+// TestResources verifies the operations on the resources of the config,
+// conditioned by their ETags.
+func (s *ClientSuite) TestResources() {
+	ctx := context.Background()
+	c := s.client
+
+	s.Require().NoError(c.CreateBypass(ctx, &config.BypassConfig{
+		Name:     "bypass-1",
+		Matchers: []string{"example.org"},
+	}, client.IfNoneMatchOption("*")))
+	err := c.CreateBypass(ctx, &config.BypassConfig{Name: "bypass-1"}, client.IfNoneMatchOption("*"))
+	s.Assert().Equal(http.StatusPreconditionFailed, s.apiError(err).StatusCode)
+
+	var etag string
+	bypass, err := c.GetBypass(ctx, "bypass-1", client.ETagOption(&etag))
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"example.org"}, bypass.Matchers)
+	s.Require().NotEmpty(etag)
+
+	bypass.Matchers = append(bypass.Matchers, "example.net")
+	s.Require().NoError(c.UpdateBypass(ctx, "bypass-1", bypass, client.IfMatchOption(etag)))
+	err = c.UpdateBypass(ctx, "bypass-1", bypass, client.IfMatchOption(etag))
+	s.Assert().Equal(http.StatusPreconditionFailed, s.apiError(err).StatusCode)
+
+	list, err := c.GetBypassList(ctx)
+	s.Require().NoError(err)
+	s.Assert().Equal(2, list.Count)
+	s.Assert().Len(list.List, 2)
+	s.Assert().Contains(list.Versions, "bypass-1")
+	s.Assert().NotEqual(etag, list.Versions["bypass-1"])
+
+	svc, err := c.GetService(ctx, "proxy")
+	s.Require().NoError(err)
+	s.Assert().Equal("bypass-0", svc.Bypass)
+
+	cfg, err := c.GetConfig(ctx, &client.GetConfigParams{Format: "yaml"})
+	s.Require().NoError(err)
+	s.Assert().Len(cfg.Bypasses, 2)
+
+	s.Require().NoError(c.DeleteBypass(ctx, "bypass-1"))
+	err = c.DeleteBypass(ctx, "bypass-1")
+	s.Assert().Equal(api.ErrCodeNotFound, s.apiError(err).Code)
+}
+
+// TestRuntime verifies the runtime operations: the batches of changes,
+// their events, revisions and audit, the connections and the tokens.
+func (s *ClientSuite) TestRuntime() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := s.client
+
+	events, err := c.StreamEvents(ctx, &client.StreamEventsParams{Type: []string{"reload"}})
+	s.Require().NoError(err)
+	defer events.Close()
+
+	result, err := c.BatchConfig(ctx, &client.BatchRequest{Operations: []client.BatchOp{{
+		Op:     "update",
+		Kind:   "bypasses",
+		Name:   "bypass-0",
+		Config: []byte(`{"matchers": ["example.com", "batch.example"]}`),
+	}}})
+	s.Require().NoError(err)
+	s.Assert().Contains(result.Versions, "bypasses/bypass-0")
+
+	e, err := events.Next()
+	s.Require().NoError(err)
+	s.Assert().Equal(server.EventReloadSucceeded, e.Type)
+	s.Assert().Contains(e.Changes, "bypasses")
+
+	status, err := c.GetReloadStatus(ctx)
+	s.Require().NoError(err)
+	s.Assert().Equal("success", status.Status)
+
+	revisions, err := c.GetRevisions(ctx)
+	s.Require().NoError(err)
+	s.Require().NotEmpty(revisions.List)
+	s.Assert().Equal(server.RevisionAPI, revisions.List[0].Source)
+	diff, err := c.DiffRevisions(ctx, nil)
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(diff.Changes)
+
+	audit, err := c.GetAuditLog(ctx, &client.GetAuditLogParams{Size: 1})
+	s.Require().NoError(err)
+	s.Require().Len(audit.List, 1)
+	s.Assert().Equal("/api/config/batch", audit.List[0].Path)
+
+	conns, err := c.GetConnections(ctx, &client.GetConnectionsParams{Service: "proxy"})
+	s.Require().NoError(err)
+	s.Assert().Equal(0, conns.Count)
+	_, err = c.CloseConnections(ctx, nil)
+	s.Assert().Equal(http.StatusBadRequest, s.apiError(err).StatusCode)
+
+	token, err := c.IssueToken(ctx, &client.TokenRequest{
+		Name:   "viewer",
+		Grants: []client.Grant{{Role: server.RoleViewer}},
+		TTL:    "1h",
+	})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(token.Secret)
+	viewer := client.New(s.api, client.TokenOption(token.Secret))
+	_, err = viewer.GetDrainStatus(ctx)
+	s.Assert().NoError(err)
+	err = viewer.DeleteBypass(ctx, "bypass-0")
+	s.Assert().Equal(http.StatusForbidden, s.apiError(err).StatusCode)
+	s.Require().NoError(c.RevokeToken(ctx, token.ID))
+}
+
+// TestDocument verifies that the OpenAPI document served describes the
+// API served, and that the client has the operations it describes.
+func (s *ClientSuite) TestDocument() {
+	doc, err := s.client.GetOpenAPI(context.Background())
+	s.Require().NoError(err)
+	s.Require().Contains(doc, "paths")
+
+	var paths map[string]map[string]struct {
+		OperationID string `json:"operationId"`
+	}
+	s.Require().NoError(json.Unmarshal(doc["paths"], &paths))
+	s.Require().NotEmpty(paths)
+
+	clientType := reflect.TypeFor[*client.Client]()
+	for path, ops := range paths {
+		for method, op := range ops {
+			name := strings.ToUpper(op.OperationID[:1]) + op.OperationID[1:]
+			_, ok := clientType.MethodByName(name)
+			s.Assert().True(ok, "no method %s for %s %s", name, method, path)
+		}
+
+		if _, ok := ops["get"]; !ok || strings.Contains(path, "{") || path == "/events" {
+			continue
+		}
+		// Served by the API, not answered by the 404 of the unknown
+		// routes.
+		resp, err := http.Get(s.api + path)
+		s.Require().NoError(err)
+		resp.Body.Close()
+		s.Assert().Less(resp.StatusCode, http.StatusInternalServerError, "GET %s", path)
+		s.Assert().Contains(resp.Header.Get("Content-Type"), "application/json", "GET %s", path)
+	}
+}
+
+func TestClientSuite(t *testing.T) {
+	suite.Run(t, new(ClientSuite))
+}
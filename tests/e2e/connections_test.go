@@ -0,0 +1,209 @@
+package e2e
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/stretchr/testify/suite"
+)
+
+// ConnectionsSuite covers the connection inventory of the API. gost and a
+// TLS backend run on the host, on the loopback.
+type ConnectionsSuite struct {
+	suite.Suite
+	backend *httptest.Server
+	// api, proxy and upstream are the addresses of the API and of the
+	// services.
+	api      string
+	proxy    string
+	upstream string
+}
+
+func (s *ConnectionsSuite) SetupSuite() {
+	s.backend = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.T().Cleanup(s.backend.Close)
+
+	addrs := freeAddrs(s.T(), "api", "proxy", "upstream")
+	s.api, s.proxy, s.upstream = "http://"+addrs["api"], addrs["proxy"], addrs["upstream"]
+	config := filepath.Join(s.T().TempDir(), "gost.yaml")
+	writeConfig(s.T(), "testdata/connections/gost.yaml", config, addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", config)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		conn, err := net.Dial("tcp", s.proxy)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// connect opens a TLS connection to the backend, with the server name
+// backend.test, tunneled through the proxy.
+func (s *ConnectionsSuite) connect() *tls.Conn {
+	conn, err := net.Dial("tcp", s.proxy)
+	s.Require().NoError(err)
+
+	target := s.backend.Listener.Addr().String()
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\nProxy-Authorization: Basic %s\r\n\r\n",
+		target, target, base64.StdEncoding.EncodeToString([]byte("user:pass")))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	tc := tls.Client(conn, &tls.Config{ServerName: "backend.test", InsecureSkipVerify: true})
+	s.Require().NoError(tc.Handshake())
+	s.T().Cleanup(func() { tc.Close() })
+	return tc
+}
+
+func (s *ConnectionsSuite) list(query string) (int, []server.Session) {
+	resp, err := http.Get(s.api + "/connections?" + query)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var r struct {
+		Data struct {
+			Count int              `json:"count"`
+			List  []server.Session `json:"list"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&r))
+	return r.Data.Count, r.Data.List
+}
+
+func (s *ConnectionsSuite) delete(path string) int {
+	req, err := http.NewRequest(http.MethodDelete, s.api+path, nil)
+	s.Require().NoError(err)
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// closed reports whether the server side of conn is closed.
+func closed(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	return err != nil && !os.IsTimeout(err)
+}
+
+// TestInventory verifies that a session is listed with its client, user,
+// target, route, sniffed protocol and bytes, and that the list is filtered
+// and paginated.
+func (s *ConnectionsSuite) TestInventory() {
+	tc := s.connect()
+	defer tc.Close()
+
+	count, list := s.list("service=proxy")
+	s.Require().Equal(1, count)
+	sess := list[0]
+	s.Assert().NotEmpty(sess.ID)
+	s.Assert().Equal(tc.LocalAddr().String(), sess.Client)
+	s.Assert().Equal("user", sess.User)
+	s.Assert().Equal(s.backend.Listener.Addr().String(), sess.Host)
+	s.Assert().Equal("chain-0", sess.Chain)
+	s.Assert().Equal([]server.SessionNode{{Hop: "hop-0", Node: "node-0", Addr: s.upstream}}, sess.Path)
+	s.Assert().Equal("tls", sess.Proto)
+	s.Assert().Equal("backend.test", sess.SNI)
+	s.Assert().NotZero(sess.InputBytes)
+	s.Assert().NotZero(sess.OutputBytes)
+	s.Assert().False(sess.Start.IsZero())
+
+	count, _ = s.list("service=proxy&host=backend.test")
+	s.Assert().Equal(1, count)
+	count, _ = s.list("user=nobody")
+	s.Assert().Zero(count)
+
+	// The upstream service handles the connection of the chain.
+	count, list = s.list("size=1&page=2")
+	s.Assert().Equal(2, count)
+	s.Assert().Len(list, 1)
+	count, list = s.list("size=1&page=3")
+	s.Assert().Equal(2, count)
+	s.Assert().Empty(list)
+}
+
+// TestKill verifies that sessions are closed by ID and by filter, and that
+// a filter is required.
+func (s *ConnectionsSuite) TestKill() {
+	tc := s.connect()
+	_, list := s.list("client=" + tc.LocalAddr().String())
+	s.Require().Len(list, 1)
+
+	s.Assert().Equal(http.StatusOK, s.delete("/connections/"+list[0].ID))
+	s.Assert().True(closed(tc))
+	s.Assert().Equal(http.StatusNotFound, s.delete("/connections/"+list[0].ID))
+
+	tc = s.connect()
+	s.Assert().Equal(http.StatusBadRequest, s.delete("/connections"))
+	s.Assert().Equal(http.StatusOK, s.delete("/connections?user=user"))
+	s.Assert().True(closed(tc))
+
+	s.Require().Eventually(func() bool {
+		count, _ := s.list("")
+		return count == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// TestHalfClose verifies that the sessions keep the half-close of the
+// connections: the backend still replies once the client is done sending.
+func (s *ConnectionsSuite) TestHalfClose() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		conn.Write(append(b, " done"...))
+	}()
+
+	conn, err := net.Dial("tcp", s.proxy)
+	s.Require().NoError(err)
+	defer conn.Close()
+	target := ln.Addr().String()
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\nProxy-Authorization: Basic %s\r\n\r\n",
+		target, target, base64.StdEncoding.EncodeToString([]byte("user:pass")))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	fmt.Fprint(conn, "request")
+	s.Require().NoError(conn.(*net.TCPConn).CloseWrite())
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b, err := io.ReadAll(br)
+	s.Require().NoError(err)
+	s.Assert().Equal("request done", string(b))
+}
+
+func TestConnectionsSuite(t *testing.T) {
+	suite.Run(t, new(ConnectionsSuite))
+}
@@ -0,0 +1,137 @@
+package e2e
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/controlplane"
+	"github.com/go-gost/x/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// ControlPlaneSuite covers an instance subscribed with --subscribe to the
+// reference management server, which runs in the test process. gost runs
+// on the host, on the loopback.
+type ControlPlaneSuite struct {
+	suite.Suite
+	cp      *controlplane.Server
+	backend *httptest.Server
+	// proxy is the address of the service of the management server,
+	// static the one of the service given on the command line.
+	proxy  string
+	static string
+}
+
+func (s *ControlPlaneSuite) SetupSuite() {
+	s.proxy, s.static = freeAddr(s.T()), freeAddr(s.T())
+
+	s.backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello-gost")
+	}))
+	s.T().Cleanup(s.backend.Close)
+
+	s.cp = controlplane.NewServer(controlplane.WaitTimeoutOption(time.Second))
+	cpServer := httptest.NewServer(s.cp)
+	s.T().Cleanup(cpServer.Close)
+
+	s.Require().NoError(s.cp.Set("services", "proxy", &config.ServiceConfig{
+		Addr:     s.proxy,
+		Handler:  &config.HandlerConfig{Type: "http"},
+		Listener: &config.ListenerConfig{Type: "tcp"},
+	}))
+
+	cmd := exec.Command(GostBinPath,
+		"-L", "http://"+s.static,
+		"--subscribe", cpServer.URL,
+		"--node", "edge-1")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+}
+
+// acked waits for the instance to acknowledge the current version.
+func (s *ControlPlaneSuite) acked() controlplane.NodeStatus {
+	var st controlplane.NodeStatus
+	s.Require().Eventually(func() bool {
+		st = s.cp.Nodes()["edge-1"]
+		return st.Version == s.cp.Version()
+	}, 10*time.Second, 100*time.Millisecond)
+	return st
+}
+
+// rejected waits for the instance to reject the current version.
+func (s *ControlPlaneSuite) rejected() controlplane.NodeStatus {
+	var st controlplane.NodeStatus
+	s.Require().Eventually(func() bool {
+		st = s.cp.Nodes()["edge-1"]
+		return st.Rejected == s.cp.Version()
+	}, 10*time.Second, 100*time.Millisecond)
+	return st
+}
+
+func (s *ControlPlaneSuite) get(proxyAddr string) int {
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: proxyAddr})},
+		Timeout:   5 * time.Second,
+	}
+	resp, err := client.Get(s.backend.URL)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestUpdates verifies that the resources are added, changed and removed
+// as they are updated on the server, that an update failing to apply is
+// rejected and leaves the running config as it was, and that the
+// resources of the config sources are kept.
+func (s *ControlPlaneSuite) TestUpdates() {
+	s.acked()
+	s.Assert().Equal(http.StatusOK, s.get(s.proxy))
+	s.Assert().Equal(http.StatusOK, s.get(s.static))
+
+	s.Require().NoError(s.cp.Set("services", "proxy", &config.ServiceConfig{
+		Addr: s.proxy,
+		Handler: &config.HandlerConfig{
+			Type: "http",
+			Auth: &config.AuthConfig{Username: "user", Password: "pass"},
+		},
+		Listener: &config.ListenerConfig{Type: "tcp"},
+	}))
+	s.acked()
+	s.Assert().Equal(http.StatusProxyAuthRequired, s.get(s.proxy))
+
+	// The handler type is unknown, the reload fails.
+	s.Require().NoError(s.cp.Set("services", "bad", &config.ServiceConfig{
+		Addr:     freeAddr(s.T()),
+		Handler:  &config.HandlerConfig{Type: "missing"},
+		Listener: &config.ListenerConfig{Type: "tcp"},
+	}))
+	st := s.rejected()
+	s.Assert().Contains(st.Error, "missing")
+	s.Assert().Equal(http.StatusProxyAuthRequired, s.get(s.proxy))
+
+	// The next version is applied from the last acknowledged one.
+	s.Require().True(s.cp.Delete("services", "bad"))
+	s.Require().True(s.cp.Delete("services", "proxy"))
+	st = s.acked()
+	s.Assert().Empty(st.Error)
+
+	_, err := net.DialTimeout("tcp", s.proxy, time.Second)
+	s.Assert().Error(err)
+	s.Assert().Equal(http.StatusOK, s.get(s.static))
+}
+
+func TestControlPlaneSuite(t *testing.T) {
+	suite.Run(t, new(ControlPlaneSuite))
+}
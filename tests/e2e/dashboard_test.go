@@ -0,0 +1,175 @@
+package e2e
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/stretchr/testify/suite"
+)
+
+// DashboardSuite covers the web dashboard of the API and the endpoints it
+// is built on. gost runs on the host, on the loopback, with the API
+// behind basic auth.
+type DashboardSuite struct {
+	suite.Suite
+	// api is the URL of the API, proxy the address of the service and node
+	// the one of its node, which nothing listens on.
+	api   string
+	proxy string
+	node  string
+}
+
+func (s *DashboardSuite) SetupSuite() {
+	addrs := freeAddrs(s.T(), "api", "proxy", "node")
+	s.api, s.proxy, s.node = "http://"+addrs["api"]+"/api", addrs["proxy"], addrs["node"]
+	config := filepath.Join(s.T().TempDir(), "gost.yaml")
+	writeConfig(s.T(), "testdata/dashboard/gost.yaml", config, addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", config)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get(s.api + "/reload")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// request makes a request to the API, with the credentials if auth is
+// set, and returns the response with its body read.
+func (s *DashboardSuite) request(method, path string, auth bool) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, s.api+path, nil)
+	s.Require().NoError(err)
+	if auth {
+		req.SetBasicAuth("admin", "secret")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	return resp, body
+}
+
+// list gets a list of the API into v.
+func (s *DashboardSuite) list(path string, v any) {
+	resp, body := s.request(http.MethodGet, path, true)
+	s.Require().Equal(http.StatusOK, resp.StatusCode, string(body))
+	var r struct {
+		Data struct {
+			List json.RawMessage `json:"list"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal(body, &r))
+	s.Require().NoError(json.Unmarshal(r.Data.List, v))
+}
+
+// TestAssets verifies that the assets of the dashboard are served under
+// the path prefix of the API, with its authentication.
+func (s *DashboardSuite) TestAssets() {
+	resp, _ := s.request(http.MethodGet, "/ui/", false)
+	s.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	resp, body := s.request(http.MethodGet, "/ui/", true)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Assert().Contains(resp.Header.Get("Content-Type"), "text/html")
+	s.Assert().Equal("DENY", resp.Header.Get("X-Frame-Options"))
+	s.Assert().Contains(string(body), `src="app.js"`)
+
+	for _, asset := range []string{"/ui/app.js", "/ui/style.css"} {
+		resp, body = s.request(http.MethodGet, asset, true)
+		s.Assert().Equal(http.StatusOK, resp.StatusCode, asset)
+		s.Assert().NotEmpty(body, asset)
+	}
+	resp, _ = s.request(http.MethodGet, "/ui/missing.js", true)
+	s.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+// TestNodes verifies that a node failing to connect is reported failed
+// once a connection is routed through it.
+func (s *DashboardSuite) TestNodes() {
+	get(s.proxy)
+
+	var nodes []server.NodeStatus
+	s.Require().Eventually(func() bool {
+		s.list("/nodes", &nodes)
+		return len(nodes) > 0 && nodes[0].Failed
+	}, 5*time.Second, 100*time.Millisecond)
+	s.Require().Len(nodes, 1)
+	n := nodes[0]
+	s.Assert().Equal("chain-0", n.Chain)
+	s.Assert().Equal("hop-0", n.Hop)
+	s.Assert().Equal("node-0", n.Node)
+	s.Assert().Equal(s.node, n.Addr)
+	s.Assert().Equal("fails", n.Reason)
+	s.Assert().GreaterOrEqual(n.Fails, int64(1))
+}
+
+// TestReloads verifies the history of the reloads, the newest first.
+func (s *DashboardSuite) TestReloads() {
+	resp, body := s.request(http.MethodPost, "/config/reload", true)
+	s.Require().Equal(http.StatusOK, resp.StatusCode, string(body))
+
+	var reloads []server.ReloadStatus
+	s.list("/reloads", &reloads)
+	s.Require().GreaterOrEqual(len(reloads), 2)
+	s.Assert().Equal("success", reloads[0].Status)
+	s.Assert().False(reloads[0].Time.Before(reloads[len(reloads)-1].Time))
+}
+
+// TestTunnels verifies that the tunnels are listed, with no tunnel handler
+// running.
+func (s *DashboardSuite) TestTunnels() {
+	var tunnels []server.TunnelConnector
+	s.list("/tunnels", &tunnels)
+	s.Assert().Empty(tunnels)
+
+	resp, body := s.request(http.MethodGet, "/tunnels", false)
+	s.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	s.Assert().False(strings.Contains(string(body), "list"))
+}
+
+// TestCORS verifies that the runtime endpoints allow the cross-origin
+// requests, as the config API does.
+func (s *DashboardSuite) TestCORS() {
+	req, err := http.NewRequest(http.MethodOptions, s.api+"/nodes", nil)
+	s.Require().NoError(err)
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Assert().Equal(http.StatusNoContent, resp.StatusCode)
+	s.Assert().Equal("*", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	req, err = http.NewRequest(http.MethodGet, s.api+"/nodes", nil)
+	s.Require().NoError(err)
+	req.Header.Set("Origin", "http://example.com")
+	req.SetBasicAuth("admin", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Assert().Equal(http.StatusOK, resp.StatusCode)
+	s.Assert().Equal("*", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestDashboardSuite(t *testing.T) {
+	suite.Run(t, new(DashboardSuite))
+}
@@ -0,0 +1,208 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/gost/builder"
+	_ "github.com/go-gost/gost/components"
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+	"github.com/stretchr/testify/suite"
+)
+
+// echoHandler echoes the connections, recording whether it is closed. Its
+// Init fails with the metadata fail.
+type echoHandler struct {
+	service string
+	closed  atomic.Bool
+}
+
+func (h *echoHandler) Init(md metadata.Metadata) error {
+	if md != nil && md.IsExists("fail") {
+		return errors.New("init failed")
+	}
+	return nil
+}
+
+func (h *echoHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+	_, err := io.Copy(conn, conn)
+	return err
+}
+
+func (h *echoHandler) Close() error {
+	h.closed.Store(true)
+	return nil
+}
+
+// DrainSuite covers the draining of the connections of the services closed
+// by a reload, against a server running in the test process, on the
+// loopback, with an echo handler.
+type DrainSuite struct {
+	suite.Suite
+	mu       sync.Mutex
+	handlers []*echoHandler
+}
+
+// registerEcho registers the e2e-echo handler, calling built with each
+// handler it builds.
+func registerEcho(built func(h *echoHandler)) {
+	registry.HandlerRegistry().Register("e2e-echo", func(opts ...handler.Option) handler.Handler {
+		var options handler.Options
+		for _, opt := range opts {
+			opt(&options)
+		}
+		h := &echoHandler{service: options.Service}
+		built(h)
+		return h
+	})
+}
+
+func (s *DrainSuite) SetupSuite() {
+	registerEcho(func(h *echoHandler) {
+		s.mu.Lock()
+		s.handlers = append(s.handlers, h)
+		s.mu.Unlock()
+	})
+}
+
+func (s *DrainSuite) TearDownSuite() {
+	registry.HandlerRegistry().Unregister("e2e-echo")
+}
+
+func (s *DrainSuite) SetupTest() {
+	s.mu.Lock()
+	s.handlers = nil
+	s.mu.Unlock()
+}
+
+// built returns the handlers built for service, the oldest first.
+func (s *DrainSuite) built(service string) []*echoHandler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var handlers []*echoHandler
+	for _, h := range s.handlers {
+		if h.service == service {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+// echoConfig returns a config with the echo service, its handler with the
+// metadata key set to value.
+func (s *DrainSuite) echoConfig(key string, value any) *config.Config {
+	cfg, err := builder.New().
+		Service(builder.Service("echo").
+			Listen("127.0.0.1:0").
+			Listener(builder.Listener("tcp")).
+			Handler(builder.Handler("e2e-echo").Metadata(key, value))).
+		Build()
+	s.Require().NoError(err)
+	return cfg
+}
+
+func (s *DrainSuite) start(cfg *config.Config, drain time.Duration) *server.Server {
+	srv := server.New(cfg, server.DrainTimeoutOption(drain))
+	s.Require().NoError(srv.Start(context.Background()))
+	s.T().Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// dial connects to the echo service of srv.
+func (s *DrainSuite) dial(srv *server.Server) net.Conn {
+	svc, ok := srv.Resource(server.Resource{Kind: "services", Name: "echo"}).(service.Service)
+	s.Require().True(ok, "no service echo")
+
+	conn, err := net.DialTimeout("tcp", svc.Addr().String(), 5*time.Second)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// echoes reports whether msg is echoed on conn.
+func echoes(conn net.Conn, msg string) bool {
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := io.WriteString(conn, msg); err != nil {
+		return false
+	}
+	b := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return false
+	}
+	return string(b) == msg
+}
+
+// TestReload verifies that a connection of a service replaced by a reload
+// keeps being served, and that its handler is only closed once the
+// connection is finished.
+func (s *DrainSuite) TestReload() {
+	srv := s.start(s.echoConfig("version", 1), 10*time.Second)
+	conn := s.dial(srv)
+	s.Require().True(echoes(conn, "before"))
+
+	_, err := srv.Reload(s.echoConfig("version", 2))
+	s.Require().NoError(err)
+	handlers := s.built("echo")
+	s.Require().Len(handlers, 2)
+	old := handlers[0]
+
+	s.Assert().True(echoes(conn, "after"))
+	s.Assert().False(old.closed.Load(), "handler closed under a live connection")
+	s.Assert().True(echoes(s.dial(srv), "new"))
+
+	conn.Close()
+	s.Assert().Eventually(old.closed.Load, 5*time.Second, 50*time.Millisecond)
+	s.Assert().False(handlers[1].closed.Load())
+}
+
+// TestDeadline verifies that the connections left when the drain timeout
+// is over are closed, and their handler with them.
+func (s *DrainSuite) TestDeadline() {
+	srv := s.start(s.echoConfig("version", 1), 500*time.Millisecond)
+	conn := s.dial(srv)
+	s.Require().True(echoes(conn, "before"))
+
+	_, err := srv.Reload(s.echoConfig("version", 2))
+	s.Require().NoError(err)
+	old := s.built("echo")[0]
+	s.Assert().False(old.closed.Load())
+
+	s.Assert().Eventually(old.closed.Load, 5*time.Second, 50*time.Millisecond)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+// TestFailedService verifies that the handler of a service failing to be
+// built is closed.
+func (s *DrainSuite) TestFailedService() {
+	srv := s.start(s.echoConfig("version", 1), time.Second)
+
+	_, err := srv.Reload(s.echoConfig("fail", true))
+	s.Require().Error(err)
+	s.Assert().Equal("rolledback", srv.LastReload().Status)
+
+	handlers := s.built("echo")
+	s.Require().Len(handlers, 3)
+	s.Assert().True(handlers[1].closed.Load(), "handler of the failed service not closed")
+	s.Assert().True(echoes(s.dial(srv), "rolled back"))
+}
+
+func TestDrainSuite(t *testing.T) {
+	suite.Run(t, new(DrainSuite))
+}
@@ -0,0 +1,278 @@
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+	"github.com/stretchr/testify/suite"
+)
+
+// EventsSuite covers the event stream of the API. gost runs on the host,
+// on the loopback, with a copy of the config the reloads are made with.
+type EventsSuite struct {
+	suite.Suite
+	config string
+	orig   []byte
+	// addrs are the addresses of the config by name, api the URL of the
+	// API.
+	addrs map[string]string
+	api   string
+}
+
+func (s *EventsSuite) SetupSuite() {
+	s.addrs = freeAddrs(s.T(), "api", "proxy", "denied", "limited", "chained", "node", "tunnel")
+	s.api = "http://" + s.addrs["api"]
+	s.config = filepath.Join(s.T().TempDir(), "gost.yaml")
+	writeConfig(s.T(), "testdata/events/gost.yaml", s.config, s.addrs)
+	var err error
+	s.orig, err = os.ReadFile(s.config)
+	s.Require().NoError(err)
+
+	cmd := exec.Command(GostBinPath, "-C", s.config)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get(s.api + "/reload")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// stream subscribes to the events selected by query, the events are
+// received until the end of the test.
+func (s *EventsSuite) stream(query string, lastEventID uint64) <-chan server.Event {
+	req, err := http.NewRequest(http.MethodGet, s.api+"/events?"+query, nil)
+	s.Require().NoError(err)
+	if lastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(lastEventID, 10))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	s.T().Cleanup(func() { resp.Body.Close() })
+
+	ch := make(chan server.Event, 64)
+	go func() {
+		defer close(ch)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			data, ok := strings.CutPrefix(sc.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var e server.Event
+			if json.Unmarshal([]byte(data), &e) == nil {
+				ch <- e
+			}
+		}
+	}()
+	return ch
+}
+
+// await returns the next event of type typ, skipping the others.
+func (s *EventsSuite) await(ch <-chan server.Event, typ string) server.Event {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e, ok := <-ch:
+			s.Require().True(ok, "event stream closed")
+			if e.Type == typ {
+				return e
+			}
+		case <-timeout:
+			s.FailNow("no " + typ + " event")
+		}
+	}
+}
+
+// get requests a URL through the HTTP proxy at proxyAddr.
+func get(proxyAddr string) {
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: proxyAddr})},
+		Timeout:   5 * time.Second,
+	}
+	if resp, err := client.Get("http://127.0.0.1:9/"); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (s *EventsSuite) reload() {
+	resp, err := http.Post(s.api+"/config/reload", "", nil)
+	s.Require().NoError(err)
+	resp.Body.Close()
+}
+
+// TestRefusals verifies that the authentication failures, the admission
+// denials and the limiter rejections are published.
+func (s *EventsSuite) TestRefusals() {
+	ch := s.stream("type=auth,admission&type=limiter", 0)
+
+	get(s.addrs["proxy"])
+	e := s.await(ch, server.EventAuthFailed)
+	s.Assert().Equal("proxy", e.Service)
+	s.Assert().Contains(e.Client, "127.0.0.1:")
+	s.Assert().NotZero(e.ID)
+	s.Assert().False(e.Time.IsZero())
+
+	get(s.addrs["denied"])
+	e = s.await(ch, server.EventAdmissionDenied)
+	s.Assert().Equal("denied", e.Service)
+	s.Assert().Equal("admissions/admission-0", e.Resource)
+	s.Assert().Contains(e.Client, "127.0.0.1:")
+
+	conn, err := net.Dial("tcp", s.addrs["limited"])
+	s.Require().NoError(err)
+	defer conn.Close()
+	get(s.addrs["limited"])
+	e = s.await(ch, server.EventLimiterRejected)
+	s.Assert().Equal("climiters/climiter-0", e.Resource)
+	s.Assert().Equal("127.0.0.1", e.Key)
+}
+
+// TestNodes verifies that a node is published as failed once it failed as
+// many times as its hop allows, and as recovered after the fail timeout.
+func (s *EventsSuite) TestNodes() {
+	ch := s.stream("type=node", 0)
+
+	get(s.addrs["chained"])
+	e := s.await(ch, server.EventNodeFailed)
+	s.Assert().Equal("chained", e.Service)
+	s.Assert().Equal("chain-0", e.Chain)
+	s.Assert().Equal("hop-0", e.Hop)
+	s.Assert().Equal("node-0", e.Node)
+	s.Assert().Equal(s.addrs["node"], e.Addr)
+	s.Assert().Equal("fails", e.Reason)
+
+	e = s.await(ch, server.EventNodeRecovered)
+	s.Assert().Equal("node-0", e.Node)
+}
+
+// TestTunnel verifies that the connectors binding to and leaving a tunnel
+// are published.
+func (s *EventsSuite) TestTunnel() {
+	ch := s.stream("type=tunnel", 0)
+
+	const tunnelID = "0d4e4b9c-7cf8-4b1e-9a4c-3a0f3b8a6e21"
+	cmd := exec.Command(GostBinPath,
+		"-L", "rtcp://:0/127.0.0.1:9",
+		"-F", "tunnel://"+s.addrs["tunnel"]+"?tunnel.id="+tunnelID)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	defer cmd.Wait()
+
+	e := s.await(ch, server.EventTunnelConnected)
+	s.Assert().Equal("tunnel", e.Service)
+	s.Assert().Equal(tunnelID, e.Tunnel)
+	s.Assert().NotEmpty(e.Connector)
+	s.Assert().Contains(e.Client, "127.0.0.1:")
+
+	cmd.Process.Kill()
+	d := s.await(ch, server.EventTunnelDisconnected)
+	s.Assert().Equal(e.Connector, d.Connector)
+	s.Assert().Equal(tunnelID, d.Tunnel)
+}
+
+// TestReload verifies that the services started and stopped by the reloads
+// and their outcome are published, and that a stream resumed with
+// Last-Event-ID receives the events it missed.
+func (s *EventsSuite) TestReload() {
+	defer func() {
+		os.WriteFile(s.config, s.orig, 0644)
+		s.reload()
+	}()
+
+	ch := s.stream("type=service,reload", 0)
+
+	extra := freeAddr(s.T())
+	s.Require().NoError(os.WriteFile(s.config, []byte(strings.Replace(string(s.orig), "services:\n", "services:\n"+serviceYAML("extra", extra), 1)), 0644))
+	s.reload()
+
+	started := s.await(ch, server.EventServiceStarted)
+	s.Assert().Equal("extra", started.Service)
+	s.Assert().Equal(extra, started.Addr)
+	e := s.await(ch, server.EventReloadSucceeded)
+	s.Assert().Contains(e.Changes, "services/extra")
+
+	s.Require().NoError(os.WriteFile(s.config, []byte(strings.Replace(string(s.orig), "type: http", "type: missing", 1)), 0644))
+	s.reload()
+	e = s.await(ch, server.EventReloadFailed)
+	s.Assert().Equal("failed", e.Status)
+	s.Assert().Contains(e.Error, "missing")
+
+	resumed := s.stream("type=reload", started.ID)
+	e = s.await(resumed, server.EventReloadSucceeded)
+	s.Assert().Contains(e.Changes, "services/extra")
+	e = s.await(resumed, server.EventReloadFailed)
+	s.Assert().Equal("failed", e.Status)
+}
+
+// TestTunnelSD verifies that the service discovery publishing the
+// connectors of a tunnel service is unregistered once the service is
+// replaced or removed, with a server running in the test process.
+func (s *EventsSuite) TestTunnelSD() {
+	tunnel := func(addr string) *config.Config {
+		return &config.Config{Services: []*config.ServiceConfig{{
+			Name:     "tunnel",
+			Addr:     addr,
+			Handler:  &config.HandlerConfig{Type: "tunnel"},
+			Listener: &config.ListenerConfig{Type: "tcp"},
+		}}}
+	}
+	sds := func() int {
+		n := 0
+		for name := range registry.SDRegistry().GetAll() {
+			if strings.HasPrefix(name, "@events/") {
+				n++
+			}
+		}
+		return n
+	}
+
+	srv := server.New(tunnel(freeAddr(s.T())))
+	s.Require().NoError(srv.Start(context.Background()))
+	defer srv.Close()
+	s.Assert().Equal(1, sds())
+
+	// The handler of a closed service is released once its accept loop is
+	// done.
+	_, err := srv.Reload(tunnel(freeAddr(s.T())))
+	s.Require().NoError(err)
+	s.Assert().Eventually(func() bool { return sds() == 1 }, 5*time.Second, 50*time.Millisecond)
+
+	_, err = srv.Reload(&config.Config{})
+	s.Require().NoError(err)
+	s.Assert().Eventually(func() bool { return sds() == 0 }, 5*time.Second, 50*time.Millisecond)
+}
+
+func serviceYAML(name, addr string) string {
+	return "- name: " + name + "\n  addr: " + addr + "\n  handler:\n    type: http\n  listener:\n    type: tcp\n"
+}
+
+func TestEventsSuite(t *testing.T) {
+	suite.Run(t, new(EventsSuite))
+}
@@ -0,0 +1,61 @@
+package e2e
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ExplainSuite covers the effective config written by -O yaml --explain,
+// with the defaults filled in and the source of each value. No socket is
+// bound, so gost runs on the host.
+type ExplainSuite struct {
+	suite.Suite
+}
+
+// TestSources verifies that the values are annotated with the file and
+// line defining them, the flag or environment variable setting them, or
+// the default applied.
+func (s *ExplainSuite) TestSources() {
+	cmd := exec.Command(GostBinPath,
+		"-C", "testdata/explain/gost.yaml",
+		"-C", "testdata/explain/override.yaml",
+		"-L", "socks5://:1080",
+		"-O", "yaml", "--explain")
+	cmd.Env = append(os.Environ(), "PROXY_PASSWORD=secret", "GOST_METRICS=:9000")
+	out, err := cmd.CombinedOutput()
+	s.Require().NoError(err, string(out))
+
+	for _, want := range []string{
+		"addr: :8080 # testdata/explain/gost.yaml:3",
+		"password: ${PROXY_PASSWORD} # testdata/explain/gost.yaml:8, env PROXY_PASSWORD",
+		"type: tcp # default",
+		"name: service-0 # flag -L",
+		"strategy: rand # testdata/explain/gost.yaml:12",
+		"maxFails: 1 # default",
+		"failTimeout: 10s # default",
+		"level: info # default",
+		// The section of the last file replaces the previous ones.
+		"addr: :19090 # testdata/explain/override.yaml:2",
+		"addr: :6060 # default",
+		"addr: :9000 # env GOST_METRICS",
+		"path: /metrics # default",
+	} {
+		s.Assert().Contains(string(out), want)
+	}
+	s.Assert().NotContains(string(out), "secret")
+}
+
+// TestYAMLOnly verifies that --explain is rejected with another format,
+// which could not hold the annotations.
+func (s *ExplainSuite) TestYAMLOnly() {
+	out, err := exec.Command(GostBinPath, "-L", "http://:8080", "-O", "json", "--explain").CombinedOutput()
+	s.Require().Error(err, string(out))
+	s.Assert().Contains(string(out), "--explain requires -O yaml")
+}
+
+func TestExplainSuite(t *testing.T) {
+	suite.Run(t, new(ExplainSuite))
+}
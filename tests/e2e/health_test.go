@@ -0,0 +1,199 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+	"github.com/stretchr/testify/suite"
+)
+
+// HealthSuite covers the health served by the API and metrics services.
+// gost runs on the host, on the loopback, with chain-0 required to be
+// ready, its node probed and down until the test listens on its address.
+type HealthSuite struct {
+	suite.Suite
+	api     string
+	metrics string
+	node    string
+}
+
+func (s *HealthSuite) SetupSuite() {
+	// The config is written with free addresses.
+	addrs := freeAddrs(s.T(), "proxy", "node", "api", "metrics")
+	s.api, s.metrics, s.node = "http://"+addrs["api"], "http://"+addrs["metrics"], addrs["node"]
+	file := filepath.Join(s.T().TempDir(), "gost.yaml")
+	writeConfig(s.T(), "testdata/health/gost.yaml", file, addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", file, "-ready-chain", "chain-0")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		for _, base := range []string{s.api, s.metrics} {
+			resp, err := http.Get(base + "/healthz")
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// health gets the health at url, without authentication, and returns the
+// status of the response.
+func (s *HealthSuite) health(url string) (int, *server.Health) {
+	resp, err := http.Get(url)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Require().Contains(resp.Header.Get("Content-Type"), "application/json")
+
+	var h server.Health
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&h))
+	return resp.StatusCode, &h
+}
+
+// TestHealth verifies that the health is served by both services without
+// authentication, the rest of the services still requiring it.
+func (s *HealthSuite) TestHealth() {
+	for _, base := range []string{s.api, s.metrics} {
+		status, h := s.health(base + "/healthz")
+		s.Require().Equal(http.StatusOK, status, base)
+		s.Assert().False(h.Ready, base)
+		s.Assert().Equal([]server.ServiceHealth{{Name: "proxy", State: "ready", Ready: true}}, h.Services, base)
+
+		s.Require().Len(h.Chains, 2, base)
+		s.Assert().Equal(server.ChainHealth{
+			Name:     "chain-0",
+			Required: true,
+			Hops:     []server.HopHealth{{Name: "hop-0", Nodes: 1}},
+		}, h.Chains[0], base)
+		s.Assert().Equal(server.ChainHealth{
+			Name:  "chain-1",
+			Ready: true,
+			Hops:  []server.HopHealth{{Name: "hop-1", Nodes: 1, Passing: 1}},
+		}, h.Chains[1], base)
+	}
+
+	for _, url := range []string{s.api + "/config", s.metrics + "/metrics"} {
+		resp, err := http.Get(url)
+		s.Require().NoError(err)
+		resp.Body.Close()
+		s.Assert().Equal(http.StatusUnauthorized, resp.StatusCode, url)
+	}
+}
+
+// TestReadiness verifies that the server is ready once the node of the
+// required chain passes its probe.
+func (s *HealthSuite) TestReadiness() {
+	for _, base := range []string{s.api, s.metrics} {
+		status, h := s.health(base + "/readyz")
+		s.Assert().Equal(http.StatusServiceUnavailable, status, base)
+		s.Assert().False(h.Ready, base)
+	}
+
+	ln, err := net.Listen("tcp", s.node)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	s.Require().Eventually(func() bool {
+		status, _ := s.health(s.api + "/readyz")
+		return status == http.StatusOK
+	}, 10*time.Second, 200*time.Millisecond)
+
+	status, h := s.health(s.metrics + "/readyz")
+	s.Assert().Equal(http.StatusOK, status)
+	s.Assert().True(h.Ready)
+	s.Require().NotEmpty(h.Chains)
+	s.Assert().Equal([]server.HopHealth{{Name: "hop-0", Nodes: 1, Passing: 1}}, h.Chains[0].Hops)
+}
+
+// TestInlineHops verifies that the inline hops of a chain are registered
+// along with it, for its health, and unregistered once it is replaced or
+// removed, with a server running in the test process.
+func (s *HealthSuite) TestInlineHops() {
+	chained := func(addr string) *config.Config {
+		return &config.Config{Chains: []*config.ChainConfig{{
+			Name: "inline-0",
+			Hops: []*config.HopConfig{{
+				Name: "hop-0",
+				Nodes: []*config.NodeConfig{{
+					Name:      "node-0",
+					Addr:      addr,
+					Connector: &config.ConnectorConfig{Type: "http"},
+					Dialer:    &config.DialerConfig{Type: "tcp"},
+				}},
+			}},
+		}}}
+	}
+	hops := func() int {
+		n := 0
+		for name := range registry.HopRegistry().GetAll() {
+			if strings.HasPrefix(name, "@inline-0/hop-0/") {
+				n++
+			}
+		}
+		return n
+	}
+
+	srv := server.New(chained("127.0.0.1:1"))
+	s.Require().NoError(srv.Start(context.Background()))
+	defer srv.Close()
+	s.Assert().Equal(1, hops())
+	h := srv.Health()
+	s.Require().Len(h.Chains, 1)
+	s.Assert().Equal([]server.HopHealth{{Name: "hop-0", Nodes: 1, Passing: 1}}, h.Chains[0].Hops)
+
+	_, err := srv.Reload(chained("127.0.0.1:2"))
+	s.Require().NoError(err)
+	s.Assert().Equal(1, hops())
+
+	// A reload failing to bind a service leaves the hops of the previous
+	// chain registered only.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer ln.Close()
+	cfg := chained("127.0.0.1:3")
+	cfg.Services = append(cfg.Services, &config.ServiceConfig{
+		Name:     "taken",
+		Addr:     ln.Addr().String(),
+		Handler:  &config.HandlerConfig{Type: "http"},
+		Listener: &config.ListenerConfig{Type: "tcp"},
+	})
+	_, err = srv.Reload(cfg)
+	s.Require().Error(err)
+	s.Assert().Equal(1, hops())
+
+	_, err = srv.Reload(&config.Config{})
+	s.Require().NoError(err)
+	s.Assert().Zero(hops())
+}
+
+func TestHealthSuite(t *testing.T) {
+	suite.Run(t, new(HealthSuite))
+}
@@ -0,0 +1,59 @@
+package e2e
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// IncludeSuite covers the include directive of the config files. Neither
+// the merging nor the conflicts need any socket, so gost runs on the host.
+type IncludeSuite struct {
+	suite.Suite
+}
+
+func (s *IncludeSuite) run(args ...string) (int, string) {
+	out, err := exec.Command(GostBinPath, args...).CombinedOutput()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		s.Require().True(ok, "run gost: %v", err)
+		return exitErr.ExitCode(), string(out)
+	}
+	return 0, string(out)
+}
+
+// TestMerged verifies that the files matched by an include pattern,
+// relative to the including file, are merged into the config, and that the
+// references between files resolve.
+func (s *IncludeSuite) TestMerged() {
+	code, out := s.run("-C", "testdata/include/gost.yaml", "-O", "yaml")
+	s.Require().Equal(0, code, out)
+	for _, name := range []string{"name: proxy", "name: socks", "name: chain-0"} {
+		s.Assert().Contains(out, name)
+	}
+	s.Assert().NotContains(out, "include")
+
+	code, out = s.run("-t", "-C", "testdata/include/gost.yaml")
+	s.Require().Equal(0, code, out)
+	s.Assert().Contains(out, "config test passed")
+}
+
+// TestConflict verifies that a resource defined in two files is an error
+// locating both definitions.
+func (s *IncludeSuite) TestConflict() {
+	code, out := s.run("-t", "-C", "testdata/include/conflict/gost.yaml")
+	s.Require().NotEqual(0, code, out)
+	s.Assert().Contains(out, "testdata/include/conflict/socks.yaml:2:9: services/socks name: already defined at testdata/include/conf.d/socks.yaml:2:9")
+}
+
+// TestMissing verifies that an include path without pattern must exist.
+func (s *IncludeSuite) TestMissing() {
+	code, out := s.run("-t", "-C", "testdata/include/missing.yaml")
+	s.Require().NotEqual(0, code, out)
+	s.Assert().Contains(out, `testdata/include/missing.yaml: include "conf.d/missing.yaml": file does not exist`)
+}
+
+func TestIncludeSuite(t *testing.T) {
+	suite.Run(t, new(IncludeSuite))
+}
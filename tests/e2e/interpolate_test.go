@@ -0,0 +1,147 @@
+package e2e
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// InterpolateSuite covers the expansion of ${ENV}, ${ENV:-default} and
+// ${file:path} references in the config, and their redaction when the
+// config is written. Neither needs any socket, so gost runs on the host.
+type InterpolateSuite struct {
+	suite.Suite
+}
+
+func (s *InterpolateSuite) run(env []string, args ...string) (int, string) {
+	cmd := exec.Command(GostBinPath, args...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		s.Require().True(ok, "run gost: %v", err)
+		return exitErr.ExitCode(), string(out)
+	}
+	return 0, string(out)
+}
+
+// TestRedacted verifies that the config written with -O shows the
+// references instead of the secrets they expand to, for the config files
+// as well as for the -L URLs.
+func (s *InterpolateSuite) TestRedacted() {
+	for _, format := range []string{"yaml", "json"} {
+		code, out := s.run([]string{"BOB_PASSWORD=env-secret", "URL_PASSWORD=url-secret"},
+			"-C", "testdata/interpolate/config.yaml",
+			"-L", "http://alice:${URL_PASSWORD}@:8081",
+			"-O", format)
+		s.Require().Equal(0, code, out)
+
+		s.Assert().Contains(out, "${file:testdata/interpolate/secret.txt}", format)
+		s.Assert().Contains(out, "${BOB_PASSWORD}", format)
+		s.Assert().Contains(out, "${URL_PASSWORD}", format)
+		s.Assert().NotContains(out, "file-secret", format)
+		s.Assert().NotContains(out, "env-secret", format)
+		s.Assert().NotContains(out, "url-secret", format)
+	}
+}
+
+// TestRedactedByLocation verifies that only the strings expanded from the
+// environment or from files are redacted, not the ones expanded from
+// defaults, nor other strings with the same values.
+func (s *InterpolateSuite) TestRedactedByLocation() {
+	code, out := s.run([]string{"BOB_PASSWORD=tcp"},
+		"-C", "testdata/interpolate/config.yaml",
+		"-L", "http://:8081?listener=tcp",
+		"-O", "yaml")
+	s.Require().Equal(0, code, out)
+
+	s.Assert().Contains(out, "password: ${BOB_PASSWORD}")
+	s.Assert().Contains(out, "addr: :8080")
+	s.Assert().NotContains(out, "${PROXY_ADDR")
+	s.Assert().NotContains(out, "${PROXY_LISTENER")
+	s.Assert().Equal(1, strings.Count(out, "${BOB_PASSWORD}"), out)
+	s.Assert().Contains(out, "type: tcp")
+}
+
+// TestEscaped verifies that $${ is a literal ${ in the -L URLs, which are
+// expanded once: the values of their references are not expanded.
+func (s *InterpolateSuite) TestEscaped() {
+	code, out := s.run([]string{"BOB_PASSWORD=x", "URL_PASSWORD=url-secret", "NOTE=${BOB_PASSWORD}"},
+		"-C", "testdata/interpolate/config.yaml",
+		"-L", "http://alice:${URL_PASSWORD}@:8081?escaped=$${GOST_E2E_UNSET}&note=${NOTE}",
+		"-O", "json")
+	s.Require().Equal(0, code, out)
+
+	s.Assert().Contains(out, `"escaped": "${GOST_E2E_UNSET}"`)
+	// Expanded again, the note would be x, which is not redacted.
+	s.Assert().Contains(out, `"note": "${NOTE}"`)
+	s.Assert().Contains(out, `"password": "${URL_PASSWORD}"`)
+	s.Assert().NotContains(out, "url-secret")
+}
+
+// TestExpanded verifies that references are expanded before the config is
+// checked, with their defaults when the variables are unset.
+func (s *InterpolateSuite) TestExpanded() {
+	code, out := s.run([]string{"BOB_PASSWORD=x"},
+		"-t", "-C", "testdata/interpolate/config.yaml")
+	s.Require().Equal(0, code, out)
+
+	code, out = s.run([]string{"BOB_PASSWORD=x", "PROXY_LISTENER=tpc"},
+		"-t", "-C", "testdata/interpolate/config.yaml")
+	s.Require().NotEqual(0, code, out)
+	s.Assert().Contains(out, `services/proxy listener.type: unknown listener type "tpc"`)
+}
+
+// TestUnset verifies that a reference to an unset variable without
+// default is an error located in the config.
+func (s *InterpolateSuite) TestUnset() {
+	cmd := exec.Command(GostBinPath, "-t", "-C", "testdata/interpolate/config.yaml")
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "BOB_PASSWORD=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	out, err := cmd.CombinedOutput()
+	s.Require().Error(err, string(out))
+	s.Assert().Contains(string(out), "authers[0].auths[0].password: ${BOB_PASSWORD}: environment variable BOB_PASSWORD is not set")
+}
+
+// TestReplacement verifies that the replacements of the HTTP rewrite rules,
+// whose $ are the ones of regular expressions, are loaded unchanged.
+func (s *InterpolateSuite) TestReplacement() {
+	code, out := s.run(nil, "-C", "testdata/interpolate/rewrite.yaml", "-O", "yaml")
+	s.Require().Equal(0, code, out)
+	s.Assert().Contains(out, "replacement: /v2/${1}")
+	s.Assert().Contains(out, "replacement: ${name}@example.org")
+}
+
+// TestRemoteFile verifies that a config fetched from a URL can only
+// reference the files the local config files do.
+func (s *InterpolateSuite) TestRemoteFile() {
+	remote := func(file string) string {
+		return "authers:\n- name: remote\n  auths:\n  - username: eve\n    password: ${file:" + file + "}\n"
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, remote(r.URL.Query().Get("file")))
+	}))
+	defer srv.Close()
+
+	code, out := s.run([]string{"BOB_PASSWORD=x"},
+		"-t", "-C", "testdata/interpolate/config.yaml", "-C", srv.URL+"/?file=/etc/hostname")
+	s.Require().NotEqual(0, code, out)
+	s.Assert().Contains(out, "${file:/etc/hostname}: not referenced by a local config file")
+
+	code, out = s.run([]string{"BOB_PASSWORD=x"},
+		"-t", "-C", "testdata/interpolate/config.yaml", "-C", srv.URL+"/?file=testdata/interpolate/secret.txt")
+	s.Assert().Equal(0, code, out)
+}
+
+func TestInterpolateSuite(t *testing.T) {
+	suite.Run(t, new(InterpolateSuite))
+}
@@ -0,0 +1,179 @@
+package e2e
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/stretchr/testify/suite"
+)
+
+// PersistSuite covers the changes made by the API written back to the
+// config file with --persist, and its revisions. gost runs on the host, on
+// the loopback, with a copy of the config.
+type PersistSuite struct {
+	suite.Suite
+	config string
+	// api is the URL of the API.
+	api string
+}
+
+func (s *PersistSuite) SetupSuite() {
+	addrs := freeAddrs(s.T(), "api", "proxy")
+	s.api = "http://" + addrs["api"]
+	s.config = filepath.Join(s.T().TempDir(), "gost.yaml")
+	writeConfig(s.T(), "testdata/persist/gost.yaml", s.config, addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", s.config, "--persist")
+	// The references resolved from the environment are kept in the file,
+	// not the ones resolved to their defaults.
+	cmd.Env = append(os.Environ(), "PERSIST_HOST=hidden.example")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get(s.api + "/reload")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func (s *PersistSuite) do(method, path, body string) (int, []byte) {
+	req, err := http.NewRequest(method, s.api+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	return resp.StatusCode, b
+}
+
+// setMatchers replaces the matchers of bypass-0.
+func (s *PersistSuite) setMatchers(matchers ...string) {
+	b, err := json.Marshal(map[string]any{"name": "bypass-0", "matchers": matchers})
+	s.Require().NoError(err)
+	code, body := s.do(http.MethodPut, "/config/bypasses/bypass-0", string(b))
+	s.Require().Equal(http.StatusOK, code, string(body))
+}
+
+func (s *PersistSuite) matchers() string {
+	code, b := s.do(http.MethodGet, "/config/bypasses/bypass-0", "")
+	s.Require().Equal(http.StatusOK, code)
+	return string(b)
+}
+
+func (s *PersistSuite) file() string {
+	b, err := os.ReadFile(s.config)
+	s.Require().NoError(err)
+	return string(b)
+}
+
+func (s *PersistSuite) revisions() []server.Revision {
+	code, b := s.do(http.MethodGet, "/config/revisions", "")
+	s.Require().Equal(http.StatusOK, code)
+	var r struct {
+		Data struct {
+			Count int               `json:"count"`
+			List  []server.Revision `json:"list"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal(b, &r))
+	s.Require().Len(r.Data.List, r.Data.Count)
+	s.Require().NotEmpty(r.Data.List)
+	return r.Data.List
+}
+
+// TestPersist verifies that the changes made by the API are written to the
+// config file, with its references kept, and survive a reload of it.
+func (s *PersistSuite) TestPersist() {
+	s.setMatchers("example.com", "persist.example")
+
+	file := s.file()
+	s.Assert().Contains(file, "persist.example")
+	s.Assert().Contains(file, "${PERSIST_HOST:-hidden.example}")
+
+	list := s.revisions()
+	s.Assert().Equal(server.RevisionAPI, list[0].Source)
+	s.Assert().Equal(http.MethodPut, list[0].Method)
+	s.Assert().Equal("/config/bypasses/bypass-0", list[0].Path)
+	s.Assert().Equal(server.RevisionFile, list[len(list)-1].Source)
+	s.Assert().Equal(1, list[len(list)-1].Revision)
+
+	code, b := s.do(http.MethodPost, "/config/reload", "")
+	s.Require().Equal(http.StatusOK, code, string(b))
+	s.Assert().Contains(s.matchers(), "persist.example")
+
+	// Reading the file again records no revision.
+	s.Assert().Equal(list[0].Revision, s.revisions()[0].Revision)
+}
+
+// TestRollback verifies that two revisions are compared by resource, and
+// that rolling back restores the file and the running config as a new
+// revision.
+func (s *PersistSuite) TestRollback() {
+	s.setMatchers("example.com", "before.example")
+	s.setMatchers("example.com", "after.example")
+	list := s.revisions()
+	last, prev := list[0].Revision, list[1].Revision
+
+	code, b := s.do(http.MethodGet, "/config/revisions/diff?from="+strconv.Itoa(prev)+"&to="+strconv.Itoa(last), "")
+	s.Require().Equal(http.StatusOK, code, string(b))
+	var diff struct {
+		Data struct {
+			From    int                     `json:"from"`
+			To      int                     `json:"to"`
+			Changes []server.ResourceChange `json:"changes"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal(b, &diff))
+	s.Assert().Equal(prev, diff.Data.From)
+	s.Require().Len(diff.Data.Changes, 1)
+	s.Assert().Equal("bypasses/bypass-0", diff.Data.Changes[0].Resource)
+	s.Assert().Equal("changed", diff.Data.Changes[0].Change)
+	s.Assert().Contains(string(diff.Data.Changes[0].Before), "before.example")
+	s.Assert().Contains(string(diff.Data.Changes[0].After), "after.example")
+
+	code, b = s.do(http.MethodPost, "/config/revisions/"+strconv.Itoa(prev)+"/rollback", "")
+	s.Require().Equal(http.StatusOK, code, string(b))
+	s.Assert().Contains(s.matchers(), "before.example")
+	s.Assert().NotContains(s.file(), "after.example")
+
+	rev := s.revisions()[0]
+	s.Assert().Equal(last+1, rev.Revision)
+	s.Assert().Equal(server.RevisionRollback, rev.Source)
+	s.Assert().Equal(prev, rev.Rollback)
+
+	code, _ = s.do(http.MethodPost, "/config/revisions/9999/rollback", "")
+	s.Assert().Equal(http.StatusNotFound, code)
+}
+
+// TestFlags verifies that --persist is refused along with the management
+// services given by flags, which the file would be written with.
+func (s *PersistSuite) TestFlags() {
+	for _, flag := range []string{"-api", "-metrics"} {
+		out, err := exec.Command(GostBinPath, "-C", s.config, "--persist", flag, "127.0.0.1:0").CombinedOutput()
+		s.Assert().Error(err, flag)
+		s.Assert().Contains(string(out), "--persist can not be used with -api or -metrics", flag)
+	}
+}
+
+func TestPersistSuite(t *testing.T) {
+	suite.Run(t, new(PersistSuite))
+}
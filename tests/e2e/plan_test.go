@@ -0,0 +1,105 @@
+package e2e
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PlanSuite covers the reload plan printed by --plan against the API of a
+// running instance. Both instances run on the host, on the loopback.
+type PlanSuite struct {
+	suite.Suite
+	// addrs are the addresses of the configs by name.
+	addrs map[string]string
+	// running is the config of the running instance, candidate the one
+	// planned against it.
+	running   string
+	candidate string
+}
+
+func (s *PlanSuite) SetupSuite() {
+	s.addrs = freeAddrs(s.T(), "api", "proxy", "old", "new")
+	dir := s.T().TempDir()
+	s.running = filepath.Join(dir, "running.yaml")
+	writeConfig(s.T(), "testdata/plan/running.yaml", s.running, s.addrs)
+	s.candidate = filepath.Join(dir, "candidate.yaml")
+	writeConfig(s.T(), "testdata/plan/candidate.yaml", s.candidate, s.addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", s.running)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get("http://" + s.addrs["api"] + "/config")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func (s *PlanSuite) plan(args ...string) string {
+	cmd := exec.Command(GostBinPath, append([]string{"--plan"}, args...)...)
+	cmd.Env = append(os.Environ(), "PROXY_PASSWORD=secret")
+	out, err := cmd.CombinedOutput()
+	s.Require().NoError(err, string(out))
+	return string(out)
+}
+
+// TestPlan verifies that the plan lists the services to rebind with their
+// live connections, the changed nodes and matchers, and the added and
+// removed resources.
+func (s *PlanSuite) TestPlan() {
+	conn, err := net.Dial("tcp", s.addrs["proxy"])
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	// The connection is counted once accepted by the service.
+	var out string
+	s.Require().Eventually(func() bool {
+		out = s.plan("-C", s.candidate, "--against", s.addrs["api"])
+		return strings.Contains(out, "1 live connection)")
+	}, 5*time.Second, 100*time.Millisecond, out)
+
+	for _, want := range []string{
+		"~ bypasses/bp\n",
+		"    + matchers: 192.168.0.0/16\n",
+		"    - matchers: 10.0.0.0/8\n",
+		"~ chains/chain-0\n",
+		"    ~ hops[hop-0].nodes[node-0].addr: 127.0.0.1:1080 -> 127.0.0.1:1081\n",
+		"    + hops[hop-0].nodes[node-1]\n",
+		"+ services/new (bind " + s.addrs["new"] + ")\n",
+		"- services/old (stop " + s.addrs["old"] + ", 0 live connections)\n",
+		"~ services/proxy (rebind " + s.addrs["proxy"] + ", 1 live connection)\n",
+		"    + handler.auth\n",
+		"Plan: 1 to add, 3 to change, 1 to remove.\n",
+		"2 services to rebind or stop, carrying 1 live connection.\n",
+	} {
+		s.Assert().Contains(out, want)
+	}
+	s.Assert().NotContains(out, "secret")
+}
+
+// TestNoChanges verifies that the running config itself plans no change.
+func (s *PlanSuite) TestNoChanges() {
+	out := s.plan("-C", s.running, "--against", "http://"+s.addrs["api"]+"/")
+	s.Assert().Equal("No changes.\n", out)
+}
+
+func TestPlanSuite(t *testing.T) {
+	suite.Run(t, new(PlanSuite))
+}
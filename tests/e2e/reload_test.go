@@ -0,0 +1,193 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/gost/builder"
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/go-gost/x/registry"
+	"github.com/stretchr/testify/suite"
+)
+
+// ReloadSuite covers which services a reload rebuilds, against a server
+// running in the test process, on the loopback, with an echo service and
+// an HTTP proxy.
+type ReloadSuite struct {
+	suite.Suite
+	mu       sync.Mutex
+	handlers []*echoHandler
+	backend  *httptest.Server
+}
+
+func (s *ReloadSuite) SetupSuite() {
+	registerEcho(func(h *echoHandler) {
+		s.mu.Lock()
+		s.handlers = append(s.handlers, h)
+		s.mu.Unlock()
+	})
+	s.backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello-gost")
+	}))
+}
+
+func (s *ReloadSuite) TearDownSuite() {
+	registry.HandlerRegistry().Unregister("e2e-echo")
+	s.backend.Close()
+}
+
+func (s *ReloadSuite) SetupTest() {
+	s.mu.Lock()
+	s.handlers = nil
+	s.mu.Unlock()
+}
+
+// config returns a config with the echo service and the proxy service,
+// with the user password and bypassing the matchers.
+func (s *ReloadSuite) config(password string, matchers ...string) *config.Config {
+	cfg, err := builder.New().
+		Service(builder.Service("echo").
+			Listen("127.0.0.1:0").
+			Listener(builder.Listener("tcp")).
+			Handler(builder.Handler("e2e-echo"))).
+		Service(builder.Service("proxy").
+			Listen("127.0.0.1:0").
+			Handler(builder.HTTP().Auth("user", password)).
+			Bypass(builder.Bypass("bypass-0").Matchers(matchers...))).
+		Build()
+	s.Require().NoError(err)
+	return cfg
+}
+
+func (s *ReloadSuite) start(cfg *config.Config) *server.Server {
+	srv := server.New(cfg)
+	s.Require().NoError(srv.Start(context.Background()))
+	s.T().Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func (s *ReloadSuite) service(srv *server.Server, name string) service.Service {
+	svc, ok := srv.Resource(server.Resource{Kind: "services", Name: name}).(service.Service)
+	s.Require().True(ok, "no service %s", name)
+	return svc
+}
+
+// get requests the backend through the proxy service of srv and returns
+// the status of the response.
+func (s *ReloadSuite) get(srv *server.Server, password string) int {
+	proxy := &url.URL{
+		Scheme: "http",
+		User:   url.UserPassword("user", password),
+		Host:   s.service(srv, "proxy").Addr().String(),
+	}
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+		Timeout:   5 * time.Second,
+	}
+	resp, err := client.Get(s.backend.URL)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestUnchanged verifies that a reload changing a service leaves the other
+// services, their listeners and their connections alone.
+func (s *ReloadSuite) TestUnchanged() {
+	srv := s.start(s.config("pass", "example.com"))
+	echo := s.service(srv, "echo")
+	proxy := s.service(srv, "proxy")
+
+	conn, err := net.DialTimeout("tcp", echo.Addr().String(), 5*time.Second)
+	s.Require().NoError(err)
+	defer conn.Close()
+	s.Require().True(echoes(conn, "before"))
+
+	diff, err := srv.Reload(s.config("secret", "example.com"))
+	s.Require().NoError(err)
+	s.Assert().Equal([]server.Resource{{Kind: "services", Name: "proxy"}},
+		diff.Resources(server.Added, server.Changed, server.Removed))
+
+	s.Assert().Same(echo, s.service(srv, "echo"))
+	s.Assert().NotSame(proxy, s.service(srv, "proxy"))
+	s.Assert().True(echoes(conn, "after"), "connection of an unchanged service broken")
+
+	s.mu.Lock()
+	handlers := s.handlers
+	s.mu.Unlock()
+	s.Require().Len(handlers, 1)
+	s.Assert().False(handlers[0].closed.Load())
+
+	s.Assert().Equal(http.StatusProxyAuthRequired, s.get(srv, "pass"))
+	s.Assert().Equal(http.StatusOK, s.get(srv, "secret"))
+}
+
+// TestDependent verifies that a reload changing a bypass rebinds the
+// service using it, and only it.
+func (s *ReloadSuite) TestDependent() {
+	srv := s.start(s.config("pass", "example.com"))
+	echo := s.service(srv, "echo")
+	proxy := s.service(srv, "proxy")
+	s.Require().Equal(http.StatusOK, s.get(srv, "pass"))
+
+	diff, err := srv.Reload(s.config("pass", "127.0.0.1"))
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"bypass-0"}, diff.Names("bypasses", server.Changed))
+	s.Assert().Equal([]string{"proxy"}, diff.Names("services", server.Changed))
+	cause, ok := diff.Cause(server.Resource{Kind: "services", Name: "proxy"})
+	s.Assert().True(ok)
+	s.Assert().Equal(server.Resource{Kind: "bypasses", Name: "bypass-0"}, cause)
+
+	s.Assert().Same(echo, s.service(srv, "echo"))
+	s.Assert().NotSame(proxy, s.service(srv, "proxy"))
+	s.Assert().Equal(http.StatusForbidden, s.get(srv, "pass"))
+}
+
+// TestRollback verifies that a reload failing to bind a service is rolled
+// back, the services of the previous config still serving.
+func (s *ReloadSuite) TestRollback() {
+	srv := s.start(s.config("pass", "example.com"))
+	echo := s.service(srv, "echo")
+
+	conn, err := net.DialTimeout("tcp", echo.Addr().String(), 5*time.Second)
+	s.Require().NoError(err)
+	defer conn.Close()
+	s.Require().True(echoes(conn, "before"))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer ln.Close()
+
+	cfg := s.config("secret", "example.com")
+	cfg.Services = append(cfg.Services, &config.ServiceConfig{
+		Name:     "taken",
+		Addr:     ln.Addr().String(),
+		Handler:  &config.HandlerConfig{Type: "http"},
+		Listener: &config.ListenerConfig{Type: "tcp"},
+	})
+	_, err = srv.Reload(cfg)
+	s.Require().Error(err)
+	s.Assert().Contains(err.Error(), ln.Addr().String())
+
+	st := srv.LastReload()
+	s.Assert().Equal("rolledback", st.Status)
+	s.Assert().Equal(err.Error(), st.Error)
+
+	s.Assert().Nil(srv.Resource(server.Resource{Kind: "services", Name: "taken"}))
+	s.Assert().Same(echo, s.service(srv, "echo"))
+	s.Assert().True(echoes(conn, "after"), "connection of an unchanged service broken")
+	s.Assert().Equal(http.StatusOK, s.get(srv, "pass"))
+	s.Assert().Equal(http.StatusProxyAuthRequired, s.get(srv, "secret"))
+}
+
+func TestReloadSuite(t *testing.T) {
+	suite.Run(t, new(ReloadSuite))
+}
@@ -0,0 +1,96 @@
+package e2e
+
+import (
+	"encoding/json"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SchemaSuite covers the JSON Schema of the config written by -O schema.
+// No config is needed, so gost runs on the host.
+type SchemaSuite struct {
+	suite.Suite
+	schema map[string]any
+}
+
+func (s *SchemaSuite) SetupSuite() {
+	out, err := exec.Command(GostBinPath, "-O", "schema").Output()
+	s.Require().NoError(err)
+	s.Require().NoError(json.Unmarshal(out, &s.schema))
+}
+
+// get returns the value at path in the schema.
+func (s *SchemaSuite) get(path ...any) any {
+	var v any = s.schema
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			s.Require().True(ok, "%v: not an object at %q", path, p)
+			v, ok = m[p]
+			s.Require().True(ok, "%v: no %q", path, p)
+		case int:
+			l, ok := v.([]any)
+			s.Require().True(ok && p < len(l), "%v: no item %d", path, p)
+			v = l[p]
+		}
+	}
+	return v
+}
+
+// TestModel verifies that the schema describes the config sections and
+// rejects unknown keys.
+func (s *SchemaSuite) TestModel() {
+	s.Assert().Equal("https://json-schema.org/draft/2020-12/schema", s.get("$schema"))
+
+	for _, section := range []string{"services", "chains", "hops", "tls", "resolvers", "hosts",
+		"ingresses", "limiters", "caches", "metrics", "api", "log", "profiling", "include"} {
+		s.get("properties", section)
+	}
+	s.Assert().Equal("#/$defs/ServiceConfig", s.get("properties", "services", "items", "$ref"))
+	s.Assert().Equal(false, s.get("$defs", "ServiceConfig", "additionalProperties"))
+	s.Assert().Equal("#/$defs/SelectorConfig", s.get("$defs", "HopConfig", "properties", "selector", "$ref"))
+	// time.Duration
+	s.Assert().Equal([]any{"string", "integer"}, s.get("$defs", "HopConfig", "properties", "reload", "type"))
+}
+
+// TestComponents verifies that the component types are the registered
+// ones, and that the metadata keys read by a type are described.
+func (s *SchemaSuite) TestComponents() {
+	for def, typ := range map[string]string{
+		"HandlerConfig":   "http",
+		"ListenerConfig":  "tcp",
+		"DialerConfig":    "tls",
+		"ConnectorConfig": "socks5",
+	} {
+		s.Assert().Contains(s.get("$defs", def, "properties", "type", "anyOf", 0, "enum"), typ, def)
+	}
+
+	var found bool
+	for _, c := range s.get("$defs", "HandlerConfig", "allOf").([]any) {
+		cond := c.(map[string]any)
+		if s.getIn(cond, "if", "properties", "type", "const") != "http" {
+			continue
+		}
+		found = true
+		md := s.getIn(cond, "then", "properties", "metadata", "properties").(map[string]any)
+		s.Assert().Contains(md, "readTimeout")
+		s.Assert().Contains(md, "probeResist")
+	}
+	s.Assert().True(found, "no metadata for the http handler")
+}
+
+func (s *SchemaSuite) getIn(v any, path ...string) any {
+	for _, p := range path {
+		m, ok := v.(map[string]any)
+		s.Require().True(ok, "%v: not an object at %q", path, p)
+		v = m[p]
+	}
+	return v
+}
+
+func TestSchemaSuite(t *testing.T) {
+	suite.Run(t, new(SchemaSuite))
+}
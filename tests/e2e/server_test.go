@@ -0,0 +1,108 @@
+package e2e
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/service"
+	"github.com/go-gost/gost/builder"
+	_ "github.com/go-gost/gost/components"
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// ServerSuite covers gost embedded with the server package: the services
+// run in the test process, on the loopback.
+type ServerSuite struct {
+	suite.Suite
+	backend *httptest.Server
+}
+
+func (s *ServerSuite) SetupSuite() {
+	s.backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello-gost")
+	}))
+}
+
+func (s *ServerSuite) TearDownSuite() {
+	s.backend.Close()
+}
+
+func (s *ServerSuite) proxyConfig(h *builder.HandlerBuilder) *config.Config {
+	cfg, err := builder.New().
+		Service(builder.Service("proxy").
+			Listen("127.0.0.1:0").
+			Listener(builder.Listener("tcp")).
+			Handler(h)).
+		Build()
+	s.Require().NoError(err)
+	return cfg
+}
+
+// get requests the backend through the proxy service of srv.
+func (s *ServerSuite) get(srv *server.Server) (int, string) {
+	svc, ok := srv.Resource(server.Resource{Kind: "services", Name: "proxy"}).(service.Service)
+	s.Require().True(ok, "no service proxy")
+
+	proxy := &url.URL{Scheme: "http", Host: svc.Addr().String()}
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+		Timeout:   5 * time.Second,
+	}
+	resp, err := client.Get(s.backend.URL)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+// TestLifecycle verifies that a server started in-process serves, is
+// reloaded with a new config and is shut down.
+func (s *ServerSuite) TestLifecycle() {
+	srv := server.New(s.proxyConfig(builder.HTTP()))
+	s.Require().NoError(srv.Start(context.Background()))
+
+	code, body := s.get(srv)
+	s.Assert().Equal(http.StatusOK, code)
+	s.Assert().Equal("hello-gost", body)
+
+	cfg := s.proxyConfig(builder.HTTP().Auth("user", "pass"))
+	plan := srv.Plan(cfg)
+	s.Require().Len(plan.Changes, 1)
+	s.Assert().Equal("services/proxy", plan.Changes[0].Resource)
+	s.Assert().True(plan.Changes[0].Rebind)
+
+	d, err := srv.Reload(cfg)
+	s.Require().NoError(err)
+	c, ok := d.Change(server.Resource{Kind: "services", Name: "proxy"})
+	s.Assert().True(ok)
+	s.Assert().Equal(server.Changed, c)
+	s.Assert().Equal("success", srv.LastReload().Status)
+
+	code, _ = s.get(srv)
+	s.Assert().Equal(http.StatusProxyAuthRequired, code)
+
+	svc := srv.Resource(server.Resource{Kind: "services", Name: "proxy"}).(service.Service)
+	addr := svc.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Require().NoError(srv.Shutdown(ctx))
+
+	_, err = net.DialTimeout("tcp", addr, time.Second)
+	s.Assert().Error(err)
+	_, err = srv.Reload(cfg)
+	s.Assert().ErrorIs(err, server.ErrServerClosed)
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
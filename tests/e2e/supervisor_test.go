@@ -0,0 +1,98 @@
+package e2e
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// output records the output of a process, safe to read while it runs.
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// SupervisorSuite covers the supervision of the workers started with
+// argument groups separated by --. gost runs on the host, on the loopback.
+type SupervisorSuite struct {
+	suite.Suite
+}
+
+// TestGiveUp verifies that a crashing worker is restarted until it exceeds
+// its restart limit, the other workers keeping serving, and that the
+// supervisor stops the workers on SIGTERM.
+func (s *SupervisorSuite) TestGiveUp() {
+	// The crashing worker fails to bind an address in use.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer busy.Close()
+	addr := freeAddr(s.T())
+
+	out := &output{}
+	cmd := exec.Command(GostBinPath,
+		"-restart", "on-failure", "-restart-max", "2", "-L", "http://"+busy.Addr().String(),
+		"--",
+		"-restart", "on-failure", "-L", "http://"+addr)
+	cmd.Stdout = io.MultiWriter(os.Stdout, out)
+	cmd.Stderr = io.MultiWriter(os.Stderr, out)
+	// The workers are killed with the supervisor, in its process group.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	s.Require().NoError(cmd.Start())
+	var waitErr error
+	done := make(chan struct{})
+	go func() {
+		waitErr = cmd.Wait()
+		close(done)
+	}()
+	s.T().Cleanup(func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+	})
+
+	s.Require().Eventually(func() bool {
+		return strings.Contains(out.String(), "worker 0 exited") &&
+			strings.Contains(out.String(), "giving up")
+	}, 15*time.Second, 100*time.Millisecond, "crashing worker not given up")
+	s.Assert().Equal(2, strings.Count(out.String(), "worker 0 exited with status 1, restarting"))
+	s.Assert().Contains(out.String(), "worker 0 exited with status 1, restarted 2 times in 1m0s, giving up")
+	s.Assert().NotContains(out.String(), "worker 1 exited")
+	s.Assert().Contains(out.String(), "[1] ")
+	s.Assert().True(serving(addr))
+
+	s.Require().NoError(cmd.Process.Signal(syscall.SIGTERM))
+	select {
+	case <-done:
+		// The exit status is the highest one of the workers.
+		var exit *exec.ExitError
+		s.Require().ErrorAs(waitErr, &exit)
+		s.Assert().Equal(1, exit.ExitCode())
+	case <-time.After(10 * time.Second):
+		s.Fail("supervisor not stopped")
+	}
+	s.Assert().False(serving(addr))
+}
+
+func TestSupervisorSuite(t *testing.T) {
+	suite.Run(t, new(SupervisorSuite))
+}
@@ -0,0 +1,168 @@
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SystemdSuite covers the integration with systemd: readiness, reload and
+// stop notifications, watchdog pings and socket activation. The service
+// manager is faked by the test, so gost runs on the host.
+type SystemdSuite struct {
+	suite.Suite
+}
+
+// notifySocket listens on a fake NOTIFY_SOCKET.
+func (s *SystemdSuite) notifySocket() *net.UnixConn {
+	addr := &net.UnixAddr{Name: filepath.Join(s.T().TempDir(), "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expect reads notifications until one starting with state is received.
+func (s *SystemdSuite) expect(conn *net.UnixConn, state string) string {
+	deadline := time.Now().Add(10 * time.Second)
+	conn.SetReadDeadline(deadline)
+
+	b := make([]byte, 4096)
+	for {
+		n, err := conn.Read(b)
+		s.Require().NoError(err, "waiting for %s", state)
+		if msg := string(b[:n]); strings.HasPrefix(msg, state) {
+			return msg
+		}
+	}
+}
+
+func (s *SystemdSuite) start(cmd *exec.Cmd) {
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+}
+
+// TestNotify verifies that readiness, reloads, stop and watchdog pings are
+// notified.
+func (s *SystemdSuite) TestNotify() {
+	conn := s.notifySocket()
+
+	cmd := exec.Command(GostBinPath, "-L", "http://127.0.0.1:0")
+	cmd.Env = append(os.Environ(),
+		"NOTIFY_SOCKET="+conn.LocalAddr().String(),
+		"WATCHDOG_USEC=200000",
+	)
+	s.start(cmd)
+
+	s.expect(conn, "READY=1")
+	s.expect(conn, "WATCHDOG=1")
+	s.expect(conn, "WATCHDOG=1")
+
+	s.Require().NoError(cmd.Process.Signal(syscall.SIGHUP))
+	msg := s.expect(conn, "RELOADING=1")
+	s.Assert().Contains(msg, "MONOTONIC_USEC=")
+	msg = s.expect(conn, "READY=1")
+	s.Assert().Contains(msg, "STATUS=reload success")
+
+	s.Require().NoError(cmd.Process.Signal(syscall.SIGTERM))
+	s.expect(conn, "STOPPING=1")
+}
+
+// TestWatchdogOtherProcess verifies that no watchdog pings are sent when
+// the watchdog is set for another process.
+func (s *SystemdSuite) TestWatchdogOtherProcess() {
+	conn := s.notifySocket()
+
+	cmd := exec.Command(GostBinPath, "-L", "http://127.0.0.1:0")
+	cmd.Env = append(os.Environ(),
+		"NOTIFY_SOCKET="+conn.LocalAddr().String(),
+		"WATCHDOG_USEC=200000",
+		"WATCHDOG_PID=1",
+	)
+	s.start(cmd)
+
+	s.expect(conn, "READY=1")
+
+	conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	_, err := conn.Read(make([]byte, 4096))
+	s.Require().Error(err, "unexpected notification")
+}
+
+// TestSocketActivation verifies that the services listen on the sockets
+// passed by the service manager, matched by address or by name, instead of
+// binding their own. The sockets are kept open by the test, binding them
+// again would fail.
+func (s *SystemdSuite) TestSocketActivation() {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+
+	byAddr, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer byAddr.Close()
+	byName, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer byName.Close()
+
+	f1, err := byAddr.(*net.TCPListener).File()
+	s.Require().NoError(err)
+	defer f1.Close()
+	f2, err := byName.(*net.TCPListener).File()
+	s.Require().NoError(err)
+	defer f2.Close()
+
+	cfg := fmt.Sprintf(`{"services":[`+
+		`{"name":"by-addr","addr":%q,"handler":{"type":"http"},"listener":{"type":"tcp"}},`+
+		`{"name":"by-name","addr":":0","handler":{"type":"http"},"listener":{"type":"tcp"}}]}`,
+		byAddr.Addr().String())
+
+	conn := s.notifySocket()
+
+	// LISTEN_PID is the PID of gost, which is only known once it is
+	// started, as systemd does it after forking.
+	cmd := exec.Command("sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`, GostBinPath, "-C", cfg)
+	cmd.Env = append(os.Environ(),
+		"NOTIFY_SOCKET="+conn.LocalAddr().String(),
+		"LISTEN_FDS=2",
+		"LISTEN_FDNAMES=gost.socket:by-name",
+	)
+	cmd.ExtraFiles = []*os.File{f1, f2}
+	s.start(cmd)
+
+	s.expect(conn, "READY=1")
+
+	for _, ln := range []net.Listener{byAddr, byName} {
+		proxy, _ := url.Parse("http://" + ln.Addr().String())
+		client := &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+			Timeout:   5 * time.Second,
+		}
+		resp, err := client.Get(backend.URL)
+		s.Require().NoError(err, ln.Addr())
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.Assert().Equal("ok", string(body))
+	}
+}
+
+func TestSystemdSuite(t *testing.T) {
+	suite.Run(t, new(SystemdSuite))
+}
@@ -0,0 +1,165 @@
+package e2e
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// UpgradeSuite covers the upgrade on SIGUSR2, the process handing its
+// listening sockets over to a new one. gost runs on the host, on the
+// loopback.
+type UpgradeSuite struct {
+	suite.Suite
+}
+
+// start runs gost with args, returning the process, a channel closed once
+// it has exited and a function reading its output.
+func (s *UpgradeSuite) start(args ...string) (*exec.Cmd, chan struct{}, func() string) {
+	// The output goes to a file, so that waiting for the previous process
+	// does not wait for the new one, which inherits it.
+	logs := filepath.Join(s.T().TempDir(), "gost.log")
+	f, err := os.Create(logs)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { f.Close() })
+	read := func() string {
+		b, _ := os.ReadFile(logs)
+		return string(b)
+	}
+
+	cmd := exec.Command(GostBinPath, args...)
+	cmd.Stdout = f
+	cmd.Stderr = f
+	// The new process is killed with the previous one, in its process
+	// group.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	s.Require().NoError(cmd.Start())
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	s.T().Cleanup(func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		if s.T().Failed() {
+			s.T().Log(read())
+		}
+	})
+	return cmd, done, read
+}
+
+// TestHandover verifies that the sockets are handed over to the new
+// process without any connection refused, the previous process exiting
+// once the new one serves. The socket of the tls listener is not handed
+// over, with a warning.
+func (s *UpgradeSuite) TestHandover() {
+	addr := freeAddr(s.T())
+	tlsAddr := freeAddr(s.T())
+
+	listening := func() bool {
+		conn, err := net.DialTimeout("tcp", tlsAddr, time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	cmd, done, read := s.start("-L", "http://"+addr, "-L", "http+tls://"+tlsAddr)
+	s.Require().Eventually(func() bool { return serving(addr) && listening() }, 10*time.Second, 100*time.Millisecond)
+
+	// Connections are made one after another during the upgrade.
+	var requests, refused atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			requests.Add(1)
+			if !serving(addr) {
+				refused.Add(1)
+			}
+		}
+	})
+
+	s.Require().NoError(cmd.Process.Signal(syscall.SIGUSR2))
+	select {
+	case <-done:
+		s.Assert().True(cmd.ProcessState.Success(), cmd.ProcessState.String())
+	case <-time.After(20 * time.Second):
+		s.Fail("previous process not exited")
+	}
+
+	// The new process logs that it serves once the previous one has
+	// released its resources.
+	s.Require().Eventually(func() bool {
+		return strings.Contains(read(), "upgrade: serving on 1 inherited socket(s)")
+	}, 10*time.Second, 100*time.Millisecond)
+	s.Assert().Contains(read(), "upgraded to process")
+	s.Assert().Contains(read(), "service service-1 (tls listener) has no inherited socket")
+
+	close(stop)
+	wg.Wait()
+	s.Assert().Zero(refused.Load(), "%d of %d connections refused", refused.Load(), requests.Load())
+	s.Assert().Greater(requests.Load(), int64(1))
+	s.Assert().True(serving(addr), "new process not serving")
+	s.Assert().Eventually(listening, 5*time.Second, 100*time.Millisecond, "new process not listening over tls")
+}
+
+// TestFailedUpgrade verifies that the previous process serves its config
+// again when the new one can not apply its config once the resources are
+// released.
+func (s *UpgradeSuite) TestFailedUpgrade() {
+	addr := freeAddr(s.T())
+	config := filepath.Join(s.T().TempDir(), "gost.yaml")
+	service := func(name, addr string) string {
+		return "- name: " + name + "\n" +
+			"  addr: " + addr + "\n" +
+			"  handler:\n    type: http\n" +
+			"  listener:\n    type: tcp\n"
+	}
+	s.Require().NoError(os.WriteFile(config, []byte("services:\n"+service("proxy", addr)), 0600))
+
+	cmd, done, read := s.start("-C", config)
+	s.Require().Eventually(func() bool { return serving(addr) }, 10*time.Second, 100*time.Millisecond)
+
+	// The new process reads a service whose address is taken, which it
+	// listens on only once the previous process has released its
+	// resources.
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer taken.Close()
+	s.Require().NoError(os.WriteFile(config,
+		[]byte("services:\n"+service("proxy", addr)+service("taken", taken.Addr().String())), 0600))
+
+	s.Require().NoError(cmd.Process.Signal(syscall.SIGUSR2))
+	s.Require().Eventually(func() bool {
+		return strings.Contains(read(), "not serving")
+	}, 20*time.Second, 100*time.Millisecond)
+
+	select {
+	case <-done:
+		s.Fail("previous process exited", cmd.ProcessState.String())
+	default:
+	}
+	s.Assert().True(serving(addr), "previous process not serving")
+	s.Assert().NotContains(read(), "upgraded to process")
+}
+
+func TestUpgradeSuite(t *testing.T) {
+	suite.Run(t, new(UpgradeSuite))
+}
@@ -0,0 +1,64 @@
+package e2e
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ValidateSuite covers the offline config validation (gost -t). The
+// validation does not bind any socket, so gost runs on the host.
+type ValidateSuite struct {
+	suite.Suite
+}
+
+func (s *ValidateSuite) validate(args ...string) (int, string) {
+	cmd := exec.Command(GostBinPath, append([]string{"-t"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		s.Require().True(ok, "run gost: %v", err)
+		return exitErr.ExitCode(), string(out)
+	}
+	return 0, string(out)
+}
+
+// TestValidConfig verifies that a config whose references all resolve
+// passes with exit code 0.
+func (s *ValidateSuite) TestValidConfig() {
+	code, out := s.validate("-C", "testdata/validate/valid.yaml")
+	s.Require().Equal(0, code, out)
+	s.Assert().Contains(out, "config test passed")
+}
+
+// TestInvalidConfig verifies that unresolved references and unknown
+// component types are reported with their file position, and that the
+// exit code is non-zero.
+func (s *ValidateSuite) TestInvalidConfig() {
+	code, out := s.validate("-C", "testdata/validate/invalid.yaml")
+	s.Require().NotEqual(0, code, out)
+
+	for _, want := range []string{
+		`testdata/validate/invalid.yaml:6:12: services/proxy handler.chain: reference to undefined chains/chain-1`,
+		`testdata/validate/invalid.yaml:9:16: services/proxy handler.chainGroup.chains[0].chain: reference to undefined chains/chain-2`,
+		`testdata/validate/invalid.yaml:11:11: services/proxy listener.type: unknown listener type "tpc"`,
+		`testdata/validate/invalid.yaml:21:15: chains/chain-0 hops[0].nodes[0].connector.type: unknown connector type "sock5"`,
+	} {
+		s.Assert().Contains(out, want)
+	}
+	s.Assert().Equal(5, strings.Count(out, "\n"), out)
+}
+
+// TestSyntaxError verifies that YAML syntax errors are reported with
+// the file and line.
+func (s *ValidateSuite) TestSyntaxError() {
+	code, out := s.validate("-C", "testdata/validate/syntax.yaml")
+	s.Require().NotEqual(0, code, out)
+	s.Assert().Contains(out, "testdata/validate/syntax.yaml:3:")
+}
+
+func TestValidateSuite(t *testing.T) {
+	suite.Run(t, new(ValidateSuite))
+}
@@ -0,0 +1,225 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/gost/server"
+	"github.com/go-gost/x/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// VersionsSuite covers the ETags of the resources of the API, the changes
+// conditioned by them, and the batches of changes. gost runs on the host,
+// on the loopback.
+type VersionsSuite struct {
+	suite.Suite
+	// addrs are the addresses of the config by name, api the URL of the
+	// API.
+	addrs map[string]string
+	api   string
+}
+
+func (s *VersionsSuite) SetupSuite() {
+	s.addrs = freeAddrs(s.T(), "api", "proxy", "node0", "node1", "node2")
+	s.api = "http://" + s.addrs["api"]
+	config := filepath.Join(s.T().TempDir(), "gost.yaml")
+	writeConfig(s.T(), "testdata/versions/gost.yaml", config, s.addrs)
+
+	cmd := exec.Command(GostBinPath, "-C", config)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get(s.api + "/reload")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// do makes a request with the headers, given as name and value pairs.
+func (s *VersionsSuite) do(method, path, body string, headers ...string) *http.Response {
+	req, err := http.NewRequest(method, s.api+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (s *VersionsSuite) body(resp *http.Response) string {
+	b, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	return string(b)
+}
+
+type op map[string]any
+
+// batch returns the body of a batch of the operations.
+func (s *VersionsSuite) batch(ops ...op) string {
+	b, err := json.Marshal(map[string]any{"operations": ops})
+	s.Require().NoError(err)
+	return string(b)
+}
+
+// TestETag verifies that the resources carry an ETag, and that the changes
+// honor If-Match and If-None-Match.
+func (s *VersionsSuite) TestETag() {
+	resp := s.do(http.MethodGet, "/config/bypasses/bypass-0", "")
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	s.Require().NotEmpty(etag)
+
+	resp = s.do(http.MethodGet, "/config/bypasses/bypass-0", "", "If-None-Match", etag)
+	s.Assert().Equal(http.StatusNotModified, resp.StatusCode)
+
+	resp = s.do(http.MethodPut, "/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com", "first.example"]}`, "If-Match", etag)
+	s.Require().Equal(http.StatusOK, resp.StatusCode, s.body(resp))
+	updated := resp.Header.Get("ETag")
+	s.Assert().NotEmpty(updated)
+	s.Assert().NotEqual(etag, updated)
+
+	// The second writer read the resource before the first one changed it.
+	resp = s.do(http.MethodPut, "/config/bypasses/bypass-0",
+		`{"name": "bypass-0", "matchers": ["example.com", "second.example"]}`, "If-Match", etag)
+	s.Assert().Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	s.Assert().NotContains(s.body(s.do(http.MethodGet, "/config/bypasses/bypass-0", "")), "second.example")
+
+	resp = s.do(http.MethodGet, "/config/bypasses", "")
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Assert().NotEmpty(resp.Header.Get("ETag"))
+	var list struct {
+		Data struct {
+			Versions map[string]string `json:"versions"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal([]byte(s.body(resp)), &list))
+	s.Assert().Equal(updated, list.Data.Versions["bypass-0"])
+
+	resp = s.do(http.MethodPost, "/config/bypasses",
+		`{"name": "bypass-0", "matchers": ["example.net"]}`, "If-None-Match", "*")
+	s.Assert().Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	resp = s.do(http.MethodDelete, "/config/bypasses/bypass-0", "", "If-Match", `"stale"`)
+	s.Assert().Equal(http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+// TestBatch verifies that the changes of a batch are applied together, or
+// none of them.
+func (s *VersionsSuite) TestBatch() {
+	resp := s.do(http.MethodGet, "/config/hops/hop-1", "")
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	hopETag := resp.Header.Get("ETag")
+
+	resp = s.do(http.MethodPost, "/config/batch", s.batch(
+		op{"op": "update", "kind": "hops", "name": "hop-1", "ifMatch": hopETag, "config": op{
+			"nodes": []op{
+				{"name": "node-1", "addr": s.addrs["node1"]},
+				{"name": "node-2", "addr": s.addrs["node2"]},
+			},
+		}},
+		op{"op": "update", "kind": "bypasses", "name": "bypass-0", "config": op{
+			"matchers": []string{"example.com", "batch.example"},
+		}},
+		op{"op": "update", "kind": "services", "name": "proxy", "config": op{
+			"addr":     s.addrs["proxy"],
+			"bypass":   "bypass-0",
+			"handler":  op{"type": "http", "chain": "chain-1"},
+			"listener": op{"type": "tcp"},
+		}},
+	))
+	body := s.body(resp)
+	s.Require().Equal(http.StatusOK, resp.StatusCode, body)
+	var result struct {
+		Data struct {
+			Versions map[string]string `json:"versions"`
+		} `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal([]byte(body), &result))
+	s.Assert().Len(result.Data.Versions, 3)
+
+	s.Assert().Contains(s.body(s.do(http.MethodGet, "/config/hops/hop-1", "")), "node-2")
+	s.Assert().Contains(s.body(s.do(http.MethodGet, "/config/bypasses/bypass-0", "")), "batch.example")
+	s.Assert().Contains(s.body(s.do(http.MethodGet, "/config/services/proxy", "")), `"chain":"chain-1"`)
+
+	// The stale version of hop-1 fails the whole batch.
+	resp = s.do(http.MethodPost, "/config/batch", s.batch(
+		op{"op": "update", "kind": "bypasses", "name": "bypass-0", "config": op{"matchers": []string{"stale.example"}}},
+		op{"op": "delete", "kind": "hops", "name": "hop-1", "ifMatch": hopETag},
+	))
+	s.Assert().Equal(http.StatusPreconditionFailed, resp.StatusCode)
+
+	// So does a change that can not be made.
+	resp = s.do(http.MethodPost, "/config/batch", s.batch(
+		op{"op": "add", "kind": "bypasses", "name": "bypass-1", "config": op{"matchers": []string{"example.org"}}},
+		op{"op": "update", "kind": "chains", "name": "chain-9", "config": op{"hops": []op{{"name": "hop-0"}}}},
+	))
+	s.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+
+	bypasses := s.body(s.do(http.MethodGet, "/config/bypasses", ""))
+	s.Assert().NotContains(bypasses, "stale.example")
+	s.Assert().NotContains(bypasses, "bypass-1")
+	s.Assert().Contains(s.body(s.do(http.MethodGet, "/config/hops", "")), "hop-1")
+}
+
+// TestBatchReloadLock verifies that a batch waits for the reload lock of
+// the program running the server, with a server running in the test
+// process.
+func (s *VersionsSuite) TestBatchReloadLock() {
+	var mu sync.Mutex
+	srv := server.New(&config.Config{}, server.ReloadLockOption(&mu))
+	s.Require().NoError(srv.Start(context.Background()))
+	defer srv.Close()
+
+	mu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := srv.Batch([]server.BatchOp{{
+			Op:     "add",
+			Kind:   "bypasses",
+			Name:   "bypass-locked",
+			Config: json.RawMessage(`{"matchers": ["example.com"]}`),
+		}})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		mu.Unlock()
+		s.FailNow("batch applied under the reload lock", "%v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	mu.Unlock()
+
+	select {
+	case err := <-done:
+		s.Require().NoError(err)
+	case <-time.After(5 * time.Second):
+		s.FailNow("batch not applied once the reload lock is released")
+	}
+	s.Assert().NotNil(srv.Resource(server.Resource{Kind: "bypasses", Name: "bypass-locked"}))
+}
+
+func TestVersionsSuite(t *testing.T) {
+	suite.Run(t, new(VersionsSuite))
+}
@@ -0,0 +1,139 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// freeAddr returns a loopback address no one listens on.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// freeAddrs returns a free loopback address for each of names.
+func freeAddrs(t *testing.T, names ...string) map[string]string {
+	addrs := make(map[string]string)
+	for _, name := range names {
+		addrs[name] = freeAddr(t)
+	}
+	return addrs
+}
+
+// writeConfig executes the config template file with addrs, the addresses
+// of the config by name, and writes it to dst.
+func writeConfig(t *testing.T, file, dst string, addrs map[string]string) {
+	tmpl, err := template.ParseFiles(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Option("missingkey=error").Execute(&b, addrs); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serving reports whether an HTTP proxy accepts requests on addr.
+func serving(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	fmt.Fprintf(conn, "GET http://127.0.0.1:9/ HTTP/1.1\r\nHost: 127.0.0.1:9\r\nConnection: close\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// WatchSuite covers the reloads on the changes of the config file with -W.
+// gost runs on the host, on the loopback, with the config in a temporary
+// directory.
+type WatchSuite struct {
+	suite.Suite
+	file string
+}
+
+func (s *WatchSuite) SetupTest() {
+	s.file = filepath.Join(s.T().TempDir(), "gost.yaml")
+}
+
+// write writes the config with the proxy service listening on addr.
+func (s *WatchSuite) write(name, addr string) {
+	cfg := fmt.Sprintf("services:\n- name: proxy\n  addr: %s\n  handler:\n    type: http\n  listener:\n    type: tcp\n", addr)
+	s.Require().NoError(os.WriteFile(name, []byte(cfg), 0644))
+}
+
+func (s *WatchSuite) start() {
+	cmd := exec.Command(GostBinPath, "-C", s.file, "-W")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	s.Require().NoError(cmd.Start())
+	s.T().Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+}
+
+// TestEdit verifies that the config is reloaded when the file is written
+// in place.
+func (s *WatchSuite) TestEdit() {
+	addr := freeAddr(s.T())
+	s.write(s.file, addr)
+	s.start()
+	s.Require().Eventually(func() bool { return serving(addr) }, 10*time.Second, 100*time.Millisecond)
+
+	moved := freeAddr(s.T())
+	s.write(s.file, moved)
+	s.Require().Eventually(func() bool { return serving(moved) }, 10*time.Second, 100*time.Millisecond)
+	s.Assert().False(serving(addr))
+}
+
+// TestRename verifies that the config is reloaded when the file is
+// replaced by renaming another one over it, as editors and deployment
+// tools do.
+func (s *WatchSuite) TestRename() {
+	addr := freeAddr(s.T())
+	s.write(s.file, addr)
+	s.start()
+	s.Require().Eventually(func() bool { return serving(addr) }, 10*time.Second, 100*time.Millisecond)
+
+	moved := freeAddr(s.T())
+	tmp := s.file + ".tmp"
+	s.write(tmp, moved)
+	s.Require().NoError(os.Rename(tmp, s.file))
+	s.Require().Eventually(func() bool { return serving(moved) }, 10*time.Second, 100*time.Millisecond)
+	s.Assert().False(serving(addr))
+
+	// The file is still followed once replaced.
+	s.write(s.file, addr)
+	s.Require().Eventually(func() bool { return serving(addr) }, 10*time.Second, 100*time.Millisecond)
+	s.Assert().False(serving(moved))
+}
+
+func TestWatchSuite(t *testing.T) {
+	suite.Run(t, new(WatchSuite))
+}